@@ -0,0 +1,337 @@
+// Package acl keeps the users clients authenticate as and decides which
+// commands, keys and channels each of them can access, like redis ACLs.
+package acl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const DefaultUser = "default"
+
+var (
+	ErrWrongPass        = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+	ErrNoAuth           = errors.New("NOAUTH Authentication required.")
+	ErrRemoveDefault    = errors.New("ERR The 'default' user cannot be removed")
+	ErrNoACLFile        = errors.New("ERR This Redis instance is not configured to use an ACL file. You may want to specify users via the ACL SETUSER command and then issue a CONFIG REWRITE (assuming you have a Redis configuration file set) in order to store users in the Redis configuration.")
+	ErrDefaultNoPassSet = errors.New("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+)
+
+// Reasons a request can be denied for, as reported in the ACL log.
+const (
+	ReasonCommand = "command"
+	ReasonKey     = "key"
+	ReasonChannel = "channel"
+	ReasonAuth    = "auth"
+)
+
+// Request describes what running a command needs access to.
+type Request struct {
+	// Command and Subcommand are lower case
+	Command    string
+	Subcommand string
+
+	Keys      []string
+	KeyAccess Access
+
+	// Channels are matched against the channel patterns of a user while
+	// Patterns, the patterns given to PSUBSCRIBE, have to be allowed as is.
+	Channels []string
+	Patterns []string
+}
+
+// Denied is the error returned when a user is not allowed to run a command.
+type Denied struct {
+	Username string
+	Reason   string
+	Object   string
+}
+
+func (d *Denied) Error() string {
+	switch d.Reason {
+	case ReasonKey:
+		return "NOPERM No permissions to access a key"
+	case ReasonChannel:
+		return "NOPERM No permissions to access a channel"
+	default:
+		return fmt.Sprintf("NOPERM User %s has no permissions to run the '%s' command", d.Username, d.Object)
+	}
+}
+
+// ACL is the registry of users, it is safe for concurrent use.
+type ACL struct {
+	mu    sync.RWMutex
+	users map[string]*User
+	file  string
+
+	Log *Log
+}
+
+type Option = func(*ACL)
+
+// WithFile sets the acl file users are loaded from and saved to.
+func WithFile(path string) Option {
+	return func(a *ACL) {
+		a.file = path
+	}
+}
+
+// New returns an ACL with only the default user, which can run every command
+// without a password.
+func New(opts ...Option) *ACL {
+	a := &ACL{
+		users: map[string]*User{DefaultUser: defaultUser()},
+		Log:   newLog(),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func defaultUser() *User {
+	u := newUser(DefaultUser)
+	for _, rule := range []string{"on", "nopass", "~*", "&*", "+@all"} {
+		_ = u.apply(rule)
+	}
+	return u
+}
+
+// Authenticate reports whether the user exists, is enabled and password is
+// one of its passwords.
+func (a *ACL) Authenticate(username, password string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[username]
+	if !ok || !u.enabled {
+		return false
+	}
+
+	if u.noPass {
+		return true
+	}
+
+	_, ok = u.passwords[hashPassword(password)]
+	return ok
+}
+
+// DefaultNoPass reports whether new connections are authenticated as the
+// default user right away, which is the case unless it has a password or is
+// disabled.
+func (a *ACL) DefaultNoPass() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u := a.users[DefaultUser]
+	return u.enabled && u.noPass
+}
+
+// Check returns a *Denied error if the user is not allowed to run req.
+func (a *ACL) Check(username string, req Request) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[username]
+	if !ok {
+		return &Denied{Username: username, Reason: ReasonCommand, Object: req.Command}
+	}
+
+	denied := u.root.check(req)
+	if denied == nil {
+		return nil
+	}
+
+	for _, s := range u.selectors {
+		if s.check(req) == nil {
+			return nil
+		}
+	}
+
+	denied.Username = username
+	return denied
+}
+
+// SetUser creates the user if needed and applies rules to it. Either every
+// rule is applied or, if one of them is invalid, none is. A selector split
+// over several arguments is put back together.
+func (a *ACL) SetUser(username string, rules []string) error {
+	rules, err := mergeSelectors(rules)
+	if err != nil {
+		return fmt.Errorf("ERR %s", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	u, err := applyRules(a.users[username], username, rules)
+	if err != nil {
+		return err
+	}
+
+	a.users[username] = u
+	return nil
+}
+
+func applyRules(u *User, username string, rules []string) (*User, error) {
+	if u == nil {
+		u = newUser(username)
+	} else {
+		u = u.clone()
+	}
+
+	for _, rule := range rules {
+		if err := u.apply(rule); err != nil {
+			return nil, fmt.Errorf("ERR Error in ACL SETUSER modifier '%s': %s", rule, err)
+		}
+	}
+
+	return u, nil
+}
+
+// DelUser removes users and returns how many of them existed.
+func (a *ACL) DelUser(usernames []string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, name := range usernames {
+		if name == DefaultUser {
+			return 0, ErrRemoveDefault
+		}
+	}
+
+	count := 0
+	for _, name := range usernames {
+		if _, ok := a.users[name]; ok {
+			delete(a.users, name)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Exists reports whether a user with that name exists.
+func (a *ACL) Exists(username string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	_, ok := a.users[username]
+	return ok
+}
+
+// Enabled reports whether a user with that name exists and is enabled.
+func (a *ACL) Enabled(username string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[username]
+	return ok && u.enabled
+}
+
+// Users returns the user names sorted.
+func (a *ACL) Users() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.sortedNames()
+}
+
+func (a *ACL) sortedNames() []string {
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List describes every user with the rules that would recreate it.
+func (a *ACL) List() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	lines := make([]string, 0, len(a.users))
+	for _, name := range a.sortedNames() {
+		lines = append(lines, a.users[name].describe())
+	}
+	return lines
+}
+
+// SelectorInfo describes the permissions of a selector.
+type SelectorInfo struct {
+	Commands string
+	Keys     string
+	Channels string
+}
+
+// UserInfo describes a user, as shown by ACL GETUSER.
+type UserInfo struct {
+	Flags     []string
+	Passwords []string
+	SelectorInfo
+	Selectors []SelectorInfo
+}
+
+func (s *selector) info() SelectorInfo {
+	return SelectorInfo{
+		Commands: s.describeCommands(),
+		Keys:     s.describeKeys(),
+		Channels: s.describeChannels(),
+	}
+}
+
+func (a *ACL) GetUser(username string) (UserInfo, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[username]
+	if !ok {
+		return UserInfo{}, false
+	}
+
+	info := UserInfo{
+		Flags:        u.flags(),
+		Passwords:    u.hashes(),
+		SelectorInfo: u.root.info(),
+	}
+
+	for _, s := range u.selectors {
+		info.Selectors = append(info.Selectors, s.info())
+	}
+
+	return info, true
+}
+
+// mergeSelectors joins the rules of a selector between parentheses that were
+// given as separate arguments.
+func mergeSelectors(args []string) ([]string, error) {
+	var rules []string
+	var group []string
+
+	for _, field := range args {
+		switch {
+		case group != nil:
+			group = append(group, field)
+			if strings.HasSuffix(field, ")") {
+				rules = append(rules, strings.Join(group, " "))
+				group = nil
+			}
+		case strings.HasPrefix(field, "(") && !strings.HasSuffix(field, ")"):
+			group = []string{field}
+		default:
+			rules = append(rules, field)
+		}
+	}
+
+	if group != nil {
+		return nil, errors.New("Unmatched parenthesis in acl group starting at '" + group[0] + "'.")
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,151 @@
+package acl_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aelnahas/sider/acl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	a := acl.New()
+	assert.NoError(t, a.SetUser("alice", []string{
+		"on", ">secret", "+@read", "-exists", "+set", "+client|id",
+		"%R~cache:*", "~alice:*", "&news.*", "+publish", "+psubscribe",
+		"(~shared:*", "+del)",
+	}))
+
+	tests := []struct {
+		name   string
+		req    acl.Request
+		reason string
+	}{
+		{
+			name: "category",
+			req:  acl.Request{Command: "get", Keys: []string{"alice:1"}, KeyAccess: acl.AccessRead},
+		},
+		{
+			name:   "removed from category",
+			req:    acl.Request{Command: "exists", Keys: []string{"alice:1"}, KeyAccess: acl.AccessRead},
+			reason: acl.ReasonCommand,
+		},
+		{
+			name: "read only pattern",
+			req:  acl.Request{Command: "get", Keys: []string{"cache:1"}, KeyAccess: acl.AccessRead},
+		},
+		{
+			name:   "write to read only pattern",
+			req:    acl.Request{Command: "set", Keys: []string{"cache:1"}, KeyAccess: acl.AccessWrite},
+			reason: acl.ReasonKey,
+		},
+		{
+			name:   "key not matching",
+			req:    acl.Request{Command: "get", Keys: []string{"bob:1"}, KeyAccess: acl.AccessRead},
+			reason: acl.ReasonKey,
+		},
+		{
+			name: "subcommand",
+			req:  acl.Request{Command: "client", Subcommand: "id"},
+		},
+		{
+			name:   "other subcommand",
+			req:    acl.Request{Command: "client", Subcommand: "tracking"},
+			reason: acl.ReasonCommand,
+		},
+		{
+			name: "channel",
+			req:  acl.Request{Command: "publish", Channels: []string{"news.sports"}},
+		},
+		{
+			name:   "channel not matching",
+			req:    acl.Request{Command: "publish", Channels: []string{"chat"}},
+			reason: acl.ReasonChannel,
+		},
+		{
+			name: "pattern allowed as is",
+			req:  acl.Request{Command: "psubscribe", Patterns: []string{"news.*"}},
+		},
+		{
+			name:   "narrower pattern",
+			req:    acl.Request{Command: "psubscribe", Patterns: []string{"news.s*"}},
+			reason: acl.ReasonChannel,
+		},
+		{
+			name: "selector",
+			req:  acl.Request{Command: "del", Keys: []string{"shared:1"}, KeyAccess: acl.AccessWrite},
+		},
+		{
+			name:   "selector key outside root keys",
+			req:    acl.Request{Command: "del", Keys: []string{"alice:1"}, KeyAccess: acl.AccessWrite},
+			reason: acl.ReasonCommand,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := a.Check("alice", tc.req)
+			if tc.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var denied *acl.Denied
+			if assert.ErrorAs(t, err, &denied) {
+				assert.Equal(t, tc.reason, denied.Reason)
+			}
+		})
+	}
+}
+
+func TestSetUser(t *testing.T) {
+	a := acl.New()
+
+	assert.NoError(t, a.SetUser("bob", []string{"on", ">pw", "+get"}))
+	assert.True(t, a.Authenticate("bob", "pw"))
+	assert.False(t, a.Authenticate("bob", "other"))
+
+	// an invalid rule leaves the user untouched
+	assert.EqualError(t, a.SetUser("bob", []string{"off", "+nope"}), "ERR Error in ACL SETUSER modifier '+nope': Unknown command")
+	assert.True(t, a.Authenticate("bob", "pw"))
+
+	assert.NoError(t, a.SetUser("bob", []string{"off"}))
+	assert.False(t, a.Authenticate("bob", "pw"))
+
+	_, err := a.DelUser([]string{acl.DefaultUser})
+	assert.ErrorIs(t, err, acl.ErrRemoveDefault)
+
+	count, err := a.DelUser([]string{"bob", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestSaveLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "users.acl")
+
+	a := acl.New(acl.WithFile(file))
+	assert.NoError(t, a.SetUser("alice", []string{"on", ">secret", "%R~cache:*", "&news", "+@read", "-exists", "(~shared:* +del)"}))
+	assert.NoError(t, a.Save())
+
+	b := acl.New(acl.WithFile(file))
+	assert.NoError(t, b.Load())
+	assert.Equal(t, a.List(), b.List())
+	assert.True(t, b.Authenticate("alice", "secret"))
+}
+
+func TestLog(t *testing.T) {
+	a := acl.New()
+
+	a.Log.Add(acl.LogEntry{Reason: acl.ReasonCommand, Context: "toplevel", Object: "get", Username: "bob"})
+	a.Log.Add(acl.LogEntry{Reason: acl.ReasonCommand, Context: "toplevel", Object: "get", Username: "bob"})
+	a.Log.Add(acl.LogEntry{Reason: acl.ReasonKey, Context: "toplevel", Object: "k", Username: "bob"})
+
+	entries := a.Log.Entries(-1)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "k", entries[0].Object)
+		assert.Equal(t, 2, entries[1].Count)
+	}
+
+	a.Log.Reset()
+	assert.Empty(t, a.Log.Entries(-1))
+}
@@ -0,0 +1,57 @@
+package acl
+
+import (
+	"sort"
+
+	"github.com/aelnahas/sider/commands"
+)
+
+// Categories are the command categories rules can refer to with +@category
+// and -@category, they are the same as the ones redis uses even though sider
+// does not implement commands for all of them.
+var Categories = []string{
+	"keyspace", "read", "write", "set", "sortedset", "list", "hash", "string",
+	"bitmap", "hyperloglog", "geo", "stream", "pubsub", "admin", "fast", "slow",
+	"blocking", "dangerous", "connection", "transaction", "scripting",
+}
+
+func isCategory(name string) bool {
+	if name == "all" {
+		return true
+	}
+
+	for _, category := range Categories {
+		if category == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CategoryCommands returns the commands in category sorted by name.
+func CategoryCommands(category string) ([]string, bool) {
+	if !isCategory(category) || category == "all" {
+		return nil, false
+	}
+
+	names := make([]string, 0)
+	for _, cmd := range commands.All() {
+		if cmd.InCategory(category) {
+			names = append(names, cmd.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, true
+}
+
+// InCategory reports whether command belongs to category, every command
+// belongs to the all category.
+func InCategory(command, category string) bool {
+	if category == "all" {
+		return true
+	}
+
+	cmd, ok := commands.Lookup(command)
+	return ok && cmd.InCategory(category)
+}
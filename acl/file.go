@@ -0,0 +1,99 @@
+package acl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/aelnahas/sider/internal/fileutil"
+)
+
+var ErrSaveFailed = errors.New("ERR There was an error trying to save the ACLs. Please check the server logs for more information")
+
+// File returns the path of the acl file, empty if there is none.
+func (a *ACL) File() string {
+	return a.file
+}
+
+// Load replaces the users with the ones in the acl file. Nothing changes if
+// the file has an error. The default user is kept as is unless the file
+// defines it.
+func (a *ACL) Load() error {
+	if a.file == "" {
+		return ErrNoACLFile
+	}
+
+	data, err := os.ReadFile(a.file)
+	if err != nil {
+		return fmt.Errorf("ERR Error loading ACLs, opening file '%s': %s", a.file, err)
+	}
+
+	users := make(map[string]*User)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if fields[0] != "user" || len(fields) < 2 {
+			return fmt.Errorf("ERR %s:%d: line should start with user keyword", a.file, n)
+		}
+
+		name := fields[1]
+		if _, ok := users[name]; ok {
+			return fmt.Errorf("ERR %s:%d: duplicate user '%s' found", a.file, n, name)
+		}
+
+		rules, err := mergeSelectors(fields[2:])
+		if err != nil {
+			return fmt.Errorf("ERR %s:%d: %s", a.file, n, err)
+		}
+
+		u, err := applyRules(nil, name, rules)
+		if err != nil {
+			return fmt.Errorf("ERR %s:%d: %s", a.file, n, strings.TrimPrefix(err.Error(), "ERR "))
+		}
+		users[name] = u
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ERR Error loading ACLs from '%s': %s", a.file, err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := users[DefaultUser]; !ok {
+		users[DefaultUser] = a.users[DefaultUser]
+	}
+	a.users = users
+	return nil
+}
+
+// Save writes every user to the acl file. The file is replaced atomically so
+// a crash half way through leaves the previous version in place.
+func (a *ACL) Save() error {
+	if a.file == "" {
+		return ErrNoACLFile
+	}
+
+	var buf bytes.Buffer
+	for _, line := range a.List() {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	if err := fileutil.WriteFileAtomic(a.file, buf.Bytes(), 0o600); err != nil {
+		slog.Error("could not save the acl file", "file", a.file, "error", err)
+		return ErrSaveFailed
+	}
+
+	return nil
+}
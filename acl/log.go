@@ -0,0 +1,96 @@
+package acl
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLogMaxLen is the number of entries kept in the log, like
+	// acllog-max-len in redis.
+	DefaultLogMaxLen = 128
+
+	// logGroupWindow is how long a denial is counted on an existing entry
+	// instead of getting one of its own.
+	logGroupWindow = 60 * time.Second
+)
+
+// LogEntry is a denied command or authentication attempt.
+type LogEntry struct {
+	ID         int
+	Count      int
+	Reason     string
+	Context    string
+	Object     string
+	Username   string
+	ClientInfo string
+	Created    time.Time
+	Updated    time.Time
+}
+
+// Log keeps the most recent denials, newest first. Similar denials close to
+// each other in time share an entry.
+type Log struct {
+	mu      sync.Mutex
+	entries []*LogEntry
+	nextID  int
+	maxLen  int
+}
+
+func newLog() *Log {
+	return &Log{maxLen: DefaultLogMaxLen}
+}
+
+// Add records a denial, context is where the command ran: toplevel, multi
+// or lua.
+func (l *Log) Add(entry LogEntry) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, e := range l.entries {
+		if e.Reason == entry.Reason && e.Context == entry.Context && e.Object == entry.Object &&
+			e.Username == entry.Username && now.Sub(e.Updated) < logGroupWindow {
+			e.Count++
+			e.Updated = now
+			e.ClientInfo = entry.ClientInfo
+			return
+		}
+	}
+
+	entry.ID = l.nextID
+	entry.Count = 1
+	entry.Created = now
+	entry.Updated = now
+	l.nextID++
+
+	l.entries = append([]*LogEntry{&entry}, l.entries...)
+	if len(l.entries) > l.maxLen {
+		l.entries = l.entries[:l.maxLen]
+	}
+}
+
+// Entries returns up to count entries, newest first, or all of them if count
+// is negative.
+func (l *Log) Entries(count int) []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if count < 0 || count > len(l.entries) {
+		count = len(l.entries)
+	}
+
+	entries := make([]LogEntry, 0, count)
+	for _, e := range l.entries[:count] {
+		entries = append(entries, *e)
+	}
+	return entries
+}
+
+func (l *Log) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = nil
+}
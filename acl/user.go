@@ -0,0 +1,474 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/glob"
+)
+
+// Access is the kind of access a key pattern grants.
+type Access uint8
+
+const (
+	AccessRead Access = 1 << iota
+	AccessWrite
+
+	AccessReadWrite = AccessRead | AccessWrite
+)
+
+var (
+	errSyntax          = errors.New("Syntax error")
+	errUnknownCommand  = errors.New("Unknown command")
+	errUnknownCategory = errors.New("Unknown command category")
+	errBadHash         = errors.New("The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+	errNoSuchPassword  = errors.New("The password you are trying to remove from the user does not exist")
+	errNestedSelector  = errors.New("Selectors can not be nested")
+)
+
+// User is a named identity clients authenticate as. What it is allowed to do
+// is described by its root selector, a command is allowed if the root
+// selector or any of the additional selectors allow it.
+type User struct {
+	Name      string
+	enabled   bool
+	noPass    bool
+	passwords map[string]struct{}
+
+	root      *selector
+	selectors []*selector
+}
+
+type keyPattern struct {
+	pattern string
+	access  Access
+}
+
+// selector is a set of permissions: the commands that can be run, and the
+// keys and channels they can access.
+type selector struct {
+	commands    map[string]bool
+	subcommands map[string]bool
+	rules       []string
+
+	allKeys bool
+	keys    []keyPattern
+
+	allChannels bool
+	channels    []string
+}
+
+func newUser(name string) *User {
+	return &User{
+		Name:      name,
+		passwords: make(map[string]struct{}),
+		root:      newSelector(),
+	}
+}
+
+func newSelector() *selector {
+	return &selector{
+		commands:    make(map[string]bool),
+		subcommands: make(map[string]bool),
+	}
+}
+
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func (u *User) clone() *User {
+	c := &User{
+		Name:      u.Name,
+		enabled:   u.enabled,
+		noPass:    u.noPass,
+		passwords: make(map[string]struct{}, len(u.passwords)),
+		root:      u.root.clone(),
+	}
+
+	for hash := range u.passwords {
+		c.passwords[hash] = struct{}{}
+	}
+
+	for _, s := range u.selectors {
+		c.selectors = append(c.selectors, s.clone())
+	}
+
+	return c
+}
+
+func (s *selector) clone() *selector {
+	c := &selector{
+		commands:    make(map[string]bool, len(s.commands)),
+		subcommands: make(map[string]bool, len(s.subcommands)),
+		rules:       append([]string(nil), s.rules...),
+		allKeys:     s.allKeys,
+		keys:        append([]keyPattern(nil), s.keys...),
+		allChannels: s.allChannels,
+		channels:    append([]string(nil), s.channels...),
+	}
+
+	for k, v := range s.commands {
+		c.commands[k] = v
+	}
+	for k, v := range s.subcommands {
+		c.subcommands[k] = v
+	}
+
+	return c
+}
+
+// apply applies a single ACL SETUSER rule to the user.
+func (u *User) apply(rule string) error {
+	switch lower := strings.ToLower(rule); {
+	case lower == "on":
+		u.enabled = true
+	case lower == "off":
+		u.enabled = false
+	case lower == "nopass":
+		u.noPass = true
+		u.passwords = make(map[string]struct{})
+	case lower == "resetpass":
+		u.noPass = false
+		u.passwords = make(map[string]struct{})
+	case lower == "reset":
+		u.noPass = false
+		u.enabled = false
+		u.passwords = make(map[string]struct{})
+		u.root = newSelector()
+		u.selectors = nil
+	case lower == "clearselectors":
+		u.selectors = nil
+	case strings.HasPrefix(rule, ">"):
+		u.passwords[hashPassword(rule[1:])] = struct{}{}
+		u.noPass = false
+	case strings.HasPrefix(rule, "<"):
+		hash := hashPassword(rule[1:])
+		if _, ok := u.passwords[hash]; !ok {
+			return errNoSuchPassword
+		}
+		delete(u.passwords, hash)
+	case strings.HasPrefix(rule, "#"):
+		if !validHash(rule[1:]) {
+			return errBadHash
+		}
+		u.passwords[rule[1:]] = struct{}{}
+		u.noPass = false
+	case strings.HasPrefix(rule, "!"):
+		if !validHash(rule[1:]) {
+			return errBadHash
+		}
+		if _, ok := u.passwords[rule[1:]]; !ok {
+			return errNoSuchPassword
+		}
+		delete(u.passwords, rule[1:])
+	case strings.HasPrefix(rule, "(") && strings.HasSuffix(rule, ")"):
+		s := newSelector()
+		for _, r := range strings.Fields(rule[1 : len(rule)-1]) {
+			if strings.HasPrefix(r, "(") {
+				return errNestedSelector
+			}
+			if err := s.apply(r); err != nil {
+				return err
+			}
+		}
+		u.selectors = append(u.selectors, s)
+	default:
+		return u.root.apply(rule)
+	}
+
+	return nil
+}
+
+func validHash(hash string) bool {
+	if len(hash) != 64 {
+		return false
+	}
+
+	for _, r := range hash {
+		if !(r >= '0' && r <= '9') && !(r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// apply applies a command, key or channel rule to the selector.
+func (s *selector) apply(rule string) error {
+	switch lower := strings.ToLower(rule); {
+	case lower == "allkeys" || rule == "~*":
+		s.allKeys = true
+		s.keys = nil
+	case lower == "resetkeys":
+		s.allKeys = false
+		s.keys = nil
+	case strings.HasPrefix(rule, "~"):
+		s.keys = append(s.keys, keyPattern{pattern: rule[1:], access: AccessReadWrite})
+	case strings.HasPrefix(rule, "%"):
+		perms, pattern, ok := strings.Cut(rule[1:], "~")
+		if !ok {
+			return errSyntax
+		}
+
+		var access Access
+		for _, p := range strings.ToUpper(perms) {
+			switch p {
+			case 'R':
+				access |= AccessRead
+			case 'W':
+				access |= AccessWrite
+			default:
+				return errSyntax
+			}
+		}
+		if access == 0 {
+			return errSyntax
+		}
+
+		s.keys = append(s.keys, keyPattern{pattern: pattern, access: access})
+	case lower == "allchannels" || rule == "&*":
+		s.allChannels = true
+		s.channels = nil
+	case lower == "resetchannels":
+		s.allChannels = false
+		s.channels = nil
+	case strings.HasPrefix(rule, "&"):
+		s.channels = append(s.channels, rule[1:])
+	case lower == "allcommands":
+		return s.apply("+@all")
+	case lower == "nocommands":
+		return s.apply("-@all")
+	case strings.HasPrefix(rule, "+") || strings.HasPrefix(rule, "-"):
+		return s.applyCommand(rule[0] == '+', strings.ToLower(rule[1:]))
+	default:
+		return errSyntax
+	}
+
+	return nil
+}
+
+func (s *selector) applyCommand(allow bool, name string) error {
+	sign := "-"
+	if allow {
+		sign = "+"
+	}
+
+	if category, ok := strings.CutPrefix(name, "@"); ok {
+		if !isCategory(category) {
+			return errUnknownCategory
+		}
+
+		for _, cmd := range commands.All() {
+			if InCategory(cmd.Name, category) {
+				s.setCommand(cmd.Name, allow)
+			}
+		}
+
+		if category == "all" {
+			s.rules = nil
+		}
+		s.rules = append(s.rules, sign+name)
+		return nil
+	}
+
+	if command, sub, ok := strings.Cut(name, "|"); ok {
+		if !commands.IsContainer(command) || sub == "" {
+			return errUnknownCommand
+		}
+
+		s.subcommands[name] = allow
+		s.rules = append(s.rules, sign+name)
+		return nil
+	}
+
+	if _, known := commands.Lookup(name); !known {
+		return errUnknownCommand
+	}
+
+	s.setCommand(name, allow)
+	s.rules = append(s.rules, sign+name)
+	return nil
+}
+
+// setCommand allows or denies a command along with all of its subcommands.
+func (s *selector) setCommand(command string, allow bool) {
+	s.commands[command] = allow
+
+	for name := range s.subcommands {
+		if strings.HasPrefix(name, command+"|") {
+			delete(s.subcommands, name)
+		}
+	}
+}
+
+func (s *selector) allowsCommand(command, subcommand string) bool {
+	if subcommand != "" {
+		if allowed, ok := s.subcommands[command+"|"+subcommand]; ok {
+			return allowed
+		}
+	}
+
+	return s.commands[command]
+}
+
+func (s *selector) allowsKey(key string, access Access) bool {
+	if s.allKeys {
+		return true
+	}
+
+	// a single pattern has to grant every kind of access the key needs
+	for _, p := range s.keys {
+		if p.access&access == access && glob.Match(p.pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *selector) allowsChannel(channel string, literal bool) bool {
+	if s.allChannels {
+		return true
+	}
+
+	for _, pattern := range s.channels {
+		if literal && pattern == channel {
+			return true
+		}
+		if !literal && glob.Match(pattern, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns why req is denied by the selector, or nil if it is allowed.
+func (s *selector) check(req Request) *Denied {
+	if !s.allowsCommand(req.Command, req.Subcommand) {
+		object := req.Command
+		if req.Subcommand != "" {
+			object += "|" + req.Subcommand
+		}
+		return &Denied{Reason: ReasonCommand, Object: object}
+	}
+
+	for _, key := range req.Keys {
+		if !s.allowsKey(key, req.KeyAccess) {
+			return &Denied{Reason: ReasonKey, Object: key}
+		}
+	}
+
+	for _, channel := range req.Channels {
+		if !s.allowsChannel(channel, false) {
+			return &Denied{Reason: ReasonChannel, Object: channel}
+		}
+	}
+
+	for _, pattern := range req.Patterns {
+		if !s.allowsChannel(pattern, true) {
+			return &Denied{Reason: ReasonChannel, Object: pattern}
+		}
+	}
+
+	return nil
+}
+
+// describeCommands returns the command rules, in the order they were given.
+func (s *selector) describeCommands() string {
+	rules := s.rules
+	if len(rules) == 0 || (rules[0] != "+@all" && rules[0] != "-@all") {
+		rules = append([]string{"-@all"}, rules...)
+	}
+	return strings.Join(rules, " ")
+}
+
+func (s *selector) describeKeys() string {
+	if s.allKeys {
+		return "~*"
+	}
+
+	keys := make([]string, 0, len(s.keys))
+	for _, p := range s.keys {
+		switch p.access {
+		case AccessRead:
+			keys = append(keys, "%R~"+p.pattern)
+		case AccessWrite:
+			keys = append(keys, "%W~"+p.pattern)
+		default:
+			keys = append(keys, "~"+p.pattern)
+		}
+	}
+	return strings.Join(keys, " ")
+}
+
+func (s *selector) describeChannels() string {
+	if s.allChannels {
+		return "&*"
+	}
+
+	channels := make([]string, 0, len(s.channels))
+	for _, channel := range s.channels {
+		channels = append(channels, "&"+channel)
+	}
+	return strings.Join(channels, " ")
+}
+
+// describe returns the rules that recreate the selector.
+func (s *selector) describe() string {
+	parts := make([]string, 0, 3)
+	if keys := s.describeKeys(); keys != "" {
+		parts = append(parts, keys)
+	}
+
+	if channels := s.describeChannels(); channels != "" {
+		parts = append(parts, channels)
+	} else {
+		parts = append(parts, "resetchannels")
+	}
+
+	parts = append(parts, s.describeCommands())
+	return strings.Join(parts, " ")
+}
+
+func (u *User) hashes() []string {
+	hashes := make([]string, 0, len(u.passwords))
+	for hash := range u.passwords {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+func (u *User) flags() []string {
+	flags := []string{"off"}
+	if u.enabled {
+		flags[0] = "on"
+	}
+	if u.noPass {
+		flags = append(flags, "nopass")
+	}
+	return flags
+}
+
+// describe returns the user the way ACL LIST and the acl file show it, the
+// rules given to ACL SETUSER recreate the user.
+func (u *User) describe() string {
+	parts := append([]string{"user", u.Name}, u.flags()...)
+
+	for _, hash := range u.hashes() {
+		parts = append(parts, "#"+hash)
+	}
+
+	parts = append(parts, u.root.describe())
+
+	for _, s := range u.selectors {
+		parts = append(parts, fmt.Sprintf("(%s)", s.describe()))
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,310 @@
+// Package client is a client for sider. It keeps a pool of connections,
+// retries commands that failed on the network, pipelines commands and has
+// helpers for the commands sider implements, pub/sub and transactions.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/aelnahas/sider/resp"
+)
+
+const (
+	DefaultAddr            = "localhost:6379"
+	DefaultPoolSize        = 10
+	DefaultDialTimeout     = 5 * time.Second
+	DefaultReadTimeout     = 3 * time.Second
+	DefaultWriteTimeout    = 3 * time.Second
+	DefaultMaxRetries      = 3
+	DefaultMinRetryBackoff = 8 * time.Millisecond
+	DefaultMaxRetryBackoff = 512 * time.Millisecond
+)
+
+var (
+	// ErrNil is returned by the helpers when the server replied with a
+	// null, like GET on a missing key.
+	ErrNil = errors.New("nil reply")
+
+	ErrClosed = errors.New("client is closed")
+)
+
+type Config struct {
+	// Network is tcp or unix, Addr is a host and port or the path of a unix
+	// socket.
+	Network string
+	Addr    string
+
+	// TLS connects over TLS when set.
+	TLS *tls.Config
+
+	// Username and Password authenticate every new connection, without a
+	// username the default user is assumed.
+	Username string
+	Password string
+
+	// ClientName is set with CLIENT SETNAME on every new connection.
+	ClientName string
+
+	// PoolSize is the most connections the pool opens.
+	PoolSize int
+
+	// DialTimeout, ReadTimeout and WriteTimeout bound the network calls, a
+	// deadline of the context given to a command comes first when it is
+	// earlier. Zero means no timeout.
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// MaxRetries is how many times a command that failed on the network is
+	// sent again, with a backoff doubling from MinRetryBackoff up to
+	// MaxRetryBackoff. Error replies are never retried.
+	MaxRetries      int
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+type Option = func(*Config)
+
+func WithAddr(addr string) Option {
+	return func(c *Config) {
+		c.Network = "tcp"
+		c.Addr = addr
+	}
+}
+
+func WithUnixSocket(path string) Option {
+	return func(c *Config) {
+		c.Network = "unix"
+		c.Addr = path
+	}
+}
+
+func WithTLS(config *tls.Config) Option {
+	return func(c *Config) {
+		c.TLS = config
+	}
+}
+
+func WithAuth(username, password string) Option {
+	return func(c *Config) {
+		c.Username = username
+		c.Password = password
+	}
+}
+
+func WithClientName(name string) Option {
+	return func(c *Config) {
+		c.ClientName = name
+	}
+}
+
+func WithPoolSize(size int) Option {
+	return func(c *Config) {
+		c.PoolSize = size
+	}
+}
+
+func WithTimeouts(dial, read, write time.Duration) Option {
+	return func(c *Config) {
+		c.DialTimeout = dial
+		c.ReadTimeout = read
+		c.WriteTimeout = write
+	}
+}
+
+func WithRetries(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Config) {
+		c.MaxRetries = maxRetries
+		c.MinRetryBackoff = minBackoff
+		c.MaxRetryBackoff = maxBackoff
+	}
+}
+
+// Client runs commands on a pool of connections, it is safe to use from many
+// goroutines.
+type Client struct {
+	cmdable
+
+	config Config
+	pool   *pool
+}
+
+// New returns a client for the server at DefaultAddr unless configured
+// otherwise, connections are only opened once commands need them.
+func New(opts ...Option) *Client {
+	cfg := Config{
+		Network:         "tcp",
+		Addr:            DefaultAddr,
+		PoolSize:        DefaultPoolSize,
+		DialTimeout:     DefaultDialTimeout,
+		ReadTimeout:     DefaultReadTimeout,
+		WriteTimeout:    DefaultWriteTimeout,
+		MaxRetries:      DefaultMaxRetries,
+		MinRetryBackoff: DefaultMinRetryBackoff,
+		MaxRetryBackoff: DefaultMaxRetryBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.PoolSize < 1 {
+		cfg.PoolSize = 1
+	}
+
+	c := &Client{config: cfg}
+	c.pool = newPool(cfg.PoolSize, c.dial)
+	c.cmdable = c.Do
+	return c
+}
+
+// Do runs a command and returns its reply as read by resp.ReplyReader, an
+// error reply is returned as a resp.ReplyError error. Arguments are sent as
+// bulk strings, numbers in decimal, booleans as 1 or 0 and durations in
+// milliseconds.
+func (c *Client) Do(ctx context.Context, args ...any) (any, error) {
+	var reply any
+	err := c.withConn(ctx, func(cn *conn) error {
+		var err error
+		reply, err = cn.roundTrip(ctx, args)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replyErr(reply)
+}
+
+// Close closes the connections of the pool, the ones in use are closed once
+// they are given back.
+func (c *Client) Close() error {
+	return c.pool.close()
+}
+
+// withConn runs fn with a connection of the pool, retrying it on a new
+// connection when it fails on the network.
+func (c *Client) withConn(ctx context.Context, fn func(cn *conn) error) error {
+	var err error
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, c.backoff(attempt)); err != nil {
+				return err
+			}
+		}
+
+		var cn *conn
+		cn, err = c.pool.get(ctx)
+		if err == nil {
+			err = fn(cn)
+			c.pool.put(cn)
+		}
+
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return err
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.config.MinRetryBackoff << (attempt - 1)
+	if d > c.config.MaxRetryBackoff || d <= 0 {
+		return c.config.MaxRetryBackoff
+	}
+	return d
+}
+
+// dial opens a new connection, authenticated and named as configured.
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	if c.config.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.DialTimeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, c.config.Network, c.config.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config.TLS != nil {
+		tlsConn := tls.Client(nc, c.config.TLS)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		nc = tlsConn
+	}
+
+	cn := newConn(nc, c.config.ReadTimeout, c.config.WriteTimeout)
+	if err := c.init(ctx, cn); err != nil {
+		cn.close()
+		return nil, err
+	}
+	return cn, nil
+}
+
+func (c *Client) init(ctx context.Context, cn *conn) error {
+	var setup [][]any
+	if c.config.Password != "" {
+		if c.config.Username != "" {
+			setup = append(setup, []any{"AUTH", c.config.Username, c.config.Password})
+		} else {
+			setup = append(setup, []any{"AUTH", c.config.Password})
+		}
+	}
+	if c.config.ClientName != "" {
+		setup = append(setup, []any{"CLIENT", "SETNAME", c.config.ClientName})
+	}
+
+	for _, args := range setup {
+		reply, err := cn.roundTrip(ctx, args)
+		if err != nil {
+			return err
+		}
+		if _, err := replyErr(reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replyErr returns an error reply as the error.
+func replyErr(reply any) (any, error) {
+	if err, ok := reply.(resp.ReplyError); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// retryable tells whether err comes from a connection that broke, rather
+// than from a timeout or from the server.
+func retryable(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return !netErr.Timeout()
+	}
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
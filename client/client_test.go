@@ -0,0 +1,349 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/client"
+	"github.com/aelnahas/sider/resp"
+	"github.com/aelnahas/sider/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// startServer starts a server on a unix socket, unless opts pick another one,
+// and returns the path of the socket.
+func startServer(t *testing.T, opts ...server.Option) string {
+	t.Helper()
+
+	cfg := server.DefaultConfig()
+	cfg.Port = 0
+	cfg.UnixSocket = filepath.Join(t.TempDir(), "sider.sock")
+	cfg.UnixSocketPerm = 0o700
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	srv := server.NewConnection(server.WithConfig(cfg))
+	go srv.Start()
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("unix", cfg.UnixSocket)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	return cfg.UnixSocket
+}
+
+func newClient(t *testing.T, socket string, opts ...client.Option) *client.Client {
+	t.Helper()
+
+	c := client.New(append([]client.Option{client.WithUnixSocket(socket)}, opts...)...)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// rawConn is a connection that reads what the server sends as it comes, for
+// the tests that look at replies the client does not expose.
+type rawConn struct {
+	t  *testing.T
+	nc net.Conn
+	w  *resp.Writer
+	r  *resp.ReplyReader
+}
+
+func dialRaw(t *testing.T, socket string) *rawConn {
+	t.Helper()
+
+	nc, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { nc.Close() })
+
+	return &rawConn{t: t, nc: nc, w: resp.NewWriter(nc), r: resp.NewReplyReader(nc)}
+}
+
+// send writes a command without waiting for its reply.
+func (c *rawConn) send(args ...string) {
+	c.t.Helper()
+
+	require.NoError(c.t, c.w.WriteValue(args))
+	require.NoError(c.t, c.w.Flush())
+}
+
+// read returns the next reply.
+func (c *rawConn) read() any {
+	c.t.Helper()
+
+	require.NoError(c.t, c.nc.SetReadDeadline(time.Now().Add(time.Second)))
+	reply, err := c.r.ReadReply()
+	require.NoError(c.t, err)
+	return reply
+}
+
+func (c *rawConn) do(args ...string) any {
+	c.t.Helper()
+
+	c.send(args...)
+	return c.read()
+}
+
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, startServer(t))
+
+	pong, err := c.Ping(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "PONG", pong)
+
+	require.NoError(t, c.Set(ctx, "greeting", "hello world", 0))
+
+	value, err := c.Get(ctx, "greeting")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", value)
+
+	old, err := c.SetArgs(ctx, "greeting", "hi", client.SetArgs{Get: true})
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", old)
+
+	n, err := c.Exists(ctx, "greeting", "greeting", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = c.Del(ctx, "greeting")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	_, err = c.Get(ctx, "greeting")
+	assert.ErrorIs(t, err, client.ErrNil)
+
+	_, err = c.Do(ctx, "NOPE")
+	assert.Equal(t, resp.ReplyError("unknown command 'NOPE'"), err)
+
+	settings, err := c.ConfigGet(ctx, "slowlog-max-len")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"slowlog-max-len": "128"}, settings)
+
+	// values are bulk strings, so they can hold CRLF
+	require.NoError(t, c.Set(ctx, "lines", "a\r\nb", 0))
+	value, err = c.Get(ctx, "lines")
+	require.NoError(t, err)
+	assert.Equal(t, "a\r\nb", value)
+
+	large := strings.Repeat("v", 5000)
+	require.NoError(t, c.Set(ctx, "large", large, 0))
+	value, err = c.Get(ctx, "large")
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+
+	result, err := c.Eval(ctx, "return ARGV[1]", nil, 42)
+	require.NoError(t, err)
+	assert.Equal(t, "42", result)
+}
+
+func TestPipeline(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, startServer(t))
+
+	pipe := c.Pipeline()
+	pipe.Do("SET", "a", 1)
+	pipe.Do("GET", "a")
+	pipe.Do("ECHO")
+	pipe.Do("PING")
+	assert.Equal(t, 4, pipe.Len())
+
+	replies, err := pipe.Exec(ctx)
+	require.Len(t, replies, 4)
+	assert.Equal(t, "OK", replies[0])
+	assert.Equal(t, "1", replies[1])
+	assert.IsType(t, resp.ReplyError(""), replies[2])
+	assert.Equal(t, replies[2], err)
+	assert.Equal(t, "PONG", replies[3])
+	assert.Zero(t, pipe.Len())
+}
+
+func TestTx(t *testing.T) {
+	ctx := context.Background()
+	socket := startServer(t)
+	c := newClient(t, socket)
+
+	require.NoError(t, c.Set(ctx, "counter", "1", 0))
+
+	replies, err := c.Tx(ctx, func(tx *client.Tx) error {
+		value, err := tx.Get(ctx, "counter")
+		if err != nil {
+			return err
+		}
+		tx.Queue("SET", "counter", value+"0")
+		tx.Queue("GET", "counter")
+		return nil
+	}, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, []any{"OK", "10"}, replies)
+
+	_, err = c.Tx(ctx, func(tx *client.Tx) error {
+		// another client changes the watched key
+		if err := c.Set(ctx, "counter", "changed", 0); err != nil {
+			return err
+		}
+		tx.Queue("SET", "counter", "mine")
+		return nil
+	}, "counter")
+	assert.ErrorIs(t, err, client.ErrTxFailed)
+
+	value, err := c.Get(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, "changed", value)
+
+	// the aborted EXEC replies with a null array, not a null bulk string
+	conn := dialRaw(t, socket)
+	assert.Equal(t, "OK", conn.do("WATCH", "counter"))
+	require.NoError(t, c.Set(ctx, "counter", "again", 0))
+	assert.Equal(t, "OK", conn.do("MULTI"))
+	assert.Equal(t, "QUEUED", conn.do("SET", "counter", "mine"))
+	conn.send("EXEC")
+
+	require.NoError(t, conn.nc.SetReadDeadline(time.Now().Add(time.Second)))
+	reply := make([]byte, 5)
+	_, err = io.ReadFull(conn.nc, reply)
+	require.NoError(t, err)
+	assert.Equal(t, "*-1\r\n", string(reply))
+}
+
+func TestPubSubResubscribes(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, startServer(t), client.WithRetries(3, time.Millisecond, 10*time.Millisecond))
+
+	ps, err := c.Subscribe(ctx, "news")
+	require.NoError(t, err)
+	defer ps.Close()
+	require.NoError(t, ps.PSubscribe(ctx, "sport.*"))
+
+	_, err = c.Publish(ctx, "news", "first")
+	require.NoError(t, err)
+	_, err = c.Publish(ctx, "sport.tennis", "second")
+	require.NoError(t, err)
+
+	msg := receive(t, ps)
+	assert.Equal(t, &client.Message{Kind: "message", Channel: "news", Payload: "first"}, msg)
+	msg = receive(t, ps)
+	assert.Equal(t, &client.Message{Kind: "pmessage", Pattern: "sport.*", Channel: "sport.tennis", Payload: "second"}, msg)
+
+	killed, err := c.ClientKill(ctx, "TYPE", "pubsub")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), killed)
+
+	// the subscriber comes back on its own
+	require.Eventually(t, func() bool {
+		numSub, err := c.PubSubNumSub(ctx, "news")
+		return err == nil && numSub["news"] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = c.Publish(ctx, "news", "third")
+	require.NoError(t, err)
+	assert.Equal(t, "third", receive(t, ps).Payload)
+
+	require.NoError(t, ps.Close())
+	_, open := <-ps.Channel()
+	assert.False(t, open)
+}
+
+func receive(t *testing.T, ps *client.PubSub) *client.Message {
+	t.Helper()
+
+	select {
+	case msg := <-ps.Channel():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func TestRetryOnBrokenConnection(t *testing.T) {
+	ctx := context.Background()
+	socket := startServer(t)
+	c := newClient(t, socket, client.WithPoolSize(1))
+
+	id, err := c.ClientID(ctx)
+	require.NoError(t, err)
+
+	// the only connection of the pool is closed by the server
+	killed, err := newClient(t, socket).ClientKill(ctx, "ID", id)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), killed)
+
+	pong, err := c.Ping(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "PONG", pong)
+
+	newID, err := c.ClientID(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, id, newID)
+}
+
+func TestContextTimeout(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, startServer(t))
+
+	require.NoError(t, c.ClientPause(ctx, time.Second, false))
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.Get(timeoutCtx, "key")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestAuth(t *testing.T) {
+	ctx := context.Background()
+	socket := startServer(t, server.WithRequirePass("secret"))
+	c := newClient(t, socket)
+
+	_, err := c.Ping(ctx)
+	var replyErr resp.ReplyError
+	require.ErrorAs(t, err, &replyErr)
+	assert.Equal(t, "NOAUTH", replyErr.Prefix())
+
+	authed := newClient(t, socket, client.WithAuth("", "secret"), client.WithClientName("tests"))
+
+	user, err := authed.ACLWhoAmI(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "default", user)
+
+	name, err := authed.ClientGetName(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "tests", name)
+}
+
+func TestConn(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, startServer(t))
+
+	conn, err := c.Conn(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.ClientSetName(ctx, "dedicated"))
+	name, err := conn.ClientGetName(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "dedicated", name)
+	require.NoError(t, conn.Close())
+
+	// the named connection is not given back to the pool
+	_, err = c.ClientGetName(ctx)
+	assert.ErrorIs(t, err, client.ErrNil)
+}
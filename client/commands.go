@@ -0,0 +1,814 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cmdable holds the helpers of the commands, on top of the function that
+// runs a command. Client, Conn and Tx each run them their own way.
+type cmdable func(ctx context.Context, args ...any) (any, error)
+
+func (c cmdable) Ping(ctx context.Context) (string, error) {
+	return toString(c(ctx, "PING"))
+}
+
+func (c cmdable) Echo(ctx context.Context, message string) (string, error) {
+	return toString(c(ctx, "ECHO", message))
+}
+
+// Get returns the value of key, or ErrNil when it does not exist.
+func (c cmdable) Get(ctx context.Context, key string) (string, error) {
+	return toString(c(ctx, "GET", key))
+}
+
+// Set sets key to value, a ttl of zero keeps it forever.
+func (c cmdable) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := c.SetArgs(ctx, key, value, SetArgs{TTL: ttl})
+	return err
+}
+
+// SetArgs are the options of SET.
+type SetArgs struct {
+	// Mode is NX or XX, which decide whether the expiration applies to a new
+	// key or to an existing one.
+	Mode string
+
+	// TTL or ExpireAt, when set, expire the key.
+	TTL      time.Duration
+	ExpireAt time.Time
+
+	KeepTTL bool
+
+	// Get returns the value the key had.
+	Get bool
+}
+
+// SetArgs sets key to value with options, it returns OK or the old value of
+// the key when Get is set, ErrNil when it had none.
+func (c cmdable) SetArgs(ctx context.Context, key, value string, a SetArgs) (string, error) {
+	args := []any{"SET", key, value}
+
+	switch {
+	case a.TTL > 0 && a.TTL%time.Second == 0:
+		args = append(args, "EX", int64(a.TTL/time.Second))
+	case a.TTL > 0:
+		args = append(args, "PX", a.TTL)
+	case !a.ExpireAt.IsZero():
+		args = append(args, "PXAT", a.ExpireAt.UnixMilli())
+	}
+	if a.Mode != "" {
+		args = append(args, a.Mode)
+	}
+	if a.KeepTTL {
+		args = append(args, "KEEPTTL")
+	}
+	if a.Get {
+		args = append(args, "GET")
+	}
+
+	return toString(c(ctx, args...))
+}
+
+// Del deletes keys and returns how many existed.
+func (c cmdable) Del(ctx context.Context, keys ...string) (int64, error) {
+	return toInt(c(ctx, appendStrings([]any{"DEL"}, keys)...))
+}
+
+// Exists returns how many of keys exist, a key given twice counts twice.
+func (c cmdable) Exists(ctx context.Context, keys ...string) (int64, error) {
+	return toInt(c(ctx, appendStrings([]any{"EXISTS"}, keys)...))
+}
+
+// Publish publishes message to channel and returns how many subscribers got
+// it.
+func (c cmdable) Publish(ctx context.Context, channel, message string) (int64, error) {
+	return toInt(c(ctx, "PUBLISH", channel, message))
+}
+
+func (c cmdable) SPublish(ctx context.Context, channel, message string) (int64, error) {
+	return toInt(c(ctx, "SPUBLISH", channel, message))
+}
+
+// PubSubChannels returns the channels with subscribers matching pattern, all
+// of them when it is empty.
+func (c cmdable) PubSubChannels(ctx context.Context, pattern string) ([]string, error) {
+	return toStrings(c(ctx, withOptional([]any{"PUBSUB", "CHANNELS"}, pattern)...))
+}
+
+func (c cmdable) PubSubShardChannels(ctx context.Context, pattern string) ([]string, error) {
+	return toStrings(c(ctx, withOptional([]any{"PUBSUB", "SHARDCHANNELS"}, pattern)...))
+}
+
+// PubSubNumSub returns the number of subscribers of each channel.
+func (c cmdable) PubSubNumSub(ctx context.Context, channels ...string) (map[string]int64, error) {
+	return toIntMap(c(ctx, appendStrings([]any{"PUBSUB", "NUMSUB"}, channels)...))
+}
+
+func (c cmdable) PubSubShardNumSub(ctx context.Context, channels ...string) (map[string]int64, error) {
+	return toIntMap(c(ctx, appendStrings([]any{"PUBSUB", "SHARDNUMSUB"}, channels)...))
+}
+
+// PubSubNumPat returns the number of patterns subscribed to.
+func (c cmdable) PubSubNumPat(ctx context.Context) (int64, error) {
+	return toInt(c(ctx, "PUBSUB", "NUMPAT"))
+}
+
+// PubSubRetain keeps the messages published to channel, up to maxLen of them
+// and for up to maxAge, zero is no bound but one of them is needed.
+func (c cmdable) PubSubRetain(ctx context.Context, channel string, maxLen int64, maxAge time.Duration) error {
+	args := []any{"PUBSUB", "RETAIN", channel}
+	if maxLen > 0 {
+		args = append(args, "MAXLEN", maxLen)
+	}
+	if maxAge > 0 {
+		args = append(args, "MAXAGE", maxAge)
+	}
+	return toOK(c(ctx, args...))
+}
+
+func (c cmdable) PubSubUnretain(ctx context.Context, channel string) error {
+	return toOK(c(ctx, "PUBSUB", "UNRETAIN", channel))
+}
+
+// Eval runs a lua script, its reply is returned as read from the server.
+func (c cmdable) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+	return c(ctx, scriptArgs("EVAL", script, keys, args)...)
+}
+
+func (c cmdable) EvalSha(ctx context.Context, sha string, keys []string, args ...any) (any, error) {
+	return c(ctx, scriptArgs("EVALSHA", sha, keys, args)...)
+}
+
+// ScriptLoad caches a script and returns its sha1 for EvalSha.
+func (c cmdable) ScriptLoad(ctx context.Context, script string) (string, error) {
+	return toString(c(ctx, "SCRIPT", "LOAD", script))
+}
+
+func (c cmdable) ScriptExists(ctx context.Context, shas ...string) ([]bool, error) {
+	return toBools(c(ctx, appendStrings([]any{"SCRIPT", "EXISTS"}, shas)...))
+}
+
+func (c cmdable) ScriptFlush(ctx context.Context) error {
+	return toOK(c(ctx, "SCRIPT", "FLUSH"))
+}
+
+func (c cmdable) ScriptKill(ctx context.Context) error {
+	return toOK(c(ctx, "SCRIPT", "KILL"))
+}
+
+// FCall calls a function of a loaded library.
+func (c cmdable) FCall(ctx context.Context, function string, keys []string, args ...any) (any, error) {
+	return c(ctx, scriptArgs("FCALL", function, keys, args)...)
+}
+
+func (c cmdable) FCallRO(ctx context.Context, function string, keys []string, args ...any) (any, error) {
+	return c(ctx, scriptArgs("FCALL_RO", function, keys, args)...)
+}
+
+// FunctionLoad loads a library and returns its name, replace replaces a
+// library of the same name.
+func (c cmdable) FunctionLoad(ctx context.Context, code string, replace bool) (string, error) {
+	args := []any{"FUNCTION", "LOAD"}
+	if replace {
+		args = append(args, "REPLACE")
+	}
+	return toString(c(ctx, append(args, code)...))
+}
+
+func (c cmdable) FunctionDelete(ctx context.Context, library string) error {
+	return toOK(c(ctx, "FUNCTION", "DELETE", library))
+}
+
+func (c cmdable) FunctionFlush(ctx context.Context) error {
+	return toOK(c(ctx, "FUNCTION", "FLUSH"))
+}
+
+func (c cmdable) FunctionKill(ctx context.Context) error {
+	return toOK(c(ctx, "FUNCTION", "KILL"))
+}
+
+// FunctionList returns the loaded libraries as read from the server, args
+// are the options of FUNCTION LIST.
+func (c cmdable) FunctionList(ctx context.Context, args ...any) (any, error) {
+	return c(ctx, append([]any{"FUNCTION", "LIST"}, args...)...)
+}
+
+// FunctionDump returns the serialized libraries for FunctionRestore.
+func (c cmdable) FunctionDump(ctx context.Context) (string, error) {
+	return toString(c(ctx, "FUNCTION", "DUMP"))
+}
+
+// FunctionRestore restores libraries with a policy, FLUSH, APPEND or
+// REPLACE, or the default one when it is empty.
+func (c cmdable) FunctionRestore(ctx context.Context, payload, policy string) error {
+	return toOK(c(ctx, withOptional([]any{"FUNCTION", "RESTORE", payload}, policy)...))
+}
+
+func (c cmdable) ClientID(ctx context.Context) (int64, error) {
+	return toInt(c(ctx, "CLIENT", "ID"))
+}
+
+// ClientGetName returns the name of the connection, or ErrNil when it has
+// none.
+func (c cmdable) ClientGetName(ctx context.Context) (string, error) {
+	return toString(c(ctx, "CLIENT", "GETNAME"))
+}
+
+// ClientList returns the connections in the format of CLIENT LIST, args
+// are its options.
+func (c cmdable) ClientList(ctx context.Context, args ...any) (string, error) {
+	return toString(c(ctx, append([]any{"CLIENT", "LIST"}, args...)...))
+}
+
+func (c cmdable) ClientInfo(ctx context.Context) (string, error) {
+	return toString(c(ctx, "CLIENT", "INFO"))
+}
+
+// ClientKill closes the connections matching the filters of CLIENT KILL,
+// like ID 5 or USER alice, and returns how many were closed.
+func (c cmdable) ClientKill(ctx context.Context, filters ...any) (int64, error) {
+	return toInt(c(ctx, append([]any{"CLIENT", "KILL"}, filters...)...))
+}
+
+// ClientPause holds back the commands of the clients for d, only the writes
+// when writesOnly is set.
+func (c cmdable) ClientPause(ctx context.Context, d time.Duration, writesOnly bool) error {
+	mode := "ALL"
+	if writesOnly {
+		mode = "WRITE"
+	}
+	return toOK(c(ctx, "CLIENT", "PAUSE", d, mode))
+}
+
+func (c cmdable) ClientUnpause(ctx context.Context) error {
+	return toOK(c(ctx, "CLIENT", "UNPAUSE"))
+}
+
+// ClientGetRedir returns the client tracking invalidations are sent to, 0
+// when they are not redirected and -1 when tracking is off.
+func (c cmdable) ClientGetRedir(ctx context.Context) (int64, error) {
+	return toInt(c(ctx, "CLIENT", "GETREDIR"))
+}
+
+func (c cmdable) ACLSetUser(ctx context.Context, username string, rules ...string) error {
+	return toOK(c(ctx, appendStrings([]any{"ACL", "SETUSER", username}, rules)...))
+}
+
+// ACLGetUser returns the flags, passwords and permissions of a user, or
+// ErrNil when there is no such user.
+func (c cmdable) ACLGetUser(ctx context.Context, username string) (map[string]any, error) {
+	reply, err := c(ctx, "ACL", "GETUSER", username)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrNil
+	}
+	return toMap(reply, nil)
+}
+
+// ACLDelUser deletes users and returns how many existed.
+func (c cmdable) ACLDelUser(ctx context.Context, usernames ...string) (int64, error) {
+	return toInt(c(ctx, appendStrings([]any{"ACL", "DELUSER"}, usernames)...))
+}
+
+// ACLList returns the users in the format of the acl file.
+func (c cmdable) ACLList(ctx context.Context) ([]string, error) {
+	return toStrings(c(ctx, "ACL", "LIST"))
+}
+
+func (c cmdable) ACLUsers(ctx context.Context) ([]string, error) {
+	return toStrings(c(ctx, "ACL", "USERS"))
+}
+
+func (c cmdable) ACLWhoAmI(ctx context.Context) (string, error) {
+	return toString(c(ctx, "ACL", "WHOAMI"))
+}
+
+// ACLCat returns the categories, or the commands of category when it is not
+// empty.
+func (c cmdable) ACLCat(ctx context.Context, category string) ([]string, error) {
+	return toStrings(c(ctx, withOptional([]any{"ACL", "CAT"}, category)...))
+}
+
+// ACLLog returns the last count entries of the acl log, all of them when
+// count is zero.
+func (c cmdable) ACLLog(ctx context.Context, count int) ([]map[string]any, error) {
+	args := []any{"ACL", "LOG"}
+	if count > 0 {
+		args = append(args, count)
+	}
+
+	reply, err := c(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := toSlice(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]map[string]any, 0, len(entries))
+	for _, entry := range entries {
+		m, err := toMap(entry, nil)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+func (c cmdable) ACLLogReset(ctx context.Context) error {
+	return toOK(c(ctx, "ACL", "LOG", "RESET"))
+}
+
+func (c cmdable) ACLSave(ctx context.Context) error {
+	return toOK(c(ctx, "ACL", "SAVE"))
+}
+
+func (c cmdable) ACLLoad(ctx context.Context) error {
+	return toOK(c(ctx, "ACL", "LOAD"))
+}
+
+// ConfigGet returns the settings matching the patterns.
+func (c cmdable) ConfigGet(ctx context.Context, patterns ...string) (map[string]string, error) {
+	reply, err := c(ctx, appendStrings([]any{"CONFIG", "GET"}, patterns)...)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := toMap(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = fmt.Sprint(v)
+	}
+	return res, nil
+}
+
+// ConfigSet changes settings given as name value pairs.
+func (c cmdable) ConfigSet(ctx context.Context, pairs ...string) error {
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return fmt.Errorf("settings must be name value pairs")
+	}
+	return toOK(c(ctx, appendStrings([]any{"CONFIG", "SET"}, pairs)...))
+}
+
+func (c cmdable) ConfigRewrite(ctx context.Context) error {
+	return toOK(c(ctx, "CONFIG", "REWRITE"))
+}
+
+func (c cmdable) ConfigResetStat(ctx context.Context) error {
+	return toOK(c(ctx, "CONFIG", "RESETSTAT"))
+}
+
+// Info returns the sections of INFO, the default ones when none are given.
+func (c cmdable) Info(ctx context.Context, sections ...string) (string, error) {
+	return toString(c(ctx, appendStrings([]any{"INFO"}, sections)...))
+}
+
+// SlowlogEntry is a command that was slower than slowlog-log-slower-than.
+type SlowlogEntry struct {
+	ID         int64
+	Time       time.Time
+	Duration   time.Duration
+	Args       []string
+	ClientAddr string
+	ClientName string
+}
+
+// SlowlogGet returns the last count entries of the slowlog, the default
+// number of them when count is zero.
+func (c cmdable) SlowlogGet(ctx context.Context, count int) ([]SlowlogEntry, error) {
+	args := []any{"SLOWLOG", "GET"}
+	if count != 0 {
+		args = append(args, count)
+	}
+
+	reply, err := c(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := toRows(reply, nil, 6)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]SlowlogEntry, 0, len(rows))
+	for _, row := range rows {
+		argv, err := toStrings(row[3], nil)
+		if err != nil {
+			return nil, err
+		}
+		addr, _ := row[4].(string)
+		name, _ := row[5].(string)
+
+		entries = append(entries, SlowlogEntry{
+			ID:         intAt(row, 0),
+			Time:       time.Unix(intAt(row, 1), 0),
+			Duration:   time.Duration(intAt(row, 2)) * time.Microsecond,
+			Args:       argv,
+			ClientAddr: addr,
+			ClientName: name,
+		})
+	}
+	return entries, nil
+}
+
+func (c cmdable) SlowlogLen(ctx context.Context) (int64, error) {
+	return toInt(c(ctx, "SLOWLOG", "LEN"))
+}
+
+func (c cmdable) SlowlogReset(ctx context.Context) error {
+	return toOK(c(ctx, "SLOWLOG", "RESET"))
+}
+
+// LatencyEvent is the latest and the highest latency of an event.
+type LatencyEvent struct {
+	Event  string
+	Time   time.Time
+	Latest time.Duration
+	Max    time.Duration
+}
+
+func (c cmdable) LatencyLatest(ctx context.Context) ([]LatencyEvent, error) {
+	rows, err := toRows(c(ctx, "LATENCY", "LATEST"))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]LatencyEvent, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 4 {
+			return nil, errUnexpectedReply(row)
+		}
+		name, _ := row[0].(string)
+
+		events = append(events, LatencyEvent{
+			Event:  name,
+			Time:   time.Unix(intAt(row, 1), 0),
+			Latest: time.Duration(intAt(row, 2)) * time.Millisecond,
+			Max:    time.Duration(intAt(row, 3)) * time.Millisecond,
+		})
+	}
+	return events, nil
+}
+
+// LatencySample is a latency recorded for an event.
+type LatencySample struct {
+	Time    time.Time
+	Latency time.Duration
+}
+
+func (c cmdable) LatencyHistory(ctx context.Context, event string) ([]LatencySample, error) {
+	rows, err := toRows(c(ctx, "LATENCY", "HISTORY", event))
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]LatencySample, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 2 {
+			return nil, errUnexpectedReply(row)
+		}
+		samples = append(samples, LatencySample{
+			Time:    time.Unix(intAt(row, 0), 0),
+			Latency: time.Duration(intAt(row, 1)) * time.Millisecond,
+		})
+	}
+	return samples, nil
+}
+
+// LatencyReset clears the samples of events, all of them when none are
+// given, and returns how many events were cleared.
+func (c cmdable) LatencyReset(ctx context.Context, events ...string) (int64, error) {
+	return toInt(c(ctx, appendStrings([]any{"LATENCY", "RESET"}, events)...))
+}
+
+// LatencyHistogram returns the latency histograms of commands, of all of
+// them when none are given, as read from the server.
+func (c cmdable) LatencyHistogram(ctx context.Context, commands ...string) (map[string]any, error) {
+	reply, err := c(ctx, appendStrings([]any{"LATENCY", "HISTOGRAM"}, commands)...)
+	if err != nil {
+		return nil, err
+	}
+	return toMap(reply, nil)
+}
+
+func (c cmdable) LatencyDoctor(ctx context.Context) (string, error) {
+	return toString(c(ctx, "LATENCY", "DOCTOR"))
+}
+
+// Command returns the details of every command, as read from the server.
+func (c cmdable) Command(ctx context.Context) ([]any, error) {
+	return toSlice(c(ctx, "COMMAND"))
+}
+
+func (c cmdable) CommandCount(ctx context.Context) (int64, error) {
+	return toInt(c(ctx, "COMMAND", "COUNT"))
+}
+
+func (c cmdable) CommandInfo(ctx context.Context, names ...string) ([]any, error) {
+	return toSlice(c(ctx, appendStrings([]any{"COMMAND", "INFO"}, names)...))
+}
+
+func (c cmdable) CommandDocs(ctx context.Context, names ...string) (map[string]any, error) {
+	reply, err := c(ctx, appendStrings([]any{"COMMAND", "DOCS"}, names)...)
+	if err != nil {
+		return nil, err
+	}
+	return toMap(reply, nil)
+}
+
+// CommandList returns the names of the commands, filter is empty or one of
+// the filters of COMMAND LIST FILTERBY, like ACLCAT read.
+func (c cmdable) CommandList(ctx context.Context, filter ...string) ([]string, error) {
+	args := []any{"COMMAND", "LIST"}
+	if len(filter) > 0 {
+		args = appendStrings(append(args, "FILTERBY"), filter)
+	}
+	return toStrings(c(ctx, args...))
+}
+
+// CommandGetKeys returns the keys of a command given as its arguments.
+func (c cmdable) CommandGetKeys(ctx context.Context, args ...any) ([]string, error) {
+	return toStrings(c(ctx, append([]any{"COMMAND", "GETKEYS"}, args...)...))
+}
+
+// The commands below change the state of the connection, so they are only
+// available on a Conn.
+
+// Auth authenticates the connection as username, the default user when it
+// is empty.
+func (c *Conn) Auth(ctx context.Context, username, password string) error {
+	return toOK(c.Do(ctx, withOptional([]any{"AUTH"}, username, password)...))
+}
+
+// Reset brings the connection back to how it started.
+func (c *Conn) Reset(ctx context.Context) error {
+	status, err := toString(c.Do(ctx, "RESET"))
+	if err == nil && status != "RESET" {
+		return errUnexpectedReply(status)
+	}
+	return err
+}
+
+func (c *Conn) ClientSetName(ctx context.Context, name string) error {
+	return toOK(c.Do(ctx, "CLIENT", "SETNAME", name))
+}
+
+func (c *Conn) ClientNoEvict(ctx context.Context, on bool) error {
+	return toOK(c.Do(ctx, "CLIENT", "NO-EVICT", onOff(on)))
+}
+
+// TrackingArgs are the options of CLIENT TRACKING.
+type TrackingArgs struct {
+	// Redirect sends the invalidations to another connection, subscribed
+	// to __redis__:invalidate.
+	Redirect int64
+
+	// BCast tracks the keys starting with Prefixes, or all of them without
+	// prefixes, rather than the keys read.
+	BCast    bool
+	Prefixes []string
+
+	OptIn  bool
+	OptOut bool
+	NoLoop bool
+}
+
+// ClientTracking turns client side caching on or off.
+func (c *Conn) ClientTracking(ctx context.Context, on bool, a TrackingArgs) error {
+	args := []any{"CLIENT", "TRACKING", onOff(on)}
+	if a.Redirect != 0 {
+		args = append(args, "REDIRECT", a.Redirect)
+	}
+	for _, prefix := range a.Prefixes {
+		args = append(args, "PREFIX", prefix)
+	}
+	flags := []struct {
+		name string
+		set  bool
+	}{{"BCAST", a.BCast}, {"OPTIN", a.OptIn}, {"OPTOUT", a.OptOut}, {"NOLOOP", a.NoLoop}}
+	for _, flag := range flags {
+		if flag.set {
+			args = append(args, flag.name)
+		}
+	}
+	return toOK(c.Do(ctx, args...))
+}
+
+// ClientCaching tells whether the keys read by the next command are tracked,
+// in the OPTIN and OPTOUT modes of tracking.
+func (c *Conn) ClientCaching(ctx context.Context, yes bool) error {
+	arg := "NO"
+	if yes {
+		arg = "YES"
+	}
+	return toOK(c.Do(ctx, "CLIENT", "CACHING", arg))
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+func appendStrings(args []any, values []string) []any {
+	return append(args, stringsToArgs(values)...)
+}
+
+// withOptional appends the values that are not empty.
+func withOptional(args []any, values ...string) []any {
+	for _, v := range values {
+		if v != "" {
+			args = append(args, v)
+		}
+	}
+	return args
+}
+
+func scriptArgs(command, script string, keys []string, args []any) []any {
+	res := make([]any, 0, 3+len(keys)+len(args))
+	res = append(res, command, script, len(keys))
+	res = appendStrings(res, keys)
+	return append(res, args...)
+}
+
+func errUnexpectedReply(reply any) error {
+	return fmt.Errorf("unexpected reply %T: %v", reply, reply)
+}
+
+func toString(reply any, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+
+	switch reply := reply.(type) {
+	case nil:
+		return "", ErrNil
+	case string:
+		return reply, nil
+	case int64:
+		return strconv.FormatInt(reply, 10), nil
+	default:
+		return "", errUnexpectedReply(reply)
+	}
+}
+
+// toOK accepts the OK status.
+func toOK(reply any, err error) error {
+	status, err := toString(reply, err)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(status, "OK") {
+		return errUnexpectedReply(status)
+	}
+	return nil
+}
+
+func toInt(reply any, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+
+	switch reply := reply.(type) {
+	case int64:
+		return reply, nil
+	case string:
+		n, err := strconv.ParseInt(reply, 10, 64)
+		if err != nil {
+			return 0, errUnexpectedReply(reply)
+		}
+		return n, nil
+	case nil:
+		return 0, ErrNil
+	default:
+		return 0, errUnexpectedReply(reply)
+	}
+}
+
+func toSlice(reply any, err error) ([]any, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	switch reply := reply.(type) {
+	case []any:
+		return reply, nil
+	case nil:
+		return nil, ErrNil
+	default:
+		return nil, errUnexpectedReply(reply)
+	}
+}
+
+// toStrings reads an array of strings, null elements are empty.
+func toStrings(reply any, err error) ([]string, error) {
+	values, err := toSlice(reply, err)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		s, err := toString(v, nil)
+		if err != nil && err != ErrNil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func toBools(reply any, err error) ([]bool, error) {
+	values, err := toSlice(reply, err)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]bool, 0, len(values))
+	for _, v := range values {
+		n, err := toInt(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, n == 1)
+	}
+	return res, nil
+}
+
+// toMap reads a RESP3 map or a RESP2 array of keys each followed by its
+// value.
+func toMap(reply any, err error) (map[string]any, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	switch reply := reply.(type) {
+	case map[string]any:
+		return reply, nil
+	case []any:
+		if len(reply)%2 != 0 {
+			return nil, errUnexpectedReply(reply)
+		}
+		m := make(map[string]any, len(reply)/2)
+		for i := 0; i < len(reply); i += 2 {
+			m[fmt.Sprint(reply[i])] = reply[i+1]
+		}
+		return m, nil
+	default:
+		return nil, errUnexpectedReply(reply)
+	}
+}
+
+func toIntMap(reply any, err error) (map[string]int64, error) {
+	m, err := toMap(reply, err)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]int64, len(m))
+	for k, v := range m {
+		n, err := toInt(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		res[k] = n
+	}
+	return res, nil
+}
+
+// toRows reads an array of arrays, each of at least width elements.
+func toRows(reply any, err error, width ...int) ([][]any, error) {
+	values, err := toSlice(reply, err)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]any, 0, len(values))
+	for _, v := range values {
+		row, ok := v.([]any)
+		if !ok || len(width) > 0 && len(row) < width[0] {
+			return nil, errUnexpectedReply(v)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// intAt returns the integer at index i of a row, zero when it is not one.
+func intAt(row []any, i int) int64 {
+	n, _ := row[i].(int64)
+	return n
+}
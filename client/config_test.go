@@ -0,0 +1,111 @@
+package client_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/aelnahas/sider/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSetApplies(t *testing.T) {
+	ctx := context.Background()
+	socket := startServer(t)
+	c := newClient(t, socket)
+
+	t.Run("requirepass", func(t *testing.T) {
+		require.NoError(t, c.ConfigSet(ctx, "requirepass", "secret"))
+
+		_, err := newClient(t, socket).Ping(ctx)
+		var replyErr resp.ReplyError
+		require.ErrorAs(t, err, &replyErr)
+		assert.Equal(t, "NOAUTH", replyErr.Prefix())
+
+		require.NoError(t, c.ConfigSet(ctx, "requirepass", ""))
+		_, err = newClient(t, socket).Ping(ctx)
+		assert.NoError(t, err)
+	})
+
+	t.Run("slowlog", func(t *testing.T) {
+		require.NoError(t, c.ConfigSet(ctx, "slowlog-log-slower-than", "0", "slowlog-max-len", "2"))
+		require.NoError(t, c.SlowlogReset(ctx))
+		for i := 0; i < 3; i++ {
+			_, err := c.Echo(ctx, "slow")
+			require.NoError(t, err)
+		}
+
+		n, err := c.SlowlogLen(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+
+		require.NoError(t, c.ConfigSet(ctx, "slowlog-log-slower-than", "-1"))
+	})
+
+	t.Run("notify-keyspace-events", func(t *testing.T) {
+		require.NoError(t, c.ConfigSet(ctx, "notify-keyspace-events", "KA"))
+		ps, err := c.Subscribe(ctx, "__keyspace@0__:watched")
+		require.NoError(t, err)
+		defer ps.Close()
+
+		require.NoError(t, c.Set(ctx, "watched", "1", 0))
+		assert.Equal(t, "set", receive(t, ps).Payload)
+	})
+
+	t.Run("proto-max-bulk-len", func(t *testing.T) {
+		require.NoError(t, c.ConfigSet(ctx, "proto-max-bulk-len", "1mb"))
+
+		// the length is refused before the value is read
+		conn := dialRaw(t, socket)
+		_, err := conn.nc.Write([]byte("*3\r\n$3\r\nSET\r\n$5\r\nlarge\r\n$2097152\r\n"))
+		require.NoError(t, err)
+		assert.Equal(t, resp.ReplyError("ERR Protocol error: invalid bulk length"), conn.read())
+
+		require.NoError(t, c.ConfigSet(ctx, "proto-max-bulk-len", "512mb"))
+		assert.NoError(t, c.Set(ctx, "large", strings.Repeat("v", 2<<20), 0))
+	})
+
+	t.Run("client-output-buffer-limit", func(t *testing.T) {
+		require.NoError(t, c.ConfigSet(ctx, "client-output-buffer-limit", "pubsub 1kb 0 0"))
+		settings, err := c.ConfigGet(ctx, "client-output-buffer-limit")
+		require.NoError(t, err)
+		assert.Equal(t, "pubsub 1024 0 0", settings["client-output-buffer-limit"])
+
+		// a subscriber that does not read is disconnected once a message
+		// puts it over the new limit
+		conn := dialRaw(t, socket)
+		assert.Equal(t, []any{"subscribe", "flood", int64(1)}, conn.do("SUBSCRIBE", "flood"))
+		_, err = c.Publish(ctx, "flood", strings.Repeat("m", 4096))
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			numSub, err := c.PubSubNumSub(ctx, "flood")
+			return err == nil && numSub["flood"] == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestConfigRewrite(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "sider.conf")
+	require.NoError(t, os.WriteFile(file, []byte("# kept\nslowlog-max-len 5\n"), 0o644))
+
+	c := newClient(t, startServer(t, func(cfg *server.Config) { cfg.ConfigFile = file }))
+	require.NoError(t, c.ConfigSet(ctx, "slowlog-max-len", "7", "requirepass", "a\"b"))
+	require.NoError(t, c.ConfigRewrite(ctx))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "# kept\nslowlog-max-len 7\n# Generated by CONFIG REWRITE\n"), string(data))
+	assert.Contains(t, string(data), "\nrequirepass \"a\\\"b\"\n")
+
+	cfg, err := server.LoadConfig(file)
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), cfg.SlowlogMaxLen)
+	assert.Equal(t, "a\"b", cfg.RequirePass)
+}
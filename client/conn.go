@@ -0,0 +1,257 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aelnahas/sider/resp"
+)
+
+// conn is a single connection to the server. Once a call on it fails it is
+// broken, as there is no telling how much of a reply is left to read, and
+// the pool closes it.
+type conn struct {
+	nc  net.Conn
+	w   *resp.Writer
+	r   *resp.ReplyReader
+	buf []byte
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	// broken is set from the reading and the writing side of a subscriber
+	broken atomic.Bool
+}
+
+func newConn(nc net.Conn, readTimeout, writeTimeout time.Duration) *conn {
+	return &conn{
+		nc:           nc,
+		w:            resp.NewWriter(nc),
+		r:            resp.NewReplyReader(nc),
+		readTimeout:  readTimeout,
+		writeTimeout: writeTimeout,
+	}
+}
+
+// roundTrip sends a command and reads its reply.
+func (cn *conn) roundTrip(ctx context.Context, args []any) (any, error) {
+	if err := cn.send(ctx, args); err != nil {
+		return nil, err
+	}
+	return cn.receive(ctx)
+}
+
+// send writes commands, in a single write when there are many.
+func (cn *conn) send(ctx context.Context, cmds ...[]any) error {
+	return cn.withContext(ctx, cn.writeTimeout, cn.nc.SetWriteDeadline, func() error {
+		for _, args := range cmds {
+			if err := cn.writeCommand(args); err != nil {
+				return err
+			}
+		}
+		return cn.w.Flush()
+	})
+}
+
+// receive reads the next reply.
+func (cn *conn) receive(ctx context.Context) (any, error) {
+	var reply any
+	err := cn.withContext(ctx, cn.readTimeout, cn.nc.SetReadDeadline, func() error {
+		var err error
+		reply, err = cn.r.ReadReply()
+		return err
+	})
+	return reply, err
+}
+
+func (cn *conn) writeCommand(args []any) error {
+	if err := cn.w.WriteArrayHeader(len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		cn.buf = appendArg(cn.buf[:0], arg)
+		if err := cn.w.WriteBulk(cn.buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendArg appends the bulk string an argument is sent as.
+func appendArg(b []byte, arg any) []byte {
+	switch arg := arg.(type) {
+	case string:
+		return append(b, arg...)
+	case []byte:
+		return append(b, arg...)
+	case int:
+		return strconv.AppendInt(b, int64(arg), 10)
+	case int64:
+		return strconv.AppendInt(b, arg, 10)
+	case uint64:
+		return strconv.AppendUint(b, arg, 10)
+	case float64:
+		return strconv.AppendFloat(b, arg, 'f', -1, 64)
+	case bool:
+		if arg {
+			return append(b, '1')
+		}
+		return append(b, '0')
+	case time.Duration:
+		return strconv.AppendInt(b, arg.Milliseconds(), 10)
+	default:
+		return fmt.Append(b, arg)
+	}
+}
+
+// aLongTimeAgo is a deadline in the past, setting it interrupts the calls
+// blocked on the connection.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// withContext runs fn with a deadline, set with setDeadline, that is the
+// earlier of the context deadline and timeout, and interrupts it when the
+// context is canceled. Reads and writes have their own deadlines so a
+// subscriber can send commands while it waits for messages.
+func (cn *conn) withContext(ctx context.Context, timeout time.Duration, setDeadline func(time.Time) error, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	deadline, ok := ctx.Deadline()
+	fromCtx := ok
+	if timeout > 0 {
+		if d := time.Now().Add(timeout); !ok || d.Before(deadline) {
+			deadline, ok, fromCtx = d, true, false
+		}
+	}
+	if !ok {
+		deadline = time.Time{}
+	}
+	if err := setDeadline(deadline); err != nil {
+		cn.broken.Store(true)
+		return err
+	}
+
+	if ctx.Done() != nil {
+		done, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-ctx.Done():
+				_ = setDeadline(aLongTimeAgo)
+			case <-done:
+			}
+		}()
+		defer func() {
+			close(done)
+			<-stopped
+		}()
+	}
+
+	err := fn()
+	if err != nil {
+		cn.broken.Store(true)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		// the deadline of the connection may pass before the one of the
+		// context is noticed
+		var netErr net.Error
+		if fromCtx && errors.As(err, &netErr) && netErr.Timeout() {
+			return context.DeadlineExceeded
+		}
+	}
+	return err
+}
+
+func (cn *conn) close() error {
+	return cn.nc.Close()
+}
+
+// pool holds up to size connections, the idle ones are reused before new
+// ones are dialed.
+type pool struct {
+	dial func(ctx context.Context) (*conn, error)
+
+	// slots has a value for every connection given out
+	slots chan struct{}
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+func newPool(size int, dial func(ctx context.Context) (*conn, error)) *pool {
+	return &pool{dial: dial, slots: make(chan struct{}, size)}
+}
+
+// get returns an idle connection or dials a new one, waiting for one to be
+// given back when size of them are in use.
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, ErrClosed
+	}
+	if n := len(p.idle); n > 0 {
+		cn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return cn, nil
+	}
+	p.mu.Unlock()
+
+	cn, err := p.dial(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return cn, nil
+}
+
+// put gives a connection back, a broken one is closed.
+func (p *pool) put(cn *conn) {
+	defer func() { <-p.slots }()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if cn.broken.Load() || p.closed {
+		cn.close()
+		return
+	}
+	p.idle = append(p.idle, cn)
+}
+
+func (p *pool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+	p.closed = true
+
+	var err error
+	for _, cn := range p.idle {
+		if closeErr := cn.close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	p.idle = nil
+	return err
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+)
+
+// Conn is a connection taken out of the pool, for the commands that change
+// the state of the connection they run on like CLIENT SETNAME or CLIENT
+// TRACKING. It is not safe to use from many goroutines.
+type Conn struct {
+	cmdable
+
+	c  *Client
+	cn *conn
+}
+
+// Conn takes a connection out of the pool, it counts towards the pool size
+// until it is closed.
+func (c *Client) Conn(ctx context.Context) (*Conn, error) {
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &Conn{c: c, cn: cn}
+	conn.cmdable = conn.Do
+	return conn, nil
+}
+
+// Do runs a command on the connection, it is not retried.
+func (c *Conn) Do(ctx context.Context, args ...any) (any, error) {
+	reply, err := c.cn.roundTrip(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return replyErr(reply)
+}
+
+// Close closes the connection, it is not given back to the pool as its
+// state may have changed.
+func (c *Conn) Close() error {
+	if c.cn == nil {
+		return ErrClosed
+	}
+	c.cn.broken.Store(true)
+	c.c.pool.put(c.cn)
+	c.cn = nil
+	return nil
+}
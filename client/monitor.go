@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Monitor streams the commands the server runs, as MONITOR prints them, on a
+// connection of its own.
+type Monitor struct {
+	cn    *conn
+	lines chan string
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Monitor starts monitoring, ctx only bounds starting it.
+func (c *Client) Monitor(ctx context.Context) (*Monitor, error) {
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.roundTrip(ctx, []any{"MONITOR"})
+	if err == nil {
+		_, err = replyErr(reply)
+	}
+	if err != nil {
+		cn.close()
+		return nil, fmt.Errorf("could not start monitoring: %w", err)
+	}
+	cn.readTimeout = 0
+
+	m := &Monitor{cn: cn, lines: make(chan string, messageBuffer), done: make(chan struct{})}
+	go m.run()
+	return m, nil
+}
+
+// Lines returns the commands run, it is closed once the monitor is closed or
+// its connection breaks.
+func (m *Monitor) Lines() <-chan string {
+	return m.lines
+}
+
+func (m *Monitor) Close() error {
+	err := ErrClosed
+	m.closeOnce.Do(func() {
+		close(m.done)
+		err = m.cn.close()
+	})
+	return err
+}
+
+func (m *Monitor) run() {
+	defer close(m.lines)
+
+	for {
+		reply, err := m.cn.receive(context.Background())
+		if err != nil {
+			return
+		}
+
+		line, ok := reply.(string)
+		if !ok {
+			continue
+		}
+
+		select {
+		case m.lines <- line:
+		case <-m.done:
+			return
+		}
+	}
+}
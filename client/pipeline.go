@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+
+	"github.com/aelnahas/sider/resp"
+)
+
+// Pipeline queues commands to send them in a single write and read all their
+// replies after, saving a round trip per command.
+type Pipeline struct {
+	c    *Client
+	cmds [][]any
+}
+
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Do queues a command.
+func (p *Pipeline) Do(args ...any) {
+	p.cmds = append(p.cmds, args)
+}
+
+// Len returns the number of queued commands.
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Exec sends the queued commands and returns their replies in order, the
+// queue is emptied either way. Error replies are resp.ReplyError values in
+// the replies and the first of them is returned as the error as well.
+//
+// When the connection fails the whole pipeline is sent again, so commands
+// may run twice.
+func (p *Pipeline) Exec(ctx context.Context) ([]any, error) {
+	cmds := p.cmds
+	p.cmds = nil
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+
+	var replies []any
+	err := p.c.withConn(ctx, func(cn *conn) error {
+		var err error
+		replies, err = cn.pipeline(ctx, cmds)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return replies, firstReplyErr(replies)
+}
+
+// pipeline sends cmds at once and reads a reply for each.
+func (cn *conn) pipeline(ctx context.Context, cmds [][]any) ([]any, error) {
+	if err := cn.send(ctx, cmds...); err != nil {
+		return nil, err
+	}
+
+	replies := make([]any, 0, len(cmds))
+	for range cmds {
+		reply, err := cn.receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+func firstReplyErr(replies []any) error {
+	for _, reply := range replies {
+		if err, ok := reply.(resp.ReplyError); ok {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,540 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/sider/resp"
+)
+
+// Message is a message published to a channel the subscriber is subscribed
+// to.
+type Message struct {
+	// Kind is message, pmessage, smessage or rmessage.
+	Kind    string
+	Channel string
+
+	// Pattern is the pattern that matched the channel of a pmessage.
+	Pattern string
+
+	// Offset is the offset of an rmessage in the retained channel.
+	Offset uint64
+
+	Payload string
+}
+
+// messageBuffer is how many messages are read ahead of the reader of
+// Channel.
+const messageBuffer = 100
+
+// PubSub is a subscriber on a connection of its own. When the connection
+// breaks it connects again and subscribes to the same channels and patterns,
+// retained channels are subscribed from the last message received so none
+// are missed.
+type PubSub struct {
+	c *Client
+
+	// subMu makes the subscribing calls wait for their replies one at a
+	// time
+	subMu sync.Mutex
+
+	mu       sync.Mutex
+	cn       *conn
+	closed   bool
+	pending  *ack
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	shards   map[string]struct{}
+	retained map[string]string
+
+	msgs chan *Message
+	done chan struct{}
+}
+
+func (c *Client) newPubSub(ctx context.Context) (*PubSub, error) {
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// a subscriber waits for messages as long as it takes
+	cn.readTimeout = 0
+
+	ps := &PubSub{
+		c:        c,
+		cn:       cn,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+		shards:   make(map[string]struct{}),
+		retained: make(map[string]string),
+		msgs:     make(chan *Message, messageBuffer),
+		done:     make(chan struct{}),
+	}
+	go ps.run()
+	return ps, nil
+}
+
+// Subscribe returns a subscriber subscribed to channels.
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	ps, err := c.newPubSub(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.Subscribe(ctx, channels...); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// PSubscribe returns a subscriber subscribed to patterns.
+func (c *Client) PSubscribe(ctx context.Context, patterns ...string) (*PubSub, error) {
+	ps, err := c.newPubSub(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.PSubscribe(ctx, patterns...); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// SSubscribe returns a subscriber subscribed to shard channels.
+func (c *Client) SSubscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	ps, err := c.newPubSub(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.SSubscribe(ctx, channels...); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// RSubscribe returns a subscriber subscribed to retained channels, see
+// PubSub.RSubscribe.
+func (c *Client) RSubscribe(ctx context.Context, positions map[string]string) (*PubSub, error) {
+	ps, err := c.newPubSub(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.RSubscribe(ctx, positions); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// Channel returns the messages received, it is closed once the subscriber
+// is closed.
+func (ps *PubSub) Channel() <-chan *Message {
+	return ps.msgs
+}
+
+func (ps *PubSub) Subscribe(ctx context.Context, channels ...string) error {
+	ps.mu.Lock()
+	addAll(ps.channels, channels)
+	ps.mu.Unlock()
+
+	return ps.subscribe(ctx, "SUBSCRIBE", channels, channels)
+}
+
+func (ps *PubSub) PSubscribe(ctx context.Context, patterns ...string) error {
+	ps.mu.Lock()
+	addAll(ps.patterns, patterns)
+	ps.mu.Unlock()
+
+	return ps.subscribe(ctx, "PSUBSCRIBE", patterns, patterns)
+}
+
+func (ps *PubSub) SSubscribe(ctx context.Context, channels ...string) error {
+	ps.mu.Lock()
+	addAll(ps.shards, channels)
+	ps.mu.Unlock()
+
+	return ps.subscribe(ctx, "SSUBSCRIBE", channels, channels)
+}
+
+// RSubscribe subscribes to retained channels from a position each, the
+// offset of the last message received or a unix time in milliseconds
+// prefixed with @.
+func (ps *PubSub) RSubscribe(ctx context.Context, positions map[string]string) error {
+	args := make([]string, 0, 2*len(positions))
+	channels := make([]string, 0, len(positions))
+
+	ps.mu.Lock()
+	for channel, position := range positions {
+		ps.retained[channel] = position
+		args = append(args, channel, position)
+		channels = append(channels, channel)
+	}
+	ps.mu.Unlock()
+
+	return ps.subscribe(ctx, "RSUBSCRIBE", args, channels)
+}
+
+// Unsubscribe unsubscribes from channels, retained ones included, or from
+// all of them when none are given.
+func (ps *PubSub) Unsubscribe(ctx context.Context, channels ...string) error {
+	acked := channels
+
+	ps.mu.Lock()
+	if len(channels) == 0 {
+		acked = keys(ps.channels)
+		for channel := range ps.retained {
+			if _, ok := ps.channels[channel]; !ok {
+				acked = append(acked, channel)
+			}
+		}
+		ps.channels = make(map[string]struct{})
+		ps.retained = make(map[string]string)
+	}
+	for _, channel := range channels {
+		delete(ps.channels, channel)
+		delete(ps.retained, channel)
+	}
+	ps.mu.Unlock()
+
+	return ps.subscribe(ctx, "UNSUBSCRIBE", channels, acked)
+}
+
+func (ps *PubSub) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	acked := patterns
+
+	ps.mu.Lock()
+	if len(patterns) == 0 {
+		acked = keys(ps.patterns)
+		ps.patterns = make(map[string]struct{})
+	}
+	removeAll(ps.patterns, patterns)
+	ps.mu.Unlock()
+
+	return ps.subscribe(ctx, "PUNSUBSCRIBE", patterns, acked)
+}
+
+func (ps *PubSub) SUnsubscribe(ctx context.Context, channels ...string) error {
+	acked := channels
+
+	ps.mu.Lock()
+	if len(channels) == 0 {
+		acked = keys(ps.shards)
+		ps.shards = make(map[string]struct{})
+	}
+	removeAll(ps.shards, channels)
+	ps.mu.Unlock()
+
+	return ps.subscribe(ctx, "SUNSUBSCRIBE", channels, acked)
+}
+
+// Close unsubscribes by closing the connection.
+func (ps *PubSub) Close() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.closed {
+		return ErrClosed
+	}
+	ps.closed = true
+	close(ps.done)
+	return ps.cn.close()
+}
+
+// ack is a subscribing command waiting for its replies, one for each of the
+// channels or patterns it names. The replies are told apart by their kind and
+// channel, so replies to another command or to the subscriptions made again
+// after a reconnect are not taken for its own.
+type ack struct {
+	kind string
+
+	// remaining counts the replies still expected for each channel, a
+	// channel given twice is replied to twice
+	remaining map[string]int
+
+	// all is set for the unsubscribing commands without arguments, which
+	// also complete on the reply with no channel sent when there is nothing
+	// to unsubscribe from, or on the one leaving no subscription
+	all bool
+
+	done chan error
+}
+
+func newAck(command string, acked []string, all bool) *ack {
+	a := &ack{
+		kind:      strings.ToLower(command),
+		remaining: make(map[string]int, len(acked)),
+		all:       all,
+		done:      make(chan error, 1),
+	}
+	for _, channel := range acked {
+		a.remaining[channel]++
+	}
+	return a
+}
+
+// match counts a reply of the given kind and reports whether it was the
+// last one expected.
+func (a *ack) match(kind string, channel, count any) bool {
+	if kind != a.kind {
+		return false
+	}
+
+	name, ok := channel.(string)
+	if ok {
+		if n := a.remaining[name]; n > 1 {
+			a.remaining[name] = n - 1
+		} else {
+			delete(a.remaining, name)
+		}
+	}
+
+	if a.all {
+		n, _ := count.(int64)
+		return !ok || n == 0 || len(a.remaining) == 0
+	}
+	return len(a.remaining) == 0
+}
+
+// subscribe sends a subscribing command and waits for the replies to the
+// channels or patterns in acked. When the connection is broken the error is
+// returned but the subscription is made once connected again.
+func (ps *PubSub) subscribe(ctx context.Context, command string, args, acked []string) error {
+	ps.subMu.Lock()
+	defer ps.subMu.Unlock()
+
+	a := newAck(command, acked, len(args) == 0)
+
+	ps.mu.Lock()
+	if ps.closed {
+		ps.mu.Unlock()
+		return ErrClosed
+	}
+	cn := ps.cn
+	ps.pending = a
+	ps.mu.Unlock()
+
+	defer func() {
+		ps.mu.Lock()
+		if ps.pending == a {
+			ps.pending = nil
+		}
+		ps.mu.Unlock()
+	}()
+
+	if err := cn.send(ctx, append([]any{command}, stringsToArgs(args)...)); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-a.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ps.done:
+		return ErrClosed
+	}
+}
+
+// settle ends the wait of the pending subscribing command, if any, with err.
+// It is called with mu held.
+func (ps *PubSub) settle(err error) {
+	if ps.pending != nil {
+		ps.pending.done <- err
+		ps.pending = nil
+	}
+}
+
+// run reads what the server sends until the subscriber is closed,
+// connecting again whenever the connection breaks.
+func (ps *PubSub) run() {
+	defer close(ps.msgs)
+
+	for {
+		ps.mu.Lock()
+		cn := ps.cn
+		ps.mu.Unlock()
+
+		reply, err := cn.receive(context.Background())
+		if err != nil {
+			// the command waited for may not have reached the server
+			ps.mu.Lock()
+			if ps.cn == cn {
+				ps.settle(err)
+			}
+			ps.mu.Unlock()
+
+			if !ps.reconnect() {
+				return
+			}
+			continue
+		}
+
+		msg := ps.handle(reply)
+		if msg == nil {
+			continue
+		}
+
+		select {
+		case ps.msgs <- msg:
+		case <-ps.done:
+			return
+		}
+	}
+}
+
+// handle returns the message a reply holds, replies to the subscribing
+// commands are matched against the one waiting for them.
+func (ps *PubSub) handle(reply any) *Message {
+	var fields []any
+	switch reply := reply.(type) {
+	case []any:
+		fields = reply
+	case resp.Push:
+		fields = reply
+	case resp.ReplyError:
+		// only the subscribing commands are sent, an error is the reply to
+		// the one waiting
+		ps.mu.Lock()
+		ps.settle(reply)
+		ps.mu.Unlock()
+		return nil
+	default:
+		return nil
+	}
+	if len(fields) < 3 {
+		return nil
+	}
+
+	kind, _ := fields[0].(string)
+	channel, _ := fields[1].(string)
+	switch kind {
+	case "message", "smessage":
+		payload, _ := fields[2].(string)
+		return &Message{Kind: kind, Channel: channel, Payload: payload}
+	case "pmessage":
+		if len(fields) < 4 {
+			return nil
+		}
+		pchannel, _ := fields[2].(string)
+		payload, _ := fields[3].(string)
+		return &Message{Kind: kind, Pattern: channel, Channel: pchannel, Payload: payload}
+	case "rmessage":
+		if len(fields) < 4 {
+			return nil
+		}
+		offset, _ := fields[2].(int64)
+		payload, _ := fields[3].(string)
+
+		ps.mu.Lock()
+		if _, ok := ps.retained[channel]; ok {
+			ps.retained[channel] = strconv.FormatInt(offset, 10)
+		}
+		ps.mu.Unlock()
+
+		return &Message{Kind: kind, Channel: channel, Offset: uint64(offset), Payload: payload}
+	case "subscribe", "psubscribe", "ssubscribe", "rsubscribe", "unsubscribe", "punsubscribe", "sunsubscribe":
+		ps.mu.Lock()
+		if ps.pending != nil && ps.pending.match(kind, fields[1], fields[2]) {
+			ps.settle(nil)
+		}
+		ps.mu.Unlock()
+	}
+	return nil
+}
+
+// reconnect replaces the broken connection and subscribes again, it returns
+// false once the subscriber is closed.
+func (ps *PubSub) reconnect() bool {
+	for attempt := 1; ; attempt++ {
+		ps.mu.Lock()
+		if ps.closed {
+			ps.mu.Unlock()
+			return false
+		}
+		ps.cn.close()
+		ps.mu.Unlock()
+
+		select {
+		case <-time.After(ps.c.backoff(attempt)):
+		case <-ps.done:
+			return false
+		}
+
+		cn, err := ps.c.dial(context.Background())
+		if err != nil {
+			continue
+		}
+		cn.readTimeout = 0
+
+		ps.mu.Lock()
+		if ps.closed {
+			ps.mu.Unlock()
+			cn.close()
+			return false
+		}
+		err = cn.send(context.Background(), ps.subscriptions()...)
+		if err == nil {
+			ps.cn = cn
+		}
+		ps.mu.Unlock()
+
+		if err == nil {
+			return true
+		}
+		cn.close()
+	}
+}
+
+// subscriptions returns the commands that make the current subscriptions,
+// it is called with mu held.
+func (ps *PubSub) subscriptions() [][]any {
+	var cmds [][]any
+	add := func(command string, args []string) {
+		if len(args) > 0 {
+			cmds = append(cmds, append([]any{command}, stringsToArgs(args)...))
+		}
+	}
+
+	add("SUBSCRIBE", keys(ps.channels))
+	add("PSUBSCRIBE", keys(ps.patterns))
+	add("SSUBSCRIBE", keys(ps.shards))
+
+	positions := make([]string, 0, 2*len(ps.retained))
+	for channel, position := range ps.retained {
+		positions = append(positions, channel, position)
+	}
+	add("RSUBSCRIBE", positions)
+
+	return cmds
+}
+
+func addAll(set map[string]struct{}, values []string) {
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+}
+
+func removeAll(set map[string]struct{}, values []string) {
+	for _, v := range values {
+		delete(set, v)
+	}
+}
+
+func keys(set map[string]struct{}) []string {
+	res := make([]string, 0, len(set))
+	for k := range set {
+		res = append(res, k)
+	}
+	return res
+}
+
+func stringsToArgs(values []string) []any {
+	args := make([]any, 0, len(values))
+	for _, v := range values {
+		args = append(args, v)
+	}
+	return args
+}
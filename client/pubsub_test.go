@@ -0,0 +1,53 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/client"
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubSubAcks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	socket := startServer(t)
+	c := newClient(t, socket)
+
+	ps, err := c.Subscribe(ctx, "a", "b", "c")
+	require.NoError(t, err)
+	defer ps.Close()
+	require.NoError(t, ps.PSubscribe(ctx, "p.*"))
+
+	// every channel is unsubscribed from once it returns, the replies for
+	// the others are not taken for the ones of the next command
+	require.NoError(t, ps.Unsubscribe(ctx))
+	numSub, err := c.PubSubNumSub(ctx, "a", "b", "c")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 0, "b": 0, "c": 0}, numSub)
+
+	require.NoError(t, ps.Subscribe(ctx, "d", "d"))
+	n, err := c.Publish(ctx, "d", "hi")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, &client.Message{Kind: "message", Channel: "d", Payload: "hi"}, receive(t, ps))
+
+	// nothing left to unsubscribe from gets a single reply
+	require.NoError(t, ps.PUnsubscribe(ctx))
+	require.NoError(t, ps.PUnsubscribe(ctx))
+	require.NoError(t, ps.SUnsubscribe(ctx))
+
+	// an error reply ends the wait
+	err = ps.PSubscribe(ctx)
+	var replyErr resp.ReplyError
+	assert.ErrorAs(t, err, &replyErr)
+
+	require.NoError(t, ps.Unsubscribe(ctx, "d"))
+	n, err = c.Publish(ctx, "d", "gone")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aelnahas/sider/resp"
+)
+
+// ErrTxFailed is returned when EXEC did not run the transaction because a
+// watched key changed, it can be tried again.
+var ErrTxFailed = errors.New("transaction failed, a watched key changed")
+
+// Tx is a transaction on a connection of its own. Commands run with Do are
+// sent right away, to read the watched keys, while the ones queued with Queue
+// are sent between MULTI and EXEC.
+type Tx struct {
+	cmdable
+
+	cn    *conn
+	queue [][]any
+}
+
+// Tx watches keys, if any, and runs fn, then runs the commands it queued in
+// a MULTI/EXEC block and returns their replies. Errors of the queued commands
+// are resp.ReplyError values in the replies. ErrTxFailed is returned when a
+// watched key changed since it was watched. Nothing is sent when fn returns
+// an error, which is returned as is.
+//
+// Transactions are not retried as fn may have read stale values.
+func (c *Client) Tx(ctx context.Context, fn func(tx *Tx) error, watch ...string) ([]any, error) {
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.pool.put(cn)
+
+	tx := &Tx{cn: cn}
+	tx.cmdable = tx.Do
+
+	if len(watch) > 0 {
+		args := append([]any{"WATCH"}, stringsToArgs(watch)...)
+		if _, err := tx.Do(ctx, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fn(tx); err != nil {
+		if len(watch) > 0 {
+			_, _ = tx.Do(ctx, "UNWATCH")
+		}
+		return nil, err
+	}
+
+	return tx.exec(ctx)
+}
+
+// Do runs a command right away, outside of the transaction.
+func (tx *Tx) Do(ctx context.Context, args ...any) (any, error) {
+	reply, err := tx.cn.roundTrip(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return replyErr(reply)
+}
+
+// Queue adds a command to the transaction.
+func (tx *Tx) Queue(args ...any) {
+	tx.queue = append(tx.queue, args)
+}
+
+func (tx *Tx) exec(ctx context.Context) ([]any, error) {
+	cmds := make([][]any, 0, len(tx.queue)+2)
+	cmds = append(cmds, []any{"MULTI"})
+	cmds = append(cmds, tx.queue...)
+	cmds = append(cmds, []any{"EXEC"})
+
+	replies, err := tx.cn.pipeline(ctx, cmds)
+	if err != nil {
+		return nil, err
+	}
+
+	// MULTI and the queued commands only reply with OK and QUEUED, unless
+	// a command could not be queued in which case EXEC says so
+	reply := replies[len(replies)-1]
+	switch reply := reply.(type) {
+	case nil:
+		return nil, ErrTxFailed
+	case resp.ReplyError:
+		return nil, reply
+	case []any:
+		return reply, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply to EXEC: %v", reply)
+	}
+}
@@ -18,7 +18,6 @@ const (
 func Execute() error {
 
 	daemon := false
-	var port uint
 
 	app := &cli.App{
 		Name:                 "sider",
@@ -29,6 +28,9 @@ func Execute() error {
 				Name:    "start",
 				Aliases: []string{"s"},
 				Usage:   "starts sider service",
+				// flags other than daemon are named after the setting they
+				// override in the config file
+				ArgsUsage: "[config file]",
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:        "daemon",
@@ -38,11 +40,50 @@ func Execute() error {
 						Destination: &daemon,
 					},
 					&cli.UintFlag{
-						Name:        "Port",
-						Value:       server.ConfigDefaultPort,
-						Aliases:     []string{"p"},
-						Usage:       "set port",
-						Destination: &port,
+						Name:    "port",
+						Value:   server.ConfigDefaultPort,
+						Aliases: []string{"p", "Port"},
+						Usage:   "set port",
+					},
+					&cli.StringFlag{
+						Name:  "requirepass",
+						Usage: "set the password of the default user",
+					},
+					&cli.StringFlag{
+						Name:  "aclfile",
+						Usage: "load users from an acl file",
+					},
+					&cli.UintFlag{
+						Name:  "tls-port",
+						Usage: "accept tls connections on this port",
+					},
+					&cli.StringFlag{
+						Name:  "tls-cert-file",
+						Usage: "server certificate",
+					},
+					&cli.StringFlag{
+						Name:  "tls-key-file",
+						Usage: "server private key",
+					},
+					&cli.StringFlag{
+						Name:  "tls-ca-cert-file",
+						Usage: "ca certificate client certificates are verified with",
+					},
+					&cli.StringFlag{
+						Name:  "tls-auth-clients",
+						Usage: "require client certificates: yes, no or optional",
+					},
+					&cli.StringFlag{
+						Name:  "tls-auth-clients-user",
+						Usage: "set to CN to authenticate clients as the acl user named after their certificate",
+					},
+					&cli.StringFlag{
+						Name:  "unixsocket",
+						Usage: "also listen on a unix socket at this path",
+					},
+					&cli.StringFlag{
+						Name:  "unixsocketperm",
+						Usage: "set the permissions of the unix socket, in octal",
 					},
 				},
 				Action: func(c *cli.Context) error {
@@ -59,7 +100,22 @@ func Execute() error {
 						daemon = false
 						return nil
 					}
-					conn := server.NewConnection(server.WithPort(port))
+
+					var overrides []string
+					for _, flag := range c.Command.Flags {
+						name := flag.Names()[0]
+						if name == "daemon" || !c.IsSet(name) {
+							continue
+						}
+						overrides = append(overrides, name, fmt.Sprint(c.Value(name)))
+					}
+
+					config, err := server.LoadConfig(c.Args().First(), overrides...)
+					if err != nil {
+						return err
+					}
+
+					conn := server.NewConnection(server.WithConfig(config))
 					return conn.Start()
 
 				},
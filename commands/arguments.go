@@ -0,0 +1,110 @@
+package commands
+
+import "strings"
+
+// The grammars of the commands that have one, named after the names redis
+// gives their arguments.
+
+func pureToken(name, token string) Arg {
+	return Arg{Name: name, Type: TypePureToken, Token: token}
+}
+
+func oneOf(name string, tokens ...string) Arg {
+	arg := Arg{Name: name, Type: TypeOneOf}
+	for _, token := range tokens {
+		arg.Args = append(arg.Args, pureToken(strings.ToLower(token), token))
+	}
+	return arg
+}
+
+func optional(arg Arg) Arg {
+	arg.Optional = true
+	return arg
+}
+
+var (
+	setArgs = []Arg{
+		{Name: "key", Type: TypeKey},
+		{Name: "value", Type: TypeString},
+		optional(oneOf("condition", "NX", "XX")),
+		optional(pureToken("get", "GET")),
+		{Name: "expiration", Type: TypeOneOf, Optional: true, Args: []Arg{
+			{Name: "seconds", Type: TypeInteger, Token: "EX"},
+			{Name: "milliseconds", Type: TypeInteger, Token: "PX"},
+			{Name: "unix-time-seconds", Type: TypeUnixTime, Token: "EXAT"},
+			{Name: "unix-time-milliseconds", Type: TypeUnixTime, Token: "PXAT"},
+			pureToken("keepttl", "KEEPTTL"),
+		}},
+	}
+
+	clientTrackingArgs = []Arg{
+		oneOf("status", "ON", "OFF"),
+		{Name: "client-id", Type: TypeInteger, Token: "REDIRECT", Optional: true},
+		{Name: "prefix", Type: TypeString, Token: "PREFIX", Optional: true, Multiple: true, MultipleToken: true},
+		optional(pureToken("bcast", "BCAST")),
+		optional(pureToken("optin", "OPTIN")),
+		optional(pureToken("optout", "OPTOUT")),
+		optional(pureToken("noloop", "NOLOOP")),
+	}
+
+	clientListArgs = []Arg{
+		{Name: "client-type", Type: TypeString, Token: "TYPE", Optional: true},
+		{Name: "client-id", Type: TypeInteger, Token: "ID", Optional: true, Multiple: true},
+	}
+
+	// clientKillArgs is either the address of the client, the old format, or
+	// any number of filters.
+	clientKillArgs = []Arg{
+		{Name: "filter", Type: TypeOneOf, Args: []Arg{
+			{Name: "old-format", Type: TypeString},
+			{Name: "new-format", Type: TypeOneOf, Multiple: true, Args: []Arg{
+				{Name: "client-id", Type: TypeInteger, Token: "ID"},
+				{Name: "client-type", Type: TypeString, Token: "TYPE"},
+				{Name: "username", Type: TypeString, Token: "USER"},
+				{Name: "addr", Type: TypeString, Token: "ADDR"},
+				{Name: "laddr", Type: TypeString, Token: "LADDR"},
+				{Name: "skipme", Type: TypeOneOf, Token: "SKIPME", Args: oneOf("", "YES", "NO").Args},
+				{Name: "maxage", Type: TypeInteger, Token: "MAXAGE"},
+			}},
+		}},
+	}
+
+	clientPauseArgs = []Arg{
+		{Name: "timeout", Type: TypeInteger},
+		optional(oneOf("mode", "WRITE", "ALL")),
+	}
+
+	pubsubRetainArgs = []Arg{
+		{Name: "channel", Type: TypeString},
+		{Name: "count", Type: TypeInteger, Token: "MAXLEN", Optional: true},
+		{Name: "milliseconds", Type: TypeInteger, Token: "MAXAGE", Optional: true},
+	}
+
+	configGetArgs = []Arg{
+		{Name: "parameter", Type: TypeString, Multiple: true},
+	}
+
+	configSetArgs = []Arg{
+		{Name: "data", Type: TypeBlock, Multiple: true, Args: []Arg{
+			{Name: "parameter", Type: TypeString},
+			{Name: "value", Type: TypeString},
+		}},
+	}
+
+	slowlogGetArgs = []Arg{
+		{Name: "count", Type: TypeInteger, Optional: true},
+	}
+
+	commandListArgs = []Arg{
+		{Name: "filterby", Type: TypeOneOf, Token: "FILTERBY", Optional: true, Args: []Arg{
+			{Name: "module-name", Type: TypeString, Token: "MODULE"},
+			{Name: "category", Type: TypeString, Token: "ACLCAT"},
+			{Name: "pattern", Type: TypePattern, Token: "PATTERN"},
+		}},
+	}
+
+	commandGetKeysArgs = []Arg{
+		{Name: "command", Type: TypeString},
+		{Name: "arg", Type: TypeString, Optional: true, Multiple: true},
+	}
+)
@@ -0,0 +1,268 @@
+// Package commands describes every command sider implements: its arity,
+// flags, ACL categories, where its keys are and its documentation. The
+// parser, the ACLs and the COMMAND command all read this table.
+package commands
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Command flags, with the names redis uses in COMMAND INFO.
+const (
+	FlagWrite           = "write"
+	FlagReadOnly        = "readonly"
+	FlagDenyOOM         = "denyoom"
+	FlagAdmin           = "admin"
+	FlagPubSub          = "pubsub"
+	FlagNoScript        = "noscript"
+	FlagLoading         = "loading"
+	FlagStale           = "stale"
+	FlagSkipMonitor     = "skip_monitor"
+	FlagSkipSlowlog     = "skip_slowlog"
+	FlagFast            = "fast"
+	FlagNoAuth          = "no_auth"
+	FlagNoMulti         = "no_multi"
+	FlagAllowBusy       = "allow_busy"
+	FlagMayReplicate    = "may_replicate"
+	FlagNoMandatoryKeys = "no_mandatory_keys"
+	FlagMovableKeys     = "movablekeys"
+)
+
+// Key spec flags, with the names redis uses in COMMAND INFO.
+const (
+	KeyRO            = "RO"
+	KeyRW            = "RW"
+	KeyRM            = "RM"
+	KeyAccess        = "access"
+	KeyUpdate        = "update"
+	KeyDelete        = "delete"
+	KeyNotKey        = "not_key"
+	KeyVariableFlags = "variable_flags"
+)
+
+// Command describes a command or, when its name holds a '|', a subcommand.
+type Command struct {
+	// Name is lower case, subcommands are named container|subcommand.
+	Name string
+
+	// Arity is the number of arguments including the command name, a
+	// negative arity is the minimum number of arguments.
+	Arity int
+
+	Flags      []string
+	Categories []string
+	KeySpecs   []KeySpec
+
+	// Arguments is the grammar the arguments are checked against, commands
+	// without one only have their arity checked.
+	Arguments []Arg
+
+	Subcommands []*Command
+
+	Group   string
+	Summary string
+	Since   string
+}
+
+// KeySpec tells where the keys of a command are. The search begins at Index,
+// where the command name is at 0. From there the keys either span a range
+// ending LastKey arguments further, or counting from the end when negative,
+// or when KeyNum is set their number is read from the argument at
+// Index+KeyNumIdx and they start at Index+FirstKey.
+type KeySpec struct {
+	Flags []string
+	Index int
+
+	LastKey int
+	Step    int
+
+	KeyNum    bool
+	KeyNumIdx int
+	FirstKey  int
+}
+
+var byName = make(map[string]*Command)
+
+// init indexes the table, subcommands are named after their container and
+// share its group and ACL categories.
+func init() {
+	for _, cmd := range table {
+		byName[cmd.Name] = cmd
+		for _, sub := range cmd.Subcommands {
+			sub.Name = cmd.Name + "|" + sub.Name
+			sub.Group = cmd.Group
+			if sub.Categories == nil {
+				sub.Categories = cmd.Categories
+			}
+		}
+	}
+}
+
+// Lookup returns the command called name, in any case.
+func Lookup(name string) (*Command, bool) {
+	cmd, ok := byName[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// All returns every command ordered by name.
+func All() []*Command {
+	all := make([]*Command, 0, len(byName))
+	for _, cmd := range byName {
+		all = append(all, cmd)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// Resolve returns the command called name or, for containers, the subcommand
+// args start with when there is one.
+func Resolve(name string, args []string) (*Command, bool) {
+	cmd, ok := Lookup(name)
+	if !ok || len(cmd.Subcommands) == 0 || len(args) == 0 {
+		return cmd, ok
+	}
+
+	if sub, ok := cmd.Subcommand(args[0]); ok {
+		return sub, true
+	}
+	return cmd, true
+}
+
+// HasFlag reports whether the command called name has flag.
+func HasFlag(name, flag string) bool {
+	cmd, ok := Lookup(name)
+	return ok && cmd.HasFlag(flag)
+}
+
+// IsContainer reports whether the command called name has subcommands.
+func IsContainer(name string) bool {
+	cmd, ok := Lookup(name)
+	return ok && len(cmd.Subcommands) > 0
+}
+
+// Keys returns the keys the command called name accesses given its
+// arguments, without the command name.
+func Keys(name string, args []string) []string {
+	cmd, ok := Lookup(name)
+	if !ok {
+		return nil
+	}
+
+	keys, _ := cmd.Keys(append([]string{cmd.Name}, args...))
+	return keys
+}
+
+func (c *Command) HasFlag(flag string) bool {
+	for _, f := range c.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Command) InCategory(category string) bool {
+	for _, cat := range c.Categories {
+		if cat == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Subcommand returns the subcommand called name, in any case.
+func (c *Command) Subcommand(name string) (*Command, bool) {
+	full := c.Name + "|" + strings.ToLower(name)
+	for _, sub := range c.Subcommands {
+		if sub.Name == full {
+			return sub, true
+		}
+	}
+	return nil, false
+}
+
+// CheckArity reports whether n arguments, including the command name, are
+// valid for the command.
+func (c *Command) CheckArity(n int) bool {
+	if c.Arity < 0 {
+		return n >= -c.Arity
+	}
+	return n == c.Arity
+}
+
+// MovableKeys reports whether the position of the keys depends on the
+// arguments, in which case the first and last key can not tell where they
+// are.
+func (c *Command) MovableKeys() bool {
+	for _, spec := range c.KeySpecs {
+		if spec.KeyNum {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyRange returns the first key, last key and step of the command the way
+// redis reported them before key specs, all 0 when the keys are movable.
+func (c *Command) KeyRange() (first, last, step int) {
+	if len(c.KeySpecs) == 0 || c.MovableKeys() {
+		return 0, 0, 0
+	}
+
+	spec := c.KeySpecs[0]
+	last = spec.Index + spec.LastKey
+	if spec.LastKey < 0 {
+		last = spec.LastKey
+	}
+	return spec.Index, last, spec.Step
+}
+
+// Keys returns the keys in argv, the full command with its name. Arguments
+// that are not keys, like the shard channels of SSUBSCRIBE, are left out.
+// ok is false when argv does not hold as many keys as it claims to.
+func (c *Command) Keys(argv []string) (keys []string, ok bool) {
+	for _, spec := range c.KeySpecs {
+		if spec.Index >= len(argv) || spec.hasFlag(KeyNotKey) {
+			continue
+		}
+
+		first, last, step := spec.Index, 0, spec.Step
+		if spec.KeyNum {
+			n, err := strconv.Atoi(argv[spec.Index+spec.KeyNumIdx])
+			if err != nil || n < 0 {
+				return nil, false
+			}
+
+			first = spec.Index + spec.FirstKey
+			last = first + (n-1)*step
+		} else if spec.LastKey < 0 {
+			last = len(argv) + spec.LastKey
+		} else {
+			last = first + spec.LastKey
+		}
+
+		if last >= len(argv) {
+			return nil, false
+		}
+
+		for i := first; i <= last; i += step {
+			keys = append(keys, argv[i])
+		}
+	}
+
+	return keys, true
+}
+
+func (s KeySpec) hasFlag(flag string) bool {
+	for _, f := range s.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeys(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		argv     []string
+		expected []string
+		ok       bool
+	}{
+		{
+			desc:     "single key",
+			argv:     []string{"SET", "foo", "bar", "EX", "10"},
+			expected: []string{"foo"},
+			ok:       true,
+		},
+		{
+			desc:     "keys up to the last argument",
+			argv:     []string{"DEL", "a", "b", "c"},
+			expected: []string{"a", "b", "c"},
+			ok:       true,
+		},
+		{
+			desc:     "number of keys given as an argument",
+			argv:     []string{"EVAL", "return 1", "2", "a", "b", "arg"},
+			expected: []string{"a", "b"},
+			ok:       true,
+		},
+		{
+			desc: "no keys",
+			argv: []string{"EVAL", "return 1", "0", "arg"},
+			ok:   true,
+		},
+		{
+			desc: "more keys than arguments",
+			argv: []string{"FCALL", "fn", "3", "a"},
+		},
+		{
+			desc: "invalid number of keys",
+			argv: []string{"FCALL", "fn", "many", "a"},
+		},
+		{
+			desc: "shard channels are not keys",
+			argv: []string{"SPUBLISH", "channel", "message"},
+			ok:   true,
+		},
+		{
+			desc: "command without keys",
+			argv: []string{"PING"},
+			ok:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cmd, found := commands.Lookup(tc.argv[0])
+			assert.True(t, found)
+
+			keys, ok := cmd.Keys(tc.argv)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, keys)
+		})
+	}
+}
+
+func TestCheckArity(t *testing.T) {
+	get, _ := commands.Lookup("get")
+	assert.False(t, get.CheckArity(1))
+	assert.True(t, get.CheckArity(2))
+	assert.False(t, get.CheckArity(3))
+
+	set, _ := commands.Lookup("set")
+	assert.False(t, set.CheckArity(2))
+	assert.True(t, set.CheckArity(3))
+	assert.True(t, set.CheckArity(5))
+}
+
+func TestKeyRange(t *testing.T) {
+	testCases := []struct {
+		name              string
+		first, last, step int
+		movable           bool
+	}{
+		{name: "get", first: 1, last: 1, step: 1},
+		{name: "del", first: 1, last: -1, step: 1},
+		{name: "eval", movable: true},
+		{name: "ping"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd, _ := commands.Lookup(tc.name)
+			first, last, step := cmd.KeyRange()
+			assert.Equal(t, []int{tc.first, tc.last, tc.step}, []int{first, last, step})
+			assert.Equal(t, tc.movable, cmd.MovableKeys())
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	cmd, ok := commands.Resolve("CLIENT", []string{"Kill", "ID", "1"})
+	assert.True(t, ok)
+	assert.Equal(t, "client|kill", cmd.Name)
+	assert.Equal(t, "connection", cmd.Group)
+	assert.True(t, cmd.InCategory("admin"))
+
+	cmd, ok = commands.Resolve("CLIENT", []string{"missing"})
+	assert.True(t, ok)
+	assert.Equal(t, "client", cmd.Name)
+
+	_, ok = commands.Resolve("missing", nil)
+	assert.False(t, ok)
+}
+
+func TestTable(t *testing.T) {
+	for _, cmd := range commands.All() {
+		assert.NotZero(t, cmd.Arity, cmd.Name)
+		assert.NotEmpty(t, cmd.Categories, cmd.Name)
+		assert.NotEmpty(t, cmd.Summary, cmd.Name)
+
+		for _, sub := range cmd.Subcommands {
+			assert.False(t, sub.CheckArity(1), "%s: subcommands take at least the container and their name", sub.Name)
+			assert.NotEmpty(t, sub.Flags, "%s: subcommands have their own flags", sub.Name)
+		}
+
+		// only the commands that change the mode of the connection can not
+		// be queued
+		if cmd.HasFlag(commands.FlagNoMulti) {
+			assert.Contains(t, []string{"multi", "watch", "monitor", "subscribe", "unsubscribe", "psubscribe",
+				"punsubscribe", "ssubscribe", "sunsubscribe", "rsubscribe"}, cmd.Name)
+		}
+	}
+}
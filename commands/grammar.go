@@ -0,0 +1,528 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Argument types, with the names redis uses in COMMAND DOCS.
+const (
+	TypeKey       = "key"
+	TypeString    = "string"
+	TypeInteger   = "integer"
+	TypeDouble    = "double"
+	TypePattern   = "pattern"
+	TypeUnixTime  = "unix-time"
+	TypePureToken = "pure-token"
+	TypeOneOf     = "oneof"
+	TypeBlock     = "block"
+)
+
+var (
+	ErrSyntax     = errors.New("ERR syntax error")
+	ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+	ErrNotFloat   = errors.New("ERR value is not a valid float")
+)
+
+// ArityError is returned for a command given the wrong number of arguments.
+type ArityError struct {
+	Name string
+}
+
+func (e ArityError) Error() string {
+	return fmt.Sprintf("ERR wrong number of arguments for '%s' command", e.Name)
+}
+
+// UnknownSubcommandError is returned for a subcommand the container does not
+// have.
+type UnknownSubcommandError struct {
+	Container  string
+	Subcommand string
+}
+
+func (e UnknownSubcommandError) Error() string {
+	return fmt.Sprintf("ERR unknown subcommand '%s'. Try %s HELP.", e.Subcommand, strings.ToUpper(e.Container))
+}
+
+// Arg is an argument in the grammar of a command, the way COMMAND DOCS
+// describes them in redis.
+type Arg struct {
+	Name string
+	Type string
+
+	// Token is the literal that comes before the value, for pure tokens it
+	// is the whole argument.
+	Token string
+
+	Optional bool
+	Multiple bool
+
+	// MultipleToken repeats the token before every value of a multiple
+	// argument, like GET pattern GET pattern in SORT.
+	MultipleToken bool
+
+	// Args are the alternatives of a oneof or the arguments of a block.
+	Args []Arg
+}
+
+// Args holds the arguments a command was given, by their name in its grammar.
+// A oneof holds the name of the alternative that was given, and a block holds
+// one Args for every time it was given.
+type Args struct {
+	values map[string][]string
+	blocks map[string][]*Args
+}
+
+func newArgs() *Args {
+	return &Args{values: make(map[string][]string), blocks: make(map[string][]*Args)}
+}
+
+// Has reports whether the argument called name was given.
+func (a *Args) Has(name string) bool {
+	return len(a.values[name]) > 0 || len(a.blocks[name]) > 0
+}
+
+// String returns the first value given for name, or "" when it was not.
+func (a *Args) String(name string) string {
+	if values := a.values[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Strings returns every value given for name.
+func (a *Args) Strings(name string) []string {
+	return a.values[name]
+}
+
+// Int returns the first value given for an integer argument, 0 when it was not
+// given.
+func (a *Args) Int(name string) int64 {
+	n, _ := strconv.ParseInt(a.String(name), 10, 64)
+	return n
+}
+
+// Float returns the first value given for a double argument, 0 when it was not
+// given.
+func (a *Args) Float(name string) float64 {
+	f, _ := strconv.ParseFloat(a.String(name), 64)
+	return f
+}
+
+// Block returns the first time the block called name was given, nil when it
+// was not.
+func (a *Args) Block(name string) *Args {
+	if blocks := a.blocks[name]; len(blocks) > 0 {
+		return blocks[0]
+	}
+	return nil
+}
+
+// Blocks returns every time the block called name was given.
+func (a *Args) Blocks(name string) []*Args {
+	return a.blocks[name]
+}
+
+// Parse checks argv, the full command with its name and subcommand, against
+// the arity and grammar of the command. The arguments are returned by name
+// for commands that have a grammar, nil otherwise.
+func Parse(argv []string) (*Args, error) {
+	cmd, ok := Lookup(argv[0])
+	if !ok {
+		return nil, fmt.Errorf("ERR unknown command '%s'", argv[0])
+	}
+
+	if len(cmd.Subcommands) > 0 {
+		if len(argv) < 2 {
+			return nil, ArityError{Name: cmd.Name}
+		}
+
+		sub, ok := cmd.Subcommand(argv[1])
+		if !ok {
+			return nil, UnknownSubcommandError{Container: cmd.Name, Subcommand: argv[1]}
+		}
+		cmd = sub
+	}
+
+	if !cmd.CheckArity(len(argv)) {
+		return nil, ArityError{Name: cmd.Name}
+	}
+	if cmd.Arguments == nil {
+		return nil, nil
+	}
+
+	start := 1
+	if strings.Contains(cmd.Name, "|") {
+		start = 2
+	}
+
+	m := &matcher{argv: argv}
+	if !m.seq(cmd.Arguments, start, m.end) {
+		if m.err != nil {
+			return nil, m.err
+		}
+		return nil, ErrSyntax
+	}
+
+	return m.build(), nil
+}
+
+const (
+	eventValue = iota
+	eventOpen
+	eventClose
+)
+
+type event struct {
+	kind  int
+	name  string
+	value string
+}
+
+// matcher matches arguments against a grammar by backtracking, every match
+// takes a continuation that matches whatever comes after it. What matched is
+// recorded as events, which are dropped when backtracking.
+type matcher struct {
+	argv   []string
+	events []event
+
+	// err is the error of the value that failed furthest into argv, like an
+	// integer that is not one.
+	err    error
+	errPos int
+}
+
+func (m *matcher) end(pos int) bool {
+	return pos == len(m.argv)
+}
+
+func (m *matcher) emit(kind int, name, value string) {
+	m.events = append(m.events, event{kind: kind, name: name, value: value})
+}
+
+func (m *matcher) fail(pos int, err error) {
+	if m.err == nil || pos >= m.errPos {
+		m.err, m.errPos = err, pos
+	}
+}
+
+// seq matches args in order, except that a run of optional arguments which
+// start with a token can be given in any order, like the options of SET.
+func (m *matcher) seq(args []Arg, pos int, k func(int) bool) bool {
+	if len(args) == 0 {
+		return k(pos)
+	}
+
+	if isOption(args[0]) {
+		n := 1
+		for n < len(args) && isOption(args[n]) {
+			n++
+		}
+
+		return m.options(args[:n], make([]bool, n), pos, func(p int) bool {
+			return m.seq(args[n:], p, k)
+		})
+	}
+
+	arg := args[0]
+	if arg.Optional {
+		mark := len(m.events)
+		if m.repeat(arg, pos, true, func(p int) bool { return m.seq(args[1:], p, k) }) {
+			return true
+		}
+		m.events = m.events[:mark]
+		return m.seq(args[1:], pos, k)
+	}
+
+	return m.repeat(arg, pos, true, func(p int) bool { return m.seq(args[1:], p, k) })
+}
+
+// options matches any of opts that has not been given yet, or that is
+// multiple, until none of them is next.
+func (m *matcher) options(opts []Arg, given []bool, pos int, k func(int) bool) bool {
+	for i, opt := range opts {
+		if given[i] && !opt.Multiple || !m.startsWith(opt, pos) {
+			continue
+		}
+
+		mark, was := len(m.events), given[i]
+		given[i] = true
+		if m.repeat(opt, pos, true, func(p int) bool { return m.options(opts, given, p, k) }) {
+			return true
+		}
+		given[i] = was
+		m.events = m.events[:mark]
+	}
+
+	return k(pos)
+}
+
+// repeat matches arg once and, when it is multiple, as many more times as it
+// can. The token of a multiple argument is only repeated with MultipleToken.
+func (m *matcher) repeat(arg Arg, pos int, first bool, k func(int) bool) bool {
+	if arg.Multiple && isPlain(arg) {
+		return m.repeatPlain(arg, pos, first, k)
+	}
+
+	return m.one(arg, pos, first || arg.MultipleToken, func(p int) bool {
+		if arg.Multiple && p > pos {
+			mark := len(m.events)
+			if m.repeat(arg, p, false, k) {
+				return true
+			}
+			m.events = m.events[:mark]
+		}
+		return k(p)
+	})
+}
+
+// repeatPlain is repeat for a plain argument. It can be given as many times
+// as argv is long, like the parameters of CONFIG GET, so it is matched in a
+// loop rather than by recursion, then backs off from the most occurrences
+// until k matches.
+func (m *matcher) repeatPlain(arg Arg, pos int, first bool, k func(int) bool) bool {
+	mark := len(m.events)
+
+	var ends, marks []int
+	for withToken := first || arg.MultipleToken; ; withToken = arg.MultipleToken {
+		p, ok := m.step(arg, pos, withToken)
+		if !ok {
+			break
+		}
+		pos = p
+		ends = append(ends, p)
+		marks = append(marks, len(m.events))
+	}
+
+	for i := len(ends) - 1; i >= 0; i-- {
+		m.events = m.events[:marks[i]]
+		if k(ends[i]) {
+			return true
+		}
+	}
+	m.events = m.events[:mark]
+	return false
+}
+
+// step matches a single occurrence of a plain argument, with its token when
+// withToken is set, and returns where it ends.
+func (m *matcher) step(arg Arg, pos int, withToken bool) (int, bool) {
+	mark := len(m.events)
+
+	if arg.Token != "" && withToken {
+		if pos >= len(m.argv) || !strings.EqualFold(m.argv[pos], arg.Token) {
+			return pos, false
+		}
+		pos++
+
+		if arg.Type == TypePureToken {
+			m.emit(eventValue, arg.Name, arg.Token)
+			return pos, true
+		}
+	}
+
+	switch arg.Type {
+	case TypeOneOf:
+		for _, alt := range arg.Args {
+			if !m.startsWith(alt, pos) {
+				continue
+			}
+
+			m.emit(eventValue, arg.Name, alt.Name)
+			p, ok := m.step(alt, pos, true)
+			if !ok {
+				m.events = m.events[:mark]
+			}
+			return p, ok
+		}
+		return pos, false
+	case TypeBlock:
+		m.emit(eventOpen, arg.Name, "")
+		for _, a := range arg.Args {
+			p, ok := m.step(a, pos, true)
+			if !ok {
+				m.events = m.events[:mark]
+				return pos, false
+			}
+			pos = p
+		}
+		m.emit(eventClose, arg.Name, "")
+		return pos, true
+	}
+
+	if pos >= len(m.argv) {
+		return pos, false
+	}
+	if err := checkValue(arg.Type, m.argv[pos]); err != nil {
+		m.fail(pos, err)
+		return pos, false
+	}
+
+	m.emit(eventValue, arg.Name, m.argv[pos])
+	return pos + 1, true
+}
+
+// one matches a single occurrence of arg, with its token when withToken is set.
+func (m *matcher) one(arg Arg, pos int, withToken bool, k func(int) bool) bool {
+	mark := len(m.events)
+
+	if arg.Token != "" && withToken {
+		if pos >= len(m.argv) || !strings.EqualFold(m.argv[pos], arg.Token) {
+			return false
+		}
+		pos++
+
+		if arg.Type == TypePureToken {
+			m.emit(eventValue, arg.Name, arg.Token)
+			if k(pos) {
+				return true
+			}
+			m.events = m.events[:mark]
+			return false
+		}
+	}
+
+	switch arg.Type {
+	case TypeOneOf:
+		for _, alt := range arg.Args {
+			m.emit(eventValue, arg.Name, alt.Name)
+			if m.repeat(alt, pos, true, k) {
+				return true
+			}
+			m.events = m.events[:mark]
+		}
+		return false
+	case TypeBlock:
+		m.emit(eventOpen, arg.Name, "")
+		ok := m.seq(arg.Args, pos, func(p int) bool {
+			m.emit(eventClose, arg.Name, "")
+			return k(p)
+		})
+		if !ok {
+			m.events = m.events[:mark]
+		}
+		return ok
+	}
+
+	if pos >= len(m.argv) {
+		m.events = m.events[:mark]
+		return false
+	}
+
+	if err := checkValue(arg.Type, m.argv[pos]); err != nil {
+		m.fail(pos, err)
+		m.events = m.events[:mark]
+		return false
+	}
+
+	m.emit(eventValue, arg.Name, m.argv[pos])
+	if k(pos + 1) {
+		return true
+	}
+	m.events = m.events[:mark]
+	return false
+}
+
+// startsWith reports whether arg, an option, is the next argument.
+func (m *matcher) startsWith(arg Arg, pos int) bool {
+	if pos >= len(m.argv) {
+		return false
+	}
+
+	switch {
+	case arg.Token != "":
+		return strings.EqualFold(m.argv[pos], arg.Token)
+	case arg.Type == TypeOneOf:
+		for _, alt := range arg.Args {
+			if m.startsWith(alt, pos) {
+				return true
+			}
+		}
+	case arg.Type == TypeBlock:
+		return m.startsWith(arg.Args[0], pos)
+	}
+	return false
+}
+
+// build turns the recorded events into Args.
+func (m *matcher) build() *Args {
+	stack := []*Args{newArgs()}
+	for _, e := range m.events {
+		top := stack[len(stack)-1]
+		switch e.kind {
+		case eventValue:
+			top.values[e.name] = append(top.values[e.name], e.value)
+		case eventOpen:
+			block := newArgs()
+			top.blocks[e.name] = append(top.blocks[e.name], block)
+			stack = append(stack, block)
+		case eventClose:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return stack[0]
+}
+
+// isPlain reports whether arg can be matched without backtracking into it: a
+// value, a block of plain arguments that are all given once, or a oneof of
+// plain alternatives that are told apart by their token.
+func isPlain(arg Arg) bool {
+	switch arg.Type {
+	case TypeOneOf:
+		for _, alt := range arg.Args {
+			if alt.Token == "" || alt.Optional || alt.Multiple || !isPlain(alt) {
+				return false
+			}
+		}
+		return len(arg.Args) > 0
+	case TypeBlock:
+		for _, a := range arg.Args {
+			if a.Optional || a.Multiple || !isPlain(a) {
+				return false
+			}
+		}
+		return len(arg.Args) > 0
+	}
+	return true
+}
+
+// isOption reports whether arg is optional and always starts with a token,
+// so it can be told apart from the arguments around it in any order.
+func isOption(arg Arg) bool {
+	return arg.Optional && startsWithToken(arg)
+}
+
+func startsWithToken(arg Arg) bool {
+	switch {
+	case arg.Token != "":
+		return true
+	case arg.Type == TypeOneOf:
+		for _, alt := range arg.Args {
+			if !startsWithToken(alt) {
+				return false
+			}
+		}
+		return len(arg.Args) > 0
+	case arg.Type == TypeBlock:
+		return len(arg.Args) > 0 && !arg.Args[0].Optional && startsWithToken(arg.Args[0])
+	}
+	return false
+}
+
+func checkValue(typ, value string) error {
+	switch typ {
+	case TypeInteger, TypeUnixTime:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return ErrNotInteger
+		}
+	case TypeDouble:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) {
+			return ErrNotFloat
+		}
+	}
+	return nil
+}
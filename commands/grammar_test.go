@@ -0,0 +1,194 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		argv        []string
+		expectedErr error
+	}{
+		{
+			desc:        "container without a subcommand",
+			argv:        []string{"CLIENT"},
+			expectedErr: commands.ArityError{Name: "client"},
+		},
+		{
+			desc:        "unknown subcommand",
+			argv:        []string{"client", "nope"},
+			expectedErr: commands.UnknownSubcommandError{Container: "client", Subcommand: "nope"},
+		},
+		{
+			desc:        "subcommand arity",
+			argv:        []string{"CLIENT", "SETNAME"},
+			expectedErr: commands.ArityError{Name: "client|setname"},
+		},
+		{
+			desc:        "not an integer",
+			argv:        []string{"SLOWLOG", "GET", "ten"},
+			expectedErr: commands.ErrNotInteger,
+		},
+		{
+			desc:        "not one of the tokens",
+			argv:        []string{"CLIENT", "REPLY", "MAYBE"},
+			expectedErr: commands.ErrSyntax,
+		},
+		{
+			desc:        "option given twice",
+			argv:        []string{"PUBSUB", "RETAIN", "ch", "MAXLEN", "1", "MAXLEN", "2"},
+			expectedErr: commands.ErrSyntax,
+		},
+		{
+			desc:        "option missing its value",
+			argv:        []string{"CLIENT", "TRACKING", "ON", "REDIRECT"},
+			expectedErr: commands.ErrSyntax,
+		},
+		{
+			desc:        "incomplete block",
+			argv:        []string{"CONFIG", "SET", "maxmemory", "1", "timeout"},
+			expectedErr: commands.ErrSyntax,
+		},
+		{
+			desc:        "filter missing its value",
+			argv:        []string{"CLIENT", "KILL", "ID", "1", "MAXAGE"},
+			expectedErr: commands.ErrSyntax,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := commands.Parse(tc.argv)
+			assert.Equal(t, tc.expectedErr, err)
+			if err != nil {
+				assert.Regexp(t, "^ERR ", err.Error())
+			}
+		})
+	}
+}
+
+func TestParseWithoutGrammar(t *testing.T) {
+	args, err := commands.Parse([]string{"DEL", "a", "b"})
+	assert.NoError(t, err)
+	assert.Nil(t, args)
+}
+
+func TestParseOptionsInAnyOrder(t *testing.T) {
+	args, err := commands.Parse([]string{"CLIENT", "TRACKING", "on", "PREFIX", "a", "bcast", "REDIRECT", "5", "prefix", "b"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "on", args.String("status"))
+	assert.Equal(t, []string{"a", "b"}, args.Strings("prefix"))
+	assert.True(t, args.Has("bcast"))
+	assert.False(t, args.Has("optin"))
+	assert.Equal(t, int64(5), args.Int("client-id"))
+}
+
+func TestParseOneOf(t *testing.T) {
+	args, err := commands.Parse([]string{"CLIENT", "KILL", "127.0.0.1:6000"})
+	require.NoError(t, err)
+	assert.Equal(t, "old-format", args.String("filter"))
+	assert.Equal(t, "127.0.0.1:6000", args.String("old-format"))
+
+	args, err = commands.Parse([]string{"CLIENT", "KILL", "ID", "3", "SKIPME", "no", "id", "4"})
+	require.NoError(t, err)
+	assert.Equal(t, "new-format", args.String("filter"))
+	assert.Equal(t, []string{"3", "4"}, args.Strings("client-id"))
+	assert.Equal(t, "no", args.String("skipme"))
+
+	args, err = commands.Parse([]string{"CLIENT", "PAUSE", "100", "write"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), args.Int("timeout"))
+	assert.Equal(t, "write", args.String("mode"))
+}
+
+func TestParseRepeatedBlocks(t *testing.T) {
+	args, err := commands.Parse([]string{"CONFIG", "SET", "maxmemory", "1", "timeout", "2"})
+	require.NoError(t, err)
+
+	blocks := args.Blocks("data")
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "maxmemory", blocks[0].String("parameter"))
+	assert.Equal(t, "1", blocks[0].String("value"))
+	assert.Equal(t, "timeout", blocks[1].String("parameter"))
+	assert.Equal(t, "2", blocks[1].String("value"))
+	assert.Equal(t, blocks[0], args.Block("data"))
+}
+
+func TestParseOptionalValue(t *testing.T) {
+	args, err := commands.Parse([]string{"SLOWLOG", "GET"})
+	require.NoError(t, err)
+	assert.False(t, args.Has("count"))
+
+	args, err = commands.Parse([]string{"COMMAND", "GETKEYS", "SET", "k", "v"})
+	require.NoError(t, err)
+	assert.Equal(t, "SET", args.String("command"))
+	assert.Equal(t, []string{"k", "v"}, args.Strings("arg"))
+}
+
+func TestParseLongArgv(t *testing.T) {
+	const n = 1_000_000
+
+	testCases := []struct {
+		desc   string
+		prefix []string
+		check  func(t *testing.T, args *commands.Args)
+	}{
+		{
+			desc:   "repeated value",
+			prefix: []string{"CONFIG", "GET"},
+			check: func(t *testing.T, args *commands.Args) {
+				assert.Len(t, args.Strings("parameter"), n)
+			},
+		},
+		{
+			desc:   "optional repeated value",
+			prefix: []string{"COMMAND", "GETKEYS", "MSET"},
+			check: func(t *testing.T, args *commands.Args) {
+				assert.Len(t, args.Strings("arg"), n)
+			},
+		},
+		{
+			desc:   "repeated block",
+			prefix: []string{"CONFIG", "SET"},
+			check: func(t *testing.T, args *commands.Args) {
+				assert.Len(t, args.Blocks("data"), n/2)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			argv := tc.prefix
+			for i := 0; i < n; i++ {
+				argv = append(argv, "x")
+			}
+
+			args, err := commands.Parse(argv)
+			require.NoError(t, err)
+			tc.check(t, args)
+		})
+	}
+}
+
+func TestParseLongArgvErrors(t *testing.T) {
+	argv := []string{"CLIENT", "KILL"}
+	for i := 0; i < 100_000; i++ {
+		argv = append(argv, "ID", "1")
+	}
+
+	args, err := commands.Parse(argv)
+	require.NoError(t, err)
+	assert.Len(t, args.Strings("client-id"), 100_000)
+
+	_, err = commands.Parse(append(argv, "ID", "one"))
+	assert.Equal(t, commands.ErrNotInteger, err)
+
+	_, err = commands.Parse(append(argv, "ID"))
+	assert.Equal(t, commands.ErrSyntax, err)
+}
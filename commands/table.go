@@ -0,0 +1,308 @@
+package commands
+
+var (
+	readKey = KeySpec{Flags: []string{KeyRO, KeyAccess}, Index: 1, Step: 1}
+	setKey  = KeySpec{Flags: []string{KeyRW, KeyAccess, KeyUpdate, KeyVariableFlags}, Index: 1, Step: 1}
+
+	readKeys   = KeySpec{Flags: []string{KeyRO, KeyAccess}, Index: 1, LastKey: -1, Step: 1}
+	deleteKeys = KeySpec{Flags: []string{KeyRM, KeyDelete}, Index: 1, LastKey: -1, Step: 1}
+
+	// scripts get their keys after the script and the number of keys
+	scriptKeys   = KeySpec{Flags: []string{KeyRW, KeyAccess, KeyUpdate}, Index: 2, KeyNum: true, FirstKey: 1, Step: 1}
+	scriptROKeys = KeySpec{Flags: []string{KeyRO, KeyAccess}, Index: 2, KeyNum: true, FirstKey: 1, Step: 1}
+
+	// shard channels are placed like keys but they are not keys
+	shardChannel  = KeySpec{Flags: []string{KeyNotKey}, Index: 1, Step: 1}
+	shardChannels = KeySpec{Flags: []string{KeyNotKey}, Index: 1, LastKey: -1, Step: 1}
+)
+
+// Flags shared by several commands, the same ones redis gives them. Containers
+// have none of their own, their subcommands have the flags that apply. Only
+// the commands that change the mode of the connection are no_multi.
+var (
+	connectionFlags  = []string{FlagNoScript, FlagLoading, FlagStale, FlagFast, FlagNoAuth, FlagAllowBusy}
+	transactionFlags = []string{FlagNoScript, FlagLoading, FlagStale, FlagFast, FlagAllowBusy}
+	multiFlags       = []string{FlagNoScript, FlagLoading, FlagStale, FlagFast, FlagNoMulti, FlagAllowBusy}
+	subscribeFlags   = []string{FlagPubSub, FlagNoScript, FlagLoading, FlagStale, FlagNoMulti}
+	publishFlags     = []string{FlagPubSub, FlagLoading, FlagStale, FlagFast, FlagMayReplicate}
+	pubsubFlags      = []string{FlagPubSub, FlagLoading, FlagStale}
+	serverFlags      = []string{FlagLoading, FlagStale}
+	clientFlags      = []string{FlagNoScript, FlagLoading, FlagStale}
+	adminFlags       = []string{FlagAdmin, FlagNoScript, FlagLoading, FlagStale}
+	monitorFlags     = []string{FlagAdmin, FlagNoScript, FlagLoading, FlagStale, FlagNoMulti}
+	scriptFlags      = []string{FlagNoScript, FlagStale, FlagSkipMonitor, FlagMayReplicate, FlagNoMandatoryKeys}
+)
+
+var table = []*Command{
+	{
+		Name: "get", Arity: 2, Flags: []string{FlagReadOnly, FlagFast},
+		Categories: []string{"read", "string", "fast"}, KeySpecs: []KeySpec{readKey},
+		Group: "string", Summary: "Returns the string value of a key.", Since: "1.0.0",
+	},
+	{
+		Name: "set", Arity: -3, Flags: []string{FlagWrite, FlagDenyOOM},
+		Categories: []string{"write", "string", "slow"}, KeySpecs: []KeySpec{setKey}, Arguments: setArgs,
+		Group: "string", Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", Since: "1.0.0",
+	},
+	{
+		Name: "del", Arity: -2, Flags: []string{FlagWrite},
+		Categories: []string{"keyspace", "write", "slow"}, KeySpecs: []KeySpec{deleteKeys},
+		Group: "generic", Summary: "Deletes one or more keys.", Since: "1.0.0",
+	},
+	{
+		Name: "exists", Arity: -2, Flags: []string{FlagReadOnly, FlagFast},
+		Categories: []string{"keyspace", "read", "fast"}, KeySpecs: []KeySpec{readKeys},
+		Group: "generic", Summary: "Determines whether one or more keys exist.", Since: "1.0.0",
+	},
+
+	{
+		Name: "ping", Arity: -1, Flags: []string{FlagFast},
+		Categories: []string{"fast", "connection"},
+		Group:      "connection", Summary: "Returns the server's liveliness response.", Since: "1.0.0",
+	},
+	{
+		Name: "echo", Arity: 2, Flags: []string{FlagFast},
+		Categories: []string{"fast", "connection"},
+		Group:      "connection", Summary: "Returns the given string.", Since: "1.0.0",
+	},
+	{
+		Name: "quit", Arity: 1, Flags: connectionFlags,
+		Categories: []string{"fast", "connection"},
+		Group:      "connection", Summary: "Closes the connection.", Since: "1.0.0",
+	},
+	{
+		Name: "reset", Arity: 1, Flags: connectionFlags,
+		Categories: []string{"fast", "connection"},
+		Group:      "connection", Summary: "Resets the connection.", Since: "6.2.0",
+	},
+	{
+		Name: "auth", Arity: -2, Flags: connectionFlags,
+		Categories: []string{"fast", "connection"},
+		Group:      "connection", Summary: "Authenticates the connection.", Since: "1.0.0",
+	},
+	{
+		Name: "client", Arity: -2,
+		Categories: []string{"admin", "slow", "dangerous", "connection"},
+		Group:      "connection", Summary: "A container for client connection commands.", Since: "2.4.0",
+		Subcommands: []*Command{
+			{Name: "id", Arity: 2, Flags: clientFlags, Summary: "Returns the unique client ID of the connection.", Since: "5.0.0"},
+			{Name: "tracking", Arity: -3, Flags: clientFlags, Arguments: clientTrackingArgs, Summary: "Controls server-assisted client-side caching for the connection.", Since: "6.0.0"},
+			{Name: "caching", Arity: 3, Flags: clientFlags, Arguments: []Arg{oneOf("mode", "YES", "NO")}, Summary: "Instructs the server whether to track the keys in the next request.", Since: "6.0.0"},
+			{Name: "getredir", Arity: 2, Flags: clientFlags, Summary: "Returns the client ID to which the connection's tracking notifications are redirected.", Since: "6.0.0"},
+			{Name: "list", Arity: -2, Flags: adminFlags, Arguments: clientListArgs, Summary: "Lists open connections.", Since: "2.4.0"},
+			{Name: "info", Arity: 2, Flags: clientFlags, Summary: "Returns information about the connection.", Since: "6.2.0"},
+			{Name: "kill", Arity: -3, Flags: adminFlags, Arguments: clientKillArgs, Summary: "Terminates open connections.", Since: "2.4.0"},
+			{Name: "setname", Arity: 3, Flags: clientFlags, Summary: "Sets the connection name.", Since: "2.6.9"},
+			{Name: "getname", Arity: 2, Flags: clientFlags, Summary: "Returns the name of the connection.", Since: "2.6.9"},
+			{Name: "pause", Arity: -3, Flags: adminFlags, Arguments: clientPauseArgs, Summary: "Suspends commands processing.", Since: "3.0.0"},
+			{Name: "unpause", Arity: 2, Flags: adminFlags, Summary: "Resumes processing commands from paused clients.", Since: "6.2.0"},
+			{Name: "no-evict", Arity: 3, Flags: adminFlags, Arguments: []Arg{oneOf("enabled", "ON", "OFF")}, Summary: "Sets the client eviction mode of the connection.", Since: "7.0.0"},
+			{Name: "reply", Arity: 3, Flags: clientFlags, Arguments: []Arg{oneOf("action", "ON", "OFF", "SKIP")}, Summary: "Instructs the server whether to reply to commands.", Since: "3.2.0"},
+		},
+	},
+
+	{
+		Name: "subscribe", Arity: -2, Flags: subscribeFlags,
+		Categories: []string{"pubsub", "slow"},
+		Group:      "pubsub", Summary: "Listens for messages published to channels.", Since: "2.0.0",
+	},
+	{
+		Name: "unsubscribe", Arity: -1, Flags: subscribeFlags,
+		Categories: []string{"pubsub", "slow"},
+		Group:      "pubsub", Summary: "Stops listening to messages posted to channels.", Since: "2.0.0",
+	},
+	{
+		Name: "psubscribe", Arity: -2, Flags: subscribeFlags,
+		Categories: []string{"pubsub", "slow"},
+		Group:      "pubsub", Summary: "Listens for messages published to channels that match one or more patterns.", Since: "2.0.0",
+	},
+	{
+		Name: "punsubscribe", Arity: -1, Flags: subscribeFlags,
+		Categories: []string{"pubsub", "slow"},
+		Group:      "pubsub", Summary: "Stops listening to messages published to channels that match one or more patterns.", Since: "2.0.0",
+	},
+	{
+		Name: "ssubscribe", Arity: -2, Flags: subscribeFlags,
+		Categories: []string{"pubsub", "slow"}, KeySpecs: []KeySpec{shardChannels},
+		Group: "pubsub", Summary: "Listens for messages published to shard channels.", Since: "7.0.0",
+	},
+	{
+		Name: "sunsubscribe", Arity: -1, Flags: subscribeFlags,
+		Categories: []string{"pubsub", "slow"}, KeySpecs: []KeySpec{shardChannels},
+		Group: "pubsub", Summary: "Stops listening to messages posted to shard channels.", Since: "7.0.0",
+	},
+	{
+		Name: "rsubscribe", Arity: -3, Flags: subscribeFlags,
+		Categories: []string{"pubsub", "slow"},
+		Group:      "pubsub", Summary: "Listens for messages published to retained channels, replaying them from the given positions.",
+	},
+	{
+		Name: "publish", Arity: 3, Flags: publishFlags,
+		Categories: []string{"pubsub", "fast"},
+		Group:      "pubsub", Summary: "Posts a message to a channel.", Since: "2.0.0",
+	},
+	{
+		Name: "spublish", Arity: 3, Flags: publishFlags,
+		Categories: []string{"pubsub", "fast"}, KeySpecs: []KeySpec{shardChannel},
+		Group: "pubsub", Summary: "Post a message to a shard channel", Since: "7.0.0",
+	},
+	{
+		Name: "pubsub", Arity: -2,
+		Categories: []string{"pubsub", "slow"},
+		Group:      "pubsub", Summary: "A container for Pub/Sub commands.", Since: "2.8.0",
+		Subcommands: []*Command{
+			{Name: "channels", Arity: -2, Flags: pubsubFlags, Summary: "Returns the active channels.", Since: "2.8.0"},
+			{Name: "numsub", Arity: -2, Flags: pubsubFlags, Summary: "Returns a count of subscribers to channels.", Since: "2.8.0"},
+			{Name: "numpat", Arity: 2, Flags: pubsubFlags, Summary: "Returns a count of unique pattern subscriptions.", Since: "2.8.0"},
+			{Name: "shardchannels", Arity: -2, Flags: pubsubFlags, Summary: "Returns the active shard channels.", Since: "7.0.0"},
+			{Name: "shardnumsub", Arity: -2, Flags: pubsubFlags, Summary: "Returns the count of subscribers of shard channels.", Since: "7.0.0"},
+			{Name: "retain", Arity: -3, Flags: pubsubFlags, Arguments: pubsubRetainArgs, Summary: "Keeps the messages published to a channel so subscribers can replay them."},
+			{Name: "unretain", Arity: 3, Flags: pubsubFlags, Summary: "Stops keeping the messages published to a channel."},
+		},
+	},
+
+	{
+		Name: "multi", Arity: 1, Flags: multiFlags,
+		Categories: []string{"fast", "transaction"},
+		Group:      "transactions", Summary: "Starts a transaction.", Since: "1.2.0",
+	},
+	{
+		Name: "exec", Arity: 1, Flags: []string{FlagNoScript, FlagLoading, FlagStale, FlagSkipSlowlog},
+		Categories: []string{"slow", "transaction"},
+		Group:      "transactions", Summary: "Executes all commands in a transaction.", Since: "1.2.0",
+	},
+	{
+		Name: "discard", Arity: 1, Flags: transactionFlags,
+		Categories: []string{"fast", "transaction"},
+		Group:      "transactions", Summary: "Discards a transaction.", Since: "2.0.0",
+	},
+	{
+		Name: "watch", Arity: -2, Flags: multiFlags,
+		Categories: []string{"fast", "transaction"}, KeySpecs: []KeySpec{readKeys},
+		Group: "transactions", Summary: "Monitors changes to keys to determine the execution of a transaction.", Since: "2.2.0",
+	},
+	{
+		Name: "unwatch", Arity: 1, Flags: transactionFlags,
+		Categories: []string{"fast", "transaction"},
+		Group:      "transactions", Summary: "Forgets about watched keys of a transaction.", Since: "2.2.0",
+	},
+
+	{
+		Name: "eval", Arity: -3, Flags: scriptFlags,
+		Categories: []string{"slow", "scripting"}, KeySpecs: []KeySpec{scriptKeys},
+		Group: "scripting", Summary: "Executes a server-side Lua script.", Since: "2.6.0",
+	},
+	{
+		Name: "evalsha", Arity: -3, Flags: scriptFlags,
+		Categories: []string{"slow", "scripting"}, KeySpecs: []KeySpec{scriptKeys},
+		Group: "scripting", Summary: "Executes a server-side Lua script by SHA1 digest.", Since: "2.6.0",
+	},
+	{
+		Name: "script", Arity: -2, Flags: []string{FlagNoScript},
+		Categories: []string{"slow", "scripting"},
+		Group:      "scripting", Summary: "A container for Lua scripts management commands.", Since: "2.6.0",
+		Subcommands: []*Command{
+			{Name: "load", Arity: 3, Flags: []string{FlagNoScript, FlagStale}, Summary: "Loads a server-side Lua script to the script cache.", Since: "2.6.0"},
+			{Name: "exists", Arity: -3, Flags: []string{FlagNoScript}, Summary: "Determines whether server-side Lua scripts exist in the script cache.", Since: "2.6.0"},
+			{Name: "flush", Arity: -2, Flags: []string{FlagNoScript}, Summary: "Removes all server-side Lua scripts from the script cache.", Since: "2.6.0"},
+			{Name: "kill", Arity: 2, Flags: []string{FlagNoScript, FlagAllowBusy}, Summary: "Terminates a server-side Lua script during execution.", Since: "2.6.0"},
+		},
+	},
+	{
+		Name: "fcall", Arity: -3, Flags: scriptFlags,
+		Categories: []string{"slow", "scripting"}, KeySpecs: []KeySpec{scriptKeys},
+		Group: "scripting", Summary: "Invokes a function.", Since: "7.0.0",
+	},
+	{
+		Name: "fcall_ro", Arity: -3, Flags: []string{FlagReadOnly, FlagNoScript, FlagStale, FlagSkipMonitor, FlagNoMandatoryKeys},
+		Categories: []string{"slow", "scripting"}, KeySpecs: []KeySpec{scriptROKeys},
+		Group: "scripting", Summary: "Invokes a read-only function.", Since: "7.0.0",
+	},
+	{
+		Name: "function", Arity: -2, Flags: []string{FlagNoScript},
+		Categories: []string{"slow", "scripting"},
+		Group:      "scripting", Summary: "A container for function commands.", Since: "7.0.0",
+		Subcommands: []*Command{
+			{Name: "load", Arity: -3, Flags: []string{FlagNoScript, FlagDenyOOM}, Summary: "Creates a library.", Since: "7.0.0"},
+			{Name: "list", Arity: -2, Flags: []string{FlagNoScript}, Summary: "Returns information about all libraries.", Since: "7.0.0"},
+			{Name: "delete", Arity: 3, Flags: []string{FlagNoScript}, Summary: "Deletes a library and its functions.", Since: "7.0.0"},
+			{Name: "flush", Arity: -2, Flags: []string{FlagNoScript}, Summary: "Deletes all libraries and functions.", Since: "7.0.0"},
+			{Name: "dump", Arity: 2, Flags: []string{FlagNoScript}, Summary: "Dumps all libraries into a serialized binary payload.", Since: "7.0.0"},
+			{Name: "restore", Arity: -3, Flags: []string{FlagNoScript, FlagDenyOOM}, Summary: "Restores all libraries from a payload.", Since: "7.0.0"},
+			{Name: "kill", Arity: 2, Flags: []string{FlagNoScript, FlagAllowBusy}, Summary: "Terminates a function during execution.", Since: "7.0.0"},
+		},
+	},
+
+	{
+		Name: "acl", Arity: -2,
+		Categories: []string{"admin", "slow", "dangerous"},
+		Group:      "server", Summary: "A container for Access List Control commands.", Since: "6.0.0",
+		Subcommands: []*Command{
+			{Name: "setuser", Arity: -3, Flags: adminFlags, Summary: "Creates and modifies an ACL user and its rules.", Since: "6.0.0"},
+			{Name: "getuser", Arity: 3, Flags: adminFlags, Summary: "Lists the ACL rules of a user.", Since: "6.0.0"},
+			{Name: "deluser", Arity: -3, Flags: adminFlags, Summary: "Deletes ACL users, and terminates their connections.", Since: "6.0.0"},
+			{Name: "list", Arity: 2, Flags: adminFlags, Summary: "Dumps the effective rules in ACL file format.", Since: "6.0.0"},
+			{Name: "users", Arity: 2, Flags: adminFlags, Summary: "Lists all ACL users.", Since: "6.0.0"},
+			{Name: "whoami", Arity: 2, Flags: clientFlags, Summary: "Returns the authenticated username of the current connection.", Since: "6.0.0"},
+			{Name: "cat", Arity: -2, Flags: clientFlags, Summary: "Lists the ACL categories, or the commands inside a category.", Since: "6.0.0"},
+			{Name: "log", Arity: -2, Flags: adminFlags, Summary: "Lists recent security events generated due to ACL rules.", Since: "6.0.0"},
+			{Name: "save", Arity: 2, Flags: adminFlags, Summary: "Saves the effective ACL rules in the configured ACL file.", Since: "6.0.0"},
+			{Name: "load", Arity: 2, Flags: adminFlags, Summary: "Reloads the rules from the configured ACL file.", Since: "6.0.0"},
+		},
+	},
+	{
+		Name: "config", Arity: -2,
+		Categories: []string{"admin", "slow", "dangerous"},
+		Group:      "server", Summary: "A container for server configuration commands.", Since: "2.0.0",
+		Subcommands: []*Command{
+			{Name: "get", Arity: -3, Flags: adminFlags, Arguments: configGetArgs, Summary: "Returns the effective values of configuration parameters.", Since: "2.0.0"},
+			{Name: "set", Arity: -4, Flags: adminFlags, Arguments: configSetArgs, Summary: "Sets configuration parameters in-flight.", Since: "2.0.0"},
+			{Name: "rewrite", Arity: 2, Flags: adminFlags, Summary: "Persists the effective configuration to file.", Since: "2.8.0"},
+			{Name: "resetstat", Arity: 2, Flags: adminFlags, Summary: "Resets the server's statistics.", Since: "2.0.0"},
+		},
+	},
+	{
+		Name: "info", Arity: -1, Flags: serverFlags,
+		Categories: []string{"slow", "dangerous"},
+		Group:      "server", Summary: "Returns information and statistics about the server.", Since: "1.0.0",
+	},
+	{
+		Name: "slowlog", Arity: -2,
+		Categories: []string{"admin", "slow", "dangerous"},
+		Group:      "server", Summary: "A container for slow log commands.", Since: "2.2.12",
+		Subcommands: []*Command{
+			{Name: "get", Arity: -2, Flags: []string{FlagAdmin, FlagLoading, FlagStale}, Arguments: slowlogGetArgs, Summary: "Returns the slow log's entries.", Since: "2.2.12"},
+			{Name: "len", Arity: 2, Flags: []string{FlagAdmin, FlagLoading, FlagStale}, Summary: "Returns the number of entries in the slow log.", Since: "2.2.12"},
+			{Name: "reset", Arity: 2, Flags: []string{FlagAdmin, FlagLoading, FlagStale}, Summary: "Clears all entries from the slow log.", Since: "2.2.12"},
+		},
+	},
+	{
+		Name: "latency", Arity: -2,
+		Categories: []string{"admin", "slow", "dangerous"},
+		Group:      "server", Summary: "A container for latency diagnostics commands.", Since: "2.8.13",
+		Subcommands: []*Command{
+			{Name: "latest", Arity: 2, Flags: adminFlags, Summary: "Returns the latest latency samples for all events.", Since: "2.8.13"},
+			{Name: "history", Arity: 3, Flags: adminFlags, Summary: "Returns timestamp-latency samples for an event.", Since: "2.8.13"},
+			{Name: "reset", Arity: -2, Flags: adminFlags, Summary: "Resets the latency data for one or more events.", Since: "2.8.13"},
+			{Name: "histogram", Arity: -2, Flags: adminFlags, Summary: "Returns the cumulative distribution of latencies of a subset or all commands.", Since: "7.0.0"},
+			{Name: "doctor", Arity: 2, Flags: adminFlags, Summary: "Returns a human-readable latency analysis report.", Since: "2.8.13"},
+		},
+	},
+	{
+		Name: "monitor", Arity: 1, Flags: monitorFlags,
+		Categories: []string{"admin", "slow", "dangerous"},
+		Group:      "server", Summary: "Listens for all requests received by the server in real-time.", Since: "1.0.0",
+	},
+	{
+		Name: "command", Arity: -1, Flags: serverFlags,
+		Categories: []string{"slow", "connection"},
+		Group:      "server", Summary: "Returns detailed information about all commands.", Since: "2.8.13",
+		Subcommands: []*Command{
+			{Name: "count", Arity: 2, Flags: serverFlags, Summary: "Returns a count of commands.", Since: "2.8.13"},
+			{Name: "info", Arity: -2, Flags: serverFlags, Summary: "Returns information about one, multiple or all commands.", Since: "2.8.13"},
+			{Name: "docs", Arity: -2, Flags: serverFlags, Summary: "Returns documentary information about one, multiple or all commands.", Since: "7.0.0"},
+			{Name: "list", Arity: -2, Flags: serverFlags, Arguments: commandListArgs, Summary: "Returns a list of command names.", Since: "7.0.0"},
+			{Name: "getkeys", Arity: -3, Flags: serverFlags, Arguments: commandGetKeysArgs, Summary: "Extracts the key names from an arbitrary command.", Since: "2.8.13"},
+		},
+	},
+}
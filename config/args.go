@@ -0,0 +1,18 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/aelnahas/sider/resp"
+)
+
+// quote returns value as it has to be written for resp.SplitArgs to read it
+// back, values that are plain words separated by single spaces are left as is
+// since settings get their arguments joined by a space.
+func quote(value string) string {
+	if value != "" && strings.Join(strings.Fields(value), " ") == value && !strings.ContainsAny(value, `"'\`) {
+		return value
+	}
+
+	return resp.Quote(value)
+}
@@ -0,0 +1,314 @@
+// Package config implements a registry of typed settings. Settings are loaded
+// from a redis.conf style file, read and changed at runtime like CONFIG GET and
+// CONFIG SET do, and written back to the file like CONFIG REWRITE does.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aelnahas/sider/glob"
+	"github.com/aelnahas/sider/internal/fileutil"
+	"github.com/aelnahas/sider/resp"
+)
+
+var ErrNoConfigFile = errors.New("ERR The server is running without a config file")
+
+// rewriteMarker precedes the settings CONFIG REWRITE appends to the file.
+const rewriteMarker = "# Generated by CONFIG REWRITE"
+
+// Value is the value of a setting. Like flag.Value it is set from and
+// formatted to text, the text is what config files and CONFIG GET use.
+type Value interface {
+	String() string
+	Set(string) error
+}
+
+// Setting is a single configuration parameter.
+type Setting struct {
+	Name string
+	// Alias is an optional second name the setting goes by.
+	Alias string
+	Value Value
+
+	// Immutable settings can only be set on start.
+	Immutable bool
+
+	// Default is the value the setting has out of the box, CONFIG REWRITE
+	// only adds settings that changed from it to the file.
+	Default string
+
+	// Apply makes a change made at runtime take effect, it is not called for
+	// the settings set on start.
+	Apply func() error
+}
+
+// Config is the registry of settings, it is safe for concurrent use.
+type Config struct {
+	mu       sync.Mutex
+	settings map[string]*Setting
+	ordered  []*Setting
+	file     string
+}
+
+type Option = func(*Config)
+
+// WithFile sets the config file settings are loaded from and rewritten to.
+func WithFile(path string) Option {
+	return func(c *Config) {
+		c.file = path
+	}
+}
+
+// New returns a registry of settings.
+func New(settings []*Setting, opts ...Option) *Config {
+	c := &Config{settings: make(map[string]*Setting)}
+
+	for _, s := range settings {
+		c.ordered = append(c.ordered, s)
+		c.settings[s.Name] = s
+		if s.Alias != "" {
+			c.settings[s.Alias] = s
+		}
+	}
+
+	sort.Slice(c.ordered, func(i, j int) bool {
+		return c.ordered[i].Name < c.ordered[j].Name
+	})
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// File returns the path of the config file, empty if there is none.
+func (c *Config) File() string {
+	return c.file
+}
+
+// Get returns the name and value of every setting matching one of patterns,
+// as a flat list sorted by name. Aliases are matched and listed on their own.
+func (c *Config) Get(patterns ...string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.settings))
+	for name := range c.settings {
+		for _, pattern := range patterns {
+			if glob.MatchFold(pattern, name) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+
+	res := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		res = append(res, name, c.settings[name].Value.String())
+	}
+	return res
+}
+
+// Set changes the settings given as name value pairs and applies them. The
+// change is atomic, if any of the settings can not be set or applied all of
+// them keep their previous value.
+func (c *Config) Set(args ...string) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return errors.New("ERR wrong number of arguments for 'config|set' command")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	settings := make([]*Setting, 0, len(args)/2)
+	seen := make(map[*Setting]bool)
+	for i := 0; i < len(args); i += 2 {
+		s, ok := c.settings[strings.ToLower(args[i])]
+		if !ok {
+			return fmt.Errorf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", args[i])
+		}
+		if s.Immutable {
+			return setFailed(args[i], errors.New("can't set immutable config"))
+		}
+		if seen[s] {
+			return setFailed(args[i], errors.New("duplicate parameter"))
+		}
+		seen[s] = true
+		settings = append(settings, s)
+	}
+
+	previous := make([]string, len(settings))
+	for i, s := range settings {
+		previous[i] = s.Value.String()
+		if err := s.Value.Set(args[2*i+1]); err != nil {
+			restore(settings[:i], previous)
+			return setFailed(args[2*i], err)
+		}
+	}
+
+	for i, s := range settings {
+		if s.Apply == nil {
+			continue
+		}
+		if err := s.Apply(); err != nil {
+			restore(settings, previous)
+			reapply(settings[:i])
+			return setFailed(args[2*i], err)
+		}
+	}
+
+	return nil
+}
+
+func setFailed(name string, err error) error {
+	return fmt.Errorf("ERR CONFIG SET failed (possibly related to argument '%s') - %s", name, strings.TrimPrefix(err.Error(), "ERR "))
+}
+
+// restore sets the settings back to their previous values, which are known to
+// be valid.
+func restore(settings []*Setting, previous []string) {
+	for i, s := range settings {
+		_ = s.Value.Set(previous[i])
+	}
+}
+
+// reapply applies the restored values of settings that were already applied.
+func reapply(settings []*Setting) {
+	for _, s := range settings {
+		if s.Apply == nil {
+			continue
+		}
+		if err := s.Apply(); err != nil {
+			slog.Error("could not restore setting", "name", s.Name, "error", err)
+		}
+	}
+}
+
+// Override sets a setting on start, immutable settings can be set and the
+// change is not applied.
+func (c *Config) Override(name, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.settings[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown setting '%s'", name)
+	}
+
+	if err := s.Value.Set(value); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
+
+// Load sets the settings found in the config file, the ones it does not
+// mention keep their value. Like Override the changes are not applied.
+func (c *Config) Load() error {
+	if c.file == "" {
+		return ErrNoConfigFile
+	}
+
+	data, err := os.ReadFile(c.file)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		args, err := resp.SplitArgs(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: unbalanced quotes in configuration line", c.file, n+1)
+		}
+
+		if len(args) < 2 {
+			return fmt.Errorf("%s:%d: wrong number of arguments for '%s'", c.file, n+1, args[0])
+		}
+
+		if err := c.Override(args[0], strings.Join(args[1:], " ")); err != nil {
+			return fmt.Errorf("%s:%d: %w", c.file, n+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Rewrite writes the current settings to the config file. Comments and lines
+// it does not know about are kept, settings the file already has are updated
+// in place and settings that changed from their default are appended.
+func (c *Config) Rewrite() error {
+	if c.file == "" {
+		return ErrNoConfigFile
+	}
+
+	data, err := os.ReadFile(c.file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("ERR Rewriting config file: %s", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+
+	written := make(map[*Setting]bool)
+	out := make([]string, 0, len(lines))
+	marker := false
+	for _, line := range lines {
+		marker = marker || strings.TrimSpace(line) == rewriteMarker
+
+		args, err := resp.SplitArgs(line)
+		if err != nil || len(args) == 0 || strings.HasPrefix(args[0], "#") {
+			out = append(out, line)
+			continue
+		}
+
+		s, ok := c.settings[strings.ToLower(args[0])]
+		if !ok {
+			out = append(out, line)
+			continue
+		}
+
+		// a setting given more than once keeps only its first line
+		if !written[s] {
+			out = append(out, s.line())
+			written[s] = true
+		}
+	}
+
+	for _, s := range c.ordered {
+		if written[s] || s.Value.String() == s.Default {
+			continue
+		}
+
+		if !marker {
+			out = append(out, rewriteMarker)
+			marker = true
+		}
+		out = append(out, s.line())
+	}
+
+	if err := fileutil.WriteFileAtomic(c.file, []byte(strings.Join(out, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("ERR Rewriting config file: %s", err)
+	}
+	return nil
+}
+
+// line is how the setting is written to the config file.
+func (s *Setting) line() string {
+	return s.Name + " " + quote(s.Value.String())
+}
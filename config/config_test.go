@@ -0,0 +1,152 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type values struct {
+	port    uint
+	pass    string
+	timeout time.Duration
+	mode    string
+}
+
+func newConfig(v *values, file string) *config.Config {
+	return config.New([]*config.Setting{
+		{Name: "port", Value: config.Uint(&v.port, 0, 65535), Default: "6379", Immutable: true},
+		{Name: "requirepass", Value: config.String(&v.pass)},
+		{Name: "busy-reply-threshold", Alias: "lua-time-limit", Value: config.Millis(&v.timeout), Default: "5000"},
+		{
+			Name:  "mode",
+			Value: config.Enum(&v.mode, "yes", "no"),
+			Apply: func() error {
+				if v.mode == "no" {
+					return errors.New("mode can not be turned off")
+				}
+				return nil
+			},
+		},
+	}, config.WithFile(file))
+}
+
+func TestGet(t *testing.T) {
+	v := values{port: 6379, timeout: time.Second}
+	c := newConfig(&v, "")
+
+	assert.Equal(t, []string{"port", "6379"}, c.Get("port"))
+	assert.Equal(t, []string{"busy-reply-threshold", "1000", "lua-time-limit", "1000", "port", "6379"}, c.Get("*t*", "PORT"))
+	assert.Empty(t, c.Get("missing"))
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{
+			name: "unknown",
+			args: []string{"foo", "1"},
+			err:  "ERR Unknown option or number of arguments for CONFIG SET - 'foo'",
+		},
+		{
+			name: "immutable",
+			args: []string{"port", "1"},
+			err:  "ERR CONFIG SET failed (possibly related to argument 'port') - can't set immutable config",
+		},
+		{
+			name: "alias of the same setting",
+			args: []string{"lua-time-limit", "1", "busy-reply-threshold", "2"},
+			err:  "ERR CONFIG SET failed (possibly related to argument 'busy-reply-threshold') - duplicate parameter",
+		},
+		{
+			name: "invalid value",
+			args: []string{"requirepass", "pw", "lua-time-limit", "soon"},
+			err:  "ERR CONFIG SET failed (possibly related to argument 'lua-time-limit') - argument couldn't be parsed into an integer",
+		},
+		{
+			name: "apply fails",
+			args: []string{"requirepass", "pw", "mode", "no"},
+			err:  "ERR CONFIG SET failed (possibly related to argument 'mode') - mode can not be turned off",
+		},
+		{
+			name: "valid",
+			args: []string{"requirepass", "pw", "lua-time-limit", "100", "mode", "YES"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v := values{port: 6379, timeout: time.Second, mode: "yes"}
+			c := newConfig(&v, "")
+
+			err := c.Set(tc.args...)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, values{port: 6379, pass: "pw", timeout: 100 * time.Millisecond, mode: "yes"}, v)
+				return
+			}
+
+			// a failed set leaves every setting as it was
+			assert.EqualError(t, err, tc.err)
+			assert.Equal(t, values{port: 6379, timeout: time.Second, mode: "yes"}, v)
+		})
+	}
+}
+
+func TestMemory(t *testing.T) {
+	var limit int64 = 2048
+	v := config.Memory(&limit, 1024, 1024*1024)
+
+	assert.NoError(t, v.Set("1kb"))
+	assert.Equal(t, int64(1024), limit)
+	assert.Equal(t, "1024", v.String())
+
+	assert.NoError(t, v.Set("1M"))
+	assert.Equal(t, int64(1000*1000), limit)
+
+	assert.EqualError(t, v.Set("1k"), "argument must be between 1024 and 1048576 inclusive")
+	assert.EqualError(t, v.Set("lots"), "argument must be a memory value")
+	assert.Equal(t, int64(1000*1000), limit)
+}
+
+func TestLoadRewrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sider.conf")
+	assert.NoError(t, os.WriteFile(file, []byte("# network\nport 7000\n\nrequirepass \"a  b\"\nrequirepass 'it\\'s'\n"), 0o644))
+
+	v := values{port: 6379, timeout: 5 * time.Second}
+	c := newConfig(&v, file)
+
+	assert.NoError(t, c.Load())
+	assert.Equal(t, values{port: 7000, pass: "it's", timeout: 5 * time.Second}, v)
+
+	assert.NoError(t, c.Set("requirepass", "x\ny", "lua-time-limit", "100"))
+	assert.NoError(t, c.Rewrite())
+
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "# network\nport 7000\n\nrequirepass \"x\\ny\"\n# Generated by CONFIG REWRITE\nbusy-reply-threshold 100\n", string(data))
+
+	// the rewritten file loads back to the same values
+	loaded := values{}
+	assert.NoError(t, newConfig(&loaded, file).Load())
+	assert.Equal(t, v, loaded)
+
+	assert.NoError(t, os.WriteFile(file, []byte("port 7000\nunknown 1\n"), 0o644))
+	assert.EqualError(t, c.Load(), file+":2: unknown setting 'unknown'")
+
+	// lines are split like inline commands
+	assert.NoError(t, os.WriteFile(file, []byte("requirepass\v\"\\x41\\xZZ\"\n"), 0o644))
+	assert.NoError(t, c.Load())
+	assert.Equal(t, "AxZZ", v.pass)
+
+	assert.NoError(t, os.WriteFile(file, []byte("requirepass \"open\n"), 0o644))
+	assert.EqualError(t, c.Load(), file+":1: unbalanced quotes in configuration line")
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type stringValue struct {
+	p *string
+}
+
+// String is a setting holding any string.
+func String(p *string) Value {
+	return stringValue{p}
+}
+
+func (v stringValue) String() string {
+	return *v.p
+}
+
+func (v stringValue) Set(s string) error {
+	*v.p = s
+	return nil
+}
+
+type uintValue struct {
+	p        *uint
+	min, max uint
+}
+
+// Uint is a setting holding an unsigned integer between min and max.
+func Uint(p *uint, min, max uint) Value {
+	return uintValue{p, min, max}
+}
+
+func (v uintValue) String() string {
+	return strconv.FormatUint(uint64(*v.p), 10)
+}
+
+func (v uintValue) Set(s string) error {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return errors.New("argument couldn't be parsed into an integer")
+	}
+
+	if n < uint64(v.min) || n > uint64(v.max) {
+		return fmt.Errorf("argument must be between %d and %d inclusive", v.min, v.max)
+	}
+
+	*v.p = uint(n)
+	return nil
+}
+
+type intValue struct {
+	p        *int
+	min, max int
+}
+
+// Int is a setting holding an integer between min and max.
+func Int(p *int, min, max int) Value {
+	return intValue{p, min, max}
+}
+
+func (v intValue) String() string {
+	return strconv.Itoa(*v.p)
+}
+
+func (v intValue) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return errors.New("argument couldn't be parsed into an integer")
+	}
+
+	if n < int64(v.min) || n > int64(v.max) {
+		return fmt.Errorf("argument must be between %d and %d inclusive", v.min, v.max)
+	}
+
+	*v.p = int(n)
+	return nil
+}
+
+type memoryValue struct {
+	p        *int64
+	min, max int64
+}
+
+// Memory is a setting holding a number of bytes between min and max, it can
+// be set with a unit like 512mb.
+func Memory(p *int64, min, max int64) Value {
+	return memoryValue{p, min, max}
+}
+
+func (v memoryValue) String() string {
+	return strconv.FormatInt(*v.p, 10)
+}
+
+func (v memoryValue) Set(s string) error {
+	n, err := ParseMemory(s)
+	if err != nil {
+		return err
+	}
+
+	if n < v.min || n > v.max {
+		return fmt.Errorf("argument must be between %d and %d inclusive", v.min, v.max)
+	}
+
+	*v.p = n
+	return nil
+}
+
+type enumValue struct {
+	p      *string
+	values []string
+}
+
+// Enum is a setting holding one of values, matched case insensitively.
+func Enum(p *string, values ...string) Value {
+	return enumValue{p, values}
+}
+
+func (v enumValue) String() string {
+	return *v.p
+}
+
+func (v enumValue) Set(s string) error {
+	for _, value := range v.values {
+		if strings.EqualFold(s, value) {
+			*v.p = value
+			return nil
+		}
+	}
+	return fmt.Errorf("argument(s) must be one of the following: %s", strings.Join(v.values, ", "))
+}
+
+type millisValue struct {
+	p *time.Duration
+}
+
+// Millis is a setting holding a duration, given in milliseconds.
+func Millis(p *time.Duration) Value {
+	return millisValue{p}
+}
+
+func (v millisValue) String() string {
+	return strconv.FormatInt(v.p.Milliseconds(), 10)
+}
+
+func (v millisValue) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return errors.New("argument couldn't be parsed into an integer")
+	}
+	if n < 0 {
+		return errors.New("argument must be a positive number of milliseconds")
+	}
+
+	*v.p = time.Duration(n) * time.Millisecond
+	return nil
+}
+
+type funcValue struct {
+	get func() string
+	set func(string) error
+}
+
+// Func is a setting parsed and formatted by the given functions, for values
+// none of the other types fit. set must leave the value untouched when it
+// returns an error.
+func Func(get func() string, set func(string) error) Value {
+	return funcValue{get, set}
+}
+
+func (v funcValue) String() string {
+	return v.get()
+}
+
+func (v funcValue) Set(s string) error {
+	return v.set(s)
+}
+
+var memoryUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"k":  1000,
+	"kb": 1024,
+	"m":  1000 * 1000,
+	"mb": 1024 * 1024,
+	"g":  1000 * 1000 * 1000,
+	"gb": 1024 * 1024 * 1024,
+}
+
+// ParseMemory parses a number of bytes with an optional unit like redis.conf
+// does, k is 1000 bytes while kb is 1024 and so on for m and g.
+func ParseMemory(s string) (int64, error) {
+	lower := strings.ToLower(s)
+	digits := strings.TrimRight(lower, "kmgb")
+
+	unit, ok := memoryUnits[lower[len(digits):]]
+	if !ok {
+		return 0, errors.New("argument must be a memory value")
+	}
+
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("argument must be a memory value")
+	}
+
+	return n * unit, nil
+}
@@ -3,15 +3,102 @@ package db
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aelnahas/sider/commands"
 )
 
 type Command interface {
-	Read(args []string, options map[string]any) error
+	// Read takes the arguments of the command, parsed holds them by name for
+	// the commands that have a grammar in the command table.
+	Read(args []string, parsed *commands.Args) error
 	Execute(ctx context.Context) (any, error)
 }
 
 type DB struct {
-	store *memory
+	index     int
+	store     *memory
+	scripts   *scripts
+	functions *functions
+
+	publisher Publisher
+
+	// notifyClasses is set without the store lock, so CONFIG SET can change
+	// it from a transaction while the lock is held
+	notifyClasses atomic.Int64
+
+	authorizer Authorizer
+	latency    LatencyMonitor
+	fallback   Executor
+
+	stats stats
+}
+
+// Authorizer decides whether the client behind ctx can run a command, it is
+// consulted for the commands scripts run through redis.call.
+type Authorizer interface {
+	Authorize(ctx context.Context, name string, args []string) error
+}
+
+// Executor runs the commands the keyspace does not implement, like PUBLISH or
+// INFO, when a transaction or a script calls them. It is called with the
+// store lock held so it must not run commands against the DB.
+type Executor interface {
+	Execute(ctx context.Context, name string, args []string, parsed *commands.Args) (any, error)
+}
+
+// LatencyMonitor is told how long the work done outside of commands took,
+// like deleting expired keys.
+type LatencyMonitor interface {
+	Add(event string, latency time.Duration)
+}
+
+type Option = func(*DB)
+
+// WithKeyObserver sets an observer that is told about every modified key.
+func WithKeyObserver(o KeyObserver) Option {
+	return func(d *DB) {
+		d.store.observer = o
+	}
+}
+
+// WithAuthorizer sets the authorizer commands run from scripts are checked
+// with.
+func WithAuthorizer(a Authorizer) Option {
+	return func(d *DB) {
+		d.authorizer = a
+	}
+}
+
+// WithPublisher sets where keyspace notifications are published to.
+func WithPublisher(p Publisher) Option {
+	return func(d *DB) {
+		d.publisher = p
+	}
+}
+
+// WithExecutor sets where the commands the keyspace does not implement are
+// sent.
+func WithExecutor(e Executor) Option {
+	return func(d *DB) {
+		d.fallback = e
+	}
+}
+
+// WithLatencyMonitor sets the monitor the latency of expiring keys is
+// reported to.
+func WithLatencyMonitor(m LatencyMonitor) Option {
+	return func(d *DB) {
+		d.latency = m
+	}
+}
+
+// SetScriptTimeLimit sets how long a script runs before other clients get a
+// BUSY error, like busy-reply-threshold in redis.
+func (d *DB) SetScriptTimeLimit(limit time.Duration) {
+	d.scripts.timeLimit.Store(int64(limit))
 }
 
 type Expiration struct {
@@ -24,39 +111,112 @@ type Expiration struct {
 	KeepTTL           bool
 }
 
-func NewDB() *DB {
+func NewDB(opts ...Option) *DB {
 	store := newMemory()
-	return &DB{store: store}
+	d := &DB{store: store, scripts: newScripts(), functions: newFunctions()}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
 }
 
-func (d *DB) Execute(ctx context.Context, name string, args []string, opts map[string]any) (any, error) {
+func (d *DB) Execute(ctx context.Context, name string, args []string, parsed *commands.Args) (any, error) {
+	if isKill(name, args) {
+		return d.execute(ctx, name, args, parsed)
+	}
+
+	if d.scripts.busy() {
+		return nil, ErrBusy
+	}
+
+	d.store.Lock()
+	defer d.store.Unlock()
+
+	return d.execute(ctx, name, args, parsed)
+}
+
+// execute runs a single command, the caller must hold the store lock.
+func (d *DB) execute(ctx context.Context, name string, args []string, parsed *commands.Args) (any, error) {
 	cmd, err := d.getCommand(name)
+	if err != nil && d.fallback != nil {
+		return d.fallback.Execute(ctx, name, args, parsed)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if err := cmd.Read(args, opts); err != nil {
+	if err := cmd.Read(args, parsed); err != nil {
 		return nil, err
 	}
 	return cmd.Execute(ctx)
 }
 
+// expire removes a key whose ttl ran out, it is called from the background
+// timers so it has to take the lock itself. Expiring is not done on behalf of
+// any client so it runs with its own context.
+func (d *DB) expire(key string) error {
+	ctx := context.Background()
+
+	d.store.Lock()
+	defer d.store.Unlock()
+
+	start := time.Now()
+
+	if !d.store.exists(ctx, key) {
+		return nil
+	}
+
+	if err := d.store.del(ctx, key); err != nil {
+		return err
+	}
+
+	d.stats.expired.Add(1)
+	d.notify(notifyExpired, "expired", key)
+
+	if d.latency != nil {
+		d.latency.Add("expire-cycle", time.Since(start))
+	}
+	return nil
+}
+
+// CommandKeys returns the keys a command operates on.
+func CommandKeys(name string, args []string) []string {
+	return commands.Keys(name, args)
+}
+
+// IsWrite reports whether a command modifies the keyspace.
+func IsWrite(name string) bool {
+	return commands.HasFlag(name, commands.FlagWrite)
+}
+
+// IsReadOnly reports whether a command only reads the keyspace.
+func IsReadOnly(name string) bool {
+	return commands.HasFlag(name, commands.FlagReadOnly)
+}
+
+// handlers build the commands the keyspace runs, by their name in the
+// command table.
+var handlers = map[string]func(d *DB) Command{
+	"set":      func(d *DB) Command { return &setCmd{store: d} },
+	"get":      func(d *DB) Command { return &getCmd{store: d} },
+	"ping":     func(d *DB) Command { return &pingCmd{} },
+	"echo":     func(d *DB) Command { return &pingCmd{} },
+	"exists":   func(d *DB) Command { return &existsCmd{store: d} },
+	"del":      func(d *DB) Command { return &delCmd{store: d} },
+	"eval":     func(d *DB) Command { return &evalCmd{store: d} },
+	"evalsha":  func(d *DB) Command { return &evalCmd{store: d, bySha: true} },
+	"script":   func(d *DB) Command { return &scriptCmd{store: d} },
+	"fcall":    func(d *DB) Command { return &fcallCmd{store: d} },
+	"fcall_ro": func(d *DB) Command { return &fcallCmd{store: d, readOnly: true} },
+	"function": func(d *DB) Command { return &functionCmd{store: d} },
+}
+
 func (d *DB) getCommand(name string) (Command, error) {
-	switch name {
-	case "SET":
-		return &setCmd{store: d}, nil
-	case "GET":
-		return &getCmd{store: d}, nil
-	case "PING":
-		return &pingCmd{}, nil
-	case "ECHO":
-		return &pingCmd{}, nil
-	case "EXISTS":
-		return &existsCmd{store: d}, nil
-	case "DEL":
-		return &delCmd{store: d}, nil
-	default:
+	handler, ok := handlers[strings.ToLower(name)]
+	if !ok {
 		return nil, fmt.Errorf("unknown command %s", name)
 	}
-
+	return handler(d), nil
 }
@@ -1,13 +1,17 @@
 package db
 
-import "context"
+import (
+	"context"
+
+	"github.com/aelnahas/sider/commands"
+)
 
 type delCmd struct {
 	keys  []string
 	store *DB
 }
 
-func (d *delCmd) Read(args []string, _ map[string]any) error {
+func (d *delCmd) Read(args []string, _ *commands.Args) error {
 	d.keys = args
 	return nil
 }
@@ -16,13 +20,16 @@ func (d *delCmd) Execute(ctx context.Context) (any, error) {
 	count := 0
 
 	for _, key := range d.keys {
-		if d.store.store.exists(ctx, key) {
-			count++
+		if !d.store.store.exists(ctx, key) {
+			continue
 		}
+
 		if err := d.store.store.del(ctx, key); err != nil {
 			return count, err
 		}
 
+		count++
+		d.store.notify(notifyGeneric, "del", key)
 	}
 
 	return count, nil
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/resp"
+)
+
+type evalCmd struct {
+	bySha  bool
+	script string
+	keys   []string
+	args   []string
+
+	store *DB
+}
+
+func (e *evalCmd) Read(args []string, _ *commands.Args) error {
+	e.script = args[0]
+
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errors.New("ERR value is not an integer or out of range")
+	}
+
+	if numKeys < 0 {
+		return errors.New("ERR Number of keys can't be negative")
+	}
+
+	if numKeys > len(args)-2 {
+		return errors.New("ERR Number of keys can't be greater than number of args")
+	}
+
+	e.keys = args[2 : 2+numKeys]
+	e.args = args[2+numKeys:]
+	return nil
+}
+
+func (e *evalCmd) Execute(ctx context.Context) (any, error) {
+	if e.bySha {
+		proto, ok := e.store.scripts.get(e.script)
+		if !ok {
+			return nil, ErrNoScript
+		}
+		return e.store.runScript(ctx, "f_"+strings.ToLower(e.script), proto, e.keys, e.args)
+	}
+
+	sha, proto, err := e.store.scripts.load(e.script)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.store.runScript(ctx, "f_"+sha, proto, e.keys, e.args)
+}
+
+type scriptCmd struct {
+	subcommand string
+	args       []string
+
+	store *DB
+}
+
+func (s *scriptCmd) Read(args []string, _ *commands.Args) error {
+	s.subcommand = strings.ToUpper(args[0])
+	s.args = args[1:]
+
+	switch s.subcommand {
+	case "LOAD":
+		if len(s.args) != 1 {
+			return errWrongArgs("script|load")
+		}
+	case "EXISTS":
+		if len(s.args) == 0 {
+			return errWrongArgs("script|exists")
+		}
+	case "FLUSH":
+		if len(s.args) > 1 {
+			return errWrongArgs("script|flush")
+		}
+		if len(s.args) == 1 {
+			mode := strings.ToUpper(s.args[0])
+			if mode != "ASYNC" && mode != "SYNC" {
+				return ErrSyntax
+			}
+		}
+	case "KILL":
+		if len(s.args) != 0 {
+			return errWrongArgs("script|kill")
+		}
+	default:
+		return fmt.Errorf("ERR unknown subcommand '%s'. Try SCRIPT HELP.", args[0])
+	}
+
+	return nil
+}
+
+func (s *scriptCmd) Execute(ctx context.Context) (any, error) {
+	switch s.subcommand {
+	case "LOAD":
+		sha, _, err := s.store.scripts.load(s.args[0])
+		if err != nil {
+			return nil, err
+		}
+		return sha, nil
+	case "EXISTS":
+		res := make([]any, 0, len(s.args))
+		for _, sha := range s.args {
+			if s.store.scripts.exists(sha) {
+				res = append(res, 1)
+			} else {
+				res = append(res, 0)
+			}
+		}
+		return res, nil
+	case "FLUSH":
+		s.store.scripts.flush()
+		return resp.OK, nil
+	default:
+		if err := s.store.scripts.kill(); err != nil {
+			return nil, err
+		}
+		return resp.OK, nil
+	}
+}
+
+// isKill reports whether the command is SCRIPT KILL or FUNCTION KILL, which
+// have to be served while another script holds the store lock.
+func isKill(name string, args []string) bool {
+	return (name == "SCRIPT" || name == "FUNCTION") && len(args) > 0 && strings.ToUpper(args[0]) == "KILL"
+}
+
+func errWrongArgs(name string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", name)
+}
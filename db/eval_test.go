@@ -0,0 +1,89 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aelnahas/sider/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+
+		expected    any
+		expectedErr error
+	}{
+		{
+			name:     "keys and args",
+			args:     []string{"return {KEYS[1], ARGV[1], #ARGV}", "1", "foo", "bar", "baz"},
+			expected: []any{"foo", "bar", 2},
+		},
+		{
+			name:     "call",
+			args:     []string{"redis.call('SET', KEYS[1], ARGV[1]); return redis.call('GET', KEYS[1])", "1", "foo", "bar"},
+			expected: "bar",
+		},
+		{
+			name:     "nil and booleans",
+			args:     []string{"return {1, true, false, 2}", "0"},
+			expected: []any{1, 1, nil, 2},
+		},
+		{
+			name:     "pcall error",
+			args:     []string{"return {redis.pcall('GET')}", "0"},
+			expected: []any{errors.New("ERR wrong number of arguments for 'get' command")},
+		},
+		{
+			name:        "call error",
+			args:        []string{"return redis.call('GET')", "0"},
+			expectedErr: errors.New("ERR wrong number of arguments for 'get' command"),
+		},
+		{
+			name:        "error reply",
+			args:        []string{"return redis.error_reply('MY error')", "0"},
+			expectedErr: errors.New("MY error"),
+		},
+		{
+			name:        "too many keys",
+			args:        []string{"return 1", "2", "foo"},
+			expectedErr: errors.New("ERR Number of keys can't be greater than number of args"),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := db.NewDB()
+
+			res, err := store.Execute(context.Background(), "EVAL", tc.args, nil)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestEvalSha(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewDB()
+
+	_, err := store.Execute(ctx, "EVALSHA", []string{"e0e1f9fabfc9d4800c877a703b823ac0578ff8db", "0"}, nil)
+	assert.Equal(t, db.ErrNoScript, err)
+
+	sha, err := store.Execute(ctx, "SCRIPT", []string{"LOAD", "return 'ok'"}, nil)
+	assert.NoError(t, err)
+
+	res, err := store.Execute(ctx, "EVALSHA", []string{sha.(string), "0"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", res)
+
+	_, err = store.Execute(ctx, "SCRIPT", []string{"FLUSH"}, nil)
+	assert.NoError(t, err)
+
+	res, err = store.Execute(ctx, "SCRIPT", []string{"EXISTS", sha.(string)}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{0}, res)
+}
@@ -1,13 +1,17 @@
 package db
 
-import "context"
+import (
+	"context"
+
+	"github.com/aelnahas/sider/commands"
+)
 
 type existsCmd struct {
 	store *DB
 	keys  []string
 }
 
-func (e *existsCmd) Read(args []string, _ map[string]any) error {
+func (e *existsCmd) Read(args []string, _ *commands.Args) error {
 	e.keys = args
 	return nil
 }
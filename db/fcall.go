@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/glob"
+	"github.com/aelnahas/sider/resp"
+)
+
+type fcallCmd struct {
+	readOnly bool
+	name     string
+	keys     []string
+	args     []string
+
+	store *DB
+}
+
+func (f *fcallCmd) Read(args []string, parsed *commands.Args) error {
+	// FCALL takes the same arguments as EVAL with the function name in place
+	// of the script
+	eval := &evalCmd{}
+	if err := eval.Read(args, parsed); err != nil {
+		return err
+	}
+
+	f.name = eval.script
+	f.keys = eval.keys
+	f.args = eval.args
+	return nil
+}
+
+func (f *fcallCmd) Execute(ctx context.Context) (any, error) {
+	fn, ok := f.store.functions.functions[f.name]
+	if !ok {
+		return nil, ErrFunctionNotFound
+	}
+
+	return f.store.callFunction(ctx, fn, f.keys, f.args, f.readOnly)
+}
+
+type functionCmd struct {
+	subcommand string
+	args       []string
+
+	// LOAD and RESTORE
+	replace bool
+	code    string
+	policy  string
+
+	// LIST
+	libraryPattern string
+	withCode       bool
+
+	store *DB
+}
+
+func (f *functionCmd) Read(args []string, _ *commands.Args) error {
+	f.subcommand = strings.ToUpper(args[0])
+	f.args = args[1:]
+
+	switch f.subcommand {
+	case "LOAD":
+		return f.readLoad()
+	case "LIST":
+		return f.readList()
+	case "RESTORE":
+		return f.readRestore()
+	case "DELETE":
+		if len(f.args) != 1 {
+			return errWrongArgs("function|delete")
+		}
+	case "FLUSH":
+		if len(f.args) > 1 {
+			return errWrongArgs("function|flush")
+		}
+		if len(f.args) == 1 {
+			mode := strings.ToUpper(f.args[0])
+			if mode != "ASYNC" && mode != "SYNC" {
+				return ErrSyntax
+			}
+		}
+	case "DUMP", "KILL":
+		if len(f.args) != 0 {
+			return errWrongArgs("function|" + strings.ToLower(f.subcommand))
+		}
+	default:
+		return fmt.Errorf("ERR unknown subcommand '%s'. Try FUNCTION HELP.", args[0])
+	}
+
+	return nil
+}
+
+func (f *functionCmd) readLoad() error {
+	switch len(f.args) {
+	case 1:
+		f.code = f.args[0]
+	case 2:
+		if strings.ToUpper(f.args[0]) != "REPLACE" {
+			return fmt.Errorf("ERR Unknown option given: %s", f.args[0])
+		}
+		f.replace = true
+		f.code = f.args[1]
+	default:
+		return errWrongArgs("function|load")
+	}
+
+	return nil
+}
+
+func (f *functionCmd) readList() error {
+	for i := 0; i < len(f.args); i++ {
+		switch strings.ToUpper(f.args[i]) {
+		case "WITHCODE":
+			f.withCode = true
+		case "LIBRARYNAME":
+			if i+1 >= len(f.args) {
+				return ErrSyntax
+			}
+			i++
+			f.libraryPattern = f.args[i]
+		default:
+			return fmt.Errorf("ERR Unknown argument %s", f.args[i])
+		}
+	}
+
+	return nil
+}
+
+func (f *functionCmd) readRestore() error {
+	if len(f.args) < 1 || len(f.args) > 2 {
+		return errWrongArgs("function|restore")
+	}
+
+	f.code = f.args[0]
+	f.policy = "APPEND"
+
+	if len(f.args) == 2 {
+		f.policy = strings.ToUpper(f.args[1])
+		if f.policy != "APPEND" && f.policy != "REPLACE" && f.policy != "FLUSH" {
+			return errors.New("ERR Wrong restore policy given, value should be either FLUSH, APPEND or REPLACE.")
+		}
+	}
+
+	return nil
+}
+
+func (f *functionCmd) Execute(ctx context.Context) (any, error) {
+	switch f.subcommand {
+	case "LOAD":
+		lib, err := f.store.loadLibrary(f.code)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := f.store.functions.add(lib, f.replace); err != nil {
+			lib.state.Close()
+			return nil, err
+		}
+		return lib.name, nil
+	case "LIST":
+		return f.list(), nil
+	case "DELETE":
+		lib, ok := f.store.functions.libraries[f.args[0]]
+		if !ok {
+			return nil, ErrLibraryNotFound
+		}
+
+		f.store.functions.remove(lib)
+		return resp.OK, nil
+	case "FLUSH":
+		f.store.functions.flush()
+		return resp.OK, nil
+	case "DUMP":
+		return f.store.functions.dump()
+	case "RESTORE":
+		if err := f.store.restoreFunctions(f.code, f.policy); err != nil {
+			return nil, err
+		}
+		return resp.OK, nil
+	default:
+		if err := f.store.scripts.kill(); err != nil {
+			return nil, err
+		}
+		return resp.OK, nil
+	}
+}
+
+func (f *functionCmd) list() []any {
+	res := make([]any, 0)
+
+	for _, lib := range f.store.functions.sorted() {
+		if f.libraryPattern != "" && !glob.Match(f.libraryPattern, lib.name) {
+			continue
+		}
+
+		fns := make([]any, 0, len(lib.functions))
+		for _, fn := range lib.functions {
+			var desc any
+			if fn.description != "" {
+				desc = fn.description
+			}
+
+			flags := make([]any, 0, len(fn.flags))
+			for _, flag := range fn.flags {
+				flags = append(flags, flag)
+			}
+
+			fns = append(fns, []any{"name", fn.name, "description", desc, "flags", flags})
+		}
+
+		entry := []any{"library_name", lib.name, "engine", functionEngine, "functions", fns}
+		if f.withCode {
+			entry = append(entry, "library_code", lib.code)
+		}
+
+		res = append(res, entry)
+	}
+
+	return res
+}
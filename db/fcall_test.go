@@ -0,0 +1,215 @@
+package db_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aelnahas/sider/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLibrary = `#!lua name=mylib
+redis.register_function('setget', function(keys, args)
+	redis.call('SET', keys[1], args[1])
+	return redis.call('GET', keys[1])
+end)
+
+redis.register_function{
+	function_name = 'get',
+	callback = function(keys) return redis.call('GET', keys[1]) end,
+	flags = {'no-writes'},
+}
+
+redis.register_function{
+	function_name = 'sneaky',
+	callback = function(keys) return redis.call('SET', keys[1], 'x') end,
+	flags = {'no-writes'},
+}
+`
+
+func TestFunctions(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewDB()
+
+	name, err := store.Execute(ctx, "FUNCTION", []string{"LOAD", testLibrary}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "mylib", name)
+
+	_, err = store.Execute(ctx, "FUNCTION", []string{"LOAD", testLibrary}, nil)
+	assert.Equal(t, errors.New("ERR Library 'mylib' already exists"), err)
+
+	_, err = store.Execute(ctx, "FUNCTION", []string{"LOAD", "REPLACE", testLibrary}, nil)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		cmd  string
+		args []string
+
+		expected    any
+		expectedErr error
+	}{
+		{
+			name:     "fcall",
+			cmd:      "FCALL",
+			args:     []string{"setget", "1", "foo", "bar"},
+			expected: "bar",
+		},
+		{
+			name:     "fcall_ro",
+			cmd:      "FCALL_RO",
+			args:     []string{"get", "1", "foo"},
+			expected: "bar",
+		},
+		{
+			name:        "fcall_ro on a function that writes",
+			cmd:         "FCALL_RO",
+			args:        []string{"setget", "1", "foo", "bar"},
+			expectedErr: db.ErrWriteFunctionRO,
+		},
+		{
+			name:        "write from no-writes function",
+			cmd:         "FCALL",
+			args:        []string{"sneaky", "1", "foo"},
+			expectedErr: db.ErrWriteFromReadOnly,
+		},
+		{
+			name:        "unknown function",
+			cmd:         "FCALL",
+			args:        []string{"nope", "0"},
+			expectedErr: db.ErrFunctionNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := store.Execute(ctx, tc.cmd, tc.args, nil)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestFunctionDumpRestore(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewDB()
+
+	_, err := store.Execute(ctx, "FUNCTION", []string{"LOAD", testLibrary}, nil)
+	assert.NoError(t, err)
+
+	payload, err := store.Execute(ctx, "FUNCTION", []string{"DUMP"}, nil)
+	assert.NoError(t, err)
+
+	_, err = store.Execute(ctx, "FUNCTION", []string{"RESTORE", payload.(string)}, nil)
+	assert.Equal(t, errors.New("ERR Library 'mylib' already exists"), err)
+
+	_, err = store.Execute(ctx, "FUNCTION", []string{"DELETE", "mylib"}, nil)
+	assert.NoError(t, err)
+
+	_, err = store.Execute(ctx, "FCALL", []string{"get", "1", "foo"}, nil)
+	assert.Equal(t, db.ErrFunctionNotFound, err)
+
+	_, err = store.Execute(ctx, "FUNCTION", []string{"RESTORE", payload.(string)}, nil)
+	assert.NoError(t, err)
+
+	res, err := store.Execute(ctx, "FUNCTION", []string{"LIST", "LIBRARYNAME", "my*"}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, res, 1)
+
+	_, err = store.Execute(ctx, "FUNCTION", []string{"RESTORE", "garbage"}, nil)
+	assert.Equal(t, db.ErrBadFunctionPayload, err)
+
+	// a library changed after the dump does not match the checksum
+	corrupted := strings.Replace(payload.(string), "mylib", "evlib", 1)
+	_, err = store.Execute(ctx, "FUNCTION", []string{"RESTORE", corrupted, "REPLACE"}, nil)
+	assert.Equal(t, db.ErrBadFunctionPayload, err)
+}
+
+func TestFunctionRestoreIsAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	library := func(name, function, reply string) string {
+		return fmt.Sprintf("#!lua name=%s\nredis.register_function('%s', function() return '%s' end)", name, function, reply)
+	}
+	dump := func(libraries ...string) string {
+		source := db.NewDB()
+		for _, code := range libraries {
+			_, err := source.Execute(ctx, "FUNCTION", []string{"LOAD", code}, nil)
+			require.NoError(t, err)
+		}
+		payload, err := source.Execute(ctx, "FUNCTION", []string{"DUMP"}, nil)
+		require.NoError(t, err)
+		return payload.(string)
+	}
+
+	// a payload can not hold two libraries with the same function, one is
+	// added to it by hand and the checksum computed again
+	prefix, data, _ := strings.Cut(dump(library("first", "one", "new")), ":")
+	data, _, _ = strings.Cut(data, "\n")
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal([]byte(data), &payload))
+	payload["libraries"] = append(payload["libraries"].([]any), library("third", "one", "new"))
+	conflicting, err := json.Marshal(payload)
+	require.NoError(t, err)
+	checksum := strconv.FormatUint(crc64.Checksum(conflicting, crc64.MakeTable(crc64.ECMA)), 16)
+
+	tests := []struct {
+		name    string
+		payload string
+		policy  string
+
+		expectedErr error
+	}{
+		{
+			name:        "replace with a function of a library that stays",
+			payload:     dump(library("first", "one", "new"), library("other", "two", "new")),
+			policy:      "REPLACE",
+			expectedErr: errors.New("ERR Function two already exists"),
+		},
+		{
+			name:        "flush with a function given twice",
+			payload:     prefix + ":" + string(conflicting) + "\n" + checksum,
+			policy:      "FLUSH",
+			expectedErr: errors.New("ERR Function one already exists"),
+		},
+		{
+			name:        "append an existing library",
+			payload:     dump(library("other", "three", "new"), library("second", "four", "new")),
+			policy:      "APPEND",
+			expectedErr: errors.New("ERR Library 'second' already exists"),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := db.NewDB()
+			for _, code := range []string{library("first", "one", "old"), library("second", "two", "old")} {
+				_, err := store.Execute(ctx, "FUNCTION", []string{"LOAD", code}, nil)
+				require.NoError(t, err)
+			}
+
+			_, err := store.Execute(ctx, "FUNCTION", []string{"RESTORE", tc.payload, tc.policy}, nil)
+			assert.Equal(t, tc.expectedErr, err)
+
+			// the libraries are left as they were, and can still run
+			for _, function := range []string{"one", "two"} {
+				res, err := store.Execute(ctx, "FCALL", []string{function, "0"}, nil)
+				assert.NoError(t, err)
+				assert.Equal(t, "old", res)
+			}
+			for _, function := range []string{"three", "four"} {
+				_, err := store.Execute(ctx, "FCALL", []string{function, "0"}, nil)
+				assert.Equal(t, db.ErrFunctionNotFound, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,449 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	functionEngine      = "LUA"
+	functionLoadTimeout = 500 * time.Millisecond
+	functionDumpVersion = 1
+	functionDumpPrefix  = "sider-functions:"
+)
+
+var (
+	ErrFunctionNotFound   = errors.New("ERR Function not found")
+	ErrLibraryNotFound    = errors.New("ERR Library not found")
+	ErrMissingMetadata    = errors.New("ERR Missing library metadata")
+	ErrNoFunctions        = errors.New("ERR No functions registered")
+	ErrFunctionLoadTime   = errors.New("ERR FUNCTION LOAD timeout")
+	ErrWriteFunctionRO    = errors.New("ERR Can not execute a script with write flag using *_ro command.")
+	ErrBadFunctionPayload = errors.New("ERR payload version or checksum are wrong")
+
+	validFunctionName = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+	functionDumpTable = crc64.MakeTable(crc64.ECMA)
+)
+
+// functionFlags are the flags a function can declare when it is registered.
+var functionFlags = map[string]bool{
+	"no-writes":             true,
+	"allow-oom":             true,
+	"allow-stale":           true,
+	"no-cluster":            true,
+	"allow-cross-slot-keys": true,
+}
+
+// functionLibrary is a loaded library, each library keeps its own lua state
+// alive since the registered functions are closures living inside of it.
+type functionLibrary struct {
+	name      string
+	code      string
+	state     *lua.LState
+	inv       *luaInvocation
+	functions []*function
+	loading   bool
+}
+
+type function struct {
+	name        string
+	description string
+	flags       []string
+	callback    *lua.LFunction
+	library     *functionLibrary
+}
+
+// functions is the registry of loaded libraries, it is only accessed while
+// holding the store lock.
+type functions struct {
+	libraries map[string]*functionLibrary
+	functions map[string]*function
+}
+
+func newFunctions() *functions {
+	return &functions{
+		libraries: make(map[string]*functionLibrary),
+		functions: make(map[string]*function),
+	}
+}
+
+func (f *function) hasFlag(flag string) bool {
+	for _, fl := range f.flags {
+		if fl == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// add registers a library, if replace is set a library with the same name is
+// replaced otherwise it is an error.
+func (fs *functions) add(lib *functionLibrary, replace bool) error {
+	old, err := fs.put(lib, replace)
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		old.state.Close()
+	}
+	return nil
+}
+
+// put is add without closing the library it replaces, which it returns.
+func (fs *functions) put(lib *functionLibrary, replace bool) (*functionLibrary, error) {
+	old, exists := fs.libraries[lib.name]
+	if exists && !replace {
+		return nil, fmt.Errorf("ERR Library '%s' already exists", lib.name)
+	}
+
+	for _, fn := range lib.functions {
+		if other, ok := fs.functions[fn.name]; ok && other.library != old {
+			return nil, fmt.Errorf("ERR Function %s already exists", fn.name)
+		}
+	}
+
+	if exists {
+		fs.unregister(old)
+	}
+
+	fs.libraries[lib.name] = lib
+	for _, fn := range lib.functions {
+		fs.functions[fn.name] = fn
+	}
+	return old, nil
+}
+
+func (fs *functions) remove(lib *functionLibrary) {
+	fs.unregister(lib)
+	lib.state.Close()
+}
+
+func (fs *functions) unregister(lib *functionLibrary) {
+	for _, fn := range lib.functions {
+		delete(fs.functions, fn.name)
+	}
+	delete(fs.libraries, lib.name)
+}
+
+// clone returns a copy of the registry sharing its libraries.
+func (fs *functions) clone() *functions {
+	c := newFunctions()
+	for name, lib := range fs.libraries {
+		c.libraries[name] = lib
+	}
+	for name, fn := range fs.functions {
+		c.functions[name] = fn
+	}
+	return c
+}
+
+func (fs *functions) flush() {
+	for _, lib := range fs.libraries {
+		lib.state.Close()
+	}
+
+	fs.libraries = make(map[string]*functionLibrary)
+	fs.functions = make(map[string]*function)
+}
+
+// sorted returns the libraries ordered by name.
+func (fs *functions) sorted() []*functionLibrary {
+	libs := make([]*functionLibrary, 0, len(fs.libraries))
+	for _, lib := range fs.libraries {
+		libs = append(libs, lib)
+	}
+
+	sort.Slice(libs, func(i, j int) bool {
+		return libs[i].name < libs[j].name
+	})
+	return libs
+}
+
+// dump serializes every library so they can be restored with restore. The
+// libraries are followed by a checksum of them, on its own line.
+func (fs *functions) dump() (string, error) {
+	payload := struct {
+		Version   int      `json:"version"`
+		Libraries []string `json:"libraries"`
+	}{Version: functionDumpVersion}
+
+	for _, lib := range fs.sorted() {
+		payload.Libraries = append(payload.Libraries, lib.code)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return functionDumpPrefix + string(data) + "\n" + dumpChecksum(data), nil
+}
+
+func dumpChecksum(data []byte) string {
+	return strconv.FormatUint(crc64.Checksum(data, functionDumpTable), 16)
+}
+
+func (d *DB) restoreFunctions(payload string, policy string) error {
+	payload, ok := strings.CutPrefix(payload, functionDumpPrefix)
+	if !ok {
+		return ErrBadFunctionPayload
+	}
+
+	data, checksum, ok := strings.Cut(payload, "\n")
+	if !ok || dumpChecksum([]byte(data)) != checksum {
+		return ErrBadFunctionPayload
+	}
+
+	var dump struct {
+		Version   int      `json:"version"`
+		Libraries []string `json:"libraries"`
+	}
+	if err := json.Unmarshal([]byte(data), &dump); err != nil {
+		return ErrBadFunctionPayload
+	}
+
+	if dump.Version != functionDumpVersion {
+		return ErrBadFunctionPayload
+	}
+
+	// everything is loaded up front so a broken payload leaves the registry
+	// untouched
+	libs := make([]*functionLibrary, 0, len(dump.Libraries))
+	closeAll := func() {
+		for _, lib := range libs {
+			lib.state.Close()
+		}
+	}
+
+	for _, code := range dump.Libraries {
+		lib, err := d.loadLibrary(code)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		libs = append(libs, lib)
+	}
+
+	// the libraries are added to a copy of the registry, which only replaces
+	// it once all of them were added
+	staged := newFunctions()
+	if policy != "FLUSH" {
+		staged = d.functions.clone()
+	}
+	for _, lib := range libs {
+		if _, err := staged.put(lib, policy == "REPLACE"); err != nil {
+			closeAll()
+			return err
+		}
+	}
+
+	// the libraries flushed or replaced are closed
+	for _, lib := range append(d.functions.sorted(), libs...) {
+		if staged.libraries[lib.name] != lib {
+			lib.state.Close()
+		}
+	}
+	*d.functions = *staged
+
+	return nil
+}
+
+// parseLibraryMetadata reads the shebang line at the top of a library, e.g.
+// "#!lua name=mylib", and returns the library name.
+func parseLibraryMetadata(code string) (string, error) {
+	if !strings.HasPrefix(code, "#!") {
+		return "", ErrMissingMetadata
+	}
+
+	line, _, _ := strings.Cut(code, "\n")
+	parts := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(parts) == 0 {
+		return "", ErrMissingMetadata
+	}
+
+	if !strings.EqualFold(parts[0], functionEngine) {
+		return "", fmt.Errorf("ERR Engine '%s' not found", parts[0])
+	}
+
+	name := ""
+	for _, part := range parts[1:] {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || key != "name" {
+			return "", fmt.Errorf("ERR Invalid metadata value given: %s", part)
+		}
+		name = val
+	}
+
+	if name == "" {
+		return "", errors.New("ERR Library name was not given")
+	}
+
+	if !validFunctionName.MatchString(name) {
+		return "", errors.New("ERR Library names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	}
+
+	return name, nil
+}
+
+// loadLibrary runs the library code in a fresh lua state, collecting the
+// functions it registers. The library is not added to the registry.
+func (d *DB) loadLibrary(code string) (*functionLibrary, error) {
+	name, err := parseLibraryMetadata(code)
+	if err != nil {
+		return nil, err
+	}
+
+	// the shebang is not valid lua, it is blanked so line numbers still match
+	body := code
+	if i := strings.Index(code, "\n"); i >= 0 {
+		body = code[i:]
+	} else {
+		body = ""
+	}
+
+	proto, err := compileScript("@user_function", body)
+	if err != nil {
+		return nil, err
+	}
+
+	lib := &functionLibrary{
+		name:    name,
+		code:    code,
+		state:   newLuaState(),
+		inv:     &luaInvocation{},
+		loading: true,
+	}
+
+	mod := d.luaRedisModule(lib.state, lib.inv)
+	mod.RawSetString("register_function", lib.state.NewFunction(lib.registerFunction))
+	lib.state.SetGlobal("redis", mod)
+
+	ctx, cancel := context.WithTimeout(context.Background(), functionLoadTimeout)
+	defer cancel()
+
+	lib.state.SetContext(ctx)
+	lib.state.Push(lib.state.NewFunctionFromProto(proto))
+	err = lib.state.PCall(0, 0, nil)
+	lib.state.RemoveContext()
+	lib.loading = false
+
+	if err != nil {
+		lib.state.Close()
+		if ctx.Err() != nil {
+			return nil, ErrFunctionLoadTime
+		}
+		return nil, luaError("@user_function", err)
+	}
+
+	if len(lib.functions) == 0 {
+		lib.state.Close()
+		return nil, ErrNoFunctions
+	}
+
+	return lib, nil
+}
+
+// registerFunction implements redis.register_function, it accepts both the
+// positional form (name, callback) and the table form with named arguments.
+func (lib *functionLibrary) registerFunction(L *lua.LState) int {
+	if !lib.loading {
+		L.RaiseError("redis.register_function can only be called on FUNCTION LOAD command")
+		return 0
+	}
+
+	fn := &function{library: lib}
+
+	switch arg := L.Get(1).(type) {
+	case lua.LString:
+		fn.name = string(arg)
+		fn.callback = L.CheckFunction(2)
+	case *lua.LTable:
+		name, ok := arg.RawGetString("function_name").(lua.LString)
+		if !ok {
+			L.RaiseError("function_name argument given to redis.register_function must be a string")
+			return 0
+		}
+		fn.name = string(name)
+
+		callback, ok := arg.RawGetString("callback").(*lua.LFunction)
+		if !ok {
+			L.RaiseError("callback argument given to redis.register_function must be a function")
+			return 0
+		}
+		fn.callback = callback
+
+		if desc, ok := arg.RawGetString("description").(lua.LString); ok {
+			fn.description = string(desc)
+		}
+
+		if flags, ok := arg.RawGetString("flags").(*lua.LTable); ok {
+			for i := 1; i <= flags.Len(); i++ {
+				flag := flags.RawGetInt(i).String()
+				if !functionFlags[flag] {
+					L.RaiseError("unknown flag given")
+					return 0
+				}
+				fn.flags = append(fn.flags, flag)
+			}
+		}
+	default:
+		L.RaiseError("wrong number of arguments to redis.register_function")
+		return 0
+	}
+
+	if !validFunctionName.MatchString(fn.name) {
+		L.RaiseError("Function names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+		return 0
+	}
+
+	for _, other := range lib.functions {
+		if other.name == fn.name {
+			L.RaiseError("Function already exists in the library")
+			return 0
+		}
+	}
+
+	lib.functions = append(lib.functions, fn)
+	return 0
+}
+
+// callFunction invokes a registered function, the caller must hold the store
+// lock.
+func (d *DB) callFunction(ctx context.Context, fn *function, keys, args []string, readOnly bool) (any, error) {
+	if readOnly && !fn.hasFlag("no-writes") {
+		return nil, ErrWriteFunctionRO
+	}
+
+	ctx, running, done := d.scripts.start(ctx)
+	defer done()
+
+	lib := fn.library
+	L := lib.state
+
+	*lib.inv = luaInvocation{ctx: ctx, running: running, readOnly: fn.hasFlag("no-writes")}
+	L.SetContext(ctx)
+	defer func() {
+		L.RemoveContext()
+		*lib.inv = luaInvocation{}
+	}()
+
+	top := L.GetTop()
+	L.Push(fn.callback)
+	L.Push(luaArray(L, keys))
+	L.Push(luaArray(L, args))
+
+	res, err := luaResult(L, fn.name, running, L.PCall(2, 1, nil))
+	L.SetTop(top)
+	return res, err
+}
@@ -1,6 +1,10 @@
 package db
 
-import "context"
+import (
+	"context"
+
+	"github.com/aelnahas/sider/commands"
+)
 
 type getCmd struct {
 	key string
@@ -8,12 +12,19 @@ type getCmd struct {
 	store *DB
 }
 
-func (g *getCmd) Read(args []string, _ map[string]any) error {
+func (g *getCmd) Read(args []string, _ *commands.Args) error {
 	g.key = args[0]
 
 	return nil
 }
 
 func (g *getCmd) Execute(ctx context.Context) (any, error) {
+	if !g.store.store.exists(ctx, g.key) {
+		g.store.stats.misses.Add(1)
+		g.store.notify(notifyKeyMiss, "keymiss", g.key)
+		return nil, nil
+	}
+
+	g.store.stats.hits.Add(1)
 	return g.store.store.get(ctx, g.key)
 }
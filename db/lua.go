@@ -0,0 +1,299 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/resp"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	luaLogDebug = iota
+	luaLogVerbose
+	luaLogNotice
+	luaLogWarning
+)
+
+var (
+	ErrNotAllowedFromScript = errors.New("ERR This Redis command is not allowed from script")
+	ErrWriteFromReadOnly    = errors.New("ERR Write commands are not allowed from read-only scripts.")
+	ErrCallOutsideScript    = errors.New("ERR redis.call can only be used while a script or function is running")
+)
+
+// luaInvocation holds what redis.call needs to run commands on behalf of the
+// script or function that is currently executing. It is empty while a function
+// library is being loaded.
+type luaInvocation struct {
+	ctx      context.Context
+	running  *runningScript
+	readOnly bool
+}
+
+// runScript executes a compiled script with KEYS and ARGV set, the caller must
+// hold the store lock.
+func (d *DB) runScript(ctx context.Context, name string, proto *lua.FunctionProto, keys, args []string) (any, error) {
+	ctx, running, done := d.scripts.start(ctx)
+	defer done()
+
+	L := newLuaState()
+	defer L.Close()
+	L.SetContext(ctx)
+
+	L.SetGlobal("KEYS", luaArray(L, keys))
+	L.SetGlobal("ARGV", luaArray(L, args))
+	L.SetGlobal("redis", d.luaRedisModule(L, &luaInvocation{ctx: ctx, running: running}))
+
+	L.Push(L.NewFunctionFromProto(proto))
+	return luaResult(L, name, running, L.PCall(0, 1, nil))
+}
+
+// luaResult converts the outcome of a protected call into a command result,
+// on success the returned value is popped from the stack.
+func luaResult(L *lua.LState, name string, running *runningScript, err error) (any, error) {
+	if err != nil {
+		if running.killed.Load() {
+			return nil, ErrKilled
+		}
+		return nil, luaError(name, err)
+	}
+
+	res := fromLua(L.Get(-1))
+	L.Pop(1)
+
+	if err, ok := res.(error); ok {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func newLuaState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	// scripts must not be able to reach the file system
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	return L
+}
+
+func (d *DB) luaRedisModule(L *lua.LState, inv *luaInvocation) *lua.LTable {
+	mod := L.NewTable()
+
+	L.SetFuncs(mod, map[string]lua.LGFunction{
+		"call": func(L *lua.LState) int {
+			res, err := d.luaCall(L, inv)
+			if err != nil {
+				L.Error(luaErrorReply(L, err.Error()), 1)
+				return 0
+			}
+			L.Push(toLua(L, res))
+			return 1
+		},
+		"pcall": func(L *lua.LState) int {
+			res, err := d.luaCall(L, inv)
+			if err != nil {
+				L.Push(luaErrorReply(L, err.Error()))
+				return 1
+			}
+			L.Push(toLua(L, res))
+			return 1
+		},
+		"error_reply": func(L *lua.LState) int {
+			L.Push(luaErrorReply(L, L.CheckString(1)))
+			return 1
+		},
+		"status_reply": func(L *lua.LState) int {
+			status := L.NewTable()
+			status.RawSetString("ok", lua.LString(L.CheckString(1)))
+			L.Push(status)
+			return 1
+		},
+		"sha1hex": func(L *lua.LState) int {
+			L.Push(lua.LString(sha1hex(L.CheckString(1))))
+			return 1
+		},
+		"log": func(L *lua.LState) int {
+			level := L.CheckInt(1)
+			msg := ""
+			for i := 2; i <= L.GetTop(); i++ {
+				msg += L.ToStringMeta(L.Get(i)).String()
+			}
+
+			switch level {
+			case luaLogDebug, luaLogVerbose:
+				slog.Debug(msg, "source", "script")
+			case luaLogNotice:
+				slog.Info(msg, "source", "script")
+			default:
+				slog.Warn(msg, "source", "script")
+			}
+			return 0
+		},
+	})
+
+	mod.RawSetString("LOG_DEBUG", lua.LNumber(luaLogDebug))
+	mod.RawSetString("LOG_VERBOSE", lua.LNumber(luaLogVerbose))
+	mod.RawSetString("LOG_NOTICE", lua.LNumber(luaLogNotice))
+	mod.RawSetString("LOG_WARNING", lua.LNumber(luaLogWarning))
+
+	return mod
+}
+
+// luaCall runs the command given as arguments to redis.call or redis.pcall.
+func (d *DB) luaCall(L *lua.LState, inv *luaInvocation) (any, error) {
+	if inv.running == nil {
+		return nil, ErrCallOutsideScript
+	}
+
+	if L.GetTop() == 0 {
+		return nil, errors.New("ERR Please specify at least one argument for this redis lib call")
+	}
+
+	args := make([]string, 0, L.GetTop())
+	for i := 1; i <= L.GetTop(); i++ {
+		switch arg := L.Get(i).(type) {
+		case lua.LString, lua.LNumber:
+			args = append(args, arg.String())
+		default:
+			return nil, errors.New("ERR Lua redis lib command arguments must be strings or integers")
+		}
+	}
+
+	cmd, err := resp.ParseArgs(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if command, _ := commands.Resolve(cmd.Name, cmd.Args); command.HasFlag(commands.FlagNoScript) {
+		return nil, ErrNotAllowedFromScript
+	}
+
+	if d.authorizer != nil {
+		if err := d.authorizer.Authorize(inv.ctx, cmd.Name, cmd.Args); err != nil {
+			return nil, err
+		}
+	}
+
+	if IsWrite(cmd.Name) {
+		if inv.readOnly {
+			return nil, ErrWriteFromReadOnly
+		}
+		inv.running.wrote.Store(true)
+	}
+
+	return d.execute(inv.ctx, cmd.Name, cmd.Args, cmd.Parsed)
+}
+
+func luaArray(L *lua.LState, items []string) *lua.LTable {
+	table := L.CreateTable(len(items), 0)
+	for _, item := range items {
+		table.Append(lua.LString(item))
+	}
+	return table
+}
+
+func luaErrorReply(L *lua.LState, msg string) *lua.LTable {
+	reply := L.NewTable()
+	reply.RawSetString("err", lua.LString(msg))
+	return reply
+}
+
+// luaError turns an error raised inside a script into the error sent back to
+// the client. Errors raised with a redis error reply are passed through as is.
+func luaError(name string, err error) error {
+	var apiErr *lua.ApiError
+	if !errors.As(err, &apiErr) {
+		return fmt.Errorf("ERR Error running script (call to %s): %s", name, err.Error())
+	}
+
+	if table, ok := apiErr.Object.(*lua.LTable); ok {
+		if msg, ok := table.RawGetString("err").(lua.LString); ok {
+			return errors.New(string(msg))
+		}
+	}
+
+	return fmt.Errorf("ERR Error running script (call to %s): %s", name, apiErr.Object.String())
+}
+
+// toLua converts a command result into a lua value following the same rules
+// as redis: nil becomes false, arrays become tables and errors become tables
+// with an err field.
+func toLua(L *lua.LState, val any) lua.LValue {
+	switch val := val.(type) {
+	case nil:
+		return lua.LFalse
+	case string:
+		return lua.LString(val)
+	case resp.SimpleString:
+		status := L.NewTable()
+		status.RawSetString("ok", lua.LString(val))
+		return status
+	case int:
+		return lua.LNumber(val)
+	case error:
+		return luaErrorReply(L, val.Error())
+	case []any:
+		table := L.CreateTable(len(val), 0)
+		for _, item := range val {
+			table.Append(toLua(L, item))
+		}
+		return table
+	default:
+		return lua.LString(fmt.Sprint(val))
+	}
+}
+
+// fromLua converts the value returned by a script into a command result.
+// Numbers are truncated to integers and arrays stop at the first nil.
+func fromLua(val lua.LValue) any {
+	switch val := val.(type) {
+	case lua.LBool:
+		if val {
+			return 1
+		}
+		return nil
+	case lua.LNumber:
+		return int(val)
+	case lua.LString:
+		return string(val)
+	case *lua.LTable:
+		if msg, ok := val.RawGetString("err").(lua.LString); ok {
+			return errors.New(string(msg))
+		}
+		if status, ok := val.RawGetString("ok").(lua.LString); ok {
+			return resp.SimpleString(status)
+		}
+
+		items := make([]any, 0, val.Len())
+		for i := 1; ; i++ {
+			item := val.RawGetInt(i)
+			if item == lua.LNil {
+				break
+			}
+			items = append(items, fromLua(item))
+		}
+		return items
+	default:
+		return nil
+	}
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 )
 
 type record struct {
@@ -10,21 +11,46 @@ type record struct {
 	val any
 }
 
+// watchedKey tracks how many connections are watching a key and bumps its
+// version every time the key is touched, so transactions can detect changes.
+type watchedKey struct {
+	refs    int
+	version uint64
+}
+
+// KeyObserver is told about every key that is modified, ctx is the context of
+// the command that modified it. It is called while holding the store lock so
+// it must not block.
+type KeyObserver interface {
+	KeyModified(ctx context.Context, key string)
+}
+
+// memory is not safe for concurrent use on its own, callers are expected to
+// hold the lock for the whole duration of a command.
 type memory struct {
 	sync.Mutex
-	data map[string]*record
+	data     map[string]*record
+	watched  map[string]*watchedKey
+	observer KeyObserver
+
+	// size is the number of keys, it can be read without holding the lock
+	size atomic.Int64
 }
 
 func newMemory() *memory {
 	return &memory{
-		data: make(map[string]*record),
+		data:    make(map[string]*record),
+		watched: make(map[string]*watchedKey),
 	}
 }
 
 func (m *memory) set(ctx context.Context, record *record) error {
-	m.Lock()
-	defer m.Unlock()
+	if _, found := m.data[record.key]; !found {
+		m.size.Add(1)
+	}
+
 	m.data[record.key] = record
+	m.modified(ctx, record.key)
 	return nil
 }
 
@@ -38,9 +64,13 @@ func (m *memory) get(ctx context.Context, key string) (any, error) {
 }
 
 func (m *memory) del(ctx context.Context, key string) error {
-	m.Lock()
+	if _, found := m.data[key]; !found {
+		return nil
+	}
+
 	delete(m.data, key)
-	m.Unlock()
+	m.size.Add(-1)
+	m.modified(ctx, key)
 	return nil
 }
 
@@ -48,3 +78,48 @@ func (m *memory) exists(ctx context.Context, key string) bool {
 	_, found := m.data[key]
 	return found
 }
+
+func (m *memory) modified(ctx context.Context, key string) {
+	m.touch(key)
+
+	if m.observer != nil {
+		m.observer.KeyModified(ctx, key)
+	}
+}
+
+func (m *memory) touch(key string) {
+	if w, ok := m.watched[key]; ok {
+		w.version++
+	}
+}
+
+func (m *memory) watch(key string) uint64 {
+	w, ok := m.watched[key]
+	if !ok {
+		w = &watchedKey{}
+		m.watched[key] = w
+	}
+
+	w.refs++
+	return w.version
+}
+
+func (m *memory) unwatch(key string) {
+	w, ok := m.watched[key]
+	if !ok {
+		return
+	}
+
+	w.refs--
+	if w.refs <= 0 {
+		delete(m.watched, key)
+	}
+}
+
+func (m *memory) version(key string) uint64 {
+	if w, ok := m.watched[key]; ok {
+		return w.version
+	}
+
+	return 0
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aelnahas/sider/commands"
+)
+
+var ErrWatchedKeyModified = errors.New("watched key modified")
+
+// Invocation is a command that has been parsed but not executed yet, like the
+// commands queued between MULTI and EXEC.
+type Invocation struct {
+	Name   string
+	Args   []string
+	Parsed *commands.Args
+}
+
+// Watch starts watching keys and returns the version each of them had at the
+// time of the call. The returned versions are handed back to ExecuteMulti.
+func (d *DB) Watch(keys []string, versions map[string]uint64) map[string]uint64 {
+	d.store.Lock()
+	defer d.store.Unlock()
+
+	if versions == nil {
+		versions = make(map[string]uint64, len(keys))
+	}
+
+	for _, key := range keys {
+		if _, ok := versions[key]; ok {
+			continue
+		}
+		versions[key] = d.store.watch(key)
+	}
+
+	return versions
+}
+
+// Unwatch releases every key in versions.
+func (d *DB) Unwatch(versions map[string]uint64) {
+	d.store.Lock()
+	defer d.store.Unlock()
+
+	for key := range versions {
+		d.store.unwatch(key)
+	}
+}
+
+// ExecuteMulti runs every queued command while holding the store lock so no
+// other client can observe or modify the keyspace half way through. If any of
+// the watched keys changed since they were watched nothing is executed and
+// ErrWatchedKeyModified is returned. Errors from individual commands do not
+// stop the transaction, they are returned in place of the command result.
+func (d *DB) ExecuteMulti(ctx context.Context, watched map[string]uint64, cmds []Invocation) ([]any, error) {
+	if d.scripts.busy() {
+		return nil, ErrBusy
+	}
+
+	d.store.Lock()
+	defer d.store.Unlock()
+
+	for key, version := range watched {
+		if d.store.version(key) != version {
+			return nil, ErrWatchedKeyModified
+		}
+	}
+
+	results := make([]any, 0, len(cmds))
+	for _, cmd := range cmds {
+		result, err := d.execute(ctx, cmd.Name, cmd.Args, cmd.Parsed)
+		if err != nil {
+			results = append(results, err)
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
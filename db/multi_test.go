@@ -0,0 +1,66 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aelnahas/sider/db"
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteMulti(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		touch []db.Invocation
+
+		expected    []any
+		expectedErr error
+	}{
+		{
+			name:     "untouched watched key",
+			expected: []any{resp.OK, "2"},
+		},
+		{
+			name:        "watched key modified",
+			touch:       []db.Invocation{{Name: "SET", Args: []string{"foo", "3"}}},
+			expectedErr: db.ErrWatchedKeyModified,
+		},
+		{
+			name:        "watched key deleted",
+			touch:       []db.Invocation{{Name: "DEL", Args: []string{"foo"}}},
+			expectedErr: db.ErrWatchedKeyModified,
+		},
+		{
+			name:     "other key modified",
+			touch:    []db.Invocation{{Name: "SET", Args: []string{"bar", "3"}}},
+			expected: []any{resp.OK, "2"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := db.NewDB()
+			_, err := store.Execute(ctx, "SET", []string{"foo", "1"}, nil)
+			assert.NoError(t, err)
+
+			watched := store.Watch([]string{"foo"}, nil)
+			defer store.Unwatch(watched)
+
+			for _, cmd := range tc.touch {
+				_, err := store.Execute(ctx, cmd.Name, cmd.Args, cmd.Parsed)
+				assert.NoError(t, err)
+			}
+
+			res, err := store.ExecuteMulti(ctx, watched, []db.Invocation{
+				{Name: "SET", Args: []string{"foo", "2"}},
+				{Name: "GET", Args: []string{"foo"}},
+			})
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
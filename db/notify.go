@@ -0,0 +1,141 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Publisher is where keyspace notifications are published to, it is
+// satisfied by pubsub.Broker.
+type Publisher interface {
+	Publish(topic string, data any) int
+}
+
+// notifyClass is a set of keyspace notification classes, configured through
+// notify-keyspace-events.
+type notifyClass int
+
+const (
+	notifyKeyspace notifyClass = 1 << iota
+	notifyKeyevent
+	notifyGeneric
+	notifyString
+	notifyList
+	notifySet
+	notifyHash
+	notifyZSet
+	notifyExpired
+	notifyEvicted
+	notifyStream
+	notifyKeyMiss
+	notifyNew
+
+	// notifyAll is what the A flag stands for, it leaves out key misses and
+	// new keys like redis does.
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash | notifyZSet | notifyExpired | notifyEvicted | notifyStream
+)
+
+var notifyFlags = []struct {
+	flag  byte
+	class notifyClass
+}{
+	{'g', notifyGeneric},
+	{'$', notifyString},
+	{'l', notifyList},
+	{'s', notifySet},
+	{'h', notifyHash},
+	{'z', notifyZSet},
+	{'x', notifyExpired},
+	{'e', notifyEvicted},
+	{'t', notifyStream},
+	{'m', notifyKeyMiss},
+	{'n', notifyNew},
+	{'K', notifyKeyspace},
+	{'E', notifyKeyevent},
+}
+
+func parseNotifyClasses(flags string) (notifyClass, error) {
+	var classes notifyClass
+
+outer:
+	for i := 0; i < len(flags); i++ {
+		if flags[i] == 'A' {
+			classes |= notifyAll
+			continue
+		}
+
+		for _, f := range notifyFlags {
+			if f.flag == flags[i] {
+				classes |= f.class
+				continue outer
+			}
+		}
+
+		return 0, fmt.Errorf("ERR Invalid event class character '%c' in notify-keyspace-events", flags[i])
+	}
+
+	return classes, nil
+}
+
+func (c notifyClass) String() string {
+	var sb strings.Builder
+
+	if c&notifyAll == notifyAll {
+		sb.WriteByte('A')
+	}
+
+	for _, f := range notifyFlags {
+		if c&notifyAll == notifyAll && f.class&notifyAll != 0 {
+			continue
+		}
+		if c&f.class != 0 {
+			sb.WriteByte(f.flag)
+		}
+	}
+
+	return sb.String()
+}
+
+// SetNotifyKeyspaceEvents sets which keyspace notifications are published,
+// flags uses the same characters as notify-keyspace-events in redis.
+func (d *DB) SetNotifyKeyspaceEvents(flags string) error {
+	classes, err := parseNotifyClasses(flags)
+	if err != nil {
+		return err
+	}
+
+	d.notifyClasses.Store(int64(classes))
+	return nil
+}
+
+// NormalizeNotifyKeyspaceEvents validates notify-keyspace-events flags and
+// returns them the way NotifyKeyspaceEvents reports them.
+func NormalizeNotifyKeyspaceEvents(flags string) (string, error) {
+	classes, err := parseNotifyClasses(flags)
+	if err != nil {
+		return "", err
+	}
+	return classes.String(), nil
+}
+
+// NotifyKeyspaceEvents returns the notify-keyspace-events flags in use.
+func (d *DB) NotifyKeyspaceEvents() string {
+	return notifyClass(d.notifyClasses.Load()).String()
+}
+
+// notify publishes a keyspace notification for an event of the given class,
+// the caller must hold the store lock.
+func (d *DB) notify(class notifyClass, event, key string) {
+	classes := notifyClass(d.notifyClasses.Load())
+	if d.publisher == nil || classes&class == 0 {
+		return
+	}
+
+	if classes&notifyKeyspace != 0 {
+		d.publisher.Publish(fmt.Sprintf("__keyspace@%d__:%s", d.index, key), event)
+	}
+
+	if classes&notifyKeyevent != 0 {
+		d.publisher.Publish(fmt.Sprintf("__keyevent@%d__:%s", d.index, event), key)
+	}
+}
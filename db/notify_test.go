@@ -0,0 +1,171 @@
+package db_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type publication struct {
+	topic string
+	data  any
+}
+
+type recorder struct {
+	sync.Mutex
+	published []publication
+}
+
+func (r *recorder) Publish(topic string, data any) int {
+	r.Lock()
+	defer r.Unlock()
+
+	r.published = append(r.published, publication{topic: topic, data: data})
+	return 0
+}
+
+func (r *recorder) all() []publication {
+	r.Lock()
+	defer r.Unlock()
+
+	return append([]publication(nil), r.published...)
+}
+
+func TestKeyspaceNotifications(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags string
+		cmd   string
+		args  []string
+
+		expected []publication
+	}{
+		{
+			name:  "disabled",
+			flags: "",
+			cmd:   "SET",
+			args:  []string{"foo", "bar"},
+		},
+		{
+			name:  "set keyspace",
+			flags: "K$",
+			cmd:   "SET",
+			args:  []string{"foo", "bar"},
+			expected: []publication{
+				{topic: "__keyspace@0__:foo", data: "set"},
+			},
+		},
+		{
+			name:  "set keyevent with new keys",
+			flags: "E$n",
+			cmd:   "SET",
+			args:  []string{"foo", "bar"},
+			expected: []publication{
+				{topic: "__keyevent@0__:new", data: "foo"},
+				{topic: "__keyevent@0__:set", data: "foo"},
+			},
+		},
+		{
+			name:  "del with all",
+			flags: "KEA",
+			cmd:   "DEL",
+			args:  []string{"existing", "missing"},
+			expected: []publication{
+				{topic: "__keyspace@0__:existing", data: "del"},
+				{topic: "__keyevent@0__:del", data: "existing"},
+			},
+		},
+		{
+			name:  "class not enabled",
+			flags: "KE$",
+			cmd:   "DEL",
+			args:  []string{"existing"},
+		},
+		{
+			name:  "key miss",
+			flags: "Em",
+			cmd:   "GET",
+			args:  []string{"missing"},
+			expected: []publication{
+				{topic: "__keyevent@0__:keymiss", data: "missing"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			rec := &recorder{}
+			store := db.NewDB(db.WithPublisher(rec))
+
+			_, err := store.Execute(ctx, "SET", []string{"existing", "1"}, nil)
+			assert.NoError(t, err)
+
+			assert.NoError(t, store.SetNotifyKeyspaceEvents(tc.flags))
+			_, err = store.Execute(ctx, tc.cmd, tc.args, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, rec.all())
+		})
+	}
+}
+
+func TestExpiredNotification(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	store := db.NewDB(db.WithPublisher(rec))
+	assert.NoError(t, store.SetNotifyKeyspaceEvents("Ex"))
+
+	_, err := store.Execute(ctx, "SET", []string{"foo", "bar", "PX", "10"}, parse(t, "SET", "foo", "bar", "PX", "10"))
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return len(rec.all()) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []publication{{topic: "__keyevent@0__:expired", data: "foo"}}, rec.all())
+}
+
+func TestNotifyKeyspaceEventsFlags(t *testing.T) {
+	store := db.NewDB()
+
+	assert.NoError(t, store.SetNotifyKeyspaceEvents("KEA"))
+	assert.Equal(t, "AKE", store.NotifyKeyspaceEvents())
+
+	assert.NoError(t, store.SetNotifyKeyspaceEvents("Kx$"))
+	assert.Equal(t, "$xK", store.NotifyKeyspaceEvents())
+
+	assert.Error(t, store.SetNotifyKeyspaceEvents("KQ"))
+}
+
+type observer struct {
+	sync.Mutex
+	keys []string
+}
+
+func (o *observer) KeyModified(_ context.Context, key string) {
+	o.Lock()
+	defer o.Unlock()
+
+	o.keys = append(o.keys, key)
+}
+
+func TestKeyObserver(t *testing.T) {
+	obs := &observer{}
+	store := db.NewDB(db.WithKeyObserver(obs))
+	ctx := context.Background()
+
+	_, err := store.Execute(ctx, "SET", []string{"foo", "bar"}, nil)
+	assert.NoError(t, err)
+	_, err = store.Execute(ctx, "GET", []string{"foo"}, nil)
+	assert.NoError(t, err)
+	_, err = store.Execute(ctx, "DEL", []string{"foo", "missing"}, nil)
+	assert.NoError(t, err)
+
+	obs.Lock()
+	defer obs.Unlock()
+	assert.Equal(t, []string{"foo", "foo"}, obs.keys)
+}
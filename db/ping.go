@@ -1,13 +1,22 @@
 package db
 
-import "context"
+import (
+	"context"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/resp"
+)
 
 type pingCmd struct {
-	echo string
+	echo any
 }
 
-func (p *pingCmd) Read(args []string, _ map[string]any) error {
-	p.echo = "PONG"
+func (p *pingCmd) Read(args []string, _ *commands.Args) error {
+	if len(args) > 1 {
+		return errWrongArgs("ping")
+	}
+
+	p.echo = resp.SimpleString("PONG")
 	if len(args) > 0 {
 		p.echo = args[0]
 	}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+const DefaultScriptTimeLimit = 5 * time.Second
+
+var (
+	ErrNoScript   = errors.New("NOSCRIPT No matching script. Please use EVAL.")
+	ErrBusy       = errors.New("BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.")
+	ErrNotBusy    = errors.New("NOTBUSY No scripts in execution right now.")
+	ErrUnkillable = errors.New("UNKILLABLE Sorry the script already executed write commands against the dataset. You can either wait the script termination or kill the server in a hard way using the SHUTDOWN NOSAVE command.")
+	ErrKilled     = errors.New("ERR Script killed by user with SCRIPT KILL...")
+)
+
+// scripts keeps the compiled scripts by their sha1 and tracks the script that
+// is currently running, there can only be one since scripts hold the store
+// lock for as long as they run.
+type scripts struct {
+	sync.Mutex
+	cache map[string]*lua.FunctionProto
+
+	timeLimit atomic.Int64
+	running   atomic.Pointer[runningScript]
+}
+
+type runningScript struct {
+	cancel context.CancelFunc
+	busy   atomic.Bool
+	wrote  atomic.Bool
+	killed atomic.Bool
+}
+
+func newScripts() *scripts {
+	s := &scripts{cache: make(map[string]*lua.FunctionProto)}
+	s.timeLimit.Store(int64(DefaultScriptTimeLimit))
+	return s
+}
+
+func sha1hex(src string) string {
+	sum := sha1.Sum([]byte(src))
+	return hex.EncodeToString(sum[:])
+}
+
+// load compiles a script and caches it, returning its sha1.
+func (s *scripts) load(src string) (string, *lua.FunctionProto, error) {
+	sha := sha1hex(src)
+
+	s.Lock()
+	defer s.Unlock()
+
+	if proto, ok := s.cache[sha]; ok {
+		return sha, proto, nil
+	}
+
+	proto, err := compileScript("f_"+sha, src)
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.cache[sha] = proto
+	return sha, proto, nil
+}
+
+func (s *scripts) get(sha string) (*lua.FunctionProto, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	proto, ok := s.cache[strings.ToLower(sha)]
+	return proto, ok
+}
+
+func (s *scripts) exists(sha string) bool {
+	_, ok := s.get(sha)
+	return ok
+}
+
+func (s *scripts) flush() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.cache = make(map[string]*lua.FunctionProto)
+}
+
+// busy reports whether a script has been running for longer than the time
+// limit, in which case other clients are refused instead of waiting on it.
+func (s *scripts) busy() bool {
+	running := s.running.Load()
+	return running != nil && running.busy.Load()
+}
+
+func (s *scripts) kill() error {
+	running := s.running.Load()
+	if running == nil {
+		return ErrNotBusy
+	}
+
+	if running.wrote.Load() {
+		return ErrUnkillable
+	}
+
+	running.killed.Store(true)
+	running.cancel()
+	return nil
+}
+
+// start marks a script as running, the returned function must be called once
+// the script is done.
+func (s *scripts) start(ctx context.Context) (context.Context, *runningScript, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	running := &runningScript{cancel: cancel}
+
+	timer := time.AfterFunc(time.Duration(s.timeLimit.Load()), func() {
+		running.busy.Store(true)
+	})
+
+	s.running.Store(running)
+
+	return ctx, running, func() {
+		timer.Stop()
+		s.running.Store(nil)
+		cancel()
+	}
+}
+
+func compileScript(name, src string) (*lua.FunctionProto, error) {
+	chunk, err := parse.Parse(strings.NewReader(src), name)
+	if err != nil {
+		return nil, fmt.Errorf("ERR Error compiling script (new function): %s", err.Error())
+	}
+
+	proto, err := lua.Compile(chunk, name)
+	if err != nil {
+		return nil, fmt.Errorf("ERR Error compiling script (new function): %s", err.Error())
+	}
+
+	return proto, nil
+}
@@ -3,13 +3,19 @@ package db
 import (
 	"context"
 	"errors"
-	"fmt"
+	"math"
 	"time"
 
 	"log/slog"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/resp"
 )
 
-var ErrSyntax = errors.New("syntax error")
+var (
+	ErrSyntax            = errors.New("syntax error")
+	ErrInvalidExpireTime = errors.New("ERR invalid expire time in 'set' command")
+)
 
 type setCmd struct {
 	key        string
@@ -21,21 +27,29 @@ type setCmd struct {
 	store *DB
 }
 
-func (s *setCmd) Read(args []string, opts map[string]any) error {
+func (s *setCmd) Read(args []string, parsed *commands.Args) error {
 	s.key = args[0]
 	s.val = args[1]
 
-	for key, opt := range opts {
-		if err := s.readOpt(key, opt); err != nil {
-			return err
-		}
+	// the options were checked against the grammar of SET by the parser, a
+	// nil parsed means none were given
+	if parsed == nil {
+		return nil
 	}
 
-	return nil
+	switch parsed.String("condition") {
+	case "nx":
+		s.expiration.SetOnKeyNotExists = true
+	case "xx":
+		s.expiration.SetOnKeyExists = true
+	}
+	s.getOldVal = parsed.Has("get")
+
+	return s.readExpiration(parsed)
 }
 
 func (s *setCmd) Execute(ctx context.Context) (any, error) {
-	var ret any = "OK"
+	var ret any = resp.OK
 	if s.getOldVal {
 		oldVal, err := s.store.store.get(ctx, s.key)
 		if err != nil {
@@ -45,16 +59,25 @@ func (s *setCmd) Execute(ctx context.Context) (any, error) {
 		ret = oldVal
 	}
 
+	isNew := !s.store.store.exists(ctx, s.key)
 	err := s.store.store.set(ctx, &record{key: s.key, val: s.val})
 
 	if s.expiration.Present {
-		s.startTTLBackground(ctx, ret != nil && ret != "OK")
+		s.startTTLBackground(ctx, ret != nil && ret != resp.OK)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	if isNew {
+		s.store.notify(notifyNew, "new", s.key)
+	}
+	s.store.notify(notifyString, "set", s.key)
+	if s.expiration.Present {
+		s.store.notify(notifyGeneric, "expire", s.key)
+	}
+
 	return ret, nil
 }
 
@@ -82,7 +105,7 @@ func (s *setCmd) setDeadline(ctx context.Context) {
 			time.Sleep(pollFreq)
 		}
 
-		if err := s.store.store.del(ctx, s.key); err != nil {
+		if err := s.store.expire(s.key); err != nil {
 			slog.Error("could not delete data after ttl expired", "error", err, "key", s.key)
 			return
 		}
@@ -98,7 +121,7 @@ func (s *setCmd) setTimer(ctx context.Context) {
 
 	go func() {
 		<-timer.C
-		err := s.store.store.del(ctx, s.key)
+		err := s.store.expire(s.key)
 
 		if err != nil {
 			slog.Error("could not delete data after ttl expired", "error", err, "key", s.key)
@@ -109,58 +132,47 @@ func (s *setCmd) setTimer(ctx context.Context) {
 	}()
 }
 
-func (s *setCmd) readOpt(name string, val any) error {
-
-	switch name {
-	case "EX", "PX", "EXAT", "PXAT":
-		return s.setExpiration(name, val)
-	case "NX", "XX":
-		return s.setExpirationPolicy(name)
-	case "KEEPTTL":
-		s.expiration.KeepTTL = true
+// readExpiration reads the expiration option, a relative one is kept as a
+// duration and an absolute one as the time it expires at.
+func (s *setCmd) readExpiration(parsed *commands.Args) error {
+	var (
+		name string
+		ttl  any
+		n    int64
+	)
+
+	switch parsed.String("expiration") {
+	case "":
 		return nil
-	case "GET":
-		s.getOldVal = true
+	case "keepttl":
+		s.expiration.KeepTTL = true
 		return nil
-	default:
-		return fmt.Errorf("syntax error")
-	}
-}
-
-func (s *setCmd) setExpirationPolicy(name string) error {
-
-	if name == "NX" {
-		if s.expiration.SetOnKeyExists {
-			return ErrSyntax
+	case "seconds":
+		n = parsed.Int("seconds")
+		name, ttl = "EX", time.Duration(n)*time.Second
+		if n > math.MaxInt64/int64(time.Second) {
+			return ErrInvalidExpireTime
 		}
-
-		s.expiration.SetOnKeyNotExists = true
-		return nil
-	}
-
-	if name == "XX" {
-		if s.expiration.SetOnKeyNotExists {
-			return ErrSyntax
+	case "milliseconds":
+		n = parsed.Int("milliseconds")
+		name, ttl = "PX", time.Duration(n)*time.Millisecond
+		if n > math.MaxInt64/int64(time.Millisecond) {
+			return ErrInvalidExpireTime
 		}
-
-		s.expiration.SetOnKeyExists = true
-		return nil
-	}
-
-	return nil
-
-}
-
-func (s *setCmd) setExpiration(name string, val any) error {
-	if s.expiration.Present {
-		return fmt.Errorf("syntax error")
+	case "unix-time-seconds":
+		n = parsed.Int("unix-time-seconds")
+		name, ttl = "EXAT", time.Unix(n, 0)
+	case "unix-time-milliseconds":
+		n = parsed.Int("unix-time-milliseconds")
+		name, ttl = "PXAT", time.UnixMilli(n)
 	}
 
-	s.expiration = Expiration{
-		Type:    name,
-		TTL:     val,
-		Present: true,
+	if n <= 0 {
+		return ErrInvalidExpireTime
 	}
 
+	s.expiration.Type = name
+	s.expiration.TTL = ttl
+	s.expiration.Present = true
 	return nil
 }
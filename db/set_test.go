@@ -0,0 +1,110 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parse checks argv against the command table like the server does before
+// running a command.
+func parse(t *testing.T, argv ...string) *commands.Args {
+	t.Helper()
+
+	parsed, err := commands.Parse(argv)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestSetExpiration(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		args []string
+
+		expectedErr error
+		expires     bool
+	}{
+		{
+			name: "no expiration",
+			args: []string{"foo", "bar"},
+		},
+		{
+			name:    "seconds",
+			args:    []string{"foo", "bar", "EX", "1"},
+			expires: true,
+		},
+		{
+			name:    "milliseconds",
+			args:    []string{"foo", "bar", "px", "10"},
+			expires: true,
+		},
+		{
+			name:    "unix time in milliseconds",
+			args:    []string{"foo", "bar", "PXAT", "1"},
+			expires: true,
+		},
+		{
+			name:    "options in any order",
+			args:    []string{"foo", "bar", "PX", "10", "GET", "NX"},
+			expires: true,
+		},
+		{
+			name: "keepttl",
+			args: []string{"foo", "bar", "KEEPTTL"},
+		},
+		{
+			name:        "zero seconds",
+			args:        []string{"foo", "bar", "EX", "0"},
+			expectedErr: db.ErrInvalidExpireTime,
+		},
+		{
+			name:        "negative milliseconds",
+			args:        []string{"foo", "bar", "PX", "-5"},
+			expectedErr: db.ErrInvalidExpireTime,
+		},
+		{
+			name:        "seconds that overflow",
+			args:        []string{"foo", "bar", "EX", "9223372036854775807"},
+			expectedErr: db.ErrInvalidExpireTime,
+		},
+		{
+			name:        "unix time before the epoch",
+			args:        []string{"foo", "bar", "EXAT", "-1"},
+			expectedErr: db.ErrInvalidExpireTime,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := db.NewDB()
+			argv := append([]string{"SET"}, tc.args...)
+
+			_, err := store.Execute(ctx, "SET", tc.args, parse(t, argv...))
+			assert.Equal(t, tc.expectedErr, err)
+			if tc.expectedErr != nil {
+				return
+			}
+
+			if !tc.expires {
+				time.Sleep(20 * time.Millisecond)
+				val, err := store.Execute(ctx, "GET", []string{"foo"}, nil)
+				assert.NoError(t, err)
+				assert.Equal(t, "bar", val)
+				return
+			}
+
+			assert.Eventually(t, func() bool {
+				val, err := store.Execute(ctx, "GET", []string{"foo"}, nil)
+				return err == nil && val == nil
+			}, 2*time.Second, 10*time.Millisecond)
+		})
+	}
+}
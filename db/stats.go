@@ -0,0 +1,35 @@
+package db
+
+import "sync/atomic"
+
+// Stats are the keyspace counters reported by INFO.
+type Stats struct {
+	Keys           int64
+	KeyspaceHits   int64
+	KeyspaceMisses int64
+	ExpiredKeys    int64
+}
+
+type stats struct {
+	hits    atomic.Int64
+	misses  atomic.Int64
+	expired atomic.Int64
+}
+
+// Stats returns the keyspace counters, it does not wait for the store lock so
+// it can be called while a script is running.
+func (d *DB) Stats() Stats {
+	return Stats{
+		Keys:           d.store.size.Load(),
+		KeyspaceHits:   d.stats.hits.Load(),
+		KeyspaceMisses: d.stats.misses.Load(),
+		ExpiredKeys:    d.stats.expired.Load(),
+	}
+}
+
+// ResetStats resets the counters, the number of keys is left alone.
+func (d *DB) ResetStats() {
+	d.stats.hits.Store(0)
+	d.stats.misses.Store(0)
+	d.stats.expired.Store(0)
+}
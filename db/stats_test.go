@@ -0,0 +1,36 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	d := db.NewDB()
+	ctx := context.Background()
+
+	for _, inv := range []db.Invocation{
+		{Name: "SET", Args: []string{"a", "1"}},
+		{Name: "SET", Args: []string{"a", "2"}},
+		{Name: "SET", Args: []string{"b", "1", "PX", "10"}, Parsed: parse(t, "SET", "b", "1", "PX", "10")},
+		{Name: "GET", Args: []string{"a"}},
+		{Name: "GET", Args: []string{"missing"}},
+		{Name: "DEL", Args: []string{"a"}},
+	} {
+		_, err := d.Execute(ctx, inv.Name, inv.Args, inv.Parsed)
+		assert.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool {
+		return d.Stats().ExpiredKeys == 1
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, db.Stats{KeyspaceHits: 1, KeyspaceMisses: 1, ExpiredKeys: 1}, d.Stats())
+
+	d.ResetStats()
+	assert.Equal(t, db.Stats{}, d.Stats())
+}
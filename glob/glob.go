@@ -0,0 +1,130 @@
+// Package glob implements the glob style patterns used by redis commands such
+// as KEYS, PSUBSCRIBE and CONFIG GET.
+package glob
+
+// Match reports whether s matches pattern. The pattern supports:
+//
+//	?      any single character
+//	*      any sequence of characters, including none
+//	[abc]  one of the characters in the brackets, ranges like [a-z] and
+//	       negation like [^a] are supported
+//	\x     the character x, used to escape the special characters above
+func Match(pattern, s string) bool {
+	return match(pattern, s, false)
+}
+
+// MatchFold is like Match but compares characters case insensitively.
+func MatchFold(pattern, s string) bool {
+	return match(pattern, s, true)
+}
+
+func match(pattern, s string, fold bool) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if match(pattern[1:], s[i:], fold) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			matched, rest := matchClass(pattern[1:], s[0], fold)
+			if !matched {
+				return false
+			}
+			s = s[1:]
+			pattern = rest
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || !equal(pattern[0], s[0], fold) {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+
+	return len(s) == 0
+}
+
+// matchClass matches c against the character class at the start of pattern,
+// which is positioned right after the opening bracket. It returns the pattern
+// left after the closing bracket.
+func matchClass(pattern string, c byte, fold bool) (bool, string) {
+	negate := false
+	if len(pattern) > 0 && pattern[0] == '^' {
+		negate = true
+		pattern = pattern[1:]
+	}
+
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			if equal(pattern[1], c, fold) {
+				matched = true
+			}
+			pattern = pattern[2:]
+		case len(pattern) >= 3 && pattern[1] == '-':
+			start, end := pattern[0], pattern[2]
+			if start > end {
+				start, end = end, start
+			}
+			if fold {
+				if l := lower(c); l >= lower(start) && l <= lower(end) {
+					matched = true
+				}
+			}
+			if c >= start && c <= end {
+				matched = true
+			}
+			pattern = pattern[3:]
+		default:
+			if equal(pattern[0], c, fold) {
+				matched = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+
+	if len(pattern) > 0 {
+		// skip the closing bracket
+		pattern = pattern[1:]
+	}
+
+	return matched != negate, pattern
+}
+
+func equal(a, b byte, fold bool) bool {
+	if fold {
+		return lower(a) == lower(b)
+	}
+	return a == b
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,47 @@
+package glob_test
+
+import (
+	"testing"
+
+	"github.com/aelnahas/sider/glob"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		input   string
+
+		expected bool
+	}{
+		{name: "exact", pattern: "foo", input: "foo", expected: true},
+		{name: "exact mismatch", pattern: "foo", input: "fo", expected: false},
+		{name: "star", pattern: "orders.*", input: "orders.created", expected: true},
+		{name: "star empty", pattern: "orders.*", input: "orders.", expected: true},
+		{name: "star middle", pattern: "a*c", input: "abbbc", expected: true},
+		{name: "star mismatch", pattern: "a*c", input: "abbb", expected: false},
+		{name: "question mark", pattern: "h?llo", input: "hallo", expected: true},
+		{name: "question mark mismatch", pattern: "h?llo", input: "hllo", expected: false},
+		{name: "class", pattern: "h[ae]llo", input: "hello", expected: true},
+		{name: "class mismatch", pattern: "h[ae]llo", input: "hillo", expected: false},
+		{name: "negated class", pattern: "h[^e]llo", input: "hallo", expected: true},
+		{name: "negated class mismatch", pattern: "h[^e]llo", input: "hello", expected: false},
+		{name: "range", pattern: "key[0-9]", input: "key7", expected: true},
+		{name: "range mismatch", pattern: "key[0-9]", input: "keyx", expected: false},
+		{name: "escape", pattern: `a\*b`, input: "a*b", expected: true},
+		{name: "escape mismatch", pattern: `a\*b`, input: "axb", expected: false},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, glob.Match(tc.pattern, tc.input))
+		})
+	}
+}
+
+func TestMatchFold(t *testing.T) {
+	assert.True(t, glob.MatchFold("MAX*", "maxmemory"))
+	assert.False(t, glob.Match("MAX*", "maxmemory"))
+}
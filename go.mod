@@ -5,7 +5,7 @@ go 1.20
 require (
 	github.com/stretchr/testify v1.8.4
 	github.com/urfave/cli/v2 v2.25.3
-	golang.org/x/exp v0.0.0-20240205201215-2c58cdc269a3
+	github.com/yuin/gopher-lua v1.1.1
 )
 
 require (
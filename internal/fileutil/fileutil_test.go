@@ -0,0 +1,55 @@
+package fileutil_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aelnahas/sider/internal/fileutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing os.FileMode
+		perm     os.FileMode
+		expected os.FileMode
+	}{
+		{name: "new file", perm: 0o640, expected: 0o640},
+		{name: "existing file keeps its mode", existing: 0o604, perm: 0o600, expected: 0o604},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "sider.conf")
+			if tc.existing != 0 {
+				require.NoError(t, os.WriteFile(path, []byte("old\n"), tc.existing))
+				require.NoError(t, os.Chmod(path, tc.existing))
+			}
+
+			require.NoError(t, fileutil.WriteFileAtomic(path, []byte("new\n"), tc.perm))
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, "new\n", string(data))
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, info.Mode().Perm())
+
+			// the temporary file is gone
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
+
+func TestWriteFileAtomicMissingDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "sider.conf")
+	assert.Error(t, fileutil.WriteFileAtomic(path, []byte("new\n"), 0o644))
+}
@@ -0,0 +1,55 @@
+package latency
+
+import (
+	"math/bits"
+	"time"
+)
+
+// buckets is how many power of two buckets a histogram has, the last one
+// holds everything from about 2 seconds up.
+const buckets = 22
+
+// Bucket counts the runs that took at most Micros microseconds, it includes
+// the runs of every bucket below it.
+type Bucket struct {
+	Micros int64
+	Count  int64
+}
+
+// Histogram counts the runs of a command by how long they took, in power of
+// two microsecond buckets.
+type Histogram struct {
+	Command string
+	Calls   int64
+
+	counts [buckets]int64
+}
+
+func (h *Histogram) observe(latency time.Duration) {
+	h.Calls++
+
+	micros := latency.Microseconds()
+	bucket := 0
+	if micros > 1 {
+		bucket = bits.Len64(uint64(micros - 1))
+	}
+	if bucket >= buckets {
+		bucket = buckets - 1
+	}
+	h.counts[bucket]++
+}
+
+// Buckets returns the cumulative counts of the buckets that any run fell in.
+func (h *Histogram) Buckets() []Bucket {
+	var result []Bucket
+	var total int64
+	for i, count := range h.counts {
+		if count == 0 {
+			continue
+		}
+
+		total += count
+		result = append(result, Bucket{Micros: 1 << i, Count: total})
+	}
+	return result
+}
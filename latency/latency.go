@@ -0,0 +1,276 @@
+// Package latency records latency spikes by event and the latency of every
+// command, like the LATENCY command of redis reports them.
+package latency
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events the server records spikes of.
+const (
+	EventCommand     = "command"
+	EventExpireCycle = "expire-cycle"
+)
+
+// HistoryLen is how many samples are kept per event, one per second at most.
+const HistoryLen = 160
+
+// Sample is the latency of an event at some point in time, samples taken the
+// same second are merged into the highest one.
+type Sample struct {
+	Time    time.Time
+	Latency time.Duration
+}
+
+// Latest summarizes the samples of an event.
+type Latest struct {
+	Event  string
+	Time   time.Time
+	Latest time.Duration
+	Max    time.Duration
+}
+
+type event struct {
+	history [HistoryLen]Sample
+	next    int
+	count   int
+	max     time.Duration
+}
+
+func (e *event) add(now time.Time, latency time.Duration) {
+	if latency > e.max {
+		e.max = latency
+	}
+
+	if e.count > 0 {
+		last := &e.history[(e.next-1+HistoryLen)%HistoryLen]
+		if last.Time.Unix() == now.Unix() {
+			if latency > last.Latency {
+				last.Latency = latency
+			}
+			return
+		}
+	}
+
+	e.history[e.next] = Sample{Time: now, Latency: latency}
+	e.next = (e.next + 1) % HistoryLen
+	if e.count < HistoryLen {
+		e.count++
+	}
+}
+
+// samples returns the history of the event, oldest first.
+func (e *event) samples() []Sample {
+	samples := make([]Sample, 0, e.count)
+	for i := e.count; i > 0; i-- {
+		samples = append(samples, e.history[(e.next-i+HistoryLen)%HistoryLen])
+	}
+	return samples
+}
+
+// Monitor records the events that took at least as long as its threshold and
+// keeps a latency histogram per command.
+type Monitor struct {
+	mu         sync.Mutex
+	threshold  time.Duration
+	events     map[string]*event
+	histograms map[string]*Histogram
+}
+
+// NewMonitor returns a monitor recording spikes of threshold or longer, a
+// zero threshold disables recording spikes.
+func NewMonitor(threshold time.Duration) *Monitor {
+	return &Monitor{
+		threshold:  threshold,
+		events:     make(map[string]*event),
+		histograms: make(map[string]*Histogram),
+	}
+}
+
+// SetThreshold changes which events are recorded from now on.
+func (m *Monitor) SetThreshold(threshold time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.threshold = threshold
+}
+
+// Enabled reports whether spikes are being recorded.
+func (m *Monitor) Enabled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.threshold > 0
+}
+
+// Add records the latency of an event if it reaches the threshold.
+func (m *Monitor) Add(name string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.threshold <= 0 || latency < m.threshold {
+		return
+	}
+
+	e, ok := m.events[name]
+	if !ok {
+		e = &event{}
+		m.events[name] = e
+	}
+	e.add(time.Now(), latency)
+}
+
+// Latest returns the last sample and the highest latency of every event,
+// ordered by event name.
+func (m *Monitor) Latest() []Latest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	latest := make([]Latest, 0, len(m.events))
+	for name, e := range m.events {
+		last := e.history[(e.next-1+HistoryLen)%HistoryLen]
+		latest = append(latest, Latest{Event: name, Time: last.Time, Latest: last.Latency, Max: e.max})
+	}
+
+	sort.Slice(latest, func(i, j int) bool {
+		return latest[i].Event < latest[j].Event
+	})
+	return latest
+}
+
+// History returns the samples of an event, oldest first.
+func (m *Monitor) History(name string) []Sample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.events[name]
+	if !ok {
+		return nil
+	}
+	return e.samples()
+}
+
+// Reset drops the samples of the given events, or of every event when none
+// are given, and returns how many events were dropped.
+func (m *Monitor) Reset(names ...string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(names) == 0 {
+		reset := len(m.events)
+		m.events = make(map[string]*event)
+		return reset
+	}
+
+	reset := 0
+	for _, name := range names {
+		if _, ok := m.events[name]; ok {
+			delete(m.events, name)
+			reset++
+		}
+	}
+	return reset
+}
+
+// Observe adds the latency of a run of command to its histogram.
+func (m *Monitor) Observe(command string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.histograms[command]
+	if !ok {
+		h = &Histogram{Command: command}
+		m.histograms[command] = h
+	}
+	h.observe(latency)
+}
+
+// Histograms returns a copy of the histograms of the given commands, or of
+// every command that ran when none are given, ordered by command.
+func (m *Monitor) Histograms(commands ...string) []Histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var histograms []Histogram
+	if len(commands) == 0 {
+		for _, h := range m.histograms {
+			histograms = append(histograms, *h)
+		}
+	} else {
+		for _, command := range commands {
+			if h, ok := m.histograms[command]; ok {
+				histograms = append(histograms, *h)
+			}
+		}
+	}
+
+	sort.Slice(histograms, func(i, j int) bool {
+		return histograms[i].Command < histograms[j].Command
+	})
+	return histograms
+}
+
+// ResetHistograms drops the histogram of every command.
+func (m *Monitor) ResetHistograms() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.histograms = make(map[string]*Histogram)
+}
+
+// Doctor describes the recorded spikes in plain words, with some advice on
+// where they could come from.
+func (m *Monitor) Doctor() string {
+	if !m.Enabled() {
+		return "Latency monitoring is disabled. Use CONFIG SET latency-monitor-threshold <milliseconds> to enable it.\n"
+	}
+
+	latest := m.Latest()
+	if len(latest) == 0 {
+		return "No latency spike was observed since latency monitoring was enabled or last reset.\n"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Latency spikes were observed for the following events:\n\n")
+	for i, l := range latest {
+		samples := m.History(l.Event)
+
+		var sum time.Duration
+		for _, s := range samples {
+			sum += s.Latency
+		}
+		avg := sum / time.Duration(len(samples))
+
+		var deviation time.Duration
+		for _, s := range samples {
+			deviation += time.Duration(math.Abs(float64(s.Latency - avg)))
+		}
+		deviation /= time.Duration(len(samples))
+
+		var period time.Duration
+		if len(samples) > 1 {
+			period = samples[len(samples)-1].Time.Sub(samples[0].Time) / time.Duration(len(samples)-1)
+		}
+
+		fmt.Fprintf(&sb, "%d. %s: %d latency spikes (average %dms, mean deviation %dms, period %.2f sec). Worst all time event %dms.\n",
+			i+1, l.Event, len(samples), avg.Milliseconds(), deviation.Milliseconds(), period.Seconds(), l.Max.Milliseconds())
+	}
+
+	sb.WriteString("\nAdvice:\n\n")
+	for _, l := range latest {
+		switch l.Event {
+		case EventCommand:
+			sb.WriteString("- Commands are slow to run. Check SLOWLOG GET for the commands and the clients sending them, and LATENCY HISTOGRAM for how slow each command usually is.\n")
+		case EventExpireCycle:
+			sb.WriteString("- Deleting expired keys is slow. Avoid setting the same expire time on many keys so they do not expire all at once.\n")
+		default:
+			fmt.Fprintf(&sb, "- There is no advice for the %s event.\n", l.Event)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package latency_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/latency"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor(t *testing.T) {
+	m := latency.NewMonitor(0)
+	m.Add(latency.EventCommand, time.Second)
+	assert.Empty(t, m.Latest(), "spikes are not recorded while disabled")
+
+	m.SetThreshold(100 * time.Millisecond)
+	m.Add(latency.EventCommand, 50*time.Millisecond)
+	m.Add(latency.EventCommand, 300*time.Millisecond)
+	m.Add(latency.EventCommand, 200*time.Millisecond)
+	m.Add(latency.EventExpireCycle, 100*time.Millisecond)
+
+	latest := m.Latest()
+	assert.Len(t, latest, 2)
+	assert.Equal(t, latency.EventCommand, latest[0].Event)
+	assert.Equal(t, 300*time.Millisecond, latest[0].Max)
+	assert.Equal(t, latency.EventExpireCycle, latest[1].Event)
+
+	// spikes of the same second are merged into the highest one, the two
+	// spikes can only end up apart if a second passed in between
+	history := m.History(latency.EventCommand)
+	assert.NotEmpty(t, history)
+	assert.LessOrEqual(t, len(history), 2)
+	assert.Equal(t, 300*time.Millisecond, history[0].Latency)
+
+	assert.Contains(t, m.Doctor(), "1. command: ")
+
+	assert.Equal(t, 1, m.Reset(latency.EventCommand, "missing"))
+	assert.Nil(t, m.History(latency.EventCommand))
+	assert.Equal(t, 1, m.Reset())
+	assert.Empty(t, m.Latest())
+}
+
+func TestHistograms(t *testing.T) {
+	m := latency.NewMonitor(0)
+	for _, latency := range []time.Duration{0, time.Microsecond, 3 * time.Microsecond, 4 * time.Microsecond, time.Millisecond} {
+		m.Observe("get", latency)
+	}
+	m.Observe("set", time.Microsecond)
+
+	histograms := m.Histograms("get", "missing")
+	assert.Len(t, histograms, 1)
+	assert.Equal(t, int64(5), histograms[0].Calls)
+	assert.Equal(t, []latency.Bucket{
+		{Micros: 1, Count: 2},
+		{Micros: 4, Count: 4},
+		{Micros: 1024, Count: 5},
+	}, histograms[0].Buckets())
+
+	assert.Len(t, m.Histograms(), 2)
+	m.ResetHistograms()
+	assert.Empty(t, m.Histograms())
+}
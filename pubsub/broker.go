@@ -1,12 +1,14 @@
 package pubsub
 
 import (
-	"bufio"
-	"fmt"
+	"hash/fnv"
+	"io"
 	"log/slog"
-	"net"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/aelnahas/sider/glob"
 	"github.com/aelnahas/sider/resp"
 )
 
@@ -16,6 +18,15 @@ const (
 	MessageKindUnknown MessageKind = iota
 	MessageKindSubscribe
 	MessageKindMessage
+	MessageKindPSubscribe
+	MessageKindPMessage
+	MessageKindUnsubscribe
+	MessageKindPUnsubscribe
+	MessageKindSSubscribe
+	MessageKindSMessage
+	MessageKindSUnsubscribe
+	MessageKindRSubscribe
+	MessageKindRMessage
 )
 
 func (mk MessageKind) String() string {
@@ -23,7 +34,25 @@ func (mk MessageKind) String() string {
 	case MessageKindMessage:
 		return "message"
 	case MessageKindSubscribe:
-		return "subscribed"
+		return "subscribe"
+	case MessageKindPMessage:
+		return "pmessage"
+	case MessageKindPSubscribe:
+		return "psubscribe"
+	case MessageKindUnsubscribe:
+		return "unsubscribe"
+	case MessageKindPUnsubscribe:
+		return "punsubscribe"
+	case MessageKindSSubscribe:
+		return "ssubscribe"
+	case MessageKindSMessage:
+		return "smessage"
+	case MessageKindSUnsubscribe:
+		return "sunsubscribe"
+	case MessageKindRSubscribe:
+		return "rsubscribe"
+	case MessageKindRMessage:
+		return "rmessage"
 	default:
 		return "unknown"
 	}
@@ -34,190 +63,636 @@ type Message struct {
 	Topic     string
 	Data      any
 	Pattern   string
+	Offset    uint64
 	Timestamp time.Time
 }
 
-type Topic struct {
-	Name     string
-	Messages []string
-}
-
+// Broker delivers published messages to subscribers. Once a client is
+// connected everything written to it, subscription confirmations, messages and
+// replies, goes through the broker so they reach the client in order.
+// The subscription methods send a confirmation for every topic or pattern and
+// return the number of subscriptions of any kind the client has left, calling
+// the unsubscribe methods with no topics or patterns unsubscribes from all of
+// them.
 type Broker interface {
-	Connect(id string, conn net.Conn)
+	Connect(id string, w io.WriteCloser)
 	Disconnect(id string)
-	Subscribe(id string, topics []string, conn net.Conn)
-	Unsubscribe(id string, topic []string)
+	Detach(id string)
+	Subscribe(id string, topics []string) int
+	Unsubscribe(id string, topics []string) int
+	PSubscribe(id string, patterns []string) int
+	PUnsubscribe(id string, patterns []string) int
+	SSubscribe(id string, topics []string) int
+	SUnsubscribe(id string, topics []string) int
 	Publish(topic string, data any) int
+	SPublish(topic string, data any) int
+	Reply(id string, data any)
+
+	// Info describes a connected client, it returns false if the client is
+	// not connected.
+	Info(id string) (ClientInfo, bool)
+	// Subscribed reports whether a connected client is subscribed to topic.
+	Subscribed(id string, topic string) bool
+
+	// SetOutputBufferLimit changes the output buffer limit of every
+	// subscriber, connected or not.
+	SetOutputBufferLimit(limit OutputBufferLimit)
+
+	// Retain enables retention of the messages published to topic and
+	// Unretain disables it. RSubscribe subscribes to topics replaying the
+	// retained messages a client missed since the given positions.
+	Retain(topic string, retention Retention)
+	Unretain(topic string) bool
+	RSubscribe(id string, positions []Position) int
+
+	// Channels returns the topics with at least one subscriber matching
+	// pattern, or all of them if pattern is empty.
+	Channels(pattern string) []string
+	// NumSub returns the number of subscribers of each topic.
+	NumSub(topics []string) []int
+	// NumPat returns the number of patterns clients are subscribed to.
+	NumPat() int
+	// ShardChannels and ShardNumSub are like Channels and NumSub for shard
+	// channels.
+	ShardChannels(pattern string) []string
+	ShardNumSub(topics []string) []int
 }
 
-type client struct {
-	id   string
-	conn net.Conn
+// shardCount is the number of shards topics are spread over, publishers to
+// different topics only contend when their topics land on the same shard.
+const shardCount = 32
 
-	messages chan *Message
-
-	writer bufio.Writer
-	reader bufio.Reader
+type shard struct {
+	sync.RWMutex
+	subscriptions map[string]map[string]*client
 }
 
 type broker struct {
-	clients       map[string]*client
-	subscriptions map[string]map[string]struct{}
+	limit OutputBufferLimit
+
+	clientsMu sync.RWMutex
+	clients   map[string]*client
+
+	shards [shardCount]*shard
+
+	// shardChannels holds the shard channels, they are spread by hash slot
+	// rather than by name
+	shardChannels [shardCount]*shard
+
+	patternsMu sync.RWMutex
+	patterns   map[string]map[string]*client
+
+	// topics holds the history of the topics with retention enabled
+	topicsMu sync.RWMutex
+	topics   map[string]*Topic
 }
 
 var _ Broker = new(broker)
 
-func NewBroker() *broker {
-	return &broker{
-		clients:       make(map[string]*client),
-		subscriptions: make(map[string]map[string]struct{}),
+type Option = func(*broker)
+
+// WithOutputBufferLimit sets the limit applied to the output buffer of every
+// subscriber.
+func WithOutputBufferLimit(limit OutputBufferLimit) Option {
+	return func(b *broker) {
+		b.limit = limit
 	}
 }
 
-func (b *broker) Connect(id string, conn net.Conn) {
-	c := newClient(id, conn)
+func NewBroker(opts ...Option) *broker {
+	b := &broker{
+		limit:    DefaultOutputBufferLimit,
+		clients:  make(map[string]*client),
+		patterns: make(map[string]map[string]*client),
+		topics:   make(map[string]*Topic),
+	}
+
+	for i := range b.shards {
+		b.shards[i] = &shard{subscriptions: make(map[string]map[string]*client)}
+		b.shardChannels[i] = &shard{subscriptions: make(map[string]map[string]*client)}
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+func (b *broker) shard(topic string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(topic))
+	return b.shards[h.Sum32()%shardCount]
+}
+
+func (b *broker) slotShard(topic string) *shard {
+	return b.shardChannels[Slot(topic)%shardCount]
+}
+
+func (b *broker) Connect(id string, w io.WriteCloser) {
+	b.clientsMu.Lock()
+	defer b.clientsMu.Unlock()
+
+	if _, ok := b.clients[id]; ok {
+		return
+	}
+
+	c := newClient(id, w, b.limit)
 	c.Listen()
 
 	b.clients[id] = c
 }
 
+func (b *broker) SetOutputBufferLimit(limit OutputBufferLimit) {
+	b.clientsMu.Lock()
+	defer b.clientsMu.Unlock()
+
+	b.limit = limit
+	for _, c := range b.clients {
+		c.mu.Lock()
+		c.limit = limit
+		c.mu.Unlock()
+	}
+}
+
+// Disconnect closes the client and removes it from every topic and pattern it
+// was subscribed to.
 func (b *broker) Disconnect(id string) {
-	client, ok := b.clients[id]
+	client, ok := b.remove(id)
 	if !ok {
 		return
 	}
 
 	client.Close()
-	delete(b.clients, id)
 }
 
-func (b *broker) Subscribe(id string, topics []string, conn net.Conn) {
-	_, ok := b.clients[id]
+// Detach removes the client like Disconnect does, but waits for everything
+// queued to be written and leaves the connection open.
+func (b *broker) Detach(id string) {
+	client, ok := b.remove(id)
 	if !ok {
-		b.Connect(id, conn)
+		return
 	}
 
-	client := b.clients[id]
+	client.Detach()
+}
+
+func (b *broker) remove(id string) (*client, bool) {
+	b.clientsMu.Lock()
+	client, ok := b.clients[id]
+	if ok {
+		delete(b.clients, id)
+	}
+	b.clientsMu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	topics, patterns, shardTopics := client.remove()
 	for _, topic := range topics {
-		b.subTopic(id, topic)
+		unsubscribe(b.shard(topic), client, topic)
+	}
+
+	for _, pattern := range patterns {
+		b.unSubPattern(client, pattern)
+	}
 
-		client.messages <- &Message{Topic: topic, Timestamp: time.Now(), Data: "subscribed", Kind: MessageKindSubscribe}
+	for _, topic := range shardTopics {
+		unsubscribe(b.slotShard(topic), client, topic)
 	}
 
+	return client, true
 }
 
-func (b *broker) subTopic(id string, topic string) {
+// Reply queues data for the client behind anything already sent to it.
+func (b *broker) Reply(id string, data any) {
+	client, ok := b.client(id)
+	if !ok {
+		return
+	}
+
+	if !client.send(resp.Encode(data)) {
+		b.Disconnect(id)
+	}
+}
 
-	if _, ok := b.subscriptions[topic]; !ok {
-		b.subscriptions[topic] = make(map[string]struct{})
+func (b *broker) Subscribe(id string, topics []string) int {
+	client, ok := b.client(id)
+	if !ok {
+		return 0
 	}
 
-	b.subscriptions[topic][id] = struct{}{}
+	for _, topic := range topics {
+		count, ok := client.addTopic(topic, func() { subscribe(b.shard(topic), client, topic) })
+		if !ok {
+			return 0
+		}
+
+		client.Send(&Message{Topic: topic, Timestamp: time.Now(), Data: count, Kind: MessageKindSubscribe})
+	}
+
+	return client.count()
 }
 
-func (b *broker) Unsubscribe(id string, topics []string) {
-	if _, ok := b.clients[id]; !ok {
+func subscribe(s *shard, c *client, topic string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.subscriptions[topic]; !ok {
+		s.subscriptions[topic] = make(map[string]*client)
+	}
+
+	s.subscriptions[topic][c.id] = c
+}
+
+func (b *broker) Unsubscribe(id string, topics []string) int {
+	client, ok := b.client(id)
+	if !ok {
+		return 0
+	}
+
+	if len(topics) == 0 {
+		topics, _, _ = client.subscriptions()
+		if len(topics) == 0 {
+			client.send(resp.EncodeArray(MessageKindUnsubscribe.String(), nil, client.countTopicsAndPatterns()))
+			return client.count()
+		}
+	}
+
+	for _, topic := range topics {
+		unsubscribe(b.shard(topic), client, topic)
+		count := client.removeTopic(topic)
+
+		client.Send(&Message{Topic: topic, Timestamp: time.Now(), Data: count, Kind: MessageKindUnsubscribe})
+	}
+
+	return client.count()
+}
+
+func unsubscribe(s *shard, client *client, topic string) {
+	s.Lock()
+	defer s.Unlock()
+
+	subs, ok := s.subscriptions[topic]
+	if !ok {
 		return
 	}
 
+	delete(subs, client.id)
+	if len(subs) == 0 {
+		delete(s.subscriptions, topic)
+	}
+}
+
+func (b *broker) PSubscribe(id string, patterns []string) int {
+	c, ok := b.client(id)
+	if !ok {
+		return 0
+	}
+
+	for _, pattern := range patterns {
+		count, ok := c.addPattern(pattern, func() { b.subPattern(c, pattern) })
+		if !ok {
+			return 0
+		}
+
+		c.Send(&Message{Pattern: pattern, Timestamp: time.Now(), Data: count, Kind: MessageKindPSubscribe})
+	}
+
+	return c.count()
+}
+
+func (b *broker) PUnsubscribe(id string, patterns []string) int {
+	client, ok := b.client(id)
+	if !ok {
+		return 0
+	}
+
+	if len(patterns) == 0 {
+		_, patterns, _ = client.subscriptions()
+		if len(patterns) == 0 {
+			client.send(resp.EncodeArray(MessageKindPUnsubscribe.String(), nil, client.countTopicsAndPatterns()))
+			return client.count()
+		}
+	}
+
+	for _, pattern := range patterns {
+		b.unSubPattern(client, pattern)
+		count := client.removePattern(pattern)
+
+		client.Send(&Message{Pattern: pattern, Timestamp: time.Now(), Data: count, Kind: MessageKindPUnsubscribe})
+	}
+
+	return client.count()
+}
+
+// SSubscribe subscribes to shard channels. The count sent with the
+// confirmations only accounts for shard channels, like redis does.
+func (b *broker) SSubscribe(id string, topics []string) int {
+	client, ok := b.client(id)
+	if !ok {
+		return 0
+	}
+
 	for _, topic := range topics {
-		b.unSubTopic(id, topic)
+		count, ok := client.addShardTopic(topic, func() { subscribe(b.slotShard(topic), client, topic) })
+		if !ok {
+			return 0
+		}
+
+		client.Send(&Message{Topic: topic, Timestamp: time.Now(), Data: count, Kind: MessageKindSSubscribe})
 	}
+
+	return client.count()
 }
 
-func (b *broker) unSubTopic(id, topic string) {
-	if _, ok := b.subscriptions[topic]; !ok {
+func (b *broker) SUnsubscribe(id string, topics []string) int {
+	client, ok := b.client(id)
+	if !ok {
+		return 0
+	}
+
+	if len(topics) == 0 {
+		_, _, topics = client.subscriptions()
+		if len(topics) == 0 {
+			client.send(resp.EncodeArray(MessageKindSUnsubscribe.String(), nil, 0))
+			return client.count()
+		}
+	}
+
+	for _, topic := range topics {
+		unsubscribe(b.slotShard(topic), client, topic)
+		count := client.removeShardTopic(topic)
+
+		client.Send(&Message{Topic: topic, Timestamp: time.Now(), Data: count, Kind: MessageKindSUnsubscribe})
+	}
+
+	return client.count()
+}
+
+func (b *broker) subPattern(c *client, pattern string) {
+	b.patternsMu.Lock()
+	defer b.patternsMu.Unlock()
+
+	if _, ok := b.patterns[pattern]; !ok {
+		b.patterns[pattern] = make(map[string]*client)
+	}
+	b.patterns[pattern][c.id] = c
+}
+
+func (b *broker) unSubPattern(client *client, pattern string) {
+	b.patternsMu.Lock()
+	defer b.patternsMu.Unlock()
+
+	subs, ok := b.patterns[pattern]
+	if !ok {
 		return
 	}
 
-	delete(b.subscriptions[topic], id)
+	delete(subs, client.id)
+	if len(subs) == 0 {
+		delete(b.patterns, pattern)
+	}
 }
 
-func (b *broker) Publish(topic string, data any) int {
+func (b *broker) client(id string) (*client, bool) {
+	b.clientsMu.RLock()
+	defer b.clientsMu.RUnlock()
 
-	sub := b.subscriptions[topic]
+	c, ok := b.clients[id]
+	return c, ok
+}
 
+// Publish sends data to every client subscribed to topic, either directly or
+// through a matching pattern, and returns the number of clients it was queued
+// for, the ones that are closed or go over their limit are not counted.
+// Publish never waits on subscribers, clients that can not keep up are
+// disconnected once they go over their output buffer limit.
+//
+// Messages published to a retained topic are added to its history, clients
+// that subscribed with RSubscribe get them along with their offset.
+func (b *broker) Publish(topic string, data any) int {
+	now := time.Now()
 	count := 0
-	for id := range sub {
-		client, ok := b.clients[id]
-		if !ok {
-			slog.Error("client not found", "id", id, "topic", topic)
-			break
+	var slow []*client
+
+	send := func(client *client, msg *Message) {
+		if !client.Send(msg) {
+			slow = append(slow, client)
+			return
 		}
+		count++
+	}
 
-		msg := &Message{
+	subs, retained := b.publishRetained(topic, data, now, send)
+	if !retained {
+		subs = subscribers(b.shard(topic), topic)
+	}
+
+	for _, client := range subs {
+		send(client, &Message{
 			Kind:      MessageKindMessage,
 			Topic:     topic,
-			Timestamp: time.Now(),
+			Timestamp: now,
 			Data:      data,
+		})
+	}
+
+	type patternSub struct {
+		pattern string
+		client  *client
+	}
+
+	b.patternsMu.RLock()
+	var psubs []patternSub
+	for pattern, subs := range b.patterns {
+		if !glob.Match(pattern, topic) {
+			continue
+		}
+
+		for _, client := range subs {
+			psubs = append(psubs, patternSub{pattern: pattern, client: client})
 		}
-		client.messages <- msg
-		count++
 	}
+	b.patternsMu.RUnlock()
+
+	// a client gets one message for each of its patterns matching the topic
+	for _, sub := range psubs {
+		send(sub.client, &Message{
+			Kind:      MessageKindPMessage,
+			Topic:     topic,
+			Pattern:   sub.pattern,
+			Timestamp: now,
+			Data:      data,
+		})
+	}
+
+	b.disconnectSlow(slow, topic)
 	return count
 }
 
-func newClient(id string, conn net.Conn) *client {
-	client := &client{
-		id:   id,
-		conn: conn,
+// publishRetained adds data to the history of topic if it is retained, and
+// sends it with its offset to the clients replaying the topic. The topic is
+// only locked for that: the offsets reach every client in order, and a
+// client is either sent the message here or by the replay of RSubscribe. It
+// returns the other subscribers of the topic and whether it is retained.
+func (b *broker) publishRetained(topic string, data any, now time.Time, send func(*client, *Message)) ([]*client, bool) {
+	t, ok := b.retained(topic)
+	if !ok {
+		return nil, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-		messages: make(chan *Message),
-		writer:   *bufio.NewWriter(conn),
-		reader:   *bufio.NewReader(conn),
+	offset := t.append(data, now)
+	var subs []*client
+	for _, client := range subscribers(b.shard(topic), topic) {
+		if !client.replaying(topic) {
+			subs = append(subs, client)
+			continue
+		}
+
+		send(client, &Message{
+			Kind:      MessageKindRMessage,
+			Topic:     topic,
+			Offset:    offset,
+			Timestamp: now,
+			Data:      data,
+		})
 	}
-	client.Listen()
-	return client
+	return subs, true
 }
 
-func (c *client) Listen() {
-	//go c.Read()
-	go c.SendMessages()
-}
+// SPublish sends data to the subscribers of a shard channel. Shard channels
+// are not matched against patterns. Every slot is served by this node, so
+// there is no other shard to forward the message to.
+func (b *broker) SPublish(topic string, data any) int {
+	now := time.Now()
+	count := 0
+	var slow []*client
 
-func (c *client) Read() {
-	for {
-		var buf [512]byte
-		str, err := c.reader.Read(buf[0:])
-		if err != nil {
-			slog.Error(err.Error())
+	for _, client := range subscribers(b.slotShard(topic), topic) {
+		msg := &Message{
+			Kind:      MessageKindSMessage,
+			Topic:     topic,
+			Timestamp: now,
+			Data:      data,
+		}
+
+		if !client.Send(msg) {
+			slow = append(slow, client)
 			continue
 		}
+		count++
+	}
+
+	b.disconnectSlow(slow, topic)
+	return count
+}
 
-		slog.Info("received", "data", str)
+// subscribers returns a copy of the clients subscribed to topic so messages
+// can be sent without holding the lock.
+func subscribers(s *shard, topic string) []*client {
+	s.RLock()
+	defer s.RUnlock()
 
+	subs := make([]*client, 0, len(s.subscriptions[topic]))
+	for _, client := range s.subscriptions[topic] {
+		subs = append(subs, client)
 	}
+	return subs
+}
 
+func (b *broker) disconnectSlow(slow []*client, topic string) {
+	for _, client := range slow {
+		slog.Warn("disconnecting slow subscriber", "id", client.id, "topic", topic)
+		b.Disconnect(client.id)
+	}
 }
 
-func (c *client) SendMessages() {
-	for msg := range c.messages {
-		slog.Info("publishing message", "topic", msg.Topic, "data", msg.Data, "kind", fmt.Sprint(msg.Kind))
+func (b *broker) Channels(pattern string) []string {
+	return channels(b.shards[:], pattern)
+}
 
-		res := resp.EncodeArray(
-			msg.Kind.String(),
-			msg.Topic,
-			msg.Data,
-		)
+func (b *broker) ShardChannels(pattern string) []string {
+	return channels(b.shardChannels[:], pattern)
+}
 
-		_, err := c.writer.Write(res)
-		fmt.Println("error ", err)
-		if err != nil {
-			slog.Error("could not publish message", "error", err, "topic", msg.Topic, "id", c.id)
-			c.writer.Write(resp.Encode(err))
-			break
-		}
+func channels(shards []*shard, pattern string) []string {
+	channels := make([]string, 0)
 
-		if err := c.writer.Flush(); err != nil {
-			slog.Error("could not publish message", "error", err, "topic", msg.Topic, "id", c.id)
-			c.writer.Write(resp.Encode(err))
-			break
+	for _, s := range shards {
+		s.RLock()
+		for topic := range s.subscriptions {
+			if pattern == "" || glob.Match(pattern, topic) {
+				channels = append(channels, topic)
+			}
 		}
+		s.RUnlock()
 	}
-	slog.Info("closed client writer thread", "id", c.id)
+
+	sort.Strings(channels)
+	return channels
 }
 
-func (c *client) Close() {
-	c.conn.Close()
-	slog.Info("closed connection", "id", c.id)
+func (b *broker) NumSub(topics []string) []int {
+	return numSub(b.shard, topics)
+}
+
+func (b *broker) ShardNumSub(topics []string) []int {
+	return numSub(b.slotShard, topics)
+}
+
+func numSub(shardOf func(string) *shard, topics []string) []int {
+	counts := make([]int, 0, len(topics))
+
+	for _, topic := range topics {
+		s := shardOf(topic)
+		s.RLock()
+		counts = append(counts, len(s.subscriptions[topic]))
+		s.RUnlock()
+	}
+
+	return counts
+}
+
+func (b *broker) Info(id string) (ClientInfo, bool) {
+	b.clientsMu.RLock()
+	c, ok := b.clients[id]
+	b.clientsMu.RUnlock()
+
+	if !ok {
+		return ClientInfo{}, false
+	}
+	return c.info(), true
+}
+
+func (b *broker) Subscribed(id string, topic string) bool {
+	c, ok := b.client(id)
+	if !ok {
+		return false
+	}
+	return c.subscribed(topic)
+}
+
+func (b *broker) NumPat() int {
+	b.patternsMu.RLock()
+	defer b.patternsMu.RUnlock()
+
+	return len(b.patterns)
+}
+
+func (m *Message) encode() []byte {
+	switch m.Kind {
+	case MessageKindPMessage:
+		return resp.EncodeArray(m.Kind.String(), m.Pattern, m.Topic, m.Data)
+	case MessageKindRMessage:
+		return resp.EncodeArray(m.Kind.String(), m.Topic, int(m.Offset), m.Data)
+	case MessageKindPSubscribe, MessageKindPUnsubscribe:
+		return resp.EncodeArray(m.Kind.String(), m.Pattern, m.Data)
+	default:
+		return resp.EncodeArray(m.Kind.String(), m.Topic, m.Data)
+	}
 }
@@ -0,0 +1,388 @@
+package pubsub_test
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/pubsub"
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readN(t *testing.T, conn net.Conn, n int) string {
+	t.Helper()
+
+	buf := make([]byte, n)
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err := io.ReadFull(conn, buf)
+	assert.NoError(t, err)
+	return string(buf)
+}
+
+func TestPublish(t *testing.T) {
+	broker := pubsub.NewBroker()
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	broker.Connect("a", server)
+	assert.Equal(t, 1, broker.Subscribe("a", []string{"orders.new"}))
+	expected := "*3\r\n$9\r\nsubscribe\r\n$10\r\norders.new\r\n:1\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 2, broker.PSubscribe("a", []string{"orders.*"}))
+	expected = "*3\r\n$10\r\npsubscribe\r\n$8\r\norders.*\r\n:2\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 2, broker.Publish("orders.new", "hi"))
+	expected = "*3\r\n$7\r\nmessage\r\n$10\r\norders.new\r\n$2\r\nhi\r\n" +
+		"*4\r\n$8\r\npmessage\r\n$8\r\norders.*\r\n$10\r\norders.new\r\n$2\r\nhi\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 1, broker.Publish("orders.old", "hi"))
+	assert.Equal(t, 0, broker.Publish("invoices.new", "hi"))
+
+	broker.Disconnect("a")
+	assert.Equal(t, 0, broker.Publish("orders.new", "hi"))
+}
+
+func TestPublishSlowSubscriber(t *testing.T) {
+	broker := pubsub.NewBroker(pubsub.WithOutputBufferLimit(pubsub.OutputBufferLimit{Hard: 128}))
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	// the subscriber never reads so everything published piles up in its
+	// output buffer
+	broker.Connect("slow", server)
+	broker.Subscribe("slow", []string{"news"})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			broker.Publish("news", "a message that is long enough to fill the buffer")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a slow subscriber")
+	}
+
+	assert.Equal(t, 0, broker.Publish("news", "hi"))
+}
+
+func TestPatterns(t *testing.T) {
+	broker := pubsub.NewBroker()
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	broker.Connect("a", server)
+	assert.Equal(t, 2, broker.PSubscribe("a", []string{"h?llo", "h[ae]llo"}))
+	expected := "*3\r\n$10\r\npsubscribe\r\n$5\r\nh?llo\r\n:1\r\n" +
+		"*3\r\n$10\r\npsubscribe\r\n$8\r\nh[ae]llo\r\n:2\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+	assert.Equal(t, 2, broker.NumPat())
+
+	// subscribing twice to a pattern is confirmed but counted once
+	assert.Equal(t, 2, broker.PSubscribe("a", []string{"h?llo"}))
+	expected = "*3\r\n$10\r\npsubscribe\r\n$5\r\nh?llo\r\n:2\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	// every matching pattern is a receiver
+	server2, client2 := net.Pipe()
+	defer client2.Close()
+	broker.Connect("b", server2)
+	broker.Subscribe("b", []string{"hello"})
+	expected2 := "*3\r\n$9\r\nsubscribe\r\n$5\r\nhello\r\n:1\r\n"
+	assert.Equal(t, expected2, readN(t, client2, len(expected2)))
+
+	assert.Equal(t, 3, broker.Publish("hello", "hi"))
+	expected = "*4\r\n$8\r\npmessage\r\n$5\r\nh?llo\r\n$5\r\nhello\r\n$2\r\nhi\r\n"
+	expected2 = "*4\r\n$8\r\npmessage\r\n$8\r\nh[ae]llo\r\n$5\r\nhello\r\n$2\r\nhi\r\n"
+	got := readN(t, client, len(expected)+len(expected2))
+	// patterns are not matched in any particular order
+	assert.Contains(t, []string{expected + expected2, expected2 + expected}, got)
+	expected = "*3\r\n$7\r\nmessage\r\n$5\r\nhello\r\n$2\r\nhi\r\n"
+	assert.Equal(t, expected, readN(t, client2, len(expected)))
+
+	assert.Equal(t, 1, broker.Publish("hxllo", "hi"))
+	expected = "*4\r\n$8\r\npmessage\r\n$5\r\nh?llo\r\n$5\r\nhxllo\r\n$2\r\nhi\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 1, broker.PUnsubscribe("a", []string{"h?llo"}))
+	expected = "*3\r\n$12\r\npunsubscribe\r\n$5\r\nh?llo\r\n:1\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+	assert.Equal(t, 0, broker.Publish("hxllo", "hi"))
+
+	assert.Equal(t, 0, broker.PUnsubscribe("a", nil))
+	expected = "*3\r\n$12\r\npunsubscribe\r\n$8\r\nh[ae]llo\r\n:0\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+	assert.Equal(t, 0, broker.NumPat())
+
+	assert.Equal(t, 0, broker.PUnsubscribe("a", nil))
+	expected = "*3\r\n$12\r\npunsubscribe\r\n$-1\r\n:0\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 1, broker.Publish("hello", "hi"))
+}
+
+func TestPublishCountsDelivered(t *testing.T) {
+	broker := pubsub.NewBroker(pubsub.WithOutputBufferLimit(pubsub.OutputBufferLimit{Hard: 16}))
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+
+	broker.Connect("a", server)
+	broker.Subscribe("a", []string{"news"})
+	broker.PSubscribe("a", []string{"n*"})
+
+	// the message puts the subscriber over its limit, so it is not delivered
+	assert.Equal(t, 0, broker.Publish("news", "a message longer than the limit"))
+	assert.Equal(t, []int{0}, broker.NumSub([]string{"news"}))
+	assert.Equal(t, 0, broker.NumPat())
+}
+
+func TestSubscribeWhileDisconnecting(t *testing.T) {
+	broker := pubsub.NewBroker()
+
+	// many topics so the client is removed halfway through subscribing
+	var topics, patterns, shardTopics []string
+	for i := 0; i < 100; i++ {
+		topics = append(topics, fmt.Sprintf("news.%d", i))
+		patterns = append(patterns, fmt.Sprintf("news.%d.*", i))
+		shardTopics = append(shardTopics, fmt.Sprintf("{shard}news.%d", i))
+	}
+
+	for i := 0; i < 100; i++ {
+		server, client := net.Pipe()
+		go io.Copy(io.Discard, client)
+		broker.Connect("a", server)
+
+		// every call starts at once so they interleave
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for _, call := range []func(){
+			func() { broker.Subscribe("a", topics) },
+			func() { broker.PSubscribe("a", patterns) },
+			func() { broker.SSubscribe("a", shardTopics) },
+			func() { broker.Disconnect("a") },
+		} {
+			wg.Add(1)
+			go func(call func()) {
+				defer wg.Done()
+				<-start
+				call()
+			}(call)
+		}
+		close(start)
+		wg.Wait()
+
+		// a subscription made while the client was removed would stay
+		// behind for good
+		broker.Disconnect("a")
+		client.Close()
+		require.Empty(t, broker.Channels(""))
+		require.Empty(t, broker.ShardChannels(""))
+		require.Zero(t, broker.NumPat())
+	}
+}
+
+func TestReplyWhileDisconnecting(t *testing.T) {
+	broker := pubsub.NewBroker()
+
+	for i := 0; i < 100; i++ {
+		server, client := net.Pipe()
+		go io.Copy(io.Discard, client)
+		broker.Connect("a", server)
+
+		// a reply queued while the client closes must not wake up its
+		// writer once it is gone
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for _, call := range []func(){
+			func() {
+				for j := 0; j < 10; j++ {
+					broker.Reply("a", "line")
+				}
+			},
+			func() { broker.Disconnect("a") },
+		} {
+			wg.Add(1)
+			go func(call func()) {
+				defer wg.Done()
+				<-start
+				call()
+			}(call)
+		}
+		close(start)
+		wg.Wait()
+		client.Close()
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	broker := pubsub.NewBroker()
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	broker.Connect("a", server)
+	broker.Subscribe("a", []string{"foo", "bar"})
+	expected := "*3\r\n$9\r\nsubscribe\r\n$3\r\nfoo\r\n:1\r\n*3\r\n$9\r\nsubscribe\r\n$3\r\nbar\r\n:2\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 1, broker.Unsubscribe("a", []string{"foo"}))
+	expected = "*3\r\n$11\r\nunsubscribe\r\n$3\r\nfoo\r\n:1\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 0, broker.Unsubscribe("a", nil))
+	expected = "*3\r\n$11\r\nunsubscribe\r\n$3\r\nbar\r\n:0\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 0, broker.Unsubscribe("a", nil))
+	expected = "*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 0, broker.Publish("foo", "hi"))
+}
+
+func TestIntrospection(t *testing.T) {
+	broker := pubsub.NewBroker()
+
+	for _, id := range []string{"a", "b"} {
+		server, client := net.Pipe()
+		defer client.Close()
+		go io.Copy(io.Discard, client)
+
+		broker.Connect(id, server)
+		broker.PSubscribe(id, []string{"orders.*"})
+	}
+
+	broker.Subscribe("a", []string{"orders.new", "invoices.new"})
+	broker.Subscribe("b", []string{"orders.new"})
+
+	assert.Equal(t, []string{"invoices.new", "orders.new"}, broker.Channels(""))
+	assert.Equal(t, []string{"orders.new"}, broker.Channels("orders.*"))
+	assert.Equal(t, []int{2, 1, 0}, broker.NumSub([]string{"orders.new", "invoices.new", "nope"}))
+	assert.Equal(t, 1, broker.NumPat())
+
+	broker.Disconnect("a")
+	assert.Equal(t, []string{"orders.new"}, broker.Channels(""))
+	assert.Equal(t, 1, broker.NumPat())
+}
+
+func TestShardChannels(t *testing.T) {
+	broker := pubsub.NewBroker()
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	broker.Connect("a", server)
+	assert.Equal(t, 1, broker.Subscribe("a", []string{"news"}))
+	expected := "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	// the count in the confirmation only covers shard channels
+	assert.Equal(t, 2, broker.SSubscribe("a", []string{"{user1}.inbox"}))
+	expected = "*3\r\n$10\r\nssubscribe\r\n$13\r\n{user1}.inbox\r\n:1\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 0, broker.Publish("{user1}.inbox", "hi"))
+	assert.Equal(t, 1, broker.SPublish("{user1}.inbox", "hi"))
+	expected = "*3\r\n$8\r\nsmessage\r\n$13\r\n{user1}.inbox\r\n$2\r\nhi\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, []string{"{user1}.inbox"}, broker.ShardChannels(""))
+	assert.Equal(t, []string{"news"}, broker.Channels(""))
+	assert.Equal(t, []int{1}, broker.ShardNumSub([]string{"{user1}.inbox"}))
+
+	assert.Equal(t, 1, broker.SUnsubscribe("a", nil))
+	expected = "*3\r\n$12\r\nsunsubscribe\r\n$13\r\n{user1}.inbox\r\n:0\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+	assert.Empty(t, broker.ShardChannels(""))
+}
+
+func TestRetention(t *testing.T) {
+	broker := pubsub.NewBroker()
+	broker.Retain("news", pubsub.Retention{MaxLen: 2})
+
+	for _, msg := range []string{"m1", "m2", "m3"} {
+		broker.Publish("news", msg)
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	broker.Connect("a", server)
+
+	// the first message is out of the retention window, the replay resumes at
+	// the oldest message kept
+	assert.Equal(t, 1, broker.RSubscribe("a", []pubsub.Position{{Topic: "news", Offset: 0}}))
+	expected := "*3\r\n$10\r\nrsubscribe\r\n$4\r\nnews\r\n:1\r\n" +
+		"*4\r\n$8\r\nrmessage\r\n$4\r\nnews\r\n:2\r\n$2\r\nm2\r\n" +
+		"*4\r\n$8\r\nrmessage\r\n$4\r\nnews\r\n:3\r\n$2\r\nm3\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.Equal(t, 1, broker.Publish("news", "m4"))
+	expected = "*4\r\n$8\r\nrmessage\r\n$4\r\nnews\r\n:4\r\n$2\r\nm4\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+
+	assert.True(t, broker.Unretain("news"))
+	assert.False(t, broker.Unretain("news"))
+
+	assert.Equal(t, 1, broker.Publish("news", "m5"))
+	expected = "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$2\r\nm5\r\n"
+	assert.Equal(t, expected, readN(t, client, len(expected)))
+}
+
+func TestRetentionWhilePublishing(t *testing.T) {
+	const publishers, messages = 4, 50
+
+	broker := pubsub.NewBroker()
+	broker.Retain("news", pubsub.Retention{MaxLen: publishers * messages})
+
+	server, client := net.Pipe()
+	defer client.Close()
+	broker.Connect("a", server)
+
+	// the client subscribes while messages are being published, each of them
+	// is either replayed or delivered live
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < publishers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for j := 0; j < messages; j++ {
+				broker.Publish("news", "m")
+			}
+		}()
+	}
+	close(start)
+	broker.RSubscribe("a", []pubsub.Position{{Topic: "news", Offset: 0}})
+
+	r := resp.NewReplyReader(client)
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+	reply, err := r.ReadReply()
+	require.NoError(t, err)
+	assert.Equal(t, []any{"rsubscribe", "news", int64(1)}, reply)
+
+	// the offsets come in order without gaps nor duplicates
+	for offset := int64(1); offset <= publishers*messages; offset++ {
+		reply, err := r.ReadReply()
+		require.NoError(t, err)
+		require.Equal(t, []any{"rmessage", "news", offset, "m"}, reply)
+	}
+	wg.Wait()
+}
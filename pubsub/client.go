@@ -0,0 +1,363 @@
+package pubsub
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// OutputBufferLimit bounds how much data can be queued for a subscriber that
+// is not reading fast enough. Going over Hard disconnects the client right
+// away, staying over Soft for longer than SoftDuration disconnects it as well.
+// A zero value disables the corresponding limit.
+type OutputBufferLimit struct {
+	Hard         int
+	Soft         int
+	SoftDuration time.Duration
+}
+
+// DefaultOutputBufferLimit matches the redis default for pubsub clients.
+var DefaultOutputBufferLimit = OutputBufferLimit{
+	Hard:         32 * 1024 * 1024,
+	Soft:         8 * 1024 * 1024,
+	SoftDuration: 60 * time.Second,
+}
+
+// ClientInfo describes a connected client for CLIENT LIST. OutputBuffer is
+// the size in bytes of the OutputMessages waiting to be written.
+type ClientInfo struct {
+	Channels       int
+	Patterns       int
+	ShardChannels  int
+	OutputMessages int
+	OutputBuffer   int
+}
+
+type client struct {
+	id    string
+	conn  io.WriteCloser
+	limit OutputBufferLimit
+
+	mu          sync.Mutex
+	topics      map[string]struct{}
+	patterns    map[string]struct{}
+	shardTopics map[string]struct{}
+
+	// replayTopics are the topics subscribed to with RSubscribe, a subset of
+	// topics
+	replayTopics map[string]struct{}
+
+	// pending holds the encoded messages waiting to be written, size counts
+	// them together with the batch currently being written
+	pending   [][]byte
+	size      int
+	softSince time.Time
+	closed    bool
+	notify    chan struct{}
+	done      chan struct{}
+
+	// removed is set once the client is taken out of the broker, it can not
+	// subscribe to anything after that
+	removed bool
+}
+
+func newClient(id string, conn io.WriteCloser, limit OutputBufferLimit) *client {
+	return &client{
+		id:    id,
+		conn:  conn,
+		limit: limit,
+
+		topics:       make(map[string]struct{}),
+		patterns:     make(map[string]struct{}),
+		shardTopics:  make(map[string]struct{}),
+		replayTopics: make(map[string]struct{}),
+		notify:       make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+}
+
+func (c *client) Listen() {
+	go c.SendMessages()
+}
+
+// Send queues msg for delivery without blocking. It returns false if the
+// client is closed or went over its output buffer limit.
+func (c *client) Send(msg *Message) bool {
+	return c.send(msg.encode())
+}
+
+func (c *client) send(data []byte) bool {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return false
+	}
+
+	c.pending = append(c.pending, data)
+	c.size += len(data)
+
+	if c.overLimit(time.Now()) {
+		c.mu.Unlock()
+		slog.Warn("subscriber went over its output buffer limit", "id", c.id, "size", c.size)
+		c.Close()
+		return false
+	}
+
+	// notify is closed by Close and Detach, which take the lock first
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	c.mu.Unlock()
+
+	return true
+}
+
+// overLimit must be called while holding the lock.
+func (c *client) overLimit(now time.Time) bool {
+	if c.limit.Hard > 0 && c.size > c.limit.Hard {
+		return true
+	}
+
+	if c.limit.Soft <= 0 || c.size <= c.limit.Soft {
+		c.softSince = time.Time{}
+		return false
+	}
+
+	if c.softSince.IsZero() {
+		c.softSince = now
+		return false
+	}
+
+	return now.Sub(c.softSince) > c.limit.SoftDuration
+}
+
+// SendMessages writes the queued messages to the connection until the client
+// is closed, messages queued in the meantime are written as a single batch.
+func (c *client) SendMessages() {
+	defer close(c.done)
+
+	var buf bytes.Buffer
+
+	for open := true; open; {
+		_, open = <-c.notify
+
+		c.mu.Lock()
+		batch := c.pending
+		c.pending = nil
+		c.mu.Unlock()
+
+		if len(batch) == 0 {
+			continue
+		}
+
+		buf.Reset()
+		for _, data := range batch {
+			buf.Write(data)
+		}
+
+		if _, err := c.conn.Write(buf.Bytes()); err != nil {
+			slog.Error("could not publish message", "error", err, "id", c.id)
+			c.Close()
+			return
+		}
+
+		c.mu.Lock()
+		c.size -= buf.Len()
+		c.mu.Unlock()
+	}
+
+	slog.Info("closed client writer thread", "id", c.id)
+}
+
+// count returns the number of subscriptions of any kind.
+func (c *client) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.topics) + len(c.patterns) + len(c.shardTopics)
+}
+
+// info describes the subscriptions and output buffer of the client.
+func (c *client) info() ClientInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return ClientInfo{
+		Channels:       len(c.topics),
+		Patterns:       len(c.patterns),
+		ShardChannels:  len(c.shardTopics),
+		OutputMessages: len(c.pending),
+		OutputBuffer:   c.size,
+	}
+}
+
+func (c *client) countTopicsAndPatterns() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.topics) + len(c.patterns)
+}
+
+// addTopic adds topic to the subscriptions of the client and runs register,
+// which adds the client to the subscribers of topic, under the same lock so
+// that a client being removed can not miss it. It returns false once the
+// client is removed from the broker.
+func (c *client) addTopic(topic string, register func()) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.removed {
+		return 0, false
+	}
+	register()
+	c.topics[topic] = struct{}{}
+	return len(c.topics) + len(c.patterns), true
+}
+
+// addReplayTopic is like addTopic for topics subscribed to with RSubscribe.
+func (c *client) addReplayTopic(topic string, register func()) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.removed {
+		return 0, false
+	}
+	register()
+	c.topics[topic] = struct{}{}
+	c.replayTopics[topic] = struct{}{}
+	return len(c.topics) + len(c.patterns), true
+}
+
+func (c *client) subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.topics[topic]
+	return ok
+}
+
+func (c *client) replaying(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.replayTopics[topic]
+	return ok
+}
+
+func (c *client) removeTopic(topic string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.topics, topic)
+	delete(c.replayTopics, topic)
+	return len(c.topics) + len(c.patterns)
+}
+
+// addPattern is like addTopic for patterns.
+func (c *client) addPattern(pattern string, register func()) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.removed {
+		return 0, false
+	}
+	register()
+	c.patterns[pattern] = struct{}{}
+	return len(c.topics) + len(c.patterns), true
+}
+
+func (c *client) removePattern(pattern string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.patterns, pattern)
+	return len(c.topics) + len(c.patterns)
+}
+
+// addShardTopic is like addTopic for shard channels.
+func (c *client) addShardTopic(topic string, register func()) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.removed {
+		return 0, false
+	}
+	register()
+	c.shardTopics[topic] = struct{}{}
+	return len(c.shardTopics), true
+}
+
+func (c *client) removeShardTopic(topic string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.shardTopics, topic)
+	return len(c.shardTopics)
+}
+
+// remove marks the client as removed from the broker and returns what it was
+// subscribed to, nothing is added to its subscriptions afterwards.
+func (c *client) remove() ([]string, []string, []string) {
+	c.mu.Lock()
+	c.removed = true
+	c.mu.Unlock()
+
+	return c.subscriptions()
+}
+
+// subscriptions returns a copy of the topics, patterns and shard topics the
+// client is subscribed to.
+func (c *client) subscriptions() ([]string, []string, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	shardTopics := make([]string, 0, len(c.shardTopics))
+	for topic := range c.shardTopics {
+		shardTopics = append(shardTopics, topic)
+	}
+
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+
+	patterns := make([]string, 0, len(c.patterns))
+	for pattern := range c.patterns {
+		patterns = append(patterns, pattern)
+	}
+
+	return topics, patterns, shardTopics
+}
+
+func (c *client) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.pending = nil
+	close(c.notify)
+	c.mu.Unlock()
+
+	c.conn.Close()
+	slog.Info("closed connection", "id", c.id)
+}
+
+// Detach stops the client once everything queued has been written, without
+// closing the connection.
+func (c *client) Detach() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	close(c.notify)
+	c.mu.Unlock()
+
+	<-c.done
+}
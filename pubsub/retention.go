@@ -0,0 +1,164 @@
+package pubsub
+
+import (
+	"sync"
+	"time"
+)
+
+// Retention bounds the history kept for a topic, by number of messages, by
+// age or both. A zero value disables the corresponding bound.
+type Retention struct {
+	MaxLen int
+	MaxAge time.Duration
+}
+
+// Topic is the history of a topic with retention enabled. Every message
+// published to it gets an offset, offsets keep growing for as long as the
+// retention is enabled so a subscriber can resume where it left off.
+type Topic struct {
+	Name      string
+	Retention Retention
+	Messages  []RetainedMessage
+
+	mu   sync.Mutex
+	next uint64
+}
+
+type RetainedMessage struct {
+	Offset    uint64
+	Data      any
+	Timestamp time.Time
+}
+
+// Position is where a resubscribing client wants the replay of a topic to
+// start: right after Offset, or at Since when it is set.
+type Position struct {
+	Topic  string
+	Offset uint64
+	Since  time.Time
+}
+
+// append adds a message to the history and returns its offset, the topic
+// must be locked.
+func (t *Topic) append(data any, now time.Time) uint64 {
+	t.next++
+	t.Messages = append(t.Messages, RetainedMessage{Offset: t.next, Data: data, Timestamp: now})
+	t.trim(now)
+	return t.next
+}
+
+// trim drops the messages that went over the retention bounds, the topic
+// must be locked.
+func (t *Topic) trim(now time.Time) {
+	drop := 0
+	if t.Retention.MaxLen > 0 && len(t.Messages) > t.Retention.MaxLen {
+		drop = len(t.Messages) - t.Retention.MaxLen
+	}
+
+	if t.Retention.MaxAge > 0 {
+		for drop < len(t.Messages) && now.Sub(t.Messages[drop].Timestamp) > t.Retention.MaxAge {
+			drop++
+		}
+	}
+
+	if drop > 0 {
+		t.Messages = append([]RetainedMessage(nil), t.Messages[drop:]...)
+	}
+}
+
+// since returns the retained messages a client resuming from pos has missed,
+// the topic must be locked.
+func (t *Topic) since(pos Position) []RetainedMessage {
+	t.trim(time.Now())
+
+	for i, msg := range t.Messages {
+		if pos.Since.IsZero() && msg.Offset > pos.Offset {
+			return t.Messages[i:]
+		}
+		if !pos.Since.IsZero() && !msg.Timestamp.Before(pos.Since) {
+			return t.Messages[i:]
+		}
+	}
+
+	return nil
+}
+
+// Retain enables retention for topic, or changes the bounds of a topic that
+// is already retained. Changing the bounds keeps the history and offsets.
+func (b *broker) Retain(topic string, retention Retention) {
+	b.topicsMu.Lock()
+	defer b.topicsMu.Unlock()
+
+	t, ok := b.topics[topic]
+	if !ok {
+		b.topics[topic] = &Topic{Name: topic, Retention: retention}
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.Retention = retention
+	t.trim(time.Now())
+}
+
+// Unretain disables retention for topic and drops its history, it reports
+// whether the topic was retained.
+func (b *broker) Unretain(topic string) bool {
+	b.topicsMu.Lock()
+	defer b.topicsMu.Unlock()
+
+	_, ok := b.topics[topic]
+	delete(b.topics, topic)
+	return ok
+}
+
+func (b *broker) retained(topic string) (*Topic, bool) {
+	b.topicsMu.RLock()
+	defer b.topicsMu.RUnlock()
+
+	t, ok := b.topics[topic]
+	return t, ok
+}
+
+// RSubscribe subscribes to topics like Subscribe, and then replays the
+// retained messages published after each position. The topic is locked
+// while doing so, a message is either replayed or delivered live but never
+// both or neither. Messages are sent as rmessage along with their offset.
+func (b *broker) RSubscribe(id string, positions []Position) int {
+	c, ok := b.client(id)
+	if !ok {
+		return 0
+	}
+
+	for _, pos := range positions {
+		t, retained := b.retained(pos.Topic)
+		if retained {
+			t.mu.Lock()
+		}
+
+		count, ok := c.addReplayTopic(pos.Topic, func() { subscribe(b.shard(pos.Topic), c, pos.Topic) })
+		if !ok {
+			if retained {
+				t.mu.Unlock()
+			}
+			return 0
+		}
+		c.Send(&Message{Topic: pos.Topic, Timestamp: time.Now(), Data: count, Kind: MessageKindRSubscribe})
+
+		if !retained {
+			continue
+		}
+
+		for _, msg := range t.since(pos) {
+			if !c.Send(&Message{Kind: MessageKindRMessage, Topic: pos.Topic, Offset: msg.Offset, Timestamp: msg.Timestamp, Data: msg.Data}) {
+				t.mu.Unlock()
+				b.disconnectSlow([]*client{c}, pos.Topic)
+				return 0
+			}
+		}
+		t.mu.Unlock()
+	}
+
+	return c.count()
+}
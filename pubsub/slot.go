@@ -0,0 +1,36 @@
+package pubsub
+
+import "strings"
+
+// SlotCount is the number of hash slots the keyspace and shard channels are
+// split into, the same as redis cluster.
+const SlotCount = 16384
+
+// Slot returns the hash slot of a shard channel. If the name contains a hash
+// tag, a non empty part between the first { and the following }, only the tag
+// is hashed so related channels can be kept in the same slot.
+func Slot(name string) uint16 {
+	if start := strings.IndexByte(name, '{'); start >= 0 {
+		if end := strings.IndexByte(name[start+1:], '}'); end > 0 {
+			name = name[start+1 : start+1+end]
+		}
+	}
+
+	return crc16(name) % SlotCount
+}
+
+// crc16 implements CRC16-CCITT (XMODEM) which is what redis uses for slots.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,28 @@
+package pubsub_test
+
+import (
+	"testing"
+
+	"github.com/aelnahas/sider/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlot(t *testing.T) {
+	tests := []struct {
+		name     string
+		channel  string
+		expected uint16
+	}{
+		{name: "plain", channel: "foo", expected: 12182},
+		{name: "plain 123456789", channel: "123456789", expected: 0x31c3 % pubsub.SlotCount},
+		{name: "hash tag", channel: "{user1000}.following", expected: pubsub.Slot("user1000")},
+		{name: "first hash tag", channel: "foo{{bar}}zap", expected: pubsub.Slot("{bar")},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, pubsub.Slot(tc.channel))
+		})
+	}
+}
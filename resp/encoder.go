@@ -1,60 +1,37 @@
 package resp
 
 import (
-	"fmt"
+	"strings"
 )
 
-func Encode(data any) []byte {
-
-	switch data := data.(type) {
-	case string:
-		return encodeString(data)
-	case error:
-		return encodeError(data)
-	case int:
-		return encodeInt(data)
-	case nil:
-		return encodeBulkString(nil)
-	default:
-		return encodeError(fmt.Errorf("unknown response type %T", data))
-	}
-}
-
-func EncodeArray(data ...any) []byte {
-	res := make([]byte, 0)
-	header := fmt.Sprintf("%c%d\r\n", SymbolArray, len(data))
-	res = append(res, []byte(header)...)
-
-	for _, entry := range data {
-		val := Encode(entry)
-		res = append(res, val...)
-	}
+// SimpleString is encoded as a simple string rather than a bulk string, for
+// status replies like OK. It can not hold CR or LF.
+type SimpleString string
 
-	return res
-}
+// OK is the status reply of commands that have nothing else to say.
+const OK SimpleString = "OK"
 
-func EncodeBulkString(data string) []byte {
-	return encodeBulkString(&data)
-}
+// NullArray is encoded as the null array, the reply of EXEC when a watched key
+// changed, where nil is encoded as the null bulk string.
+type NullArray struct{}
 
-func encodeString(data string) []byte {
-	return []byte(fmt.Sprintf("+%s\r\n", data))
+// Encode returns the encoding of any value Writer.WriteValue accepts, for
+// replies that are queued rather than written right away.
+func Encode(data any) []byte {
+	var w Writer
+	_ = w.WriteValue(data)
+	return w.buf
 }
 
-func encodeBulkString(data *string) []byte {
-	if data == nil {
-		return []byte("$-1\r\n")
-	}
-
-	return []byte(fmt.Sprintf("%c%d\r\n%s\r\n", SymbolBulkString, len(*data), *data))
-
+func EncodeArray(data ...any) []byte {
+	return Encode(data)
 }
 
-func encodeError(err error) []byte {
-	return []byte(fmt.Sprintf("-%s\r\n", err.Error()))
+func EncodeBulkString(data string) []byte {
+	var w Writer
+	_ = w.WriteBulkString(data)
+	return w.buf
 }
 
-func encodeInt(data int) []byte {
-
-	return []byte(fmt.Sprintf("%c%d\r\n", SymbolInt, data))
-}
+// newlines would end the error early, so they are replaced like redis does.
+var errorReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
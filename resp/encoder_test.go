@@ -16,10 +16,15 @@ func TestEncoder(t *testing.T) {
 
 		expected []byte
 	}{
+		{
+			name:     "simple string",
+			data:     resp.OK,
+			expected: []byte("+OK\r\n"),
+		},
 		{
 			name:     "string",
-			data:     "ok",
-			expected: []byte("+ok\r\n"),
+			data:     "a\r\nb",
+			expected: []byte("$4\r\na\r\nb\r\n"),
 		},
 		{
 			name:     "int",
@@ -37,9 +42,49 @@ func TestEncoder(t *testing.T) {
 			expected: []byte("$-1\r\n"),
 		},
 		{
-			name:     "unexpected type",
+			name:     "null array",
+			data:     resp.NullArray{},
+			expected: []byte("*-1\r\n"),
+		},
+		{
+			name:     "int64",
+			data:     int64(-7),
+			expected: []byte(":-7\r\n"),
+		},
+		{
+			name:     "bytes",
+			data:     []byte("abc"),
+			expected: []byte("$3\r\nabc\r\n"),
+		},
+		{
+			name:     "float",
+			data:     1.5,
+			expected: []byte("$3\r\n1.5\r\n"),
+		},
+		{
+			name:     "bool",
 			data:     true,
-			expected: []byte("-unknown response type bool\r\n"),
+			expected: []byte(":1\r\n"),
+		},
+		{
+			name:     "nested array",
+			data:     []any{"a", []any{1, nil}, []string{"b"}},
+			expected: []byte("*3\r\n$1\r\na\r\n*2\r\n:1\r\n$-1\r\n*1\r\n$1\r\nb\r\n"),
+		},
+		{
+			name:     "map",
+			data:     map[string]any{"b": 2, "a": resp.SimpleString("x")},
+			expected: []byte("*4\r\n$1\r\na\r\n+x\r\n$1\r\nb\r\n:2\r\n"),
+		},
+		{
+			name:     "error with newlines",
+			data:     errors.New("ERR a\nb"),
+			expected: []byte("-ERR a b\r\n"),
+		},
+		{
+			name:     "unexpected type",
+			data:     struct{}{},
+			expected: []byte("-unknown response type struct {}\r\n"),
 		},
 	}
 
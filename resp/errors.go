@@ -8,6 +8,12 @@ import (
 var (
 	ErrNotABulkString = errors.New("invalid syntax, input is not a valid resp bulk string")
 	ErrOutOfBound     = errors.New("index out of bound")
+
+	ErrInvalidBulkLength              = errors.New("invalid bulk length")
+	ErrInvalidMultiBulkLength         = errors.New("invalid multibulk length")
+	ErrUnauthenticatedBulkLength      = errors.New("unauthenticated bulk length")
+	ErrUnauthenticatedMultiBulkLength = errors.New("unauthenticated multibulk length")
+	ErrQueryBufferLimit               = errors.New("client query buffer limit reached")
 )
 
 type ErrUnexpectedSymbol struct {
@@ -26,3 +32,17 @@ type ErrUnknownCommand struct {
 func (e ErrUnknownCommand) Error() string {
 	return fmt.Sprintf("unknown command '%s'", e.Name)
 }
+
+// ErrProtocol is returned when the input is not valid resp, once that happens
+// there is no way to tell where the next command starts.
+type ErrProtocol struct {
+	Err error
+}
+
+func (e ErrProtocol) Error() string {
+	return fmt.Sprintf("ERR Protocol error: %s", e.Err.Error())
+}
+
+func (e ErrProtocol) Unwrap() error {
+	return e.Err
+}
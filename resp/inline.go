@@ -0,0 +1,211 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxInlineSize is the longest inline command accepted, like in redis.
+const maxInlineSize = 64 * 1024
+
+var (
+	ErrUnbalancedQuotes = errors.New("unbalanced quotes in request")
+	ErrInlineTooBig     = errors.New("too big inline request")
+)
+
+// readInline reads an inline command, a line of space separated arguments as
+// typed in telnet or netcat, along with the number of bytes it took. Empty
+// lines are skipped like redis does.
+func readInline(r *bufio.Reader) ([]string, int64, error) {
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		args, err := SplitArgs(string(line))
+		if err != nil || len(args) > 0 {
+			return args, int64(len(line)), err
+		}
+	}
+}
+
+// readLine reads up to and without the next \n or \r\n.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxInlineSize {
+			return nil, ErrInlineTooBig
+		}
+		line = append(line, chunk...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line = bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})
+		return line, nil
+	}
+}
+
+// SplitArgs splits an inline command, or a line of a config file, into its
+// arguments with the quoting rules of redis. Arguments are separated by
+// whitespace, in double quotes spaces are kept and \n, \r, \t, \b, \a, \\, \"
+// and \xHH are escapes, in single quotes only \' is. A closing quote must be
+// followed by a space or the end of the line.
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var arg strings.Builder
+		inDouble, inSingle := false, false
+		for done := false; !done; {
+			switch {
+			case inDouble:
+				if i == len(line) {
+					return nil, ErrUnbalancedQuotes
+				}
+
+				switch c := line[i]; {
+				case c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]):
+					arg.WriteByte(unhex(line[i+2])<<4 | unhex(line[i+3]))
+					i += 3
+				case c == '\\' && i+1 < len(line):
+					i++
+					arg.WriteByte(unescape(line[i]))
+				case c == '"':
+					// the closing quote must be followed by a space
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					arg.WriteByte(c)
+				}
+			case inSingle:
+				if i == len(line) {
+					return nil, ErrUnbalancedQuotes
+				}
+
+				switch c := line[i]; {
+				case c == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					i++
+					arg.WriteByte('\'')
+				case c == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					arg.WriteByte(c)
+				}
+			default:
+				if i == len(line) {
+					done = true
+					break
+				}
+
+				switch c := line[i]; {
+				case isSpace(c) || c == 0:
+					done = true
+				case c == '"':
+					inDouble = true
+				case c == '\'':
+					inSingle = true
+				default:
+					arg.WriteByte(c)
+				}
+			}
+
+			if i < len(line) {
+				i++
+			}
+		}
+
+		args = append(args, arg.String())
+	}
+}
+
+// Quote returns s in double quotes with every byte that is not printable
+// escaped, the way redis prints arguments. SplitArgs reads it back as s, and
+// it always fits on a single line.
+func Quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; b {
+		case '\\', '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(b)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		default:
+			if b < ' ' || b > '~' {
+				fmt.Fprintf(&sb, `\x%02x`, b)
+				continue
+			}
+			sb.WriteByte(b)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\v' || c == '\f'
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	default:
+		return c - '0'
+	}
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'a':
+		return '\a'
+	default:
+		return c
+	}
+}
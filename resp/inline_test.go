@@ -0,0 +1,92 @@
+package resp_test
+
+import (
+	"testing"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+
+		expectedError error
+		expectedArgs  []string
+	}{
+		{
+			name:         "empty",
+			line:         "  \t ",
+			expectedArgs: nil,
+		},
+		{
+			name:         "spaces",
+			line:         " set  foo\tbar ",
+			expectedArgs: []string{"set", "foo", "bar"},
+		},
+		{
+			name:         "double quotes",
+			line:         `set "foo bar" "a\"b\\c\n" "\x41\x4a" ""`,
+			expectedArgs: []string{"set", "foo bar", "a\"b\\c\n", "AJ", ""},
+		},
+		{
+			name:         "single quotes",
+			line:         `set 'it\'s' 'a\nb'`,
+			expectedArgs: []string{"set", "it's", `a\nb`},
+		},
+		{
+			name:         "every kind of whitespace",
+			line:         "set\vfoo\fbar\r\n",
+			expectedArgs: []string{"set", "foo", "bar"},
+		},
+		{
+			name:         "invalid hex escape",
+			line:         `set "\xZZ" "\x4"`,
+			expectedArgs: []string{"set", "xZZ", "x4"},
+		},
+		{
+			name:         "quotes inside an argument",
+			line:         `set foo"bar baz"`,
+			expectedArgs: []string{"set", "foobar baz"},
+		},
+		{
+			name:          "unbalanced double quotes",
+			line:          `set "foo`,
+			expectedError: resp.ErrUnbalancedQuotes,
+		},
+		{
+			name:          "unbalanced single quotes",
+			line:          `set 'foo`,
+			expectedError: resp.ErrUnbalancedQuotes,
+		},
+		{
+			name:          "closing quote followed by a character",
+			line:          `set "foo"bar`,
+			expectedError: resp.ErrUnbalancedQuotes,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			args, err := resp.SplitArgs(tc.line)
+			assert.Equal(t, tc.expectedError, err)
+			assert.Equal(t, tc.expectedArgs, args)
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	values := []string{"", "plain", "a b", "a\"b\\c", "\r\n\t\a\b", "\x00\x7f\xff"}
+	for _, value := range values {
+		quoted := resp.Quote(value)
+		assert.NotContains(t, quoted, "\n")
+
+		args, err := resp.SplitArgs(quoted)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{value}, args)
+	}
+
+	assert.Equal(t, `"a\"b\r\n\x01"`, resp.Quote("a\"b\r\n\x01"))
+}
@@ -1,28 +1,67 @@
 package resp
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"strconv"
 	"strings"
-	"time"
+
+	"github.com/aelnahas/sider/commands"
 )
 
 type RawCommand struct {
 	Name        string
 	Args        []string
-	Options     map[string]any
 	IsPubSubCMD bool
+
+	// Parsed holds the arguments by name for commands with a grammar in the
+	// command table.
+	Parsed *commands.Args
+}
+
+// Parse reads a single command from input. When the command is well formed
+// resp but is not a valid command the rest of it is still consumed, so input
+// can be used to parse the next command. Malformed input is reported with
+// ErrProtocol, as are commands over the limits given with WithLimits.
+func Parse(input io.Reader, opts ...ScannerOption) (*RawCommand, error) {
+	scanner := NewScanner(input, opts...)
+
+	cmd, err := parse(scanner)
+	if err == nil {
+		return cmd, nil
+	}
+
+	if errors.As(err, &ErrProtocol{}) {
+		return nil, err
+	}
+
+	if drainErr := scanner.Drain(); drainErr != nil {
+		return nil, ErrProtocol{Err: drainErr}
+	}
+
+	return nil, err
 }
 
-func Parse(input io.Reader) (*RawCommand, error) {
-	scanner := NewScanner(input)
+// ParseArgs validates a command given as a list of arguments, as if it had
+// been sent as a resp array of bulk strings.
+func ParseArgs(args ...string) (*RawCommand, error) {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("%c%d\r\n", SymbolArray, len(args)))
+	for _, arg := range args {
+		buf.Write(EncodeBulkString(arg))
+	}
 
-	args := make([]string, 0)
+	return Parse(&buf)
+}
 
+func parse(scanner *Scanner) (*RawCommand, error) {
 	token, lit, err := scanner.Next()
 	if err != nil {
-		return nil, err
+		if errors.As(err, &ErrUnknownCommand{}) {
+			return nil, err
+		}
+		return nil, ErrProtocol{Err: err}
 	}
 
 	if token == TokenArg {
@@ -30,80 +69,24 @@ func Parse(input io.Reader) (*RawCommand, error) {
 	}
 	name := strings.ToUpper(lit)
 
-	cmdRule, ok := rules[name]
+	command, ok := commands.Lookup(name)
 	if !ok {
 		return nil, ErrUnknownCommand{Name: name}
 	}
 
-	optionName := ""
-	options := make(map[string]any)
+	argv := []string{name}
 	for scanner.HasNext() {
-		token, lit, err := scanner.Next()
+		_, lit, err := scanner.Next()
 		if err != nil {
-			return nil, err
-		}
-
-		if token == TokenArg {
-
-			if len(args) < cmdRule.maxArgCount || cmdRule.argType == argTypeVar {
-				args = append(args, lit)
-			} else if !cmdRule.hasOptions {
-				return nil, fmt.Errorf("cmd %s does not accept options", name)
-			} else if optionName != "" {
-				optSyntax := cmdRule.options[optionName]
-				val, err := parseOptValue(optSyntax.dataType, optionName, lit)
-
-				if err != nil {
-					return nil, err
-				}
-				options[optionName] = val
-				optionName = ""
-			} else {
-				optionName = strings.ToUpper(lit)
-				opt, found := cmdRule.options[optionName]
-				if !found {
-					return nil, fmt.Errorf("syntax error command %s does not support option %s", name, lit)
-				}
-
-				_, ok := opt.dataType.(bool)
-				if ok {
-					options[optionName] = true
-					optionName = ""
-				}
-			}
+			return nil, ErrProtocol{Err: err}
 		}
+		argv = append(argv, lit)
 	}
 
-	if len(args) < cmdRule.minArgCount {
-		return nil, fmt.Errorf("syntax err command %s is missing required args", name)
-	}
-
-	if optionName != "" {
-		return nil, fmt.Errorf("syntax err option %s is missing a value", optionName)
+	parsed, err := commands.Parse(argv)
+	if err != nil {
+		return nil, err
 	}
 
-	return &RawCommand{Name: name, Args: args, Options: options, IsPubSubCMD: cmdRule.isPubSubCmd}, nil
-}
-
-func parseOptValue(dataType any, name, val string) (any, error) {
-
-	switch dataType := dataType.(type) {
-	case string:
-		return val, nil
-	case int:
-		return strconv.Atoi(val)
-	case time.Time:
-		return time.Parse(time.RFC3339, val)
-	case time.Duration:
-
-		unit := "s"
-		if dataType == time.Millisecond {
-			unit = "ms"
-		}
-
-		val += unit
-		return time.ParseDuration(val)
-	default:
-		return nil, fmt.Errorf("unsupported data type (%T) provided with option (%s)", dataType, name)
-	}
+	return &RawCommand{Name: name, Args: argv[1:], IsPubSubCMD: command.Group == "pubsub", Parsed: parsed}, nil
 }
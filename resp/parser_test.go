@@ -1,13 +1,16 @@
 package resp_test
 
 import (
+	"bufio"
 	"bytes"
-	"errors"
+	"fmt"
+	"strings"
 	"testing"
-	"time"
 
+	"github.com/aelnahas/sider/commands"
 	"github.com/aelnahas/sider/resp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParser(t *testing.T) {
@@ -23,9 +26,8 @@ func TestParser(t *testing.T) {
 			input:         "*2\r\n$3\r\nget\r\n$3\r\nfoo\r\n",
 			expectedError: nil,
 			expectedAST: &resp.RawCommand{
-				Name:    "GET",
-				Args:    []string{"foo"},
-				Options: map[string]any{},
+				Name: "GET",
+				Args: []string{"foo"},
 			},
 		},
 		{
@@ -33,9 +35,9 @@ func TestParser(t *testing.T) {
 			input:         "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n",
 			expectedError: nil,
 			expectedAST: &resp.RawCommand{
-				Name:    "SET",
-				Args:    []string{"foo", "bar"},
-				Options: map[string]any{},
+				Name:   "SET",
+				Args:   []string{"foo", "bar"},
+				Parsed: mustParse("SET", "foo", "bar"),
 			},
 		},
 		{
@@ -43,12 +45,9 @@ func TestParser(t *testing.T) {
 			input:         "*5\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n$2\r\nEX\r\n$3\r\n100\r\n",
 			expectedError: nil,
 			expectedAST: &resp.RawCommand{
-				Name: "SET",
-				Args: []string{"foo", "bar"},
-				Options: map[string]any{
-					"EX": time.Duration(100000000000),
-				},
-				IsPubSubCMD: false,
+				Name:   "SET",
+				Args:   []string{"foo", "bar", "EX", "100"},
+				Parsed: mustParse("SET", "foo", "bar", "EX", "100"),
 			},
 		},
 		{
@@ -56,9 +55,8 @@ func TestParser(t *testing.T) {
 			input:         "*1\r\n$4\r\nPING\r\n",
 			expectedError: nil,
 			expectedAST: &resp.RawCommand{
-				Name:    "PING",
-				Args:    []string{},
-				Options: map[string]any{},
+				Name: "PING",
+				Args: []string{},
 			},
 		},
 		{
@@ -66,9 +64,8 @@ func TestParser(t *testing.T) {
 			input:         "*2\r\n$4\r\nPING\r\n$3\r\nfoo\r\n",
 			expectedError: nil,
 			expectedAST: &resp.RawCommand{
-				Name:    "PING",
-				Args:    []string{"foo"},
-				Options: map[string]any{},
+				Name: "PING",
+				Args: []string{"foo"},
 			},
 		},
 		{
@@ -76,9 +73,8 @@ func TestParser(t *testing.T) {
 			input:         "*2\r\n$4\r\nPING\r\n$3\r\nfoo\r\n",
 			expectedError: nil,
 			expectedAST: &resp.RawCommand{
-				Name:    "PING",
-				Args:    []string{"foo"},
-				Options: map[string]any{},
+				Name: "PING",
+				Args: []string{"foo"},
 			},
 		},
 		{
@@ -90,13 +86,29 @@ func TestParser(t *testing.T) {
 		{
 			name:          "get given without options",
 			input:         "*1\r\n$3\r\nGET\r\n",
-			expectedError: errors.New("syntax err command GET is missing required args"),
+			expectedError: commands.ArityError{Name: "get"},
 			expectedAST:   nil,
 		},
 		{
 			name:          "set with missing option value",
 			input:         "*4\r\n$3\r\nset\r\n$3\r\nfoo\r\n$3\r\nbar\r\n$2\r\nex\r\n",
-			expectedError: errors.New("syntax err option EX is missing a value"),
+			expectedError: commands.ErrSyntax,
+			expectedAST:   nil,
+		},
+		{
+			name:          "inline",
+			input:         "\r\nset foo \"bar baz\" ex 100\r\n",
+			expectedError: nil,
+			expectedAST: &resp.RawCommand{
+				Name:   "SET",
+				Args:   []string{"foo", "bar baz", "ex", "100"},
+				Parsed: mustParse("SET", "foo", "bar baz", "ex", "100"),
+			},
+		},
+		{
+			name:          "inline with unbalanced quotes",
+			input:         "echo \"foo\r\n",
+			expectedError: resp.ErrProtocol{Err: resp.ErrUnbalancedQuotes},
 			expectedAST:   nil,
 		},
 	}
@@ -112,3 +124,158 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+// TestParseErrors checks that commands are checked against the arity and the
+// grammar of the command table, and that the rest of the input is left for
+// the next command.
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+
+		expectedError error
+	}{
+		{
+			name:          "too many arguments",
+			args:          []string{"GET", "a", "b"},
+			expectedError: commands.ArityError{Name: "get"},
+		},
+		{
+			name:          "missing argument",
+			args:          []string{"ECHO"},
+			expectedError: commands.ArityError{Name: "echo"},
+		},
+		{
+			name:          "missing variadic argument",
+			args:          []string{"WATCH"},
+			expectedError: commands.ArityError{Name: "watch"},
+		},
+		{
+			name:          "expire time that is not an integer",
+			args:          []string{"SET", "a", "b", "EX", "abc"},
+			expectedError: commands.ErrNotInteger,
+		},
+		{
+			name:          "conflicting options",
+			args:          []string{"SET", "a", "b", "NX", "XX"},
+			expectedError: commands.ErrSyntax,
+		},
+		{
+			name:          "unknown option",
+			args:          []string{"SET", "a", "b", "FOREVER"},
+			expectedError: commands.ErrSyntax,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var input bytes.Buffer
+			input.WriteString(fmt.Sprintf("*%d\r\n", len(tc.args)))
+			for _, arg := range tc.args {
+				input.Write(resp.EncodeBulkString(arg))
+			}
+			input.WriteString("*1\r\n$4\r\nPING\r\n")
+
+			// the reader is shared between commands like it is on a connection
+			reader := bufio.NewReader(&input)
+
+			_, err := resp.Parse(reader)
+			assert.Equal(t, tc.expectedError, err)
+
+			next, err := resp.Parse(reader)
+			require.NoError(t, err)
+			assert.Equal(t, "PING", next.Name)
+		})
+	}
+}
+
+func mustParse(argv ...string) *commands.Args {
+	parsed, err := commands.Parse(argv)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func TestParseLimits(t *testing.T) {
+	limits := resp.Limits{MaxBulkLen: 8, MaxMultiBulkLen: 3, MaxQueryLen: 12}
+
+	tests := []struct {
+		name   string
+		input  string
+		limits resp.Limits
+
+		expectedError error
+	}{
+		{
+			name:   "within limits",
+			input:  "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n",
+			limits: limits,
+		},
+		{
+			name:          "bulk too long",
+			input:         "*2\r\n$3\r\nGET\r\n$9\r\nfoobarbaz\r\n",
+			limits:        limits,
+			expectedError: resp.ErrProtocol{Err: resp.ErrInvalidBulkLength},
+		},
+		{
+			name:          "too many elements",
+			input:         "*4\r\n$3\r\nDEL\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n",
+			limits:        limits,
+			expectedError: resp.ErrProtocol{Err: resp.ErrInvalidMultiBulkLength},
+		},
+		{
+			name:          "query buffer limit",
+			input:         "*3\r\n$3\r\nSET\r\n$5\r\nfoo12\r\n$5\r\nbar12\r\n",
+			limits:        limits,
+			expectedError: resp.ErrProtocol{Err: resp.ErrQueryBufferLimit},
+		},
+		{
+			name:          "inline query buffer limit",
+			input:         "SET foo12 bar12\r\n",
+			limits:        limits,
+			expectedError: resp.ErrProtocol{Err: resp.ErrQueryBufferLimit},
+		},
+		{
+			name:          "size that overflows",
+			input:         "*1\r\n$99999999999999999999\r\n",
+			expectedError: resp.ErrProtocol{Err: resp.ErrInvalidBulkLength},
+		},
+		{
+			name:          "negative size",
+			input:         "*-1\r\n",
+			expectedError: resp.ErrProtocol{Err: resp.ErrInvalidMultiBulkLength},
+		},
+		{
+			name:          "unauthenticated multibulk length",
+			input:         "*11\r\n",
+			limits:        resp.Limits{Unauthenticated: true},
+			expectedError: resp.ErrProtocol{Err: resp.ErrUnauthenticatedMultiBulkLength},
+		},
+		{
+			name:          "unauthenticated bulk length",
+			input:         "*2\r\n$4\r\nAUTH\r\n$16385\r\n",
+			limits:        resp.Limits{Unauthenticated: true},
+			expectedError: resp.ErrProtocol{Err: resp.ErrUnauthenticatedBulkLength},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := resp.Parse(bytes.NewBufferString(tc.input), resp.WithLimits(tc.limits))
+			assert.Equal(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestParseLongBulk(t *testing.T) {
+	value := strings.Repeat("x", 200*1024)
+	input := bytes.NewBufferString("*2\r\n$4\r\nECHO\r\n")
+	input.Write(resp.EncodeBulkString(value))
+
+	cmd, err := resp.Parse(input)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{value}, cmd.Args)
+}
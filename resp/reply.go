@@ -0,0 +1,271 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The symbols of the types RESP3 adds, they only appear in replies.
+const (
+	SymbolNull           Symbol = '_'
+	SymbolDouble         Symbol = ','
+	SymbolBoolean        Symbol = '#'
+	SymbolBlobError      Symbol = '!'
+	SymbolVerbatimString Symbol = '='
+	SymbolBigNumber      Symbol = '('
+	SymbolMap            Symbol = '%'
+	SymbolSet            Symbol = '~'
+	SymbolAttribute      Symbol = '|'
+	SymbolPush           Symbol = '>'
+)
+
+// ErrInvalidReply is returned when a reply is not valid RESP2 or RESP3.
+var ErrInvalidReply = errors.New("invalid reply")
+
+// ReplyError is an error reply, simple or blob.
+type ReplyError string
+
+func (e ReplyError) Error() string {
+	return string(e)
+}
+
+// Prefix returns the first word of the error, its kind like ERR or WRONGTYPE.
+func (e ReplyError) Prefix() string {
+	prefix, _, _ := strings.Cut(string(e), " ")
+	return prefix
+}
+
+// Push is a RESP3 push message, out of band data like pub/sub messages or
+// invalidations.
+type Push []any
+
+// maxReplyLen bounds the strings of a reply, 512MB like the longest string
+// redis stores.
+const maxReplyLen = 512 << 20
+
+// ReplyReader reads the replies of a server, the counterpart of Writer.
+type ReplyReader struct {
+	r *bufio.Reader
+}
+
+func NewReplyReader(r io.Reader) *ReplyReader {
+	return &ReplyReader{r: bufio.NewReader(r)}
+}
+
+// Buffered returns the number of bytes that were read from the underlying
+// reader but not decoded yet.
+func (r *ReplyReader) Buffered() int {
+	return r.r.Buffered()
+}
+
+// ReadReply reads the next reply. Simple and bulk strings are read as string,
+// integers as int64, doubles as float64, booleans as bool, big numbers as
+// their decimal string, arrays and sets as []any, maps as map[string]any
+// with the keys formatted with fmt.Sprint and push messages as Push. Nulls are
+// nil and error replies are returned as a ReplyError value, not as the
+// error, which is only set when the reply could not be read. Attributes are
+// skipped.
+func (r *ReplyReader) ReadReply() (any, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("%w: empty line", ErrInvalidReply)
+	}
+
+	symbol, rest := Symbol(line[0]), string(line[1:])
+	switch symbol {
+	case SymbolString:
+		return rest, nil
+	case SymbolError:
+		return ReplyError(rest), nil
+	case SymbolInt:
+		return parseInt(rest)
+	case SymbolNull:
+		return nil, nil
+	case SymbolDouble:
+		return parseDouble(rest)
+	case SymbolBoolean:
+		switch rest {
+		case "t":
+			return true, nil
+		case "f":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%w: boolean %q", ErrInvalidReply, rest)
+	case SymbolBigNumber:
+		return rest, nil
+	case SymbolBulkString, SymbolBlobError, SymbolVerbatimString:
+		return r.readBlob(symbol, rest)
+	case SymbolArray, SymbolSet, SymbolPush:
+		n, err := parseInt(rest)
+		if err != nil || n < 0 {
+			// *-1 is the null array of RESP2
+			return nil, err
+		}
+
+		values, err := r.readValues(n)
+		if err != nil {
+			return nil, err
+		}
+		if symbol == SymbolPush {
+			return Push(values), nil
+		}
+		return values, nil
+	case SymbolMap:
+		n, err := parseInt(rest)
+		if err != nil {
+			return nil, err
+		}
+		return r.readMap(n)
+	case SymbolAttribute:
+		n, err := parseInt(rest)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.readMap(n); err != nil {
+			return nil, err
+		}
+		return r.ReadReply()
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidReply, symbol)
+	}
+}
+
+func (r *ReplyReader) readBlob(symbol Symbol, size string) (any, error) {
+	n, err := parseInt(size)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		// $-1 is the null bulk string of RESP2
+		return nil, nil
+	}
+
+	if n > maxReplyLen {
+		return nil, fmt.Errorf("%w: bulk string of %d bytes", ErrInvalidReply, n)
+	}
+
+	// the buffer grows as the blob arrives rather than trusting its size
+	var b strings.Builder
+	b.Grow(capHint(n))
+	if _, err := io.CopyN(&b, r.r, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	var crlf [2]byte
+	if _, err := io.ReadFull(r.r, crlf[:]); err != nil {
+		return nil, err
+	}
+	if crlf != [2]byte{'\r', '\n'} {
+		return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", ErrInvalidReply)
+	}
+
+	blob := b.String()
+	switch symbol {
+	case SymbolBlobError:
+		return ReplyError(blob), nil
+	case SymbolVerbatimString:
+		// the format, like txt or mkd, comes first
+		if len(blob) < 4 || blob[3] != ':' {
+			return nil, fmt.Errorf("%w: verbatim string without a format", ErrInvalidReply)
+		}
+		return blob[4:], nil
+	default:
+		return blob, nil
+	}
+}
+
+func (r *ReplyReader) readValues(n int64) ([]any, error) {
+	values := make([]any, 0, capHint(n))
+	for i := int64(0); i < n; i++ {
+		v, err := r.ReadReply()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (r *ReplyReader) readMap(n int64) (map[string]any, error) {
+	m := make(map[string]any, capHint(n))
+	for i := int64(0); i < n; i++ {
+		key, err := r.ReadReply()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.ReadReply()
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(key)] = value
+	}
+	return m, nil
+}
+
+// readLine reads up to the next CRLF, which is left out. Lines longer than
+// the buffer of the reader, like a long simple string, are put together from
+// the chunks read.
+func (r *ReplyReader) readLine() ([]byte, error) {
+	line, err := r.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		long := append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull {
+			if len(long) > maxReplyLen {
+				return nil, fmt.Errorf("%w: line too long", ErrInvalidReply)
+			}
+			line, err = r.r.ReadSlice('\n')
+			long = append(long, line...)
+		}
+		line = long
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: line not terminated by CRLF", ErrInvalidReply)
+	}
+	return line[:len(line)-2], nil
+}
+
+func parseInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: integer %q", ErrInvalidReply, s)
+	}
+	return n, nil
+}
+
+func parseDouble(s string) (float64, error) {
+	switch s {
+	case "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: double %q", ErrInvalidReply, s)
+	}
+	return f, nil
+}
+
+// capHint bounds the capacity allocated up front for an aggregate, its size
+// comes from the server and the elements may never arrive.
+func capHint(n int64) int {
+	if n > 1024 {
+		return 1024
+	}
+	return int(n)
+}
@@ -0,0 +1,142 @@
+package resp_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadReply(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		input    string
+		expected any
+	}{
+		{desc: "simple string", input: "+OK\r\n", expected: "OK"},
+		{desc: "error", input: "-ERR nope\r\n", expected: resp.ReplyError("ERR nope")},
+		{desc: "integer", input: ":-12\r\n", expected: int64(-12)},
+		{desc: "bulk string", input: "$5\r\na\r\nbc\r\n", expected: "a\r\nbc"},
+		{desc: "null bulk string", input: "$-1\r\n", expected: nil},
+		{desc: "null array", input: "*-1\r\n", expected: nil},
+		{
+			desc:     "nested array",
+			input:    "*3\r\n:1\r\n*1\r\n+a\r\n$-1\r\n",
+			expected: []any{int64(1), []any{"a"}, nil},
+		},
+		{desc: "null", input: "_\r\n", expected: nil},
+		{desc: "double", input: ",1.5\r\n", expected: 1.5},
+		{desc: "infinity", input: ",-inf\r\n", expected: math.Inf(-1)},
+		{desc: "boolean", input: "#t\r\n", expected: true},
+		{desc: "big number", input: "(3492890328409238509324850943850943825024385\r\n", expected: "3492890328409238509324850943850943825024385"},
+		{desc: "blob error", input: "!8\r\nERR a\r\nb\r\n", expected: resp.ReplyError("ERR a\r\nb")},
+		{desc: "verbatim string", input: "=8\r\ntxt:info\r\n", expected: "info"},
+		{
+			desc:     "map",
+			input:    "%2\r\n+a\r\n:1\r\n:2\r\n*0\r\n",
+			expected: map[string]any{"a": int64(1), "2": []any{}},
+		},
+		{desc: "set", input: "~2\r\n+a\r\n+b\r\n", expected: []any{"a", "b"}},
+		{
+			desc:     "push",
+			input:    ">3\r\n+message\r\n+ch\r\n+hi\r\n",
+			expected: resp.Push{"message", "ch", "hi"},
+		},
+		{
+			desc:     "attribute",
+			input:    "|1\r\n+ttl\r\n:3\r\n+value\r\n",
+			expected: "value",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			r := resp.NewReplyReader(bytes.NewBufferString(tc.input))
+
+			reply, err := r.ReadReply()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, reply)
+
+			_, err = r.ReadReply()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestReadReplyInvalid(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		input string
+	}{
+		{desc: "unknown type", input: "?1\r\n"},
+		{desc: "missing CR", input: "+OK\n"},
+		{desc: "not an integer", input: ":one\r\n"},
+		{desc: "bulk string too long", input: "$1\r\nab\r\n"},
+		{desc: "invalid boolean", input: "#x\r\n"},
+		{desc: "bulk string larger than a string can be", input: "$1000000000000\r\nab\r\n"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := resp.NewReplyReader(bytes.NewBufferString(tc.input)).ReadReply()
+			assert.ErrorIs(t, err, resp.ErrInvalidReply)
+		})
+	}
+}
+
+func TestReadReplyLong(t *testing.T) {
+	long := strings.Repeat("a", 3*4096+1)
+
+	testCases := []struct {
+		desc  string
+		input string
+	}{
+		{desc: "simple string", input: "+" + long + "\r\n"},
+		{desc: "error", input: "-" + long + "\r\n"},
+		{desc: "bulk string", input: fmt.Sprintf("$%d\r\n%s\r\n", len(long), long)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			r := resp.NewReplyReader(strings.NewReader(tc.input + ":1\r\n"))
+
+			reply, err := r.ReadReply()
+			require.NoError(t, err)
+			assert.Equal(t, long, fmt.Sprint(reply))
+
+			reply, err = r.ReadReply()
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), reply)
+		})
+	}
+}
+
+func TestReadReplyTruncated(t *testing.T) {
+	_, err := resp.NewReplyReader(strings.NewReader("$5\r\nab")).ReadReply()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReadReplyRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := resp.NewWriter(&buf)
+	require.NoError(t, w.WriteValue([]any{"OK", "v", 3, errors.New("ERR x"), nil}))
+	require.NoError(t, w.Flush())
+
+	reply, err := resp.NewReplyReader(&buf).ReadReply()
+	require.NoError(t, err)
+	assert.Equal(t, []any{"OK", "v", int64(3), resp.ReplyError("ERR x"), nil}, reply)
+}
+
+func TestReplyErrorPrefix(t *testing.T) {
+	assert.Equal(t, "WRONGPASS", resp.ReplyError("WRONGPASS invalid username-password pair").Prefix())
+}
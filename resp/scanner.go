@@ -2,11 +2,13 @@ package resp
 
 import (
 	"bufio"
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strings"
-	"unicode"
+
+	"github.com/aelnahas/sider/commands"
 )
 
 var eof = rune(0)
@@ -16,14 +18,58 @@ const (
 	unsetSize   = -2
 )
 
+// Limits bound the commands a scanner reads so a client can not make the
+// server allocate without bounds, zero means no limit.
+type Limits struct {
+	// MaxBulkLen is the longest bulk string, proto-max-bulk-len in redis.
+	MaxBulkLen int64
+
+	// MaxMultiBulkLen is the most elements a command can have.
+	MaxMultiBulkLen int64
+
+	// MaxQueryLen is the most bytes of bulk strings and inline commands a
+	// single command can hold, client-query-buffer-limit in redis.
+	MaxQueryLen int64
+
+	// Unauthenticated applies the small limits redis has for clients that
+	// did not authenticate yet.
+	Unauthenticated bool
+}
+
+// The limits for unauthenticated clients, which only need to send AUTH or
+// HELLO.
+const (
+	unauthenticatedMaxMultiBulkLen = 10
+	unauthenticatedMaxBulkLen      = 16384
+)
+
 type Scanner struct {
 	r     *bufio.Reader
 	count int
 	size  int
+
+	// inline holds the arguments of an inline command that are left to scan.
+	inline []string
+
+	limits   Limits
+	queryLen int64
+}
+
+type ScannerOption = func(*Scanner)
+
+// WithLimits bounds the commands the scanner reads.
+func WithLimits(limits Limits) ScannerOption {
+	return func(s *Scanner) {
+		s.limits = limits
+	}
 }
 
-func NewScanner(r io.Reader) *Scanner {
-	return &Scanner{r: bufio.NewReader(r), count: unsetSize}
+func NewScanner(r io.Reader, opts ...ScannerOption) *Scanner {
+	s := &Scanner{r: bufio.NewReader(r), count: unsetSize}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Scanner) HasNext() bool {
@@ -45,12 +91,24 @@ func (s *Scanner) Next() (Token, string, error) {
 	}
 
 	token, lit, err := s.Scan()
-	if err == nil {
+	if err == nil || errors.As(err, &ErrUnknownCommand{}) {
 		s.count--
 	}
 	return token, lit, err
 }
 
+// Drain consumes the remaining elements of the current command so the
+// underlying reader is positioned at the start of the next one.
+func (s *Scanner) Drain() error {
+	for s.count > 0 {
+		if _, _, err := s.Next(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *Scanner) readArray() (int, error) {
 	ch, err := s.read()
 	if err != nil {
@@ -58,18 +116,43 @@ func (s *Scanner) readArray() (int, error) {
 	}
 
 	if ch != rune(SymbolArray) {
-		return -1, ErrNotABulkString
+		if err := s.unread(); err != nil {
+			return -1, err
+		}
+
+		args, n, err := readInline(s.r)
+		if err != nil {
+			return -1, err
+		}
+		if err := s.addQueryLen(n); err != nil {
+			return -1, err
+		}
+		s.inline = args
+		return len(args), nil
 	}
 
 	size, err := s.readSize()
 	if err != nil {
-		return -1, err
+		return -1, ErrInvalidMultiBulkLength
+	}
+
+	switch {
+	case s.limits.Unauthenticated && size > unauthenticatedMaxMultiBulkLen:
+		return -1, ErrUnauthenticatedMultiBulkLength
+	case s.limits.MaxMultiBulkLen > 0 && size > s.limits.MaxMultiBulkLen:
+		return -1, ErrInvalidMultiBulkLength
 	}
 
-	return size, err
+	return int(size), nil
 }
 
 func (s *Scanner) Scan() (Token, string, error) {
+	if s.inline != nil {
+		word := s.inline[0]
+		s.inline = s.inline[1:]
+		return s.token(word)
+	}
+
 	ch, err := s.read()
 	if err != nil {
 		return TokenEOF, string(eof), err
@@ -101,6 +184,10 @@ func (s *Scanner) Scan() (Token, string, error) {
 		return TokenEOF, string(eof), err
 	}
 
+	return s.token(word)
+}
+
+func (s *Scanner) token(word string) (Token, string, error) {
 	// we are scanning the first item which should be the the command
 	if s.size == s.count {
 		return s.getCommandToken(word)
@@ -110,56 +197,55 @@ func (s *Scanner) Scan() (Token, string, error) {
 }
 
 func (s *Scanner) getCommandToken(word string) (Token, string, error) {
-
-	switch strings.ToUpper(word) {
-	case CmdGet:
-		return TokenGet, word, nil
-	case CmdPing:
-		return TokenPing, word, nil
-	case CmdSet:
-		return TokenSet, word, nil
-	case CmdEcho:
-		return TokenEcho, word, nil
-	case CmdExists:
-		return TokenExists, word, nil
-	case CmdDel:
-		return TokenDel, word, nil
-	case CmdPub:
-		return TokenPub, word, nil
-	case CmdSub:
-		return TokenSub, word, nil
-	case CmdUnSub:
-		return TokenUnSub, word, nil
-	default:
+	if _, ok := commands.Lookup(word); !ok {
 		return TokenEOF, word, ErrUnknownCommand{Name: strings.ToUpper(word)}
 	}
+	return TokenCommand, word, nil
 }
 
-func (s *Scanner) readWord() (string, error) {
-	var buf bytes.Buffer
+// bulkChunk is how much of a bulk string is allocated up front, longer ones
+// grow as their bytes arrive so a client can not reserve memory it does not
+// send.
+const bulkChunk = 64 * 1024
 
+func (s *Scanner) readWord() (string, error) {
 	size, err := s.readSize()
 	if err != nil {
-		return "", err
+		return "", ErrInvalidBulkLength
 	}
 
-	for i := 0; i < size; i++ {
-		ch, err := s.read()
-		if err != nil {
-			return "", err
-		}
+	switch {
+	case s.limits.Unauthenticated && size > unauthenticatedMaxBulkLen:
+		return "", ErrUnauthenticatedBulkLength
+	case s.limits.MaxBulkLen > 0 && size > s.limits.MaxBulkLen:
+		return "", ErrInvalidBulkLength
+	}
+	if err := s.addQueryLen(size); err != nil {
+		return "", err
+	}
 
-		_, err = buf.WriteRune(ch)
-		if err != nil {
+	// the size of a bulk string is given in bytes not runes
+	if size <= bulkChunk {
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(s.r, buf); err != nil {
 			return "", err
 		}
+		return string(buf), nil
 	}
 
+	var buf strings.Builder
+	buf.Grow(bulkChunk)
+	if _, err := io.CopyN(&buf, s.r, size); err != nil {
+		return "", err
+	}
 	return buf.String(), nil
 }
 
-func (s *Scanner) readSize() (int, error) {
-	size := 0
+// readSize reads the length of an array or a bulk string, which has to be a
+// non negative integer.
+func (s *Scanner) readSize() (int64, error) {
+	var size int64
+	digits := 0
 
 	for {
 		ch, err := s.read()
@@ -167,17 +253,22 @@ func (s *Scanner) readSize() (int, error) {
 			return -1, err
 		}
 
-		if unicode.IsDigit(ch) {
-			size = size*10 + int(ch-'0')
-		} else if ch == rune(SymbolCR) {
+		if ch == rune(SymbolCR) {
 			if err := s.unread(); err != nil {
 				return -1, err
 			}
 			break
-		} else {
+		}
+
+		if ch < '0' || ch > '9' || size > (math.MaxInt64-9)/10 {
 			return -1, fmt.Errorf("expected digits only when reading array or bulk string size, current char (%c)", ch)
 		}
+		size = size*10 + int64(ch-'0')
+		digits++
+	}
 
+	if digits == 0 {
+		return -1, errors.New("missing array or bulk string size")
 	}
 
 	if err := s.readCRLF(); err != nil {
@@ -187,6 +278,15 @@ func (s *Scanner) readSize() (int, error) {
 	return size, nil
 }
 
+// addQueryLen counts n more bytes towards the query limit of the command.
+func (s *Scanner) addQueryLen(n int64) error {
+	s.queryLen += n
+	if s.limits.MaxQueryLen > 0 && s.queryLen > s.limits.MaxQueryLen {
+		return ErrQueryBufferLimit
+	}
+	return nil
+}
+
 func (s *Scanner) readCRLF() error {
 	ch, err := s.read()
 	if err != nil {
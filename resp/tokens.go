@@ -4,16 +4,7 @@ type Token int
 
 const (
 	TokenEOF Token = iota
-	TokenSet
-	TokenGet
-	TokenEcho
-	TokenPing
-	TokenDel
-	TokenExists
-	TokenEx
-	TokenSub
-	TokenPub
-	TokenUnSub
+	TokenCommand
 	TokenArg
 )
 
@@ -30,13 +21,43 @@ const (
 )
 
 const (
-	CmdSet    = "SET"
-	CmdGet    = "GET"
-	CmdPing   = "PING"
-	CmdEcho   = "ECHO"
-	CmdDel    = "DEL"
-	CmdExists = "EXISTS"
-	CmdSub    = "SUBSCRIBE"
-	CmdPub    = "PUBLISH"
-	CmdUnSub  = "UNSUBSCRIBE"
+	CmdSet      = "SET"
+	CmdGet      = "GET"
+	CmdPing     = "PING"
+	CmdEcho     = "ECHO"
+	CmdDel      = "DEL"
+	CmdExists   = "EXISTS"
+	CmdSub      = "SUBSCRIBE"
+	CmdPub      = "PUBLISH"
+	CmdUnSub    = "UNSUBSCRIBE"
+	CmdMulti    = "MULTI"
+	CmdExec     = "EXEC"
+	CmdDiscard  = "DISCARD"
+	CmdWatch    = "WATCH"
+	CmdUnwatch  = "UNWATCH"
+	CmdEval     = "EVAL"
+	CmdEvalSha  = "EVALSHA"
+	CmdScript   = "SCRIPT"
+	CmdFCall    = "FCALL"
+	CmdFCallRO  = "FCALL_RO"
+	CmdFunction = "FUNCTION"
+	CmdPSub     = "PSUBSCRIBE"
+	CmdPUnSub   = "PUNSUBSCRIBE"
+	CmdQuit     = "QUIT"
+	CmdReset    = "RESET"
+	CmdPubSub   = "PUBSUB"
+	CmdSSub     = "SSUBSCRIBE"
+	CmdSUnSub   = "SUNSUBSCRIBE"
+	CmdSPub     = "SPUBLISH"
+	CmdClient   = "CLIENT"
+	CmdRSub     = "RSUBSCRIBE"
+	CmdAuth     = "AUTH"
+	CmdHello    = "HELLO"
+	CmdACL      = "ACL"
+	CmdConfig   = "CONFIG"
+	CmdInfo     = "INFO"
+	CmdSlowlog  = "SLOWLOG"
+	CmdLatency  = "LATENCY"
+	CmdMonitor  = "MONITOR"
+	CmdCommand  = "COMMAND"
 )
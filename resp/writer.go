@@ -0,0 +1,275 @@
+package resp
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writerBufferSize is how much a Writer buffers before writing to the
+// underlying writer, longer bulk strings are written through in chunks of it.
+const writerBufferSize = 16 * 1024
+
+// Writer encodes replies straight into a buffer it reuses, writing it to the
+// underlying writer as it fills up and on Flush. Replies are encoded as RESP2,
+// so maps are sent as flat arrays of keys and values, floats as bulk strings
+// and booleans as 1 or 0.
+//
+// After an error writing to the underlying writer every method returns it.
+type Writer struct {
+	w   io.Writer
+	buf []byte
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, buf: make([]byte, 0, writerBufferSize)}
+}
+
+// Buffered returns the number of bytes waiting to be flushed.
+func (w *Writer) Buffered() int {
+	return len(w.buf)
+}
+
+// Flush writes the buffered replies to the underlying writer.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.w == nil || len(w.buf) == 0 {
+		return nil
+	}
+
+	if _, err := w.w.Write(w.buf); err != nil {
+		w.err = err
+		return err
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// WriteValue encodes any of the values commands reply with, arrays and maps
+// can be nested. Strings are bulk strings, status replies are sent as simple
+// strings with SimpleString. A value of an unknown type is sent as an error.
+func (w *Writer) WriteValue(v any) error {
+	switch v := v.(type) {
+	case nil:
+		return w.WriteNull()
+	case NullArray:
+		return w.WriteNullArray()
+	case string:
+		return w.WriteBulkString(v)
+	case SimpleString:
+		return w.WriteSimpleString(string(v))
+	case []byte:
+		return w.WriteBulk(v)
+	case error:
+		return w.WriteError(v)
+	case int:
+		return w.WriteInt(int64(v))
+	case int32:
+		return w.WriteInt(int64(v))
+	case int64:
+		return w.WriteInt(v)
+	case float64:
+		return w.WriteFloat(v)
+	case bool:
+		return w.WriteBool(v)
+	case []any:
+		if err := w.WriteArrayHeader(len(v)); err != nil {
+			return err
+		}
+		for _, entry := range v {
+			if err := w.WriteValue(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []string:
+		if err := w.WriteArrayHeader(len(v)); err != nil {
+			return err
+		}
+		for _, entry := range v {
+			if err := w.WriteBulkString(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		if err := w.WriteMapHeader(len(v)); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(v) {
+			if err := w.WriteBulkString(key); err != nil {
+				return err
+			}
+			if err := w.WriteValue(v[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]string:
+		if err := w.WriteMapHeader(len(v)); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(v) {
+			if err := w.WriteBulkString(key); err != nil {
+				return err
+			}
+			if err := w.WriteBulkString(v[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return w.WriteError(fmt.Errorf("unknown response type %T", v))
+	}
+}
+
+func (w *Writer) WriteSimpleString(s string) error {
+	w.buf = append(w.buf, byte(SymbolString))
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, '\r', '\n')
+	return w.flushFull()
+}
+
+func (w *Writer) WriteBulkString(s string) error {
+	w.writeHeader(SymbolBulkString, int64(len(s)))
+
+	// long strings are copied in chunks so the buffer does not grow to
+	// their size
+	for len(s) > 0 && w.w != nil && len(w.buf)+len(s) > writerBufferSize {
+		n := writerBufferSize - len(w.buf)
+		if n <= 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		w.buf = append(w.buf, s[:n]...)
+		s = s[n:]
+	}
+
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, '\r', '\n')
+	return w.flushFull()
+}
+
+func (w *Writer) WriteBulk(b []byte) error {
+	w.writeHeader(SymbolBulkString, int64(len(b)))
+
+	// long values are written as they are rather than copied
+	if w.w != nil && len(b) > writerBufferSize {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if _, err := w.w.Write(b); err != nil {
+			w.err = err
+			return err
+		}
+		b = nil
+	}
+
+	w.buf = append(w.buf, b...)
+	w.buf = append(w.buf, '\r', '\n')
+	return w.flushFull()
+}
+
+// WriteNull writes the null bulk string.
+func (w *Writer) WriteNull() error {
+	w.buf = append(w.buf, "$-1\r\n"...)
+	return w.flushFull()
+}
+
+// WriteNullArray writes the null array.
+func (w *Writer) WriteNullArray() error {
+	w.buf = append(w.buf, "*-1\r\n"...)
+	return w.flushFull()
+}
+
+// WriteError writes err on a single line, newlines would end the error early
+// so they are replaced like redis does.
+func (w *Writer) WriteError(err error) error {
+	msg := strings.TrimSpace(err.Error())
+
+	w.buf = append(w.buf, byte(SymbolError))
+	if strings.ContainsAny(msg, "\r\n") {
+		msg = errorReplacer.Replace(msg)
+	}
+	w.buf = append(w.buf, msg...)
+	w.buf = append(w.buf, '\r', '\n')
+	return w.flushFull()
+}
+
+func (w *Writer) WriteInt(n int64) error {
+	w.writeHeader(SymbolInt, n)
+	return w.flushFull()
+}
+
+// WriteFloat writes f as a bulk string with the shortest representation that
+// reads back as f, infinities are inf and -inf like in redis.
+func (w *Writer) WriteFloat(f float64) error {
+	var scratch [32]byte
+	var b []byte
+	switch {
+	case math.IsInf(f, 1):
+		b = append(scratch[:0], "inf"...)
+	case math.IsInf(f, -1):
+		b = append(scratch[:0], "-inf"...)
+	default:
+		b = strconv.AppendFloat(scratch[:0], f, 'g', -1, 64)
+	}
+	return w.WriteBulk(b)
+}
+
+func (w *Writer) WriteBool(b bool) error {
+	if b {
+		return w.WriteInt(1)
+	}
+	return w.WriteInt(0)
+}
+
+// WriteArrayHeader starts an array of n elements, which have to be written
+// next.
+func (w *Writer) WriteArrayHeader(n int) error {
+	w.writeHeader(SymbolArray, int64(n))
+	return w.flushFull()
+}
+
+// WriteMapHeader starts a map of n keys, which have to be written next each
+// followed by its value.
+func (w *Writer) WriteMapHeader(n int) error {
+	return w.WriteArrayHeader(2 * n)
+}
+
+// writeHeader appends a type symbol followed by a number, the header of
+// arrays and bulk strings and the whole of an integer.
+func (w *Writer) writeHeader(symbol Symbol, n int64) {
+	w.buf = append(w.buf, byte(symbol))
+	w.buf = strconv.AppendInt(w.buf, n, 10)
+	w.buf = append(w.buf, '\r', '\n')
+}
+
+// flushFull flushes the buffer once it is full, or returns the error of an
+// earlier write.
+func (w *Writer) flushFull() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.w == nil || len(w.buf) < writerBufferSize {
+		return nil
+	}
+	return w.Flush()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,113 @@
+package resp_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterBuffersUntilFlush(t *testing.T) {
+	var out bytes.Buffer
+	w := resp.NewWriter(&out)
+
+	require.NoError(t, w.WriteArrayHeader(2))
+	require.NoError(t, w.WriteBulkString("foo"))
+	require.NoError(t, w.WriteInt(42))
+	assert.Zero(t, out.Len())
+	assert.Equal(t, 18, w.Buffered())
+
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "*2\r\n$3\r\nfoo\r\n:42\r\n", out.String())
+	assert.Zero(t, w.Buffered())
+}
+
+func TestWriterLongValues(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+
+	testCases := []struct {
+		desc  string
+		write func(w *resp.Writer) error
+	}{
+		{
+			desc:  "bulk string",
+			write: func(w *resp.Writer) error { return w.WriteBulkString(long) },
+		},
+		{
+			desc:  "bytes",
+			write: func(w *resp.Writer) error { return w.WriteBulk([]byte(long)) },
+		},
+		{
+			desc:  "array",
+			write: func(w *resp.Writer) error { return w.WriteValue([]string{long}) },
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			var out bytes.Buffer
+			w := resp.NewWriter(&out)
+
+			require.NoError(t, tc.write(w))
+			require.NoError(t, w.Flush())
+
+			expected := resp.EncodeBulkString(long)
+			assert.True(t, bytes.HasSuffix(out.Bytes(), expected))
+		})
+	}
+}
+
+func TestWriterFloats(t *testing.T) {
+	testCases := []struct {
+		value    float64
+		expected string
+	}{
+		{value: 3, expected: "$1\r\n3\r\n"},
+		{value: 0.1, expected: "$3\r\n0.1\r\n"},
+		{value: math.Inf(1), expected: "$3\r\ninf\r\n"},
+		{value: math.Inf(-1), expected: "$4\r\n-inf\r\n"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, string(resp.Encode(tc.value)))
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestWriterKeepsError(t *testing.T) {
+	w := resp.NewWriter(failingWriter{})
+
+	require.NoError(t, w.WriteSimpleString("OK"))
+	assert.EqualError(t, w.Flush(), "broken pipe")
+	assert.EqualError(t, w.WriteInt(1), "broken pipe")
+}
+
+func TestWriterAllocations(t *testing.T) {
+	values := make([]any, 100)
+	for i := range values {
+		values[i] = strings.Repeat("v", i)
+	}
+	var reply any = values
+
+	var out bytes.Buffer
+	w := resp.NewWriter(&out)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		out.Reset()
+		_ = w.WriteValue(reply)
+		_ = w.WriteInt(math.MaxInt64)
+		_ = w.Flush()
+	})
+	assert.Zero(t, allocs)
+}
@@ -0,0 +1,301 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aelnahas/sider/acl"
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/db"
+	"github.com/aelnahas/sider/resp"
+)
+
+// Contexts a command can be denied in, as reported in the ACL log.
+const (
+	aclContextTopLevel = "toplevel"
+	aclContextMulti    = "multi"
+	aclContextLua      = "lua"
+)
+
+// checkAccess makes sure the session is authenticated and its user is allowed
+// to run cmd.
+func (c *Connection) checkAccess(sess *session, cmd *resp.RawCommand) error {
+	if commands.HasFlag(cmd.Name, commands.FlagNoAuth) {
+		return nil
+	}
+
+	if !sess.authenticated {
+		return acl.ErrNoAuth
+	}
+
+	where := aclContextTopLevel
+	if sess.inMulti() && cmd.Name != resp.CmdExec && cmd.Name != resp.CmdDiscard {
+		where = aclContextMulti
+	}
+
+	return c.authorize(sess, cmd.Name, cmd.Args, where)
+}
+
+// authorize checks the ACL rules of the session user, denied commands are
+// added to the ACL log.
+func (c *Connection) authorize(sess *session, name string, args []string, where string) error {
+	err := c.acl.Check(sess.username(), aclRequest(name, args))
+
+	var denied *acl.Denied
+	if errors.As(err, &denied) {
+		c.acl.Log.Add(acl.LogEntry{
+			Reason:     denied.Reason,
+			Context:    where,
+			Object:     denied.Object,
+			Username:   denied.Username,
+			ClientInfo: c.clientInfo(sess),
+		})
+	}
+
+	return err
+}
+
+// scriptAuthorizer checks the commands scripts run against the ACL rules of
+// the client running the script.
+type scriptAuthorizer struct {
+	c *Connection
+}
+
+func (a scriptAuthorizer) Authorize(ctx context.Context, name string, args []string) error {
+	sess, ok := a.c.clients.get(clientIDFrom(ctx))
+	if !ok {
+		return nil
+	}
+
+	return a.c.authorize(sess, name, args, aclContextLua)
+}
+
+// aclRequest describes the command, keys and channels cmd accesses.
+func aclRequest(name string, args []string) acl.Request {
+	req := acl.Request{
+		Command:   strings.ToLower(name),
+		Keys:      db.CommandKeys(name, args),
+		KeyAccess: keyAccess(name),
+	}
+
+	if commands.IsContainer(name) && len(args) > 0 {
+		req.Subcommand = strings.ToLower(args[0])
+	}
+
+	switch name {
+	case resp.CmdPub, resp.CmdSPub:
+		req.Channels = args[:1]
+	case resp.CmdSub, resp.CmdSSub:
+		req.Channels = args
+	case resp.CmdRSub:
+		for i := 0; i < len(args); i += 2 {
+			req.Channels = append(req.Channels, args[i])
+		}
+	case resp.CmdPSub:
+		req.Patterns = args
+	}
+
+	return req
+}
+
+func keyAccess(name string) acl.Access {
+	switch {
+	case db.IsWrite(name):
+		return acl.AccessWrite
+	case db.IsReadOnly(name) || name == resp.CmdWatch:
+		return acl.AccessRead
+	default:
+		return acl.AccessReadWrite
+	}
+}
+
+// resetAuth authenticates the session as the default user when it does not
+// need a password, and leaves it unauthenticated otherwise.
+func (c *Connection) resetAuth(sess *session) {
+	if c.acl.DefaultNoPass() {
+		sess.login(acl.DefaultUser)
+		return
+	}
+	sess.logout()
+}
+
+// auth implements AUTH [username] password, without a username the default
+// user is assumed.
+func (c *Connection) auth(sess *session, args []string) (any, error) {
+	if len(args) > 2 {
+		return nil, ErrSyntax
+	}
+
+	username, password := acl.DefaultUser, args[0]
+	if len(args) == 2 {
+		username, password = args[0], args[1]
+	} else if c.acl.DefaultNoPass() {
+		return nil, acl.ErrDefaultNoPassSet
+	}
+
+	if !c.acl.Authenticate(username, password) {
+		c.acl.Log.Add(acl.LogEntry{
+			Reason:     acl.ReasonAuth,
+			Context:    aclContextTopLevel,
+			Object:     "AUTH",
+			Username:   username,
+			ClientInfo: c.clientInfo(sess),
+		})
+		return nil, acl.ErrWrongPass
+	}
+
+	sess.login(username)
+	return resp.OK, nil
+}
+
+// aclCommand implements the ACL subcommands.
+func (c *Connection) aclCommand(sess *session, args []string) (any, error) {
+	subcommand := args[0]
+	args = args[1:]
+
+	switch strings.ToUpper(subcommand) {
+	case "SETUSER":
+		if len(args) == 0 {
+			return nil, errWrongArgs("acl|setuser")
+		}
+		if err := c.acl.SetUser(args[0], args[1:]); err != nil {
+			return nil, err
+		}
+		return resp.OK, nil
+	case "GETUSER":
+		if len(args) != 1 {
+			return nil, errWrongArgs("acl|getuser")
+		}
+		return c.aclGetUser(args[0]), nil
+	case "DELUSER":
+		if len(args) == 0 {
+			return nil, errWrongArgs("acl|deluser")
+		}
+
+		count, err := c.acl.DelUser(args)
+		if err != nil {
+			return nil, err
+		}
+
+		c.disconnectUsers(sess, func(user string) bool {
+			return !c.acl.Exists(user)
+		})
+		return count, nil
+	case "LIST":
+		return stringList(c.acl.List()), nil
+	case "USERS":
+		return stringList(c.acl.Users()), nil
+	case "WHOAMI":
+		return sess.username(), nil
+	case "CAT":
+		if len(args) == 0 {
+			return stringList(acl.Categories), nil
+		}
+
+		commands, ok := acl.CategoryCommands(strings.ToLower(args[0]))
+		if !ok {
+			return nil, fmt.Errorf("ERR Unknown category '%s'", args[0])
+		}
+		return stringList(commands), nil
+	case "LOG":
+		return c.aclLog(args)
+	case "SAVE":
+		if err := c.acl.Save(); err != nil {
+			return nil, err
+		}
+		return resp.OK, nil
+	case "LOAD":
+		if err := c.acl.Load(); err != nil {
+			return nil, err
+		}
+
+		c.disconnectUsers(sess, func(user string) bool {
+			return !c.acl.Exists(user)
+		})
+		return resp.OK, nil
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try ACL HELP.", subcommand)
+	}
+}
+
+func (c *Connection) aclGetUser(username string) any {
+	info, ok := c.acl.GetUser(username)
+	if !ok {
+		return nil
+	}
+
+	selectors := make([]any, 0, len(info.Selectors))
+	for _, s := range info.Selectors {
+		selectors = append(selectors, []any{"commands", s.Commands, "keys", s.Keys, "channels", s.Channels})
+	}
+
+	return []any{
+		"flags", stringList(info.Flags),
+		"passwords", stringList(info.Passwords),
+		"commands", info.Commands,
+		"keys", info.Keys,
+		"channels", info.Channels,
+		"selectors", selectors,
+	}
+}
+
+// aclLog implements ACL LOG [count | RESET].
+func (c *Connection) aclLog(args []string) (any, error) {
+	if len(args) > 1 {
+		return nil, errWrongArgs("acl|log")
+	}
+
+	count := -1
+	if len(args) == 1 {
+		if strings.EqualFold(args[0], "RESET") {
+			c.acl.Log.Reset()
+			return resp.OK, nil
+		}
+
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return nil, ErrNotInteger
+		}
+		count = n
+	}
+
+	now := time.Now()
+	entries := c.acl.Log.Entries(count)
+	res := make([]any, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, []any{
+			"count", e.Count,
+			"reason", e.Reason,
+			"context", e.Context,
+			"object", e.Object,
+			"username", e.Username,
+			"age-seconds", strconv.FormatFloat(now.Sub(e.Created).Seconds(), 'f', 3, 64),
+			"client-info", e.ClientInfo,
+			"entry-id", e.ID,
+			"timestamp-created", int(e.Created.UnixMilli()),
+			"timestamp-last-updated", int(e.Updated.UnixMilli()),
+		})
+	}
+
+	return res, nil
+}
+
+// disconnectUsers closes the connections authenticated as a user matching
+// gone, the calling session is closed once it got its reply.
+func (c *Connection) disconnectUsers(caller *session, gone func(user string) bool) {
+	c.killClients(caller, func(sess *session) bool {
+		return gone(sess.username())
+	}, false)
+}
+
+func stringList(items []string) []any {
+	res := make([]any, 0, len(items))
+	for _, item := range items {
+		res = append(res, item)
+	}
+	return res
+}
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/resp"
+)
+
+var (
+	ErrSyntax             = errors.New("ERR syntax error")
+	ErrNotInteger         = errors.New("ERR value is not an integer or out of range")
+	ErrNoRedirectClient   = errors.New("ERR The client ID you want redirect to does not exist")
+	ErrPrefixWithoutBCast = errors.New("ERR PREFIX option requires BCAST mode to be enabled")
+	ErrOptInAndOptOut     = errors.New("ERR You can't use both OPTIN and OPTOUT")
+	ErrOptInWithBCast     = errors.New("ERR OPTIN and OPTOUT are not compatible with BCAST")
+	ErrCachingNotOptInOut = errors.New("ERR CLIENT CACHING can be called only when the client is in tracking mode with OPTIN or OPTOUT mode enabled")
+	ErrCachingYesNotOptIn = errors.New("ERR CLIENT CACHING YES is only valid when tracking is enabled in OPTIN mode.")
+	ErrCachingNoNotOptOut = errors.New("ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.")
+	ErrNoSuchClient       = errors.New("ERR No such client")
+	ErrInvalidClientName  = errors.New("ERR Client names cannot contain spaces, newlines or special characters.")
+	ErrPauseTimeout       = errors.New("ERR timeout is not an integer or out of range")
+)
+
+// client implements the CLIENT subcommands.
+func (c *Connection) client(sess *session, cmd *resp.RawCommand) (any, error) {
+	subcommand := cmd.Args[0]
+	args := cmd.Args[1:]
+
+	switch strings.ToUpper(subcommand) {
+	case "ID":
+		if len(args) != 0 {
+			return nil, errWrongArgs("client|id")
+		}
+		return int(sess.clientID), nil
+	case "TRACKING":
+		return c.clientTracking(sess, cmd.Parsed)
+	case "CACHING":
+		return c.clientCaching(sess, args)
+	case "GETREDIR":
+		if len(args) != 0 {
+			return nil, errWrongArgs("client|getredir")
+		}
+
+		opts, ok := c.tracker.options(sess.clientID)
+		if !ok {
+			return -1, nil
+		}
+		return int(opts.redirect), nil
+	case "LIST":
+		return c.clientList(cmd.Parsed)
+	case "INFO":
+		if len(args) != 0 {
+			return nil, errWrongArgs("client|info")
+		}
+		return c.clientInfo(sess) + "\n", nil
+	case "KILL":
+		return c.clientKill(sess, cmd.Parsed)
+	case "SETNAME":
+		if len(args) != 1 {
+			return nil, errWrongArgs("client|setname")
+		}
+		for i := 0; i < len(args[0]); i++ {
+			if args[0][i] < '!' || args[0][i] > '~' {
+				return nil, ErrInvalidClientName
+			}
+		}
+		sess.setName(args[0])
+		return resp.OK, nil
+	case "GETNAME":
+		if len(args) != 0 {
+			return nil, errWrongArgs("client|getname")
+		}
+		if name := sess.getName(); name != "" {
+			return name, nil
+		}
+		return nil, nil
+	case "PAUSE":
+		return c.clientPause(cmd.Parsed)
+	case "UNPAUSE":
+		if len(args) != 0 {
+			return nil, errWrongArgs("client|unpause")
+		}
+		c.pause.unpause()
+		return resp.OK, nil
+	case "NO-EVICT":
+		if len(args) != 1 {
+			return nil, errWrongArgs("client|no-evict")
+		}
+		switch strings.ToUpper(args[0]) {
+		case "ON":
+			sess.setNoEvict(true)
+		case "OFF":
+			sess.setNoEvict(false)
+		default:
+			return nil, ErrSyntax
+		}
+		return resp.OK, nil
+	case "REPLY":
+		return c.clientReply(sess, args)
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try CLIENT HELP.", subcommand)
+	}
+}
+
+// clientTracking implements CLIENT TRACKING ON|OFF [REDIRECT id]
+// [PREFIX prefix ...] [BCAST] [OPTIN] [OPTOUT] [NOLOOP].
+func (c *Connection) clientTracking(sess *session, args *commands.Args) (any, error) {
+	opts := trackingOptions{
+		prefixes: args.Strings("prefix"),
+		bcast:    args.Has("bcast"),
+		optIn:    args.Has("optin"),
+		optOut:   args.Has("optout"),
+		noLoop:   args.Has("noloop"),
+	}
+
+	if args.Has("client-id") {
+		id := args.Int("client-id")
+		if _, ok := c.clients.get(id); !ok {
+			return nil, ErrNoRedirectClient
+		}
+		opts.redirect = id
+	}
+
+	switch args.String("status") {
+	case "on":
+		if len(opts.prefixes) > 0 && !opts.bcast {
+			return nil, ErrPrefixWithoutBCast
+		}
+		if opts.optIn && opts.optOut {
+			return nil, ErrOptInAndOptOut
+		}
+		if opts.bcast && (opts.optIn || opts.optOut) {
+			return nil, ErrOptInWithBCast
+		}
+		if err := checkPrefixes(nil, opts.prefixes); err != nil {
+			return nil, err
+		}
+		if err := c.tracker.enable(sess.clientID, opts); err != nil {
+			return nil, err
+		}
+	case "off":
+		c.tracker.disable(sess.clientID)
+	}
+
+	return resp.OK, nil
+}
+
+// clientCaching implements CLIENT CACHING YES|NO, which only applies to the
+// command that follows it.
+func (c *Connection) clientCaching(sess *session, args []string) (any, error) {
+	if len(args) != 1 {
+		return nil, errWrongArgs("client|caching")
+	}
+
+	opts, ok := c.tracker.options(sess.clientID)
+	if !ok || (!opts.optIn && !opts.optOut) {
+		return nil, ErrCachingNotOptInOut
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "YES":
+		if !opts.optIn {
+			return nil, ErrCachingYesNotOptIn
+		}
+		sess.caching = cachingYes
+	case "NO":
+		if !opts.optOut {
+			return nil, ErrCachingNoNotOptOut
+		}
+		sess.caching = cachingNo
+	default:
+		return nil, ErrSyntax
+	}
+
+	return resp.OK, nil
+}
+
+// clientList implements CLIENT LIST [TYPE type] [ID id ...].
+func (c *Connection) clientList(args *commands.Args) (any, error) {
+	var typ string
+	if args.Has("client-type") {
+		var err error
+		if typ, err = parseClientType(args.String("client-type")); err != nil {
+			return nil, err
+		}
+	}
+
+	var ids map[int64]bool
+	if args.Has("client-id") {
+		ids = make(map[int64]bool)
+		for _, arg := range args.Strings("client-id") {
+			id, _ := strconv.ParseInt(arg, 10, 64)
+			if id <= 0 {
+				return nil, fmt.Errorf("ERR Invalid client ID")
+			}
+			ids[id] = true
+		}
+	}
+
+	var sb strings.Builder
+	for _, sess := range c.clients.list() {
+		if typ != "" && clientType(sess) != typ || ids != nil && !ids[sess.clientID] {
+			continue
+		}
+		sb.WriteString(c.clientInfo(sess))
+		sb.WriteByte('\n')
+	}
+
+	return sb.String(), nil
+}
+
+func parseClientType(typ string) (string, error) {
+	switch strings.ToLower(typ) {
+	case clientTypeNormal:
+		return clientTypeNormal, nil
+	case clientTypePubSub:
+		return clientTypePubSub, nil
+	case clientTypeMaster:
+		return clientTypeMaster, nil
+	case clientTypeReplica, "slave":
+		return clientTypeReplica, nil
+	default:
+		return "", fmt.Errorf("ERR Unknown client type '%s'", typ)
+	}
+}
+
+// clientKill implements both CLIENT KILL addr and CLIENT KILL with filters:
+// ID, ADDR, LADDR, USER, TYPE, MAXAGE and SKIPME.
+func (c *Connection) clientKill(sess *session, args *commands.Args) (any, error) {
+	if args.String("filter") == "old-format" {
+		addr := args.String("old-format")
+		killed := c.killClients(sess, func(other *session) bool {
+			return other.id == addr
+		}, false)
+		if killed == 0 {
+			return nil, ErrNoSuchClient
+		}
+		return resp.OK, nil
+	}
+
+	var filters []func(*session) bool
+	for _, arg := range args.Strings("client-id") {
+		id, _ := strconv.ParseInt(arg, 10, 64)
+		if id <= 0 {
+			return nil, errors.New("ERR client-id should be greater than 0")
+		}
+		filters = append(filters, func(other *session) bool {
+			return other.clientID == id
+		})
+	}
+	for _, addr := range args.Strings("addr") {
+		addr := addr
+		filters = append(filters, func(other *session) bool {
+			return other.id == addr
+		})
+	}
+	for _, laddr := range args.Strings("laddr") {
+		laddr := laddr
+		filters = append(filters, func(other *session) bool {
+			return other.conn.LocalAddr().String() == laddr
+		})
+	}
+	for _, user := range args.Strings("username") {
+		user := user
+		if !c.acl.Exists(user) {
+			return nil, fmt.Errorf("ERR No such user '%s'", user)
+		}
+		filters = append(filters, func(other *session) bool {
+			return other.username() == user
+		})
+	}
+	for _, arg := range args.Strings("client-type") {
+		typ, err := parseClientType(arg)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, func(other *session) bool {
+			return clientType(other) == typ
+		})
+	}
+	for _, arg := range args.Strings("maxage") {
+		seconds, _ := strconv.ParseInt(arg, 10, 64)
+		filters = append(filters, func(other *session) bool {
+			return time.Since(other.created) > time.Duration(seconds)*time.Second
+		})
+	}
+
+	// the last SKIPME given wins
+	skipMe := true
+	if choices := args.Strings("skipme"); len(choices) > 0 {
+		skipMe = choices[len(choices)-1] == "yes"
+	}
+
+	return c.killClients(sess, func(other *session) bool {
+		for _, match := range filters {
+			if !match(other) {
+				return false
+			}
+		}
+		return true
+	}, skipMe), nil
+}
+
+// clientPause implements CLIENT PAUSE timeout [WRITE|ALL].
+func (c *Connection) clientPause(args *commands.Args) (any, error) {
+	ms := args.Int("timeout")
+	if ms < 0 {
+		return nil, ErrPauseTimeout
+	}
+	all := args.String("mode") != "write"
+
+	c.pause.set(time.Now().Add(time.Duration(ms)*time.Millisecond), all)
+	return resp.OK, nil
+}
+
+// clientReply implements CLIENT REPLY ON|OFF|SKIP. OFF drops every reply
+// until turned back ON, SKIP only drops the reply to the next command.
+func (c *Connection) clientReply(sess *session, args []string) (any, error) {
+	if len(args) != 1 {
+		return nil, errWrongArgs("client|reply")
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "ON":
+		sess.replyOff = false
+		sess.skipReplies = 0
+	case "OFF":
+		sess.replyOff = true
+	case "SKIP":
+		// the reply to CLIENT REPLY SKIP itself is dropped too
+		sess.skipReplies = 2
+	default:
+		return nil, ErrSyntax
+	}
+
+	return resp.OK, nil
+}
@@ -0,0 +1,236 @@
+package server_test
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientPause(t *testing.T) {
+	socket := startServer(t)
+	admin := dial(t, socket)
+	require.Equal(t, "OK", admin.do("SET", "key", "value"))
+
+	t.Run("write", func(t *testing.T) {
+		c := dial(t, socket)
+		require.Equal(t, "OK", admin.do("CLIENT", "PAUSE", "60000", "WRITE"))
+
+		assert.Equal(t, "value", c.do("GET", "key"))
+		c.send("SET", "key", "paused")
+		c.noReply(100 * time.Millisecond)
+
+		require.Equal(t, "OK", admin.do("CLIENT", "UNPAUSE"))
+		assert.Equal(t, "OK", c.read())
+	})
+
+	t.Run("all", func(t *testing.T) {
+		c := dial(t, socket)
+		require.Equal(t, "OK", admin.do("CLIENT", "PAUSE", "60000"))
+
+		c.send("GET", "key")
+		c.noReply(100 * time.Millisecond)
+
+		require.Equal(t, "OK", admin.do("CLIENT", "UNPAUSE"))
+		assert.Equal(t, "paused", c.read())
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		reads, writes := dial(t, socket), dial(t, socket)
+		for _, c := range []*conn{reads, writes} {
+			assert.Equal(t, "OK", c.do("MULTI"))
+		}
+		assert.Equal(t, "QUEUED", reads.do("GET", "key"))
+		assert.Equal(t, "QUEUED", writes.do("SET", "key", "exec"))
+
+		// the commands are queued while paused, EXEC waits when one of
+		// them writes
+		require.Equal(t, "OK", admin.do("CLIENT", "PAUSE", "60000", "WRITE"))
+		assert.Equal(t, "QUEUED", writes.do("GET", "key"))
+		assert.Equal(t, []any{"paused"}, reads.do("EXEC"))
+		writes.send("EXEC")
+		writes.noReply(100 * time.Millisecond)
+
+		require.Equal(t, "OK", admin.do("CLIENT", "UNPAUSE"))
+		assert.Equal(t, []any{"OK", "exec"}, writes.read())
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		c := dial(t, socket)
+		require.Equal(t, "OK", admin.do("CLIENT", "PAUSE", "100"))
+
+		start := time.Now()
+		assert.Equal(t, "exec", c.do("GET", "key"))
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+}
+
+func TestClientKill(t *testing.T) {
+	// target describes the first of the two connections the filters are
+	// run against
+	type target struct {
+		id   string
+		addr string
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, c *conn)
+		filters func(socket string, a target) []string
+		reply   any
+		// closed tells which of the two connections are closed
+		closed [2]bool
+	}{
+		{
+			name:    "id",
+			filters: func(_ string, a target) []string { return []string{"ID", a.id} },
+			reply:   int64(1),
+			closed:  [2]bool{true, false},
+		},
+		{
+			name:    "addr",
+			filters: func(_ string, a target) []string { return []string{"ADDR", a.addr} },
+			reply:   int64(1),
+			closed:  [2]bool{true, false},
+		},
+		{
+			name:    "old format",
+			filters: func(_ string, a target) []string { return []string{a.addr} },
+			reply:   "OK",
+			closed:  [2]bool{true, false},
+		},
+		{
+			name:    "laddr",
+			filters: func(socket string, _ target) []string { return []string{"LADDR", socket} },
+			reply:   int64(2),
+			closed:  [2]bool{true, true},
+		},
+		{
+			name: "type",
+			setup: func(t *testing.T, c *conn) {
+				assert.Equal(t, []any{"subscribe", "news", int64(1)}, c.do("SUBSCRIBE", "news"))
+			},
+			filters: func(_ string, _ target) []string { return []string{"TYPE", "pubsub"} },
+			reply:   int64(1),
+			closed:  [2]bool{true, false},
+		},
+		{
+			name: "user",
+			setup: func(t *testing.T, c *conn) {
+				assert.Equal(t, "OK", c.do("ACL", "SETUSER", "alice", "on", "nopass", "+@all"))
+				assert.Equal(t, "OK", c.do("AUTH", "alice", "any"))
+			},
+			filters: func(_ string, _ target) []string { return []string{"USER", "alice"} },
+			reply:   int64(1),
+			closed:  [2]bool{true, false},
+		},
+		{
+			name:    "maxage",
+			filters: func(_ string, _ target) []string { return []string{"MAXAGE", "60"} },
+			reply:   int64(0),
+		},
+		{
+			name:    "filters are and-ed",
+			filters: func(_ string, a target) []string { return []string{"ID", a.id, "TYPE", "pubsub"} },
+			reply:   int64(0),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			socket := startServer(t)
+			conns := []*conn{dial(t, socket), dial(t, socket)}
+			// the server knows of a connection once it replied to it
+			assert.Equal(t, "PONG", conns[1].do("PING"))
+
+			id := conns[0].do("CLIENT", "ID").(int64)
+			a := target{id: strconv.FormatInt(id, 10), addr: fmt.Sprintf("%s:%d", socket, id)}
+			if tc.setup != nil {
+				tc.setup(t, conns[0])
+			}
+
+			caller := dial(t, socket)
+			assert.Equal(t, tc.reply, caller.do(append([]string{"CLIENT", "KILL"}, tc.filters(socket, a)...)...))
+
+			for i, c := range conns {
+				if tc.closed[i] {
+					c.closed()
+				} else {
+					assert.Equal(t, "PONG", c.do("PING"))
+				}
+			}
+
+			// the caller is skipped by default
+			assert.Equal(t, "PONG", caller.do("PING"))
+		})
+	}
+
+	t.Run("skipme", func(t *testing.T) {
+		socket := startServer(t)
+		caller, other := dial(t, socket), dial(t, socket)
+		assert.Equal(t, "PONG", other.do("PING"))
+
+		assert.Equal(t, int64(2), caller.do("CLIENT", "KILL", "LADDR", socket, "SKIPME", "no"))
+		caller.closed()
+		other.closed()
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		c := dial(t, startServer(t))
+		assert.Equal(t, resp.ReplyError("ERR No such user 'nobody'"), c.do("CLIENT", "KILL", "USER", "nobody"))
+	})
+}
+
+func TestClientReply(t *testing.T) {
+	c := dial(t, startServer(t))
+
+	// SKIP drops its own reply and the one of the next command
+	c.send("CLIENT", "REPLY", "SKIP")
+	c.send("SET", "key", "1")
+	assert.Equal(t, "1", c.do("GET", "key"))
+
+	// OFF drops every reply, errors included, until ON
+	c.send("CLIENT", "REPLY", "OFF")
+	c.send("SET", "key", "2")
+	c.send("NOPE")
+	c.send("GET", "key")
+	c.noReply(100 * time.Millisecond)
+	assert.Equal(t, "OK", c.do("CLIENT", "REPLY", "ON"))
+	assert.Equal(t, "2", c.do("GET", "key"))
+}
+
+func TestClientListAndInfo(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+
+	require.Equal(t, "OK", c.do("CLIENT", "SETNAME", "tests"))
+	id := c.do("CLIENT", "ID")
+	require.IsType(t, int64(0), id)
+	assert.Equal(t, "tests", c.do("CLIENT", "GETNAME"))
+
+	// cmd is the last command the connection finished
+	assert.Regexp(t, fmt.Sprintf(
+		`^id=%d addr=%s:%[1]d laddr=%[2]s name=tests age=\d+ idle=\d+ flags=U db=0 sub=0 psub=0 ssub=0 multi=-1 watch=0 qbuf=\d+ oll=0 omem=0 cmd=client\|getname user=default redir=-1 resp=2\n$`,
+		id, regexp.QuoteMeta(socket),
+	), c.do("CLIENT", "INFO"))
+
+	sub := dial(t, socket)
+	assert.Equal(t, []any{"subscribe", "news", int64(1)}, sub.do("SUBSCRIBE", "news"))
+	assert.Equal(t, []any{"psubscribe", "sport.*", int64(2)}, sub.do("PSUBSCRIBE", "sport.*"))
+
+	assert.Regexp(t, `^id=\d+ .* flags=PU .* sub=1 psub=1 ssub=0 .* cmd=psubscribe .*\n$`,
+		c.do("CLIENT", "LIST", "TYPE", "pubsub"))
+
+	list := c.do("CLIENT", "LIST", "ID", strconv.FormatInt(id.(int64), 10))
+	require.IsType(t, "", list)
+	assert.Equal(t, 1, strings.Count(list.(string), "\n"))
+	assert.True(t, strings.HasPrefix(list.(string), fmt.Sprintf("id=%d addr=%s:%[1]d ", id, socket)), list)
+	assert.Contains(t, list, " name=tests ")
+}
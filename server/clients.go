@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aelnahas/sider/commands"
+)
+
+// Client types CLIENT LIST and CLIENT KILL filter on, there are no master or
+// replica connections but the types are accepted.
+const (
+	clientTypeNormal  = "normal"
+	clientTypePubSub  = "pubsub"
+	clientTypeMaster  = "master"
+	clientTypeReplica = "replica"
+)
+
+// clients keeps every connected session by its client id.
+type clients struct {
+	sync.RWMutex
+	lastID   atomic.Int64
+	sessions map[int64]*session
+}
+
+func newClients() *clients {
+	return &clients{sessions: make(map[int64]*session)}
+}
+
+// nextID returns the client id of a new connection.
+func (c *clients) nextID() int64 {
+	return c.lastID.Add(1)
+}
+
+// register adds the session to the registry, by its client id.
+func (c *clients) register(sess *session) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.sessions[sess.clientID] = sess
+}
+
+func (c *clients) unregister(sess *session) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.sessions, sess.clientID)
+}
+
+func (c *clients) get(id int64) (*session, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	sess, ok := c.sessions[id]
+	return sess, ok
+}
+
+func (c *clients) len() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	return len(c.sessions)
+}
+
+// list returns the registered sessions ordered by client id.
+func (c *clients) list() []*session {
+	c.RLock()
+	sessions := make([]*session, 0, len(c.sessions))
+	for _, sess := range c.sessions {
+		sessions = append(sessions, sess)
+	}
+	c.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].clientID < sessions[j].clientID
+	})
+	return sessions
+}
+
+// commandName is how a command shows in CLIENT LIST, lower case and with its
+// subcommand if it has one.
+func commandName(name string, args []string) string {
+	if commands.IsContainer(name) && len(args) > 0 {
+		return strings.ToLower(name + "|" + args[0])
+	}
+	return strings.ToLower(name)
+}
+
+// clientType returns the type of the connection, normal or pubsub.
+func clientType(sess *session) string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.activity.subscriber {
+		return clientTypePubSub
+	}
+	return clientTypeNormal
+}
+
+// clientInfo describes the connection the way CLIENT LIST and CLIENT INFO do.
+func (c *Connection) clientInfo(sess *session) string {
+	sess.mu.Lock()
+	name, user, noEvict, act := sess.name, sess.user, sess.noEvict, sess.activity
+	sess.mu.Unlock()
+
+	var flags strings.Builder
+	if act.subscriber {
+		flags.WriteByte('P')
+	}
+	if act.monitor {
+		flags.WriteByte('O')
+	}
+	if act.multi >= 0 {
+		flags.WriteByte('x')
+	}
+
+	redir := int64(-1)
+	if opts, ok := c.tracker.options(sess.clientID); ok {
+		flags.WriteByte('t')
+		if opts.bcast {
+			flags.WriteByte('B')
+		}
+		if opts.redirect != 0 {
+			redir = opts.redirect
+		}
+	}
+
+	if sess.conn.RemoteAddr().Network() == "unix" {
+		flags.WriteByte('U')
+	}
+	if noEvict {
+		flags.WriteByte('e')
+	}
+	if flags.Len() == 0 {
+		flags.WriteByte('N')
+	}
+
+	sub, _ := c.broker.Info(sess.id)
+
+	now := time.Now()
+	return fmt.Sprintf(
+		"id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=0 sub=%d psub=%d ssub=%d multi=%d watch=%d qbuf=%d oll=%d omem=%d cmd=%s user=%s redir=%d resp=2",
+		sess.clientID, sess.id, sess.conn.LocalAddr(), name,
+		int64(now.Sub(sess.created).Seconds()), int64(now.Sub(act.at).Seconds()),
+		flags.String(), sub.Channels, sub.Patterns, sub.ShardChannels,
+		act.multi, act.watch, act.qbuf, sub.OutputMessages, sub.OutputBuffer,
+		act.cmd, user, redir,
+	)
+}
+
+// killClients closes the connections matching match and returns how many
+// there were. The caller is closed once it got its reply, unless skipMe is
+// set in which case it is left alone.
+func (c *Connection) killClients(caller *session, match func(*session) bool, skipMe bool) int {
+	killed := 0
+	for _, sess := range c.clients.list() {
+		if !match(sess) {
+			continue
+		}
+
+		if sess == caller {
+			if skipMe {
+				continue
+			}
+			sess.closeAfterReply = true
+		} else {
+			sess.conn.Close()
+		}
+		killed++
+	}
+	return killed
+}
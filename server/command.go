@@ -0,0 +1,291 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/glob"
+)
+
+var (
+	ErrInvalidCommand      = errors.New("ERR Invalid command specified")
+	ErrInvalidCommandArity = errors.New("ERR Invalid number of arguments specified for command")
+	ErrInvalidCommandArgs  = errors.New("ERR Invalid arguments specified for command")
+	ErrNoKeyArguments      = errors.New("ERR The command has no key arguments")
+)
+
+// commandCommand implements COMMAND and its subcommands COUNT, INFO, DOCS,
+// LIST and GETKEYS, all of them answered from the command table.
+func (c *Connection) commandCommand(args []string) (any, error) {
+	if len(args) == 0 {
+		return commandInfos(commands.All()), nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	switch strings.ToUpper(subcommand) {
+	case "COUNT":
+		if len(args) != 0 {
+			return nil, errWrongArgs("command|count")
+		}
+		return len(commands.All()), nil
+	case "INFO":
+		if len(args) == 0 {
+			return commandInfos(commands.All()), nil
+		}
+
+		res := make([]any, 0, len(args))
+		for _, name := range args {
+			cmd, ok := lookupCommand(name)
+			if !ok {
+				res = append(res, nil)
+				continue
+			}
+			res = append(res, commandInfo(cmd))
+		}
+		return res, nil
+	case "DOCS":
+		cmds := commands.All()
+		if len(args) > 0 {
+			cmds = cmds[:0]
+			for _, name := range args {
+				if cmd, ok := lookupCommand(name); ok {
+					cmds = append(cmds, cmd)
+				}
+			}
+		}
+		return commandDocs(cmds), nil
+	case "LIST":
+		return commandList(args)
+	case "GETKEYS":
+		return commandGetKeys(args)
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try COMMAND HELP.", subcommand)
+	}
+}
+
+// lookupCommand finds a command or a subcommand given as container|subcommand.
+func lookupCommand(name string) (*commands.Command, bool) {
+	container, sub, ok := strings.Cut(name, "|")
+	if !ok {
+		return commands.Lookup(name)
+	}
+
+	cmd, ok := commands.Lookup(container)
+	if !ok {
+		return nil, false
+	}
+	return cmd.Subcommand(sub)
+}
+
+// commandList implements COMMAND LIST [FILTERBY MODULE name | ACLCAT category
+// | PATTERN pattern], subcommands are listed along with their containers.
+func commandList(args []string) (any, error) {
+	match := func(*commands.Command) bool { return true }
+
+	switch {
+	case len(args) == 0:
+	case len(args) == 3 && strings.EqualFold(args[0], "FILTERBY"):
+		filter, value := strings.ToUpper(args[1]), args[2]
+		switch filter {
+		case "MODULE":
+			// sider has no modules
+			match = func(*commands.Command) bool { return false }
+		case "ACLCAT":
+			match = func(cmd *commands.Command) bool { return cmd.InCategory(strings.ToLower(value)) }
+		case "PATTERN":
+			match = func(cmd *commands.Command) bool { return glob.MatchFold(value, cmd.Name) }
+		default:
+			return nil, ErrSyntax
+		}
+	default:
+		return nil, ErrSyntax
+	}
+
+	names := make([]any, 0)
+	for _, cmd := range commands.All() {
+		if match(cmd) {
+			names = append(names, cmd.Name)
+		}
+		for _, sub := range cmd.Subcommands {
+			if match(sub) {
+				names = append(names, sub.Name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// commandGetKeys implements COMMAND GETKEYS, the keys are found with the key
+// specs of the command, or of its subcommand for containers.
+func commandGetKeys(argv []string) (any, error) {
+	if len(argv) == 0 {
+		return nil, errWrongArgs("command|getkeys")
+	}
+
+	cmd, ok := commands.Resolve(argv[0], argv[1:])
+	if !ok {
+		return nil, ErrInvalidCommand
+	}
+	if !cmd.CheckArity(len(argv)) {
+		return nil, ErrInvalidCommandArity
+	}
+
+	keys, ok := cmd.Keys(argv)
+	if !ok {
+		return nil, ErrInvalidCommandArgs
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeyArguments
+	}
+	return bulkStrings(keys), nil
+}
+
+func commandInfos(cmds []*commands.Command) []any {
+	res := make([]any, 0, len(cmds))
+	for _, cmd := range cmds {
+		res = append(res, commandInfo(cmd))
+	}
+	return res
+}
+
+// commandInfo describes cmd the way COMMAND INFO does in redis: its name,
+// arity, flags, first key, last key and step, ACL categories, tips, key specs
+// and subcommands.
+func commandInfo(cmd *commands.Command) []any {
+	flags := make([]any, 0, len(cmd.Flags)+1)
+	for _, flag := range cmd.Flags {
+		flags = append(flags, flag)
+	}
+	if cmd.MovableKeys() {
+		flags = append(flags, commands.FlagMovableKeys)
+	}
+
+	categories := make([]any, 0, len(cmd.Categories))
+	for _, category := range cmd.Categories {
+		categories = append(categories, "@"+category)
+	}
+
+	specs := make([]any, 0, len(cmd.KeySpecs))
+	for _, spec := range cmd.KeySpecs {
+		specs = append(specs, keySpec(spec))
+	}
+
+	first, last, step := cmd.KeyRange()
+
+	return []any{
+		cmd.Name,
+		cmd.Arity,
+		flags,
+		first,
+		last,
+		step,
+		categories,
+		[]any{},
+		specs,
+		commandInfos(cmd.Subcommands),
+	}
+}
+
+func keySpec(spec commands.KeySpec) []any {
+	flags := make([]any, 0, len(spec.Flags))
+	for _, flag := range spec.Flags {
+		flags = append(flags, flag)
+	}
+
+	findKeys := []any{
+		"type", "range",
+		"spec", []any{
+			"lastkey", spec.LastKey,
+			"step", spec.Step,
+			"limit", 0,
+		},
+	}
+	if spec.KeyNum {
+		findKeys = []any{
+			"type", "keynum",
+			"spec", []any{
+				"keynumidx", spec.KeyNumIdx,
+				"firstkey", spec.FirstKey,
+				"step", spec.Step,
+			},
+		}
+	}
+
+	return []any{
+		"flags", flags,
+		"begin_search", []any{
+			"type", "index",
+			"spec", []any{"index", spec.Index},
+		},
+		"find_keys", findKeys,
+	}
+}
+
+// commandDocs describes cmds the way COMMAND DOCS does, as a map of the
+// command names to their summary, version, group, arguments and subcommands.
+func commandDocs(cmds []*commands.Command) []any {
+	res := make([]any, 0, 2*len(cmds))
+	for _, cmd := range cmds {
+		doc := []any{"summary", cmd.Summary}
+		if cmd.Since != "" {
+			doc = append(doc, "since", cmd.Since)
+		}
+		doc = append(doc, "group", cmd.Group)
+		if len(cmd.Arguments) > 0 {
+			doc = append(doc, "arguments", argumentDocs(cmd.Arguments))
+		}
+		if len(cmd.Subcommands) > 0 {
+			doc = append(doc, "subcommands", commandDocs(cmd.Subcommands))
+		}
+
+		res = append(res, cmd.Name, doc)
+	}
+	return res
+}
+
+// argumentDocs describes the grammar of a command the way COMMAND DOCS does,
+// every argument is a map of its name, type, token, flags and arguments.
+func argumentDocs(args []commands.Arg) []any {
+	res := make([]any, 0, len(args))
+	for _, arg := range args {
+		doc := []any{
+			"name", arg.Name,
+			"type", arg.Type,
+		}
+		if arg.Token != "" {
+			doc = append(doc, "token", arg.Token)
+		}
+
+		flags := make([]any, 0)
+		if arg.Optional {
+			flags = append(flags, "optional")
+		}
+		if arg.Multiple {
+			flags = append(flags, "multiple")
+		}
+		if arg.MultipleToken {
+			flags = append(flags, "multiple_token")
+		}
+		if len(flags) > 0 {
+			doc = append(doc, "flags", flags)
+		}
+
+		if len(arg.Args) > 0 {
+			doc = append(doc, "arguments", argumentDocs(arg.Args))
+		}
+		res = append(res, doc)
+	}
+	return res
+}
+
+func bulkStrings(values []string) []any {
+	res := make([]any, 0, len(values))
+	for _, v := range values {
+		res = append(res, v)
+	}
+	return res
+}
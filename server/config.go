@@ -0,0 +1,311 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aelnahas/sider/acl"
+	"github.com/aelnahas/sider/config"
+	"github.com/aelnahas/sider/db"
+	"github.com/aelnahas/sider/pubsub"
+	"github.com/aelnahas/sider/resp"
+)
+
+// DefaultConfig returns the configuration the server runs with when nothing
+// is configured.
+func DefaultConfig() Config {
+	return Config{
+		Port:     ConfigDefaultPort,
+		HostName: ConfigDefaultHostName,
+
+		PubSubOutputBufferLimit: pubsub.DefaultOutputBufferLimit,
+		ScriptTimeLimit:         db.DefaultScriptTimeLimit,
+
+		SlowlogLogSlowerThan: ConfigDefaultSlowlogLogSlowerThan,
+		SlowlogMaxLen:        ConfigDefaultSlowlogMaxLen,
+
+		ProtoMaxBulkLen:        ConfigDefaultProtoMaxBulkLen,
+		ProtoMaxMultiBulkLen:   ConfigDefaultProtoMaxMultiBulkLen,
+		ClientQueryBufferLimit: ConfigDefaultClientQueryBufferLimit,
+
+		TLS: TLSConfig{
+			AuthClients:     TLSAuthClientsYes,
+			AuthClientsUser: TLSAuthClientsUserOff,
+		},
+	}
+}
+
+// WithConfig replaces the whole configuration, options given after it can
+// still change it.
+func WithConfig(config Config) Option {
+	return func(c *Config) {
+		*c = config
+	}
+}
+
+// LoadConfig returns the default configuration updated with the redis.conf
+// style file at path, if any, and then with overrides given as name value
+// pairs. The file is the one CONFIG REWRITE writes to.
+func LoadConfig(path string, overrides ...string) (Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid config file path: %w", err)
+		}
+		cfg.ConfigFile = abs
+	}
+
+	settings := config.New(cfg.settings(), config.WithFile(cfg.ConfigFile))
+	if path != "" {
+		if err := settings.Load(); err != nil {
+			return cfg, err
+		}
+	}
+
+	for i := 0; i+1 < len(overrides); i += 2 {
+		if err := settings.Override(overrides[i], overrides[i+1]); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// settings describes every setting of cfg, the values read and write its
+// fields.
+func (cfg *Config) settings() []*config.Setting {
+	defaults := DefaultConfig()
+
+	settings := bindSettings(cfg)
+	for i, s := range bindSettings(&defaults) {
+		settings[i].Default = s.Value.String()
+	}
+	return settings
+}
+
+func bindSettings(cfg *Config) []*config.Setting {
+	return []*config.Setting{
+		{Name: "bind", Value: config.String(&cfg.HostName), Immutable: true},
+		{Name: "port", Value: config.Uint(&cfg.Port, 0, 65535), Immutable: true},
+		{Name: "unixsocket", Value: config.String(&cfg.UnixSocket), Immutable: true},
+		{Name: "unixsocketperm", Value: config.Func(cfg.unixSocketPerm, cfg.setUnixSocketPerm), Immutable: true},
+
+		{Name: "tls-port", Value: config.Uint(&cfg.TLS.Port, 0, 65535), Immutable: true},
+		{Name: "tls-cert-file", Value: config.String(&cfg.TLS.CertFile), Immutable: true},
+		{Name: "tls-key-file", Value: config.String(&cfg.TLS.KeyFile), Immutable: true},
+		{Name: "tls-ca-cert-file", Value: config.String(&cfg.TLS.CACertFile), Immutable: true},
+		{
+			Name:      "tls-auth-clients",
+			Value:     config.Enum(&cfg.TLS.AuthClients, TLSAuthClientsYes, TLSAuthClientsNo, TLSAuthClientsOptional),
+			Immutable: true,
+		},
+		{
+			Name:      "tls-auth-clients-user",
+			Value:     config.Enum(&cfg.TLS.AuthClientsUser, TLSAuthClientsUserOff, TLSAuthClientsUserCN),
+			Immutable: true,
+		},
+
+		{Name: "aclfile", Value: config.String(&cfg.ACLFile), Immutable: true},
+		{Name: "requirepass", Value: config.String(&cfg.RequirePass)},
+
+		{Name: "notify-keyspace-events", Value: config.Func(cfg.notifyKeyspaceEvents, cfg.setNotifyKeyspaceEvents)},
+		{Name: "client-output-buffer-limit", Value: config.Func(cfg.outputBufferLimit, cfg.setOutputBufferLimit)},
+		{Name: "busy-reply-threshold", Alias: "lua-time-limit", Value: config.Millis(&cfg.ScriptTimeLimit)},
+
+		{Name: "slowlog-log-slower-than", Value: config.Int(&cfg.SlowlogLogSlowerThan, -1, math.MaxInt)},
+		{Name: "slowlog-max-len", Value: config.Uint(&cfg.SlowlogMaxLen, 0, math.MaxInt32)},
+		{Name: "latency-monitor-threshold", Value: config.Millis(&cfg.LatencyMonitorThreshold)},
+
+		{Name: "proto-max-bulk-len", Value: config.Memory(&cfg.ProtoMaxBulkLen, 1024*1024, math.MaxInt64)},
+		{Name: "proto-max-multibulk-len", Value: config.Memory(&cfg.ProtoMaxMultiBulkLen, 1, math.MaxInt32)},
+		{Name: "client-query-buffer-limit", Value: config.Memory(&cfg.ClientQueryBufferLimit, 1024*1024, math.MaxInt64)},
+	}
+}
+
+// slowlogThreshold converts slowlog-log-slower-than, in microseconds, to the
+// threshold of the slowlog.
+func slowlogThreshold(micros int) time.Duration {
+	return time.Duration(micros) * time.Microsecond
+}
+
+func (cfg *Config) unixSocketPerm() string {
+	return strconv.FormatUint(uint64(cfg.UnixSocketPerm), 8)
+}
+
+func (cfg *Config) setUnixSocketPerm(s string) error {
+	perm, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || perm > 0o777 {
+		return errors.New("argument must be an octal file mode")
+	}
+
+	cfg.UnixSocketPerm = os.FileMode(perm)
+	return nil
+}
+
+func (cfg *Config) notifyKeyspaceEvents() string {
+	return cfg.NotifyKeyspaceEvents
+}
+
+func (cfg *Config) setNotifyKeyspaceEvents(s string) error {
+	flags, err := db.NormalizeNotifyKeyspaceEvents(s)
+	if err != nil {
+		return err
+	}
+
+	cfg.NotifyKeyspaceEvents = flags
+	return nil
+}
+
+// outputBufferLimit formats the limit like client-output-buffer-limit does in
+// redis, pubsub is the only class of clients with a limit.
+func (cfg *Config) outputBufferLimit() string {
+	limit := cfg.PubSubOutputBufferLimit
+	return fmt.Sprintf("pubsub %d %d %d", limit.Hard, limit.Soft, int(limit.SoftDuration.Seconds()))
+}
+
+func (cfg *Config) setOutputBufferLimit(s string) error {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields)%4 != 0 {
+		return errors.New("wrong number of arguments in buffer limit configuration")
+	}
+
+	limit := cfg.PubSubOutputBufferLimit
+	for i := 0; i < len(fields); i += 4 {
+		if !strings.EqualFold(fields[i], "pubsub") {
+			return errors.New("only the pubsub class supports buffer limits")
+		}
+
+		hard, err := config.ParseMemory(fields[i+1])
+		if err != nil {
+			return err
+		}
+		soft, err := config.ParseMemory(fields[i+2])
+		if err != nil {
+			return err
+		}
+		seconds, err := strconv.Atoi(fields[i+3])
+		if err != nil || seconds < 0 {
+			return errors.New("soft limit seconds must be a positive integer")
+		}
+
+		limit = pubsub.OutputBufferLimit{
+			Hard:         int(hard),
+			Soft:         int(soft),
+			SoftDuration: time.Duration(seconds) * time.Second,
+		}
+	}
+
+	cfg.PubSubOutputBufferLimit = limit
+	return nil
+}
+
+// newSettings returns the registry of the connection settings, the ones
+// that can change at runtime are applied to the running server.
+func (c *Connection) newSettings() *config.Config {
+	apply := map[string]func() error{
+		"requirepass": func() error {
+			c.applyRequirePass()
+			return nil
+		},
+		"notify-keyspace-events": func() error {
+			return c.store.SetNotifyKeyspaceEvents(c.config.NotifyKeyspaceEvents)
+		},
+		"client-output-buffer-limit": func() error {
+			c.broker.SetOutputBufferLimit(c.config.PubSubOutputBufferLimit)
+			return nil
+		},
+		"busy-reply-threshold": func() error {
+			c.store.SetScriptTimeLimit(c.config.ScriptTimeLimit)
+			return nil
+		},
+		"slowlog-log-slower-than": func() error {
+			c.slowlog.SetThreshold(slowlogThreshold(c.config.SlowlogLogSlowerThan))
+			return nil
+		},
+		"slowlog-max-len": func() error {
+			c.slowlog.SetMaxLen(int(c.config.SlowlogMaxLen))
+			return nil
+		},
+		"latency-monitor-threshold": func() error {
+			c.latency.SetThreshold(c.config.LatencyMonitorThreshold)
+			return nil
+		},
+		"proto-max-bulk-len":        c.applyLimits,
+		"proto-max-multibulk-len":   c.applyLimits,
+		"client-query-buffer-limit": c.applyLimits,
+	}
+
+	settings := c.config.settings()
+	for _, s := range settings {
+		s.Apply = apply[s.Name]
+	}
+
+	return config.New(settings, config.WithFile(c.config.ConfigFile))
+}
+
+// applyLimits makes new commands read with the configured protocol limits.
+func (c *Connection) applyLimits() error {
+	c.limits.Store(&resp.Limits{
+		MaxBulkLen:      c.config.ProtoMaxBulkLen,
+		MaxMultiBulkLen: c.config.ProtoMaxMultiBulkLen,
+		MaxQueryLen:     c.config.ClientQueryBufferLimit,
+	})
+	return nil
+}
+
+// applyRequirePass makes requirepass the only password of the default user,
+// an empty one lets the default user in without a password.
+func (c *Connection) applyRequirePass() {
+	rules := []string{"nopass"}
+	if c.config.RequirePass != "" {
+		rules = []string{"resetpass", ">" + c.config.RequirePass}
+	}
+
+	// the rules are known to be valid
+	_ = c.acl.SetUser(acl.DefaultUser, rules)
+}
+
+// configCommand implements the CONFIG subcommands.
+func (c *Connection) configCommand(args []string) (any, error) {
+	subcommand := args[0]
+	args = args[1:]
+
+	switch strings.ToUpper(subcommand) {
+	case "GET":
+		if len(args) == 0 {
+			return nil, errWrongArgs("config|get")
+		}
+		return stringList(c.settings.Get(args...)), nil
+	case "SET":
+		if err := c.settings.Set(args...); err != nil {
+			return nil, err
+		}
+		return resp.OK, nil
+	case "REWRITE":
+		if len(args) != 0 {
+			return nil, errWrongArgs("config|rewrite")
+		}
+		if err := c.settings.Rewrite(); err != nil {
+			return nil, err
+		}
+		return resp.OK, nil
+	case "RESETSTAT":
+		if len(args) != 0 {
+			return nil, errWrongArgs("config|resetstat")
+		}
+		c.stats.reset()
+		c.store.ResetStats()
+		c.latency.ResetHistograms()
+		return resp.OK, nil
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try CONFIG HELP.", subcommand)
+	}
+}
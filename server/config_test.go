@@ -0,0 +1,131 @@
+package server_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/pubsub"
+	"github.com/aelnahas/sider/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sider.conf")
+	require.NoError(t, os.WriteFile(file, []byte(
+		"port 7000\n"+
+			"unixsocket /tmp/sider.sock\n"+
+			"unixsocketperm 770\n"+
+			"client-output-buffer-limit pubsub 64mb 16mb 90\n"+
+			"slowlog-max-len 10\n"), 0o644))
+
+	cfg, err := server.LoadConfig(file, "port", "7001", "lua-time-limit", "100")
+	require.NoError(t, err)
+
+	// the overrides win over the file, which wins over the defaults
+	expected := server.DefaultConfig()
+	expected.ConfigFile = file
+	expected.Port = 7001
+	expected.UnixSocket = "/tmp/sider.sock"
+	expected.UnixSocketPerm = 0o770
+	expected.PubSubOutputBufferLimit = pubsub.OutputBufferLimit{Hard: 64 << 20, Soft: 16 << 20, SoftDuration: 90 * time.Second}
+	expected.SlowlogMaxLen = 10
+	expected.ScriptTimeLimit = 100 * time.Millisecond
+	assert.Equal(t, expected, cfg)
+
+	_, err = server.LoadConfig(filepath.Join(t.TempDir(), "missing.conf"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadConfigOverrides(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		name  string
+		value string
+
+		check       func(t *testing.T, cfg server.Config)
+		expectedErr string
+	}{
+		{
+			desc:  "output buffer limit",
+			name:  "client-output-buffer-limit",
+			value: "pubsub 1mb 1k 0",
+			check: func(t *testing.T, cfg server.Config) {
+				assert.Equal(t, pubsub.OutputBufferLimit{Hard: 1 << 20, Soft: 1000}, cfg.PubSubOutputBufferLimit)
+			},
+		},
+		{
+			desc:  "last output buffer limit wins",
+			name:  "client-output-buffer-limit",
+			value: "PUBSUB 1 2 3 pubsub 4 5 6",
+			check: func(t *testing.T, cfg server.Config) {
+				assert.Equal(t, pubsub.OutputBufferLimit{Hard: 4, Soft: 5, SoftDuration: 6 * time.Second}, cfg.PubSubOutputBufferLimit)
+			},
+		},
+		{
+			desc:        "output buffer limit of another class",
+			name:        "client-output-buffer-limit",
+			value:       "normal 0 0 0",
+			expectedErr: "invalid client-output-buffer-limit: only the pubsub class supports buffer limits",
+		},
+		{
+			desc:        "output buffer limit missing a field",
+			name:        "client-output-buffer-limit",
+			value:       "pubsub 1mb 1mb",
+			expectedErr: "invalid client-output-buffer-limit: wrong number of arguments in buffer limit configuration",
+		},
+		{
+			desc:        "output buffer limit with an invalid size",
+			name:        "client-output-buffer-limit",
+			value:       "pubsub lots 1mb 60",
+			expectedErr: "invalid client-output-buffer-limit: argument must be a memory value",
+		},
+		{
+			desc:        "output buffer limit with negative seconds",
+			name:        "client-output-buffer-limit",
+			value:       "pubsub 1mb 1mb -1",
+			expectedErr: "invalid client-output-buffer-limit: soft limit seconds must be a positive integer",
+		},
+		{
+			desc:  "unix socket permissions",
+			name:  "unixsocketperm",
+			value: "0755",
+			check: func(t *testing.T, cfg server.Config) {
+				assert.Equal(t, os.FileMode(0o755), cfg.UnixSocketPerm)
+			},
+		},
+		{
+			desc:        "unix socket permissions that are not octal",
+			name:        "unixsocketperm",
+			value:       "789",
+			expectedErr: "invalid unixsocketperm: argument must be an octal file mode",
+		},
+		{
+			desc:        "unix socket permissions with more than the permission bits",
+			name:        "unixsocketperm",
+			value:       "1777",
+			expectedErr: "invalid unixsocketperm: argument must be an octal file mode",
+		},
+		{
+			desc:        "unknown setting",
+			name:        "nope",
+			value:       "1",
+			expectedErr: "unknown setting 'nope'",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg, err := server.LoadConfig("", tc.name, tc.value)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			tc.check(t, cfg)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisVersion is the redis version sider reports, the one whose commands and
+// replies it follows.
+const redisVersion = "7.2.0"
+
+// infoSection writes the fields of an INFO section.
+type infoSection struct {
+	name   string
+	fields func(c *Connection, w *infoWriter)
+}
+
+// infoSections are in the order INFO lists them, they are all part of the
+// default set.
+var infoSections = []infoSection{
+	{"server", (*Connection).infoServer},
+	{"clients", (*Connection).infoClients},
+	{"memory", (*Connection).infoMemory},
+	{"persistence", (*Connection).infoPersistence},
+	{"stats", (*Connection).infoStats},
+	{"replication", (*Connection).infoReplication},
+	{"keyspace", (*Connection).infoKeyspace},
+}
+
+type infoWriter struct {
+	sb strings.Builder
+}
+
+func (w *infoWriter) field(name string, value any) {
+	fmt.Fprintf(&w.sb, "%s:%v\r\n", name, value)
+}
+
+// info implements INFO [section ...], without sections or with default, all
+// or everything every section is listed.
+func (c *Connection) info(args []string) (any, error) {
+	selected := make(map[string]bool)
+	all := len(args) == 0
+	for _, arg := range args {
+		switch section := strings.ToLower(arg); section {
+		case "default", "all", "everything":
+			all = true
+		default:
+			selected[section] = true
+		}
+	}
+
+	var w infoWriter
+	for _, section := range infoSections {
+		if !all && !selected[section.name] {
+			continue
+		}
+
+		if w.sb.Len() > 0 {
+			w.sb.WriteString("\r\n")
+		}
+		fmt.Fprintf(&w.sb, "# %s%s\r\n", strings.ToUpper(section.name[:1]), section.name[1:])
+		section.fields(c, &w)
+	}
+
+	return w.sb.String(), nil
+}
+
+func (c *Connection) infoServer(w *infoWriter) {
+	uptime := time.Since(c.started)
+
+	w.field("redis_version", redisVersion)
+	w.field("redis_mode", "standalone")
+	w.field("os", runtime.GOOS+" "+runtime.GOARCH)
+	w.field("arch_bits", strconv.IntSize)
+	w.field("go_version", runtime.Version())
+	w.field("process_id", os.Getpid())
+	w.field("run_id", c.runID)
+	w.field("tcp_port", c.config.Port)
+	w.field("server_time_usec", time.Now().UnixMicro())
+	w.field("uptime_in_seconds", int64(uptime.Seconds()))
+	w.field("uptime_in_days", int64(uptime.Hours()/24))
+	w.field("config_file", c.config.ConfigFile)
+}
+
+func (c *Connection) infoClients(w *infoWriter) {
+	w.field("connected_clients", c.clients.len())
+	w.field("blocked_clients", 0)
+	w.field("tracking_clients", c.tracker.len())
+}
+
+func (c *Connection) infoMemory(w *infoWriter) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	w.field("used_memory", mem.HeapAlloc)
+	w.field("used_memory_human", bytesHuman(mem.HeapAlloc))
+	w.field("used_memory_rss", mem.Sys)
+	w.field("used_memory_rss_human", bytesHuman(mem.Sys))
+	w.field("maxmemory", 0)
+	w.field("maxmemory_human", bytesHuman(0))
+	w.field("maxmemory_policy", "noeviction")
+	w.field("mem_allocator", "go")
+}
+
+// infoPersistence reports that nothing is ever saved, the data only lives in
+// memory.
+func (c *Connection) infoPersistence(w *infoWriter) {
+	w.field("loading", 0)
+	w.field("rdb_changes_since_last_save", 0)
+	w.field("rdb_bgsave_in_progress", 0)
+	w.field("rdb_last_save_time", c.started.Unix())
+	w.field("rdb_last_bgsave_status", "ok")
+	w.field("aof_enabled", 0)
+	w.field("aof_rewrite_in_progress", 0)
+}
+
+func (c *Connection) infoStats(w *infoWriter) {
+	keyspace := c.store.Stats()
+
+	w.field("total_connections_received", c.stats.connectionsReceived.Load())
+	w.field("total_commands_processed", c.stats.commandsProcessed.Load())
+	w.field("instantaneous_ops_per_sec", c.stats.opsPerSec())
+	w.field("rejected_connections", 0)
+	w.field("expired_keys", keyspace.ExpiredKeys)
+	w.field("evicted_keys", 0)
+	w.field("keyspace_hits", keyspace.KeyspaceHits)
+	w.field("keyspace_misses", keyspace.KeyspaceMisses)
+	w.field("pubsub_channels", len(c.broker.Channels("")))
+	w.field("pubsub_patterns", c.broker.NumPat())
+	w.field("pubsubshard_channels", len(c.broker.ShardChannels("")))
+	w.field("total_error_replies", c.stats.errorReplies.Load())
+}
+
+// infoReplication reports a master without replicas, there is no
+// replication.
+func (c *Connection) infoReplication(w *infoWriter) {
+	w.field("role", "master")
+	w.field("connected_slaves", 0)
+	w.field("master_replid", c.runID)
+	w.field("master_repl_offset", 0)
+}
+
+// infoKeyspace lists the only database, unless it is empty like redis leaves
+// out empty databases.
+func (c *Connection) infoKeyspace(w *infoWriter) {
+	keys := c.store.Stats().Keys
+	if keys == 0 {
+		return
+	}
+	w.field("db0", fmt.Sprintf("keys=%d,expires=0,avg_ttl=0", keys))
+}
+
+// bytesHuman formats a number of bytes the way the *_human INFO fields do.
+func bytesHuman(n uint64) string {
+	units := []string{"B", "K", "M", "G", "T"}
+
+	value := float64(n)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.2f%s", value, units[unit])
+}
+
+// newRunID returns a random identifier of the server process, like the run_id
+// of redis.
+func newRunID() string {
+	b := make([]byte, 20)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
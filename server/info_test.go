@@ -0,0 +1,113 @@
+package server_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// info runs INFO with args and returns the fields of every section it lists,
+// by section name.
+func info(t *testing.T, c *conn, args ...string) map[string]map[string]string {
+	t.Helper()
+
+	reply, ok := c.do(append([]string{"INFO"}, args...)...).(string)
+	require.True(t, ok)
+
+	sections := make(map[string]map[string]string)
+	var fields map[string]string
+	for _, line := range strings.Split(reply, "\r\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "# "):
+			fields = make(map[string]string)
+			sections[strings.ToLower(line[2:])] = fields
+		default:
+			name, value, found := strings.Cut(line, ":")
+			require.True(t, found, "line %q", line)
+			require.NotNil(t, fields, "line %q before any section", line)
+			fields[name] = value
+		}
+	}
+	return sections
+}
+
+func TestInfoSections(t *testing.T) {
+	all := []string{"server", "clients", "memory", "persistence", "stats", "replication", "keyspace"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		sections []string
+	}{
+		{name: "no section", sections: all},
+		{name: "default", args: []string{"default"}, sections: all},
+		{name: "everything", args: []string{"everything"}, sections: all},
+		{name: "one section", args: []string{"stats"}, sections: []string{"stats"}},
+		{name: "case insensitive", args: []string{"KEYSPACE", "Server"}, sections: []string{"server", "keyspace"}},
+		{name: "unknown section", args: []string{"nosuch"}},
+	}
+
+	c := dial(t, startServer(t))
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			sections := info(t, c, tc.args...)
+
+			names := make([]string, 0, len(sections))
+			for name := range sections {
+				names = append(names, name)
+			}
+			assert.ElementsMatch(t, tc.sections, names)
+		})
+	}
+
+	// the sections come in the order redis lists them
+	reply, ok := c.do("INFO").(string)
+	require.True(t, ok)
+	last := -1
+	for _, name := range all {
+		header := "# " + strings.ToUpper(name[:1]) + name[1:] + "\r\n"
+		at := strings.Index(reply, header)
+		require.Greater(t, at, last, "section %s", name)
+		last = at
+	}
+}
+
+func TestInfoCounters(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+	sub := dial(t, socket)
+
+	assert.Equal(t, []any{"subscribe", "news", int64(1)}, sub.do("SUBSCRIBE", "news"))
+	assert.Equal(t, []any{"psubscribe", "news.*", int64(2)}, sub.do("PSUBSCRIBE", "news.*"))
+
+	require.Equal(t, "OK", c.do("SET", "key", "1"))
+	assert.Equal(t, "1", c.do("GET", "key"))
+	assert.Nil(t, c.do("GET", "missing"))
+
+	stats := info(t, c, "stats")["stats"]
+	assert.Equal(t, "1", stats["keyspace_hits"])
+	assert.Equal(t, "1", stats["keyspace_misses"])
+	assert.Equal(t, "1", stats["pubsub_channels"])
+	assert.Equal(t, "1", stats["pubsub_patterns"])
+	// the subscriptions, the three commands and INFO itself
+	assert.Equal(t, "6", stats["total_commands_processed"])
+
+	assert.Equal(t, map[string]string{"db0": "keys=1,expires=0,avg_ttl=0"}, info(t, c, "keyspace")["keyspace"])
+	assert.Equal(t, "master", info(t, c, "replication")["replication"]["role"])
+
+	// the connection startServer waits with is gone eventually
+	require.Eventually(t, func() bool {
+		return info(t, c, "clients")["clients"]["connected_clients"] == "2"
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, "OK", c.do("CONFIG", "RESETSTAT"))
+	stats = info(t, c, "stats")["stats"]
+	assert.Equal(t, "0", stats["keyspace_hits"])
+	assert.Equal(t, "0", stats["keyspace_misses"])
+	assert.Equal(t, "1", stats["total_commands_processed"])
+}
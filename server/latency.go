@@ -0,0 +1,144 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aelnahas/sider/latency"
+	"github.com/aelnahas/sider/resp"
+)
+
+// defaultSlowlogCount is how many entries SLOWLOG GET returns without a
+// count.
+const defaultSlowlogCount = 10
+
+// observe records how long a command took to run in the slowlog, the latency
+// spikes and the histogram of the command.
+func (c *Connection) observe(sess *session, cmd *resp.RawCommand, took time.Duration) {
+	c.latency.Observe(commandName(cmd.Name, cmd.Args), took)
+	c.latency.Add(latency.EventCommand, took)
+	c.slowlog.Add(took, redactedArgs(cmd), sess.id, sess.getName())
+}
+
+// slowlogCommand implements SLOWLOG GET, LEN and RESET.
+func (c *Connection) slowlogCommand(args []string) (any, error) {
+	subcommand := args[0]
+	args = args[1:]
+
+	switch strings.ToUpper(subcommand) {
+	case "GET":
+		if len(args) > 1 {
+			return nil, errWrongArgs("slowlog|get")
+		}
+
+		count := defaultSlowlogCount
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < -1 {
+				return nil, fmt.Errorf("ERR count should be greater than or equal to -1")
+			}
+			count = n
+		}
+
+		entries := c.slowlog.Get(count)
+		res := make([]any, 0, len(entries))
+		for _, entry := range entries {
+			argv := make([]any, 0, len(entry.Args))
+			for _, arg := range entry.Args {
+				argv = append(argv, arg)
+			}
+
+			res = append(res, []any{
+				int(entry.ID),
+				int(entry.Time.Unix()),
+				int(entry.Duration.Microseconds()),
+				argv,
+				entry.ClientAddr,
+				entry.ClientName,
+			})
+		}
+		return res, nil
+	case "LEN":
+		if len(args) != 0 {
+			return nil, errWrongArgs("slowlog|len")
+		}
+		return c.slowlog.Len(), nil
+	case "RESET":
+		if len(args) != 0 {
+			return nil, errWrongArgs("slowlog|reset")
+		}
+		c.slowlog.Reset()
+		return resp.OK, nil
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try SLOWLOG HELP.", subcommand)
+	}
+}
+
+// latencyCommand implements LATENCY LATEST, HISTORY, RESET, HISTOGRAM and
+// DOCTOR. Latencies are in milliseconds except for the histograms, which are
+// in microseconds.
+func (c *Connection) latencyCommand(args []string) (any, error) {
+	subcommand := args[0]
+	args = args[1:]
+
+	switch strings.ToUpper(subcommand) {
+	case "LATEST":
+		if len(args) != 0 {
+			return nil, errWrongArgs("latency|latest")
+		}
+
+		latest := c.latency.Latest()
+		res := make([]any, 0, len(latest))
+		for _, l := range latest {
+			res = append(res, []any{
+				l.Event,
+				int(l.Time.Unix()),
+				int(l.Latest.Milliseconds()),
+				int(l.Max.Milliseconds()),
+			})
+		}
+		return res, nil
+	case "HISTORY":
+		if len(args) != 1 {
+			return nil, errWrongArgs("latency|history")
+		}
+
+		samples := c.latency.History(args[0])
+		res := make([]any, 0, len(samples))
+		for _, s := range samples {
+			res = append(res, []any{int(s.Time.Unix()), int(s.Latency.Milliseconds())})
+		}
+		return res, nil
+	case "RESET":
+		return c.latency.Reset(args...), nil
+	case "HISTOGRAM":
+		commands := make([]string, 0, len(args))
+		for _, arg := range args {
+			commands = append(commands, strings.ToLower(arg))
+		}
+
+		histograms := c.latency.Histograms(commands...)
+		res := make([]any, 0, 2*len(histograms))
+		for _, h := range histograms {
+			buckets := make([]any, 0)
+			for _, b := range h.Buckets() {
+				buckets = append(buckets, int(b.Micros), int(b.Count))
+			}
+
+			res = append(res, h.Command, []any{
+				"calls", int(h.Calls),
+				"histogram_usec", buckets,
+			})
+		}
+		return res, nil
+	case "DOCTOR":
+		if len(args) != 0 {
+			return nil, errWrongArgs("latency|doctor")
+		}
+		return c.latency.Doctor(), nil
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try LATENCY HELP.", subcommand)
+	}
+}
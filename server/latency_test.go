@@ -0,0 +1,102 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowlog(t *testing.T) {
+	c := dial(t, startServer(t))
+
+	// every command is logged from now on
+	require.Equal(t, "OK", c.do("CONFIG", "SET", "slowlog-log-slower-than", "0"))
+	require.Equal(t, "OK", c.do("SLOWLOG", "RESET"))
+	require.Equal(t, "OK", c.do("CLIENT", "SETNAME", "app"))
+	require.Equal(t, "OK", c.do("SET", "key", "value"))
+	require.Equal(t, "OK", c.do("CONFIG", "SET", "requirepass", "secret"))
+
+	// RESET is logged once it ran, like the commands after it
+	assert.Equal(t, int64(4), c.do("SLOWLOG", "LEN"))
+
+	entries, ok := c.do("SLOWLOG", "GET", "3").([]any)
+	require.True(t, ok)
+	require.Len(t, entries, 3)
+
+	argvs := make([]any, 0, len(entries))
+	for _, entry := range entries {
+		fields, ok := entry.([]any)
+		require.True(t, ok)
+		require.Len(t, fields, 6)
+		assert.Equal(t, "app", fields[5])
+		argvs = append(argvs, fields[3])
+	}
+
+	// the newest first, with the password left out
+	assert.Equal(t, []any{
+		[]any{"SLOWLOG", "LEN"},
+		[]any{"CONFIG", "SET", "requirepass", "(redacted)"},
+		[]any{"SET", "key", "value"},
+	}, argvs)
+
+	all, ok := c.do("SLOWLOG", "GET", "-1").([]any)
+	require.True(t, ok)
+	assert.Len(t, all, 6)
+
+	assert.Equal(t, resp.ReplyError("ERR count should be greater than or equal to -1"), c.do("SLOWLOG", "GET", "-2"))
+
+	// the ring keeps only the newest entries
+	require.Equal(t, "OK", c.do("CONFIG", "SET", "slowlog-max-len", "2"))
+	assert.Equal(t, int64(2), c.do("SLOWLOG", "LEN"))
+
+	require.Equal(t, "OK", c.do("CONFIG", "SET", "slowlog-log-slower-than", "-1"))
+	require.Equal(t, "OK", c.do("SLOWLOG", "RESET"))
+	assert.Equal(t, int64(0), c.do("SLOWLOG", "LEN"))
+}
+
+func TestLatency(t *testing.T) {
+	c := dial(t, startServer(t))
+
+	assert.Equal(t, "Latency monitoring is disabled. Use CONFIG SET latency-monitor-threshold <milliseconds> to enable it.\n",
+		c.do("LATENCY", "DOCTOR"))
+
+	require.Equal(t, "OK", c.do("CONFIG", "SET", "latency-monitor-threshold", "1"))
+	assert.Equal(t, "No latency spike was observed since latency monitoring was enabled or last reset.\n",
+		c.do("LATENCY", "DOCTOR"))
+
+	// a script that keeps the server busy for longer than the threshold
+	assert.Equal(t, int64(500000), c.do("EVAL", "local i = 0 while i < 500000 do i = i + 1 end return i", "0"))
+
+	latest, ok := c.do("LATENCY", "LATEST").([]any)
+	require.True(t, ok)
+	require.Len(t, latest, 1)
+	event, ok := latest[0].([]any)
+	require.True(t, ok)
+	require.Len(t, event, 4)
+	assert.Equal(t, "command", event[0])
+	assert.GreaterOrEqual(t, event[2], int64(1))
+	assert.Equal(t, event[2], event[3])
+
+	history, ok := c.do("LATENCY", "HISTORY", "command").([]any)
+	require.True(t, ok)
+	require.Len(t, history, 1)
+	assert.Equal(t, []any{event[1], event[2]}, history[0])
+
+	assert.Equal(t, []any{}, c.do("LATENCY", "HISTORY", "nosuch"))
+
+	// histograms count every call, spike or not
+	require.Equal(t, "OK", c.do("SET", "key", "value"))
+	histograms, ok := c.do("LATENCY", "HISTOGRAM", "SET", "nosuch").([]any)
+	require.True(t, ok)
+	require.Len(t, histograms, 2)
+	assert.Equal(t, "set", histograms[0])
+	histogram, ok := histograms[1].([]any)
+	require.True(t, ok)
+	require.Len(t, histogram, 4)
+	assert.Equal(t, []any{"calls", int64(1), "histogram_usec"}, histogram[:3])
+
+	assert.Equal(t, int64(1), c.do("LATENCY", "RESET"))
+	assert.Equal(t, []any{}, c.do("LATENCY", "LATEST"))
+}
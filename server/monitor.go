@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/resp"
+)
+
+// monitors are the sessions that ran MONITOR, they get a line for every
+// command any client runs. Like replies to subscribers the lines go through
+// the broker, which buffers them and disconnects monitors that fall behind.
+type monitors struct {
+	mu  sync.RWMutex
+	ids map[string]struct{}
+}
+
+func newMonitors() *monitors {
+	return &monitors{ids: make(map[string]struct{})}
+}
+
+func (m *monitors) add(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ids[id] = struct{}{}
+}
+
+func (m *monitors) remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.ids, id)
+}
+
+// list returns the ids of the monitors, nil when there are none.
+func (m *monitors) list() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.ids) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(m.ids))
+	for id := range m.ids {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// monitor implements MONITOR, the connection keeps running commands but its
+// replies are sent in between the monitor lines.
+func (c *Connection) monitor(sess *session) (any, error) {
+	if sess.monitor {
+		return resp.OK, nil
+	}
+
+	if !sess.subscriber {
+		c.broker.Connect(sess.id, sess.conn)
+	}
+	sess.monitor = true
+	c.monitors.add(sess.id)
+
+	return resp.OK, nil
+}
+
+// unmonitor leaves monitor mode, the broker is left to the subscriptions if
+// there are any.
+func (c *Connection) unmonitor(sess *session) {
+	if !sess.monitor {
+		return
+	}
+
+	c.monitors.remove(sess.id)
+	sess.monitor = false
+	if !sess.subscriber {
+		c.broker.Detach(sess.id)
+	}
+}
+
+// unmonitored are the commands whose arguments are all credentials, they
+// are never shown to monitors, not even redacted.
+var unmonitored = map[string]bool{
+	resp.CmdAuth:  true,
+	resp.CmdHello: true,
+}
+
+// feedMonitors sends the command sess ran to every monitor, in the format of
+// redis: the time, the database and address of the client, then the quoted
+// arguments with passwords redacted. Like in redis admin commands and
+// subcommands are left out, and so are AUTH and HELLO.
+func (c *Connection) feedMonitors(sess *session, cmd *resp.RawCommand) {
+	ids := c.monitors.list()
+	if ids == nil || unmonitored[cmd.Name] {
+		return
+	}
+
+	if command, ok := commands.Resolve(cmd.Name, cmd.Args); ok && command.HasFlag(commands.FlagAdmin) {
+		return
+	}
+
+	now := time.Now()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d.%06d [0 %s]", now.Unix(), now.Nanosecond()/1000, sess.id)
+	for _, arg := range redactedArgs(cmd) {
+		sb.WriteByte(' ')
+		sb.WriteString(resp.Quote(arg))
+	}
+
+	line := resp.SimpleString(sb.String())
+	for _, id := range ids {
+		c.broker.Reply(id, line)
+	}
+}
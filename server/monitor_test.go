@@ -0,0 +1,88 @@
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// monitored returns the arguments of the next line m is sent, after the time
+// and the client.
+func monitored(t *testing.T, m *conn) string {
+	t.Helper()
+
+	line := m.read()
+	require.IsType(t, "", line)
+	require.Regexp(t, `^\d+\.\d{6} \[0 [^\]]*\] `, line)
+	_, args, _ := strings.Cut(line.(string), "] ")
+	return args
+}
+
+func TestMonitor(t *testing.T) {
+	socket := startServer(t)
+	c, m := dial(t, socket), dial(t, socket)
+	require.Equal(t, "OK", m.do("MONITOR"))
+
+	assert.Equal(t, "watched", c.do("ECHO", "watched"))
+	assert.Equal(t, `"ECHO" "watched"`, monitored(t, m))
+
+	// arguments are quoted so a line stays a single line
+	assert.Equal(t, "OK", c.do("SET", "key", "a \"b\"\r\n\x00"))
+	assert.Equal(t, `"SET" "key" "a \"b\"\r\n\x00"`, monitored(t, m))
+
+	// admin commands are left out
+	assert.IsType(t, []any{}, c.do("CONFIG", "GET", "port"))
+	assert.Equal(t, "PONG", c.do("PING"))
+	assert.Equal(t, `"PING"`, monitored(t, m))
+}
+
+func TestMonitorHidesPasswords(t *testing.T) {
+	socket := startServer(t)
+	c, m := dial(t, socket), dial(t, socket)
+	require.Equal(t, "OK", m.do("MONITOR"))
+
+	// AUTH is left out whether it succeeds or not
+	require.Equal(t, "OK", c.do("CONFIG", "SET", "requirepass", "secret"))
+	assert.IsType(t, resp.ReplyError(""), c.do("AUTH", "wrong-secret"))
+	assert.Equal(t, "OK", c.do("AUTH", "secret"))
+	assert.Equal(t, "OK", c.do("AUTH", "default", "secret"))
+	assert.Equal(t, "after", c.do("ECHO", "after"))
+
+	line := monitored(t, m)
+	assert.Equal(t, `"ECHO" "after"`, line)
+	assert.NotContains(t, line, "secret")
+}
+
+func TestMonitorTransaction(t *testing.T) {
+	socket := startServer(t)
+	c, m := dial(t, socket), dial(t, socket)
+	require.Equal(t, "OK", m.do("MONITOR"))
+
+	assert.Equal(t, "OK", c.do("MULTI"))
+	assert.Equal(t, "QUEUED", c.do("SET", "key", "1"))
+	assert.Equal(t, "QUEUED", c.do("GET", "key"))
+	assert.Equal(t, []any{"OK", "1"}, c.do("EXEC"))
+
+	// the queued commands are seen once EXEC runs them
+	assert.Equal(t, `"MULTI"`, monitored(t, m))
+	assert.Equal(t, `"SET" "key" "1"`, monitored(t, m))
+	assert.Equal(t, `"GET" "key"`, monitored(t, m))
+	assert.Equal(t, `"EXEC"`, monitored(t, m))
+}
+
+func TestMonitorReset(t *testing.T) {
+	socket := startServer(t)
+	c, m := dial(t, socket), dial(t, socket)
+	assert.Equal(t, "OK", m.do("MONITOR"))
+
+	assert.Equal(t, "seen", c.do("ECHO", "seen"))
+	assert.Contains(t, m.read(), `"ECHO" "seen"`)
+
+	// once reset the connection gets its replies and nothing else
+	assert.Equal(t, "RESET", m.do("RESET"))
+	assert.Equal(t, "unseen", c.do("ECHO", "unseen"))
+	assert.Equal(t, "PONG", m.do("PING"))
+}
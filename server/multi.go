@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/db"
+	"github.com/aelnahas/sider/resp"
+)
+
+var (
+	ErrNestedMulti      = errors.New("ERR MULTI calls can not be nested")
+	ErrExecWithoutMulti = errors.New("ERR EXEC without MULTI")
+	ErrDiscardNoMulti   = errors.New("ERR DISCARD without MULTI")
+	ErrWatchInMulti     = errors.New("ERR WATCH inside MULTI is not allowed")
+	ErrExecAbort        = errors.New("EXECABORT Transaction discarded because of previous errors.")
+	ErrNotAllowedInTx   = errors.New("ERR Command not allowed inside a transaction")
+)
+
+const replyQueued resp.SimpleString = "QUEUED"
+
+func (c *Connection) multi(sess *session) (any, error) {
+	if sess.inMulti() {
+		return nil, ErrNestedMulti
+	}
+
+	sess.tx = &transaction{}
+	return resp.OK, nil
+}
+
+func (c *Connection) queue(sess *session, cmd *resp.RawCommand) (any, error) {
+	if command, _ := commands.Resolve(cmd.Name, cmd.Args); command.HasFlag(commands.FlagNoMulti) {
+		sess.abort()
+		return nil, ErrNotAllowedInTx
+	}
+
+	sess.tx.queued = append(sess.tx.queued, db.Invocation{
+		Name:   cmd.Name,
+		Args:   cmd.Args,
+		Parsed: cmd.Parsed,
+	})
+
+	return replyQueued, nil
+}
+
+func (c *Connection) exec(ctx context.Context, sess *session) (any, error) {
+	if !sess.inMulti() {
+		return nil, ErrExecWithoutMulti
+	}
+
+	tx := sess.tx
+	defer c.clearWatched(sess)
+
+	if tx.aborted {
+		sess.tx = nil
+		return nil, ErrExecAbort
+	}
+
+	// the ACL rules may have changed since the commands were queued, the
+	// denied ones are answered with the error while the others still run
+	denied := make(map[int]error)
+	allowed := make([]db.Invocation, 0, len(tx.queued))
+	for i, queued := range tx.queued {
+		if err := c.checkAccess(sess, &resp.RawCommand{Name: queued.Name, Args: queued.Args, Parsed: queued.Parsed}); err != nil {
+			denied[i] = err
+			continue
+		}
+		allowed = append(allowed, queued)
+	}
+	sess.tx = nil
+
+	results, err := c.store.ExecuteMulti(ctx, sess.watched, allowed)
+	if errors.Is(err, db.ErrWatchedKeyModified) {
+		return resp.NullArray{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the queued commands reach the monitors once they ran, ahead of EXEC
+	for _, queued := range allowed {
+		c.feedMonitors(sess, &resp.RawCommand{Name: queued.Name, Args: queued.Args})
+	}
+
+	if len(denied) == 0 {
+		return results, nil
+	}
+
+	replies := make([]any, 0, len(tx.queued))
+	for i := range tx.queued {
+		if err, ok := denied[i]; ok {
+			replies = append(replies, err)
+			continue
+		}
+		replies = append(replies, results[0])
+		results = results[1:]
+	}
+	return replies, nil
+}
+
+func (c *Connection) discard(sess *session) (any, error) {
+	if !sess.inMulti() {
+		return nil, ErrDiscardNoMulti
+	}
+
+	sess.tx = nil
+	c.clearWatched(sess)
+	return resp.OK, nil
+}
+
+func (c *Connection) watch(sess *session, keys []string) (any, error) {
+	if sess.inMulti() {
+		return nil, ErrWatchInMulti
+	}
+
+	sess.watched = c.store.Watch(keys, sess.watched)
+	return resp.OK, nil
+}
+
+func (c *Connection) unwatch(sess *session) (any, error) {
+	c.clearWatched(sess)
+	return resp.OK, nil
+}
+
+func (c *Connection) clearWatched(sess *session) {
+	c.store.Unwatch(sess.watched)
+	sess.watched = nil
+}
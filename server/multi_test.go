@@ -0,0 +1,87 @@
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionRunsServerCommands(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+	sub := dial(t, socket)
+
+	sub.send("SUBSCRIBE", "news")
+	assert.Equal(t, []any{"subscribe", "news", int64(1)}, sub.read())
+
+	assert.Equal(t, "OK", c.do("MULTI"))
+	for _, args := range [][]string{
+		{"SET", "a", "1"},
+		{"INFO", "server"},
+		{"CONFIG", "SET", "notify-keyspace-events", "KEA"},
+		{"CONFIG", "GET", "notify-keyspace-events"},
+		{"PUBLISH", "news", "hello"},
+		{"COMMAND", "COUNT"},
+	} {
+		assert.Equal(t, "QUEUED", c.do(args...))
+	}
+
+	reply := c.do("EXEC")
+	require.IsType(t, []any{}, reply)
+	results := reply.([]any)
+	require.Len(t, results, 6)
+
+	assert.Equal(t, "OK", results[0])
+	assert.True(t, strings.HasPrefix(results[1].(string), "# Server\r\n"))
+	assert.Equal(t, "OK", results[2])
+	assert.Equal(t, []any{"notify-keyspace-events", "AKE"}, results[3])
+	assert.Equal(t, int64(1), results[4])
+	assert.IsType(t, int64(0), results[5])
+
+	assert.Equal(t, []any{"message", "news", "hello"}, sub.read())
+}
+
+func TestTransactionRejectsNoMulti(t *testing.T) {
+	c := dial(t, startServer(t))
+
+	for _, args := range [][]string{{"SUBSCRIBE", "news"}, {"MONITOR"}} {
+		assert.Equal(t, "OK", c.do("MULTI"))
+		assert.Equal(t, resp.ReplyError("ERR Command not allowed inside a transaction"), c.do(args...))
+		assert.Equal(t, resp.ReplyError("EXECABORT Transaction discarded because of previous errors."), c.do("EXEC"))
+	}
+}
+
+func TestTransactionChecksACLOnExec(t *testing.T) {
+	socket := startServer(t)
+	admin := dial(t, socket)
+	c := dial(t, socket)
+
+	require.Equal(t, "OK", admin.do("ACL", "SETUSER", "alice", "on", "nopass", "+@all", "~*"))
+	require.Equal(t, "OK", c.do("AUTH", "alice", "any"))
+
+	assert.Equal(t, "OK", c.do("MULTI"))
+	assert.Equal(t, "QUEUED", c.do("SET", "key", "1"))
+	assert.Equal(t, "QUEUED", c.do("GET", "key"))
+
+	// the rules changed after GET was queued, it is denied when EXEC runs it
+	require.Equal(t, "OK", admin.do("ACL", "SETUSER", "alice", "-get"))
+	assert.Equal(t, []any{
+		"OK",
+		resp.ReplyError("NOPERM User alice has no permissions to run the 'get' command"),
+	}, c.do("EXEC"))
+
+	entries, ok := admin.do("ACL", "LOG").([]any)
+	require.True(t, ok)
+	require.Len(t, entries, 1)
+	fields, ok := entries[0].([]any)
+	require.True(t, ok)
+	entry := make(map[any]any)
+	for i := 0; i+1 < len(fields); i += 2 {
+		entry[fields[i]] = fields[i+1]
+	}
+	assert.Equal(t, "multi", entry["context"])
+	assert.Equal(t, "get", entry["object"])
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/db"
+	"github.com/aelnahas/sider/resp"
+)
+
+// pause holds back the commands of every client while CLIENT PAUSE is in
+// effect, either all of them or only the ones that write.
+type pause struct {
+	mu    sync.Mutex
+	until time.Time
+	all   bool
+
+	// lifted is closed when the pause is lifted or changed so waiting clients
+	// check it again
+	lifted chan struct{}
+}
+
+func newPause() *pause {
+	return &pause{lifted: make(chan struct{})}
+}
+
+// set pauses clients until the given time, writes only unless all is set.
+func (p *pause) set(until time.Time, all bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.until = until
+	p.all = all
+	close(p.lifted)
+	p.lifted = make(chan struct{})
+}
+
+func (p *pause) unpause() {
+	p.set(time.Time{}, false)
+}
+
+// wait blocks until a command can run, write tells whether it may write.
+func (p *pause) wait(write bool) {
+	for {
+		p.mu.Lock()
+		remaining := time.Until(p.until)
+		if remaining <= 0 || !p.all && !write {
+			p.mu.Unlock()
+			return
+		}
+		lifted := p.lifted
+		p.mu.Unlock()
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-timer.C:
+		case <-lifted:
+			timer.Stop()
+		}
+	}
+}
+
+// pausable reports whether cmd is held back by CLIENT PAUSE and whether it
+// may write. CLIENT UNPAUSE always goes through so a paused server can be
+// resumed. Queued commands are held back on EXEC rather than when queued.
+func pausable(sess *session, cmd *resp.RawCommand) (bool, bool) {
+	switch {
+	case cmd.Name == resp.CmdClient && len(cmd.Args) > 0 && strings.EqualFold(cmd.Args[0], "UNPAUSE"):
+		return false, false
+	case cmd.Name == resp.CmdExec && sess.tx != nil:
+		for _, queued := range sess.tx.queued {
+			if isWrite(queued.Name) {
+				return true, true
+			}
+		}
+		return true, false
+	case sess.inMulti() && cmd.Name != resp.CmdDiscard:
+		return false, false
+	default:
+		return true, isWrite(cmd.Name)
+	}
+}
+
+// isWrite reports whether name writes, either to the keyspace or through the
+// scripts it runs, or is propagated like a write as publishing is. CLIENT PAUSE
+// WRITE holds all of them back.
+func isWrite(name string) bool {
+	return db.IsWrite(name) || commands.HasFlag(name, commands.FlagMayReplicate)
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/pubsub"
+	"github.com/aelnahas/sider/resp"
+)
+
+var ErrNoRetentionBound = errors.New("ERR retention requires MAXLEN or MAXAGE")
+
+// subscriberCommands are the only commands a connection can run once it
+// subscribed to a topic or pattern.
+var subscriberCommands = map[string]bool{
+	resp.CmdSub:    true,
+	resp.CmdPSub:   true,
+	resp.CmdUnSub:  true,
+	resp.CmdPUnSub: true,
+	resp.CmdSSub:   true,
+	resp.CmdSUnSub: true,
+	resp.CmdRSub:   true,
+	resp.CmdPing:   true,
+	resp.CmdQuit:   true,
+	resp.CmdReset:  true,
+}
+
+func errNotInSubscriberMode(name string) error {
+	return fmt.Errorf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", strings.ToLower(name))
+}
+
+// pong is the reply to PING while in subscriber mode.
+func pong(args []string) []any {
+	msg := ""
+	if len(args) > 0 {
+		msg = args[0]
+	}
+
+	return []any{"pong", msg}
+}
+
+// executePubSubCmd runs a pub/sub command. Subscribing puts the connection in
+// subscriber mode, which it leaves once it is no longer subscribed to anything.
+func (c *Connection) executePubSubCmd(sess *session, cmd *resp.RawCommand) error {
+	if handler, ok := serverHandlers[cmd.Name]; ok {
+		res, err := handler(c, sess, cmd)
+		if err != nil {
+			return c.reply(sess, err)
+		}
+		return c.reply(sess, res)
+	}
+
+	var positions []pubsub.Position
+	if cmd.Name == resp.CmdRSub {
+		var err error
+		if positions, err = parsePositions(cmd.Args); err != nil {
+			return c.reply(sess, err)
+		}
+	}
+
+	if !sess.subscriber {
+		c.broker.Connect(sess.id, sess.conn)
+		sess.subscriber = true
+	}
+
+	var count int
+	switch cmd.Name {
+	case resp.CmdSub:
+		count = c.broker.Subscribe(sess.id, cmd.Args)
+	case resp.CmdUnSub:
+		count = c.broker.Unsubscribe(sess.id, cmd.Args)
+	case resp.CmdPSub:
+		count = c.broker.PSubscribe(sess.id, cmd.Args)
+	case resp.CmdPUnSub:
+		count = c.broker.PUnsubscribe(sess.id, cmd.Args)
+	case resp.CmdSSub:
+		count = c.broker.SSubscribe(sess.id, cmd.Args)
+	case resp.CmdSUnSub:
+		count = c.broker.SUnsubscribe(sess.id, cmd.Args)
+	case resp.CmdRSub:
+		count = c.broker.RSubscribe(sess.id, positions)
+	}
+
+	if count == 0 {
+		// a monitor keeps its output going through the broker
+		if !sess.monitor {
+			c.broker.Detach(sess.id)
+		}
+		sess.subscriber = false
+	}
+
+	return nil
+}
+
+// introspect implements the PUBSUB subcommands.
+func (c *Connection) introspect(cmd *resp.RawCommand) (any, error) {
+	subcommand := cmd.Args[0]
+	args := cmd.Args[1:]
+
+	switch strings.ToUpper(subcommand) {
+	case "CHANNELS":
+		if len(args) > 1 {
+			return nil, errWrongArgs("pubsub|channels")
+		}
+		return channelList(c.broker.Channels, args), nil
+	case "SHARDCHANNELS":
+		if len(args) > 1 {
+			return nil, errWrongArgs("pubsub|shardchannels")
+		}
+		return channelList(c.broker.ShardChannels, args), nil
+	case "NUMSUB":
+		return numSubList(c.broker.NumSub(args), args), nil
+	case "SHARDNUMSUB":
+		return numSubList(c.broker.ShardNumSub(args), args), nil
+	case "NUMPAT":
+		if len(args) != 0 {
+			return nil, errWrongArgs("pubsub|numpat")
+		}
+		return c.broker.NumPat(), nil
+	case "RETAIN":
+		retention, err := parseRetention(cmd.Parsed)
+		if err != nil {
+			return nil, err
+		}
+
+		c.broker.Retain(cmd.Parsed.String("channel"), retention)
+		return resp.OK, nil
+	case "UNRETAIN":
+		if len(args) != 1 {
+			return nil, errWrongArgs("pubsub|unretain")
+		}
+
+		if c.broker.Unretain(args[0]) {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try PUBSUB HELP.", subcommand)
+	}
+}
+
+func errWrongArgs(name string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", name)
+}
+
+func channelList(list func(pattern string) []string, args []string) []any {
+	pattern := ""
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+
+	channels := list(pattern)
+	res := make([]any, 0, len(channels))
+	for _, channel := range channels {
+		res = append(res, channel)
+	}
+	return res
+}
+
+func numSubList(counts []int, channels []string) []any {
+	res := make([]any, 0, 2*len(channels))
+	for i, channel := range channels {
+		res = append(res, channel, counts[i])
+	}
+	return res
+}
+
+// parseRetention reads the [MAXLEN count] [MAXAGE milliseconds] options of
+// PUBSUB RETAIN, at least one of them is required.
+func parseRetention(args *commands.Args) (pubsub.Retention, error) {
+	var retention pubsub.Retention
+
+	if args.Has("count") {
+		n := args.Int("count")
+		if n <= 0 {
+			return retention, ErrNotInteger
+		}
+		retention.MaxLen = int(n)
+	}
+
+	if args.Has("milliseconds") {
+		n := args.Int("milliseconds")
+		if n <= 0 {
+			return retention, ErrNotInteger
+		}
+		retention.MaxAge = time.Duration(n) * time.Millisecond
+	}
+
+	if retention.MaxLen == 0 && retention.MaxAge == 0 {
+		return retention, ErrNoRetentionBound
+	}
+
+	return retention, nil
+}
+
+// parsePositions reads the channel position pairs of RSUBSCRIBE. A position
+// is the offset of the last message the client received, or a unix time in
+// milliseconds prefixed with @ to replay the messages published since then.
+func parsePositions(args []string) ([]pubsub.Position, error) {
+	if len(args)%2 != 0 {
+		return nil, errWrongArgs("rsubscribe")
+	}
+
+	positions := make([]pubsub.Position, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		pos := pubsub.Position{Topic: args[i]}
+
+		if ms, ok := strings.CutPrefix(args[i+1], "@"); ok {
+			n, err := strconv.ParseInt(ms, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("ERR invalid position '%s'", args[i+1])
+			}
+			pos.Since = time.UnixMilli(n)
+		} else {
+			n, err := strconv.ParseUint(args[i+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("ERR invalid position '%s'", args[i+1])
+			}
+			pos.Offset = n
+		}
+
+		positions = append(positions, pos)
+	}
+
+	return positions, nil
+}
@@ -0,0 +1,37 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatternSubscriptions(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+
+	conn := dial(t, socket)
+	conn.send("PSUBSCRIBE", "news.*", "sport.*")
+	assert.Equal(t, []any{"psubscribe", "news.*", int64(1)}, conn.read())
+	assert.Equal(t, []any{"psubscribe", "sport.*", int64(2)}, conn.read())
+
+	other := dial(t, socket)
+	assert.Equal(t, []any{"subscribe", "news.tech", int64(1)}, other.do("SUBSCRIBE", "news.tech"))
+
+	// PUBLISH counts the pattern subscribers along with the channel ones
+	assert.Equal(t, int64(2), c.do("PUBLISH", "news.tech", "hi"))
+	assert.Equal(t, []any{"pmessage", "news.*", "news.tech", "hi"}, conn.read())
+	assert.Equal(t, []any{"message", "news.tech", "hi"}, other.read())
+
+	assert.Equal(t, int64(2), c.do("PUBSUB", "NUMPAT"))
+
+	assert.Equal(t, []any{"punsubscribe", "news.*", int64(1)}, conn.do("PUNSUBSCRIBE", "news.*"))
+	assert.Equal(t, int64(1), c.do("PUBLISH", "news.tech", "again"))
+	assert.Equal(t, []any{"message", "news.tech", "again"}, other.read())
+
+	assert.Equal(t, []any{"punsubscribe", "sport.*", int64(0)}, conn.do("PUNSUBSCRIBE"))
+	assert.Equal(t, []any{"punsubscribe", nil, int64(0)}, conn.do("PUNSUBSCRIBE"))
+
+	// back out of pubsub mode
+	assert.Equal(t, "PONG", conn.do("PING"))
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/aelnahas/sider/resp"
+)
+
+// redacted replaces the arguments that carry passwords wherever commands are
+// shown to clients.
+const redacted = "(redacted)"
+
+// redactedArgs returns the command name and arguments with the passwords
+// replaced, the ones given to AUTH, ACL SETUSER and CONFIG SET requirepass.
+func redactedArgs(cmd *resp.RawCommand) []string {
+	args := append([]string{cmd.Name}, cmd.Args...)
+
+	switch {
+	case cmd.Name == resp.CmdAuth:
+		for i := 1; i < len(args); i++ {
+			args[i] = redacted
+		}
+	case cmd.Name == resp.CmdACL && len(cmd.Args) > 0 && strings.EqualFold(cmd.Args[0], "SETUSER"):
+		for i := 3; i < len(args); i++ {
+			if args[i] != "" && strings.IndexByte("><#!", args[i][0]) >= 0 {
+				args[i] = redacted
+			}
+		}
+	case cmd.Name == resp.CmdConfig && len(cmd.Args) > 0 && strings.EqualFold(cmd.Args[0], "SET"):
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.EqualFold(args[i], "requirepass") {
+				args[i+1] = redacted
+			}
+		}
+	}
+
+	return args
+}
@@ -0,0 +1,135 @@
+package server_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSubscribe(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+
+	require.Equal(t, "OK", c.do("PUBSUB", "RETAIN", "orders", "MAXLEN", "3"))
+	for i := 1; i <= 4; i++ {
+		assert.Equal(t, int64(0), c.do("PUBLISH", "orders", "m"+strconv.Itoa(i)))
+	}
+
+	// the first message went over MAXLEN, the others come after offset 1
+	sub := dial(t, socket)
+	sub.send("RSUBSCRIBE", "orders", "1")
+	assert.Equal(t, []any{"rsubscribe", "orders", int64(1)}, sub.read())
+	assert.Equal(t, []any{"rmessage", "orders", int64(2), "m2"}, sub.read())
+	assert.Equal(t, []any{"rmessage", "orders", int64(3), "m3"}, sub.read())
+	assert.Equal(t, []any{"rmessage", "orders", int64(4), "m4"}, sub.read())
+
+	// live messages carry their offset as well
+	assert.Equal(t, int64(1), c.do("PUBLISH", "orders", "m5"))
+	assert.Equal(t, []any{"rmessage", "orders", int64(5), "m5"}, sub.read())
+
+	other := dial(t, socket)
+	other.send("RSUBSCRIBE", "orders", "0", "plain", "0")
+	assert.Equal(t, []any{"rsubscribe", "orders", int64(1)}, other.read())
+	assert.Equal(t, []any{"rmessage", "orders", int64(3), "m3"}, other.read())
+	assert.Equal(t, []any{"rmessage", "orders", int64(4), "m4"}, other.read())
+	assert.Equal(t, []any{"rmessage", "orders", int64(5), "m5"}, other.read())
+	assert.Equal(t, []any{"rsubscribe", "plain", int64(2)}, other.read())
+
+	// a channel that is not retained has nothing to replay nor offsets
+	assert.Equal(t, int64(1), c.do("PUBLISH", "plain", "hi"))
+	assert.Equal(t, []any{"message", "plain", "hi"}, other.read())
+
+	assert.Equal(t, []any{"unsubscribe", "orders", int64(0)}, sub.do("UNSUBSCRIBE", "orders"))
+	assert.Equal(t, int64(1), c.do("PUBLISH", "orders", "m6"))
+	assert.Equal(t, []any{"rmessage", "orders", int64(6), "m6"}, other.read())
+
+	assert.Equal(t, int64(1), c.do("PUBSUB", "UNRETAIN", "orders"))
+	assert.Equal(t, int64(0), c.do("PUBSUB", "UNRETAIN", "orders"))
+}
+
+func TestRSubscribeSince(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+
+	require.Equal(t, "OK", c.do("PUBSUB", "RETAIN", "orders", "MAXAGE", "60000"))
+	assert.Equal(t, int64(0), c.do("PUBLISH", "orders", "old"))
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now()
+	assert.Equal(t, int64(0), c.do("PUBLISH", "orders", "new"))
+
+	sub := dial(t, socket)
+	sub.send("RSUBSCRIBE", "orders", "@"+strconv.FormatInt(since.UnixMilli(), 10))
+	assert.Equal(t, []any{"rsubscribe", "orders", int64(1)}, sub.read())
+	assert.Equal(t, []any{"rmessage", "orders", int64(2), "new"}, sub.read())
+	sub.noReply(50 * time.Millisecond)
+}
+
+func TestRetentionMaxAge(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+
+	require.Equal(t, "OK", c.do("PUBSUB", "RETAIN", "orders", "MAXAGE", "20"))
+	assert.Equal(t, int64(0), c.do("PUBLISH", "orders", "expired"))
+	time.Sleep(50 * time.Millisecond)
+
+	// changing the bounds keeps the offsets going
+	require.Equal(t, "OK", c.do("PUBSUB", "RETAIN", "orders", "MAXLEN", "10", "MAXAGE", "20"))
+	assert.Equal(t, int64(0), c.do("PUBLISH", "orders", "kept"))
+
+	sub := dial(t, socket)
+	sub.send("RSUBSCRIBE", "orders", "0")
+	assert.Equal(t, []any{"rsubscribe", "orders", int64(1)}, sub.read())
+	assert.Equal(t, []any{"rmessage", "orders", int64(2), "kept"}, sub.read())
+	sub.noReply(50 * time.Millisecond)
+}
+
+func TestRetentionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  resp.ReplyError
+	}{
+		{
+			name: "no bound",
+			args: []string{"PUBSUB", "RETAIN", "orders"},
+			err:  "ERR retention requires MAXLEN or MAXAGE",
+		},
+		{
+			name: "negative bound",
+			args: []string{"PUBSUB", "RETAIN", "orders", "MAXLEN", "-1"},
+			err:  "ERR value is not an integer or out of range",
+		},
+		{
+			name: "missing position",
+			args: []string{"RSUBSCRIBE", "orders"},
+			err:  "ERR wrong number of arguments for 'rsubscribe' command",
+		},
+		{
+			name: "odd positions",
+			args: []string{"RSUBSCRIBE", "orders", "1", "news"},
+			err:  "ERR wrong number of arguments for 'rsubscribe' command",
+		},
+		{
+			name: "invalid offset",
+			args: []string{"RSUBSCRIBE", "orders", "last"},
+			err:  "ERR invalid position 'last'",
+		},
+		{
+			name: "invalid timestamp",
+			args: []string{"RSUBSCRIBE", "orders", "@yesterday"},
+			err:  "ERR invalid position '@yesterday'",
+		},
+	}
+
+	c := dial(t, startServer(t))
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.err, c.do(tc.args...))
+		})
+	}
+}
@@ -1,33 +1,100 @@
 package server
 
 import (
-	"bytes"
+	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"os"
+	"sync/atomic"
+	"time"
 
+	"github.com/aelnahas/sider/acl"
+	"github.com/aelnahas/sider/commands"
+	"github.com/aelnahas/sider/config"
 	"github.com/aelnahas/sider/db"
+	"github.com/aelnahas/sider/latency"
 	"github.com/aelnahas/sider/pubsub"
 	"github.com/aelnahas/sider/resp"
+	"github.com/aelnahas/sider/slowlog"
 )
 
 const (
 	ConfigDefaultPort     = 6379
 	ConfigDefaultHostName = "0.0.0.0"
+
+	ConfigDefaultSlowlogLogSlowerThan = 10000
+	ConfigDefaultSlowlogMaxLen        = 128
+
+	ConfigDefaultProtoMaxBulkLen        = 512 * 1024 * 1024
+	ConfigDefaultProtoMaxMultiBulkLen   = 1024 * 1024
+	ConfigDefaultClientQueryBufferLimit = 1024 * 1024 * 1024
 )
 
 type Config struct {
 	Port     uint
 	HostName string
+
+	PubSubOutputBufferLimit pubsub.OutputBufferLimit
+	NotifyKeyspaceEvents    string
+
+	RequirePass string
+	ACLFile     string
+
+	TLS TLSConfig
+
+	UnixSocket     string
+	UnixSocketPerm os.FileMode
+
+	ScriptTimeLimit time.Duration
+
+	// SlowlogLogSlowerThan is in microseconds, a negative value disables
+	// the slowlog.
+	SlowlogLogSlowerThan int
+	SlowlogMaxLen        uint
+
+	// LatencyMonitorThreshold is the latency from which events are
+	// recorded, zero disables recording them.
+	LatencyMonitorThreshold time.Duration
+
+	// ProtoMaxBulkLen, ProtoMaxMultiBulkLen and ClientQueryBufferLimit
+	// bound the commands clients send, a command over them is a protocol
+	// error that closes the connection.
+	ProtoMaxBulkLen        int64
+	ProtoMaxMultiBulkLen   int64
+	ClientQueryBufferLimit int64
+
+	// ConfigFile is the file the configuration was loaded from, CONFIG
+	// REWRITE writes to it.
+	ConfigFile string
 }
 
 type Connection struct {
-	config Config
-	store  *db.DB
-	broker pubsub.Broker
+	config  Config
+	store   *db.DB
+	broker  pubsub.Broker
+	clients *clients
+	tracker *tracker
+	acl     *acl.ACL
+
+	settings *config.Config
+	stats    stats
+	pause    *pause
+	slowlog  *slowlog.Log
+	latency  *latency.Monitor
+	monitors *monitors
+
+	// limits are the protocol limits commands are read with, they change
+	// with CONFIG SET while connections read commands.
+	limits atomic.Pointer[resp.Limits]
+
+	// started and runID identify the running server in INFO
+	started time.Time
+	runID   string
 }
 
 type Option = func(*Config)
@@ -44,34 +111,171 @@ func WithHostName(hostname string) Option {
 	}
 }
 
-func NewConnection(opts ...Option) *Connection {
+// WithPubSubOutputBufferLimit sets the output buffer limit of subscribers,
+// like client-output-buffer-limit pubsub in redis.
+func WithPubSubOutputBufferLimit(limit pubsub.OutputBufferLimit) Option {
+	return func(c *Config) {
+		c.PubSubOutputBufferLimit = limit
+	}
+}
 
-	config := &Config{
-		Port:     ConfigDefaultPort,
-		HostName: ConfigDefaultHostName,
+// WithNotifyKeyspaceEvents sets which keyspace notifications are published,
+// using the same flags as notify-keyspace-events in redis.
+func WithNotifyKeyspaceEvents(flags string) Option {
+	return func(c *Config) {
+		c.NotifyKeyspaceEvents = flags
 	}
+}
+
+// WithRequirePass sets the password of the default user, like requirepass in
+// redis.
+func WithRequirePass(password string) Option {
+	return func(c *Config) {
+		c.RequirePass = password
+	}
+}
+
+// WithScriptTimeLimit sets how long a script can run before other clients
+// are refused with a BUSY error, like busy-reply-threshold in redis.
+func WithScriptTimeLimit(limit time.Duration) Option {
+	return func(c *Config) {
+		c.ScriptTimeLimit = limit
+	}
+}
+
+// WithACLFile sets the file users are loaded from on start and by ACL LOAD,
+// and saved to by ACL SAVE.
+func WithACLFile(path string) Option {
+	return func(c *Config) {
+		c.ACLFile = path
+	}
+}
+
+func NewConnection(opts ...Option) *Connection {
+
+	cfg := DefaultConfig()
 
 	for _, opt := range opts {
-		opt(config)
+		opt(&cfg)
 	}
 
-	store := db.NewDB()
+	broker := pubsub.NewBroker(pubsub.WithOutputBufferLimit(cfg.PubSubOutputBufferLimit))
+
+	c := &Connection{
+		config:  cfg,
+		broker:  broker,
+		clients: newClients(),
+		acl:     acl.New(acl.WithFile(cfg.ACLFile)),
+		runID:   newRunID(),
+		pause:   newPause(),
+		slowlog: slowlog.New(slowlogThreshold(cfg.SlowlogLogSlowerThan), int(cfg.SlowlogMaxLen)),
+		latency: latency.NewMonitor(cfg.LatencyMonitorThreshold),
 
-	broker := pubsub.NewBroker()
+		monitors: newMonitors(),
+	}
 
-	return &Connection{
-		config: *config,
-		store:  store,
-		broker: broker,
+	if cfg.RequirePass != "" {
+		c.applyRequirePass()
 	}
+
+	c.tracker = newTracker(c.invalidate)
+	c.store = db.NewDB(
+		db.WithPublisher(broker),
+		db.WithKeyObserver(c.tracker),
+		db.WithAuthorizer(scriptAuthorizer{c}),
+		db.WithExecutor(serverExecutor{c}),
+		db.WithLatencyMonitor(c.latency),
+	)
+	c.store.SetScriptTimeLimit(cfg.ScriptTimeLimit)
+
+	c.settings = c.newSettings()
+	c.applyLimits()
+
+	return c
 }
 
 func (c *Connection) Start() error {
-	l, err := net.Listen("tcp", fmt.Sprintf("%s:%d", c.config.HostName, c.config.Port))
+	if err := c.store.SetNotifyKeyspaceEvents(c.config.NotifyKeyspaceEvents); err != nil {
+		return fmt.Errorf("invalid notify-keyspace-events: %w", err)
+	}
+	c.config.NotifyKeyspaceEvents = c.store.NotifyKeyspaceEvents()
+
+	if c.config.ACLFile != "" {
+		if err := c.acl.Load(); err != nil {
+			return fmt.Errorf("could not load acl file: %w", err)
+		}
+	}
+
+	listeners, err := c.listen()
 	if err != nil {
-		return fmt.Errorf("could not start redis server: %w", err)
+		for _, l := range listeners {
+			l.Close()
+		}
+		return err
+	}
+
+	c.started = time.Now()
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.stats.trackOps(stop)
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			errs <- c.serve(l)
+		}(l)
+	}
+
+	// the server stops as soon as one of the listeners fails
+	err = <-errs
+	for _, l := range listeners {
+		l.Close()
+	}
+	return err
+}
+
+// listen opens the plain TCP listener, unless its port is 0, the TLS
+// listener when a TLS port is set and the unix socket when a path is set.
+func (c *Connection) listen() ([]net.Listener, error) {
+	var listeners []net.Listener
+
+	if c.config.Port != 0 {
+		l, err := net.Listen("tcp", fmt.Sprintf("%s:%d", c.config.HostName, c.config.Port))
+		if err != nil {
+			return listeners, fmt.Errorf("could not start redis server: %w", err)
+		}
+		listeners = append(listeners, l)
+	}
+
+	if c.config.TLS.Port != 0 {
+		tlsConfig, err := c.config.TLS.tlsConfig()
+		if err != nil {
+			return listeners, fmt.Errorf("invalid tls configuration: %w", err)
+		}
+
+		l, err := tls.Listen("tcp", fmt.Sprintf("%s:%d", c.config.HostName, c.config.TLS.Port), tlsConfig)
+		if err != nil {
+			return listeners, fmt.Errorf("could not start tls listener: %w", err)
+		}
+		listeners = append(listeners, l)
+	}
+
+	if c.config.UnixSocket != "" {
+		l, err := listenUnix(c.config.UnixSocket, c.config.UnixSocketPerm)
+		if err != nil {
+			return listeners, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	if len(listeners) == 0 {
+		return nil, errors.New("no port or unix socket to listen on")
 	}
 
+	return listeners, nil
+}
+
+func (c *Connection) serve(l net.Listener) error {
 	for {
 		conn, err := l.Accept()
 		if err != nil {
@@ -84,84 +288,260 @@ func (c *Connection) Start() error {
 }
 
 func (c *Connection) handleConn(conn net.Conn) error {
-	var buf [512]byte
-	request := bytes.NewBuffer(nil)
-	var response []byte
+	reader := bufio.NewReader(conn)
+	sess := newSession(conn, c.clients.nextID(), c.config.UnixSocket)
+	c.clients.register(sess)
+	c.resetAuth(sess)
+	c.stats.connectionsReceived.Add(1)
+	ctx := withClientID(context.Background(), sess.clientID)
+
+	defer func() {
+		c.clients.unregister(sess)
+		c.monitors.remove(sess.id)
+		c.tracker.disable(sess.clientID)
+		c.broker.Disconnect(sess.id)
+		c.store.Unwatch(sess.watched)
+		if err := conn.Close(); err != nil {
+			slog.Error("could not close current connection", "error", err)
+		}
+	}()
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := c.tlsHandshake(sess, tlsConn); err != nil {
+			slog.Warn("tls handshake failed", "error", err)
+			return err
+		}
+	}
 
 	slog.Info("new incomming connection")
 
 	for {
-		n, err := conn.Read(buf[0:])
+		limits := *c.limits.Load()
+		limits.Unauthenticated = !sess.authenticated
+
+		cmd, err := resp.Parse(reader, resp.WithLimits(limits))
 		if err != nil {
-			if err != io.EOF {
+			if errors.Is(err, io.EOF) {
 				slog.Warn("connection closed")
 				return nil
 			}
+
+			slog.Error("error occured while parsing", "error", err)
+			if err := c.reply(sess, err); err != nil {
+				return err
+			}
+
+			if errors.As(err, &resp.ErrProtocol{}) {
+				return err
+			}
+
+			// a command that can not be queued makes the whole transaction fail
+			sess.abort()
 			continue
 		}
 
-		request.Write(buf[0:n])
-		slog.Info("received", "raw", request.String())
+		c.stats.commandsProcessed.Add(1)
 
-		cmd, err := resp.Parse(request)
-		if err != nil {
-			slog.Error("error occured while parsing", "error", err)
-			response = resp.Encode(err)
-			_, err = conn.Write(response)
-			if err != io.EOF {
-				return nil
+		if err := c.checkAccess(sess, cmd); err != nil {
+			if err := c.reply(sess, err); err != nil {
+				return err
 			}
+			sess.abort()
+			continue
+		}
 
-			return err
+		if sess.subscriber && !subscriberCommands[cmd.Name] {
+			if err := c.reply(sess, errNotInSubscriberMode(cmd.Name)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if held, write := pausable(sess, cmd); held {
+			c.pause.wait(write)
 		}
 
-		slog.Info("command parsed", "cmd", fmt.Sprintf("%+v", cmd))
+		// queued commands are observed when EXEC runs them
+		queued := sess.inMulti()
 
-		var result any
-		if cmd.IsPubSubCMD {
-			slog.Info("is pub sub command")
-			c.executePubSubCmd(cmd, conn)
-		} else {
-			result, err = c.store.Execute(context.Background(), cmd.Name, cmd.Args, cmd.Options)
-			var err error
-			defer func() {
-				err2 := conn.Close()
-				if err2 != nil {
-					slog.Error("could not close current connection", "error", err2)
-					err = errors.Join(err, err2)
-				}
-			}()
+		// took is how long the command ran, writing the reply is left out
+		var took time.Duration
+
+		switch {
+		case cmd.Name == resp.CmdQuit:
+			err := c.reply(sess, resp.OK)
+			c.broker.Detach(sess.id)
+			return err
+		case cmd.Name == resp.CmdReset:
+			start := time.Now()
+			c.reset(sess)
+			took = time.Since(start)
+			err = c.reply(sess, resp.SimpleString("RESET"))
+		case cmd.Name == resp.CmdAuth:
+			start := time.Now()
+			result, authErr := c.auth(sess, cmd.Args)
+			took = time.Since(start)
+			if authErr != nil {
+				err = c.reply(sess, authErr)
+			} else {
+				err = c.reply(sess, result)
+			}
+		case sess.subscriber && cmd.Name == resp.CmdPing:
+			err = c.reply(sess, pong(cmd.Args))
+		case cmd.IsPubSubCMD && !sess.inMulti():
+			start := time.Now()
+			err = c.executePubSubCmd(sess, cmd)
+			took = time.Since(start)
+		default:
+			c.track(sess, cmd.Name, cmd.Args)
+			start := time.Now()
+			result, cmdErr := c.execute(ctx, sess, cmd)
+			took = time.Since(start)
+			if cmdErr != nil {
+				err = c.reply(sess, cmdErr)
+			} else {
+				err = c.reply(sess, result)
+			}
 		}
-		if err != nil {
-			response = resp.Encode(err)
-		} else {
-			response = resp.Encode(result)
+
+		if !queued || !sess.inMulti() {
+			c.observe(sess, cmd, took)
+			c.feedMonitors(sess, cmd)
 		}
 
-		slog.Info("response", "raw", response)
-		_, err = conn.Write(response)
 		if err != nil {
 			if err != io.EOF {
 				return nil
 			}
 			return err
 		}
-		slog.Info("sent", "raw", string(response))
+
+		sess.record(commandName(cmd.Name, cmd.Args), reader.Buffered())
+
+		if sess.closeAfterReply {
+			return nil
+		}
 	}
 }
 
-func (c *Connection) executePubSubCmd(cmd *resp.RawCommand, conn net.Conn) {
-	id := conn.RemoteAddr().String()
+// reply sends data back to the client. Subscribed clients and monitors get
+// their replies through the broker so they stay in order with the published
+// messages and monitor lines.
+func (c *Connection) reply(sess *session, data any) error {
+	if sess.subscriber || sess.monitor {
+		c.broker.Reply(sess.id, data)
+		return nil
+	}
+
+	if _, ok := data.(error); ok {
+		c.stats.errorReplies.Add(1)
+	}
+
+	if sess.replyOff {
+		return nil
+	}
+	if sess.skipReplies > 0 {
+		sess.skipReplies--
+		return nil
+	}
+
+	if err := sess.out.WriteValue(data); err != nil {
+		return err
+	}
+	return sess.out.Flush()
+}
+
+// reset brings the connection back to its initial state, like a fresh
+// connection would be.
+func (c *Connection) reset(sess *session) {
+	if sess.subscriber && !sess.monitor {
+		c.broker.Detach(sess.id)
+	}
+	sess.subscriber = false
+	c.unmonitor(sess)
+
+	sess.tx = nil
+	c.clearWatched(sess)
 
+	c.tracker.disable(sess.clientID)
+	sess.caching = cachingUnset
+
+	c.resetAuth(sess)
+}
+
+func (c *Connection) execute(ctx context.Context, sess *session, cmd *resp.RawCommand) (any, error) {
 	switch cmd.Name {
-	case resp.CmdSub:
-		c.broker.Subscribe(id, cmd.Args, conn)
-	case resp.CmdUnSub:
-		c.broker.Unsubscribe(id, cmd.Args)
-	default:
-		defer conn.Close()
-		count := c.broker.Publish(cmd.Args[0], cmd.Args[1])
-		res := resp.Encode(count)
-		conn.Write(res)
+	case resp.CmdMulti:
+		return c.multi(sess)
+	case resp.CmdExec:
+		return c.exec(ctx, sess)
+	case resp.CmdDiscard:
+		return c.discard(sess)
+	case resp.CmdWatch:
+		return c.watch(sess, cmd.Args)
+	case resp.CmdUnwatch:
+		return c.unwatch(sess)
+	}
+
+	if sess.inMulti() {
+		return c.queue(sess, cmd)
+	}
+
+	if handler, ok := serverHandlers[cmd.Name]; ok {
+		return handler(c, sess, cmd)
+	}
+
+	return c.store.Execute(ctx, cmd.Name, cmd.Args, cmd.Parsed)
+}
+
+// serverHandlers run the commands the server implements rather than the
+// keyspace, by their name.
+var serverHandlers = map[string]func(c *Connection, sess *session, cmd *resp.RawCommand) (any, error){
+	resp.CmdClient: (*Connection).client,
+	resp.CmdACL: func(c *Connection, sess *session, cmd *resp.RawCommand) (any, error) {
+		return c.aclCommand(sess, cmd.Args)
+	},
+	resp.CmdConfig: func(c *Connection, _ *session, cmd *resp.RawCommand) (any, error) {
+		return c.configCommand(cmd.Args)
+	},
+	resp.CmdInfo: func(c *Connection, _ *session, cmd *resp.RawCommand) (any, error) {
+		return c.info(cmd.Args)
+	},
+	resp.CmdSlowlog: func(c *Connection, _ *session, cmd *resp.RawCommand) (any, error) {
+		return c.slowlogCommand(cmd.Args)
+	},
+	resp.CmdLatency: func(c *Connection, _ *session, cmd *resp.RawCommand) (any, error) {
+		return c.latencyCommand(cmd.Args)
+	},
+	resp.CmdMonitor: func(c *Connection, sess *session, _ *resp.RawCommand) (any, error) {
+		return c.monitor(sess)
+	},
+	resp.CmdCommand: func(c *Connection, _ *session, cmd *resp.RawCommand) (any, error) {
+		return c.commandCommand(cmd.Args)
+	},
+	resp.CmdPub: func(c *Connection, _ *session, cmd *resp.RawCommand) (any, error) {
+		return c.broker.Publish(cmd.Args[0], cmd.Args[1]), nil
+	},
+	resp.CmdSPub: func(c *Connection, _ *session, cmd *resp.RawCommand) (any, error) {
+		return c.broker.SPublish(cmd.Args[0], cmd.Args[1]), nil
+	},
+	resp.CmdPubSub: func(c *Connection, _ *session, cmd *resp.RawCommand) (any, error) {
+		return c.introspect(cmd)
+	},
+}
+
+// serverExecutor runs the server commands that transactions and scripts call,
+// for the client the context belongs to.
+type serverExecutor struct {
+	c *Connection
+}
+
+func (e serverExecutor) Execute(ctx context.Context, name string, args []string, parsed *commands.Args) (any, error) {
+	handler, ok := serverHandlers[name]
+	sess, found := e.c.clients.get(clientIDFrom(ctx))
+	if !ok || !found {
+		return nil, resp.ErrUnknownCommand{Name: name}
 	}
+
+	return handler(e.c, sess, &resp.RawCommand{Name: name, Args: args, Parsed: parsed})
 }
@@ -0,0 +1,129 @@
+package server_test
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/aelnahas/sider/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// startServer starts a server on a unix socket, unless opts pick another one,
+// and returns the path of the socket.
+func startServer(t *testing.T, opts ...server.Option) string {
+	t.Helper()
+
+	cfg := server.DefaultConfig()
+	cfg.Port = 0
+	cfg.UnixSocket = filepath.Join(t.TempDir(), "sider.sock")
+	cfg.UnixSocketPerm = 0o700
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	srv := server.NewConnection(server.WithConfig(cfg))
+	go srv.Start()
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("unix", cfg.UnixSocket)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	return cfg.UnixSocket
+}
+
+// conn is a connection to the server that reads what it sends as it comes.
+type conn struct {
+	t  *testing.T
+	nc net.Conn
+	w  *resp.Writer
+	r  *resp.ReplyReader
+}
+
+func dial(t *testing.T, socket string) *conn {
+	t.Helper()
+
+	nc, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { nc.Close() })
+
+	return &conn{t: t, nc: nc, w: resp.NewWriter(nc), r: resp.NewReplyReader(nc)}
+}
+
+// send writes a command without waiting for its reply.
+func (c *conn) send(args ...string) {
+	c.t.Helper()
+
+	require.NoError(c.t, c.w.WriteValue(args))
+	require.NoError(c.t, c.w.Flush())
+}
+
+// read returns the next reply.
+func (c *conn) read() any {
+	c.t.Helper()
+
+	require.NoError(c.t, c.nc.SetReadDeadline(time.Now().Add(time.Second)))
+	reply, err := c.r.ReadReply()
+	require.NoError(c.t, err)
+	return reply
+}
+
+func (c *conn) do(args ...string) any {
+	c.t.Helper()
+
+	c.send(args...)
+	return c.read()
+}
+
+// noReply checks that nothing is sent for d.
+func (c *conn) noReply(d time.Duration) {
+	c.t.Helper()
+
+	require.NoError(c.t, c.nc.SetReadDeadline(time.Now().Add(d)))
+	reply, err := c.r.ReadReply()
+	var netErr net.Error
+	require.ErrorAs(c.t, err, &netErr, "unexpected reply %v", reply)
+	require.True(c.t, netErr.Timeout())
+}
+
+// closed checks that the server closed the connection.
+func (c *conn) closed() {
+	c.t.Helper()
+
+	require.NoError(c.t, c.nc.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err := c.r.ReadReply()
+	require.ErrorIs(c.t, err, io.EOF)
+}
+
+func TestScriptsRunServerCommands(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+	sub := dial(t, socket)
+
+	sub.send("SUBSCRIBE", "news")
+	assert.Equal(t, []any{"subscribe", "news", int64(1)}, sub.read())
+
+	assert.Equal(t, int64(1), c.do("EVAL", "return redis.call('PUBLISH', 'news', ARGV[1])", "0", "hello"))
+	assert.Equal(t, []any{"message", "news", "hello"}, sub.read())
+
+	assert.Equal(t, int64(1), c.do("EVAL", "return redis.call('PUBSUB', 'NUMSUB', 'news')[2]", "0"))
+
+	// the flags of the subcommand decide, CONFIG GET is noscript
+	assert.Equal(t, resp.ReplyError("ERR This Redis command is not allowed from script"),
+		c.do("EVAL", "return redis.call('CONFIG', 'GET', 'timeout')", "0"))
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/sider/acl"
+	"github.com/aelnahas/sider/db"
+	"github.com/aelnahas/sider/resp"
+)
+
+// session holds the state that belongs to a single client connection.
+type session struct {
+	id       string
+	clientID int64
+	conn     net.Conn
+
+	// out buffers the replies written to conn, it is reused for every reply
+	// of the connection.
+	out *resp.Writer
+
+	// subscriber is set while the connection is subscribed to a topic or
+	// pattern, its output then goes through the broker.
+	subscriber bool
+
+	// monitor is set once the connection ran MONITOR, its output then goes
+	// through the broker as well.
+	monitor bool
+
+	tx      *transaction
+	watched map[string]uint64
+
+	// caching is set by CLIENT CACHING for the command that follows it.
+	caching caching
+
+	// user is the user the connection is authenticated as, other
+	// connections read it so it is guarded by mu
+	mu            sync.Mutex
+	user          string
+	authenticated bool
+
+	// closeAfterReply closes the connection once the reply to the current
+	// command has been sent.
+	closeAfterReply bool
+
+	// replyOff and skipReplies are set by CLIENT REPLY, skipReplies is the
+	// number of replies left to drop.
+	replyOff    bool
+	skipReplies int
+
+	created time.Time
+
+	// name, noEvict and activity describe the connection to CLIENT LIST,
+	// they are guarded by mu as well
+	name     string
+	noEvict  bool
+	activity activity
+}
+
+// activity is what a session last did, recorded after every command so other
+// connections can read it.
+type activity struct {
+	at         time.Time
+	cmd        string
+	multi      int
+	watch      int
+	qbuf       int
+	subscriber bool
+	monitor    bool
+}
+
+// transaction holds the commands queued between MULTI and EXEC.
+type transaction struct {
+	queued  []db.Invocation
+	aborted bool
+}
+
+// newSession builds the session of conn. It is complete before it is
+// registered, as other connections read it through CLIENT LIST and KILL.
+func newSession(conn net.Conn, clientID int64, unixSocket string) *session {
+	id := conn.RemoteAddr().String()
+	if conn.RemoteAddr().Network() == "unix" {
+		id = unixSessionID(unixSocket, clientID)
+	}
+
+	now := time.Now()
+	return &session{
+		id:       id,
+		clientID: clientID,
+		conn:     conn,
+		out:      resp.NewWriter(conn),
+		created:  now,
+		activity: activity{at: now, cmd: "NULL", multi: -1},
+	}
+}
+
+func (s *session) inMulti() bool {
+	return s.tx != nil
+}
+
+// abort flags the open transaction, if any, so that EXEC discards it.
+func (s *session) abort() {
+	if s.tx != nil {
+		s.tx.aborted = true
+	}
+}
+
+func (s *session) username() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.user
+}
+
+// login marks the session as authenticated as user.
+func (s *session) login(user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.user = user
+	s.authenticated = true
+}
+
+// logout leaves the session unauthenticated, as the default user.
+func (s *session) logout() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.user = acl.DefaultUser
+	s.authenticated = false
+}
+
+// record notes the command the session just ran, qbuf is how many bytes of
+// the following commands are already buffered.
+func (s *session) record(cmd string, qbuf int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.activity = activity{
+		at:         time.Now(),
+		cmd:        cmd,
+		multi:      -1,
+		watch:      len(s.watched),
+		qbuf:       qbuf,
+		subscriber: s.subscriber,
+		monitor:    s.monitor,
+	}
+	if s.tx != nil {
+		s.activity.multi = len(s.tx.queued)
+	}
+}
+
+func (s *session) setName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.name = name
+}
+
+func (s *session) getName() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.name
+}
+
+func (s *session) setNoEvict(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.noEvict = on
+}
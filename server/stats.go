@@ -0,0 +1,78 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// opsSamples is how many samples of the command rate are averaged, taken
+// every opsSampleInterval like redis does.
+const (
+	opsSamples        = 16
+	opsSampleInterval = 100 * time.Millisecond
+)
+
+// stats counts what happened since the server started or since the last
+// CONFIG RESETSTAT.
+type stats struct {
+	connectionsReceived atomic.Int64
+	commandsProcessed   atomic.Int64
+	errorReplies        atomic.Int64
+
+	mu           sync.Mutex
+	samples      [opsSamples]float64
+	sample       int
+	lastCommands int64
+	lastSample   time.Time
+}
+
+func (s *stats) reset() {
+	s.connectionsReceived.Store(0)
+	s.commandsProcessed.Store(0)
+	s.errorReplies.Store(0)
+}
+
+// sampleOps records the command rate since the previous sample.
+func (s *stats) sampleOps(now time.Time) {
+	commands := s.commandsProcessed.Load()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.lastSample.IsZero() && commands >= s.lastCommands {
+		elapsed := now.Sub(s.lastSample).Seconds()
+		s.samples[s.sample] = float64(commands-s.lastCommands) / elapsed
+		s.sample = (s.sample + 1) % opsSamples
+	}
+
+	s.lastCommands = commands
+	s.lastSample = now
+}
+
+// opsPerSec is the average command rate over the recent samples.
+func (s *stats) opsPerSec() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sum float64
+	for _, sample := range s.samples {
+		sum += sample
+	}
+	return int(sum / opsSamples)
+}
+
+// trackOps samples the command rate until stop is closed.
+func (s *stats) trackOps(stop <-chan struct{}) {
+	ticker := time.NewTicker(opsSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.sampleOps(now)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriberMode(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+	sub := dial(t, socket)
+
+	require.Equal(t, "OK", c.do("SET", "key", "value"))
+
+	sub.send("SUBSCRIBE", "news", "sport")
+	assert.Equal(t, []any{"subscribe", "news", int64(1)}, sub.read())
+	assert.Equal(t, []any{"subscribe", "sport", int64(2)}, sub.read())
+	assert.Equal(t, []any{"psubscribe", "news.*", int64(3)}, sub.do("PSUBSCRIBE", "news.*"))
+
+	tests := []struct {
+		name  string
+		args  []string
+		reply any
+	}{
+		{
+			name:  "get",
+			args:  []string{"GET", "key"},
+			reply: resp.ReplyError("ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context"),
+		},
+		{
+			name:  "publish",
+			args:  []string{"PUBLISH", "news", "hi"},
+			reply: resp.ReplyError("ERR Can't execute 'publish': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context"),
+		},
+		{
+			name:  "ping",
+			args:  []string{"PING"},
+			reply: []any{"pong", ""},
+		},
+		{
+			name:  "ping with a message",
+			args:  []string{"PING", "hello"},
+			reply: []any{"pong", "hello"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.reply, sub.do(tc.args...))
+		})
+	}
+
+	// a bare UNSUBSCRIBE confirms every channel in any order, the pattern is
+	// left
+	sub.send("UNSUBSCRIBE")
+	var channels []any
+	for _, count := range []int64{2, 1} {
+		reply, ok := sub.read().([]any)
+		require.True(t, ok)
+		require.Len(t, reply, 3)
+		assert.Equal(t, "unsubscribe", reply[0])
+		assert.Equal(t, count, reply[2])
+		channels = append(channels, reply[1])
+	}
+	assert.ElementsMatch(t, []any{"news", "sport"}, channels)
+	assert.IsType(t, resp.ReplyError(""), sub.do("GET", "key"))
+
+	// the connection leaves subscriber mode with its last subscription
+	assert.Equal(t, []any{"punsubscribe", "news.*", int64(0)}, sub.do("PUNSUBSCRIBE"))
+	assert.Equal(t, "PONG", sub.do("PING"))
+	assert.Equal(t, "value", sub.do("GET", "key"))
+}
+
+func TestSubscriberReset(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+	sub := dial(t, socket)
+
+	assert.Equal(t, []any{"subscribe", "news", int64(1)}, sub.do("SUBSCRIBE", "news"))
+	assert.Equal(t, "RESET", sub.do("RESET"))
+	assert.Equal(t, int64(0), c.do("PUBLISH", "news", "hi"))
+	assert.Nil(t, sub.do("GET", "key"))
+}
+
+func TestSubscriberQuit(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+	sub := dial(t, socket)
+
+	assert.Equal(t, []any{"subscribe", "news", int64(1)}, sub.do("SUBSCRIBE", "news"))
+	assert.Equal(t, "OK", sub.do("QUIT"))
+	sub.closed()
+
+	require.Eventually(t, func() bool {
+		return c.do("PUBLISH", "news", "hi") == int64(0)
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultTLSHandshakeTimeout is how long a client has to complete the TLS
+// handshake when TLSConfig.HandshakeTimeout is not set.
+const DefaultTLSHandshakeTimeout = 10 * time.Second
+
+// Values of TLSConfig.AuthClients, like tls-auth-clients in redis.
+const (
+	TLSAuthClientsYes      = "yes"
+	TLSAuthClientsNo       = "no"
+	TLSAuthClientsOptional = "optional"
+)
+
+// Values of TLSConfig.AuthClientsUser, like tls-auth-clients-user in redis.
+const (
+	TLSAuthClientsUserOff = "off"
+	TLSAuthClientsUserCN  = "CN"
+)
+
+// TLSConfig configures the TLS listener, which is only started when Port is
+// set.
+type TLSConfig struct {
+	Port       uint
+	CertFile   string
+	KeyFile    string
+	CACertFile string
+
+	// AuthClients is whether clients have to present a certificate signed by
+	// the CA: yes, no or optional.
+	AuthClients string
+
+	// AuthClientsUser, when set to CN, authenticates clients as the ACL user
+	// named after the common name of their certificate.
+	AuthClientsUser string
+
+	// HandshakeTimeout bounds the handshake so a client that never completes
+	// it does not hold its connection open, DefaultTLSHandshakeTimeout when
+	// zero.
+	HandshakeTimeout time.Duration
+}
+
+// WithTLS enables the TLS listener.
+func WithTLS(config TLSConfig) Option {
+	return func(c *Config) {
+		c.TLS = config
+	}
+}
+
+// tlsConfig builds the configuration of the TLS listener out of the
+// certificates on disk.
+func (t TLSConfig) tlsConfig() (*tls.Config, error) {
+	if t.CertFile == "" || t.KeyFile == "" {
+		return nil, errors.New("tls-cert-file and tls-key-file are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	switch strings.ToLower(t.AuthClients) {
+	case TLSAuthClientsYes, "":
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	case TLSAuthClientsOptional:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case TLSAuthClientsNo:
+		config.ClientAuth = tls.NoClientCert
+	default:
+		return nil, fmt.Errorf("invalid tls-auth-clients '%s'", t.AuthClients)
+	}
+
+	switch {
+	case t.AuthClientsUser == "", strings.EqualFold(t.AuthClientsUser, TLSAuthClientsUserOff):
+	case strings.EqualFold(t.AuthClientsUser, TLSAuthClientsUserCN):
+	default:
+		return nil, fmt.Errorf("invalid tls-auth-clients-user '%s'", t.AuthClientsUser)
+	}
+
+	if config.ClientAuth != tls.NoClientCert {
+		if t.CACertFile == "" {
+			return nil, errors.New("tls-ca-cert-file is required to authenticate clients")
+		}
+
+		pem, err := os.ReadFile(t.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read ca certificate: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", t.CACertFile)
+		}
+		config.ClientCAs = pool
+	}
+
+	return config, nil
+}
+
+// tlsHandshake completes the handshake of a TLS connection before anything is
+// read from it. When certificates map to users the client is authenticated as
+// the user named after the common name of its certificate, if there is one.
+func (c *Connection) tlsHandshake(sess *session, conn *tls.Conn) error {
+	timeout := c.config.TLS.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = DefaultTLSHandshakeTimeout
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(c.config.TLS.AuthClientsUser, TLSAuthClientsUserCN) {
+		return nil
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil
+	}
+
+	cn := certs[0].Subject.CommonName
+	if cn == "" || !c.acl.Enabled(cn) {
+		return nil
+	}
+
+	slog.Info("authenticated with client certificate", "user", cn)
+	sess.login(cn)
+	return nil
+}
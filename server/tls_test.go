@@ -0,0 +1,194 @@
+package server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/aelnahas/sider/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// certs are a CA and the certificates it signed for the server and for a
+// client named alice.
+type certs struct {
+	dir    string
+	pool   *x509.CertPool
+	client tls.Certificate
+}
+
+func newCerts(t *testing.T) *certs {
+	t.Helper()
+
+	c := &certs{dir: t.TempDir(), pool: x509.NewCertPool()}
+
+	caKey, ca, caDER := newCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "sider test ca"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	c.pool.AddCert(ca)
+	writePEM(t, filepath.Join(c.dir, "ca.crt"), "CERTIFICATE", caDER)
+
+	serverKey, _, serverDER := newCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "sider"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	writePEM(t, filepath.Join(c.dir, "server.crt"), "CERTIFICATE", serverDER)
+	keyDER, err := x509.MarshalECPrivateKey(serverKey)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(c.dir, "server.key"), "EC PRIVATE KEY", keyDER)
+
+	clientKey, _, clientDER := newCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "alice"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	c.client = tls.Certificate{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}
+
+	return c
+}
+
+// newCert creates a certificate out of template signed by parent, or self
+// signed without one.
+func newCert(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*ecdsa.PrivateKey, *x509.Certificate, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return key, cert, der
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+}
+
+// serverConfig returns the TLS configuration of a server listening on a free
+// local port.
+func (c *certs) serverConfig(t *testing.T, authClients string) server.TLSConfig {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	return server.TLSConfig{
+		Port:             uint(port),
+		CertFile:         filepath.Join(c.dir, "server.crt"),
+		KeyFile:          filepath.Join(c.dir, "server.key"),
+		CACertFile:       filepath.Join(c.dir, "ca.crt"),
+		AuthClients:      authClients,
+		AuthClientsUser:  server.TLSAuthClientsUserCN,
+		HandshakeTimeout: 100 * time.Millisecond,
+	}
+}
+
+// clientConfig trusts the CA and presents the certificate of alice when
+// withCert is set.
+func (c *certs) clientConfig(withCert bool) *tls.Config {
+	config := &tls.Config{RootCAs: c.pool, ServerName: "127.0.0.1"}
+	if withCert {
+		config.Certificates = []tls.Certificate{c.client}
+	}
+	return config
+}
+
+// startTLSServer starts a server with TLS configured by tlsConfig and returns
+// the address of its TLS port and the path of its unix socket.
+func startTLSServer(t *testing.T, tlsConfig server.TLSConfig) (string, string) {
+	t.Helper()
+
+	socket := startServer(t, server.WithTLS(tlsConfig), func(c *server.Config) {
+		c.HostName = "127.0.0.1"
+	})
+	return net.JoinHostPort("127.0.0.1", fmt.Sprint(tlsConfig.Port)), socket
+}
+
+// dialTLS connects to the TLS port at addr.
+func dialTLS(t *testing.T, addr string, config *tls.Config) *conn {
+	t.Helper()
+
+	nc, err := tls.Dial("tcp", addr, config)
+	require.NoError(t, err)
+	t.Cleanup(func() { nc.Close() })
+
+	return &conn{t: t, nc: nc, w: resp.NewWriter(nc), r: resp.NewReplyReader(nc)}
+}
+
+func TestTLS(t *testing.T) {
+	certs := newCerts(t)
+
+	t.Run("client certificate", func(t *testing.T) {
+		addr, socket := startTLSServer(t, certs.serverConfig(t, server.TLSAuthClientsYes))
+		require.Equal(t, "OK", dial(t, socket).do("ACL", "SETUSER", "alice", "on", "nopass", "+@all"))
+
+		// the common name of the certificate is the user
+		c := dialTLS(t, addr, certs.clientConfig(true))
+		assert.Equal(t, "alice", c.do("ACL", "WHOAMI"))
+	})
+
+	t.Run("missing client certificate", func(t *testing.T) {
+		addr, _ := startTLSServer(t, certs.serverConfig(t, server.TLSAuthClientsYes))
+
+		// with TLS 1.3 the server rejects the handshake once the client
+		// finished its side, so the error comes with the first reply
+		c := dialTLS(t, addr, certs.clientConfig(false))
+		c.send("PING")
+		require.NoError(t, c.nc.SetReadDeadline(time.Now().Add(time.Second)))
+		_, err := c.r.ReadReply()
+		assert.ErrorContains(t, err, "certificate required")
+	})
+
+	t.Run("optional client certificate", func(t *testing.T) {
+		addr, _ := startTLSServer(t, certs.serverConfig(t, server.TLSAuthClientsOptional))
+
+		c := dialTLS(t, addr, certs.clientConfig(false))
+		assert.Equal(t, "default", c.do("ACL", "WHOAMI"))
+	})
+
+	t.Run("handshake timeout", func(t *testing.T) {
+		addr, _ := startTLSServer(t, certs.serverConfig(t, server.TLSAuthClientsNo))
+
+		// a client that never starts the handshake is disconnected
+		nc, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer nc.Close()
+
+		require.NoError(t, nc.SetReadDeadline(time.Now().Add(time.Second)))
+		_, err = nc.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aelnahas/sider/db"
+	"github.com/aelnahas/sider/resp"
+)
+
+// invalidateChannel is the channel invalidation messages are sent on to
+// clients that are not using RESP3 push messages.
+const invalidateChannel = "__redis__:invalidate"
+
+var (
+	errSwitchBCast = errors.New("ERR You can't switch BCAST mode on/off before disabling tracking for this client, and then re-enabling it with a different mode.")
+	errSwitchOptIn = errors.New("ERR You can't switch OPTIN/OPTOUT mode before disabling tracking for this client, and then re-enabling it with a different mode.")
+)
+
+type clientIDKey struct{}
+
+// withClientID returns a context that carries the id of the client a command
+// is executed for, so key modifications can be traced back to it.
+func withClientID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, id)
+}
+
+func clientIDFrom(ctx context.Context) int64 {
+	id, _ := ctx.Value(clientIDKey{}).(int64)
+	return id
+}
+
+// caching is what CLIENT CACHING asked for the next command.
+type caching int
+
+const (
+	cachingUnset caching = iota
+	cachingYes
+	cachingNo
+)
+
+// trackingOptions are the options of CLIENT TRACKING.
+type trackingOptions struct {
+	redirect int64
+	bcast    bool
+	prefixes []string
+	optIn    bool
+	optOut   bool
+	noLoop   bool
+}
+
+// tracker remembers which keys clients may have cached, so they can be told
+// once those keys are modified. In the default mode the keys read by a client
+// are remembered, in broadcasting mode the client is told about every key
+// matching one of its prefixes.
+type tracker struct {
+	sync.Mutex
+	clients map[int64]*trackingOptions
+
+	// keys holds the clients that read each key and read the keys each
+	// client read, so they can be forgotten when it stops tracking
+	keys map[string]map[int64]struct{}
+	read map[int64]map[string]struct{}
+
+	// invalidate delivers an invalidation message about keys to a client.
+	invalidate func(target int64, keys []string)
+}
+
+func newTracker(invalidate func(target int64, keys []string)) *tracker {
+	return &tracker{
+		clients:    make(map[int64]*trackingOptions),
+		keys:       make(map[string]map[int64]struct{}),
+		read:       make(map[int64]map[string]struct{}),
+		invalidate: invalidate,
+	}
+}
+
+// len returns the number of clients with tracking enabled.
+func (t *tracker) len() int {
+	t.Lock()
+	defer t.Unlock()
+
+	return len(t.clients)
+}
+
+// enable turns tracking on for a client, or updates the options of a client
+// already tracking. Switching between modes requires turning tracking off
+// first.
+func (t *tracker) enable(id int64, opts trackingOptions) error {
+	t.Lock()
+	defer t.Unlock()
+
+	current, ok := t.clients[id]
+	if !ok {
+		t.clients[id] = &opts
+		return nil
+	}
+
+	if current.bcast != opts.bcast {
+		return errSwitchBCast
+	}
+
+	if current.optIn != opts.optIn || current.optOut != opts.optOut {
+		return errSwitchOptIn
+	}
+
+	if err := checkPrefixes(current.prefixes, opts.prefixes); err != nil {
+		return err
+	}
+
+	current.redirect = opts.redirect
+	current.noLoop = opts.noLoop
+	current.prefixes = append(current.prefixes, opts.prefixes...)
+	return nil
+}
+
+// disable turns tracking off and forgets the keys the client read.
+func (t *tracker) disable(id int64) {
+	t.Lock()
+	defer t.Unlock()
+
+	delete(t.clients, id)
+	for key := range t.read[id] {
+		t.forget(key, id)
+	}
+	delete(t.read, id)
+}
+
+// forget removes a client from the clients that read key.
+func (t *tracker) forget(key string, id int64) {
+	clients := t.keys[key]
+	delete(clients, id)
+	if len(clients) == 0 {
+		delete(t.keys, key)
+	}
+}
+
+// options returns a copy of the tracking options of a client.
+func (t *tracker) options(id int64) (trackingOptions, bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	opts, ok := t.clients[id]
+	if !ok {
+		return trackingOptions{}, false
+	}
+	return *opts, true
+}
+
+// track remembers that a client read the given keys. Only clients tracking
+// in the default mode remember keys, in OPTIN mode only when the command was
+// preceded by CLIENT CACHING yes and in OPTOUT mode unless it was preceded by
+// CLIENT CACHING no.
+func (t *tracker) track(id int64, cache caching, keys []string) {
+	t.Lock()
+	defer t.Unlock()
+
+	opts, ok := t.clients[id]
+	if !ok || opts.bcast {
+		return
+	}
+
+	if (opts.optIn && cache != cachingYes) || (opts.optOut && cache == cachingNo) {
+		return
+	}
+
+	read, ok := t.read[id]
+	if !ok {
+		read = make(map[string]struct{})
+		t.read[id] = read
+	}
+
+	for _, key := range keys {
+		clients, ok := t.keys[key]
+		if !ok {
+			clients = make(map[int64]struct{})
+			t.keys[key] = clients
+		}
+		clients[id] = struct{}{}
+		read[key] = struct{}{}
+	}
+}
+
+// KeyModified implements db.KeyObserver, every client that may have cached
+// key is sent an invalidation message.
+func (t *tracker) KeyModified(ctx context.Context, key string) {
+	modifier := clientIDFrom(ctx)
+	targets := make(map[int64]struct{})
+
+	t.Lock()
+
+	for id := range t.keys[key] {
+		delete(t.read[id], key)
+
+		opts, ok := t.clients[id]
+		if !ok || opts.bcast || (opts.noLoop && id == modifier) {
+			continue
+		}
+		targets[trackingTarget(id, opts)] = struct{}{}
+	}
+	delete(t.keys, key)
+
+	for id, opts := range t.clients {
+		if !opts.bcast || (opts.noLoop && id == modifier) || !matchesPrefix(opts.prefixes, key) {
+			continue
+		}
+		targets[trackingTarget(id, opts)] = struct{}{}
+	}
+
+	t.Unlock()
+
+	for target := range targets {
+		t.invalidate(target, []string{key})
+	}
+}
+
+// trackingTarget returns the client invalidation messages of id are sent to.
+func trackingTarget(id int64, opts *trackingOptions) int64 {
+	if opts.redirect != 0 {
+		return opts.redirect
+	}
+	return id
+}
+
+func matchesPrefix(prefixes []string, key string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPrefixes makes sure none of the added prefixes overlaps with another
+// prefix of the same client, a key would otherwise be reported twice.
+func checkPrefixes(existing, added []string) error {
+	for i, prefix := range added {
+		others := append(append([]string{}, existing...), added[:i]...)
+		for _, other := range others {
+			if strings.HasPrefix(prefix, other) || strings.HasPrefix(other, prefix) {
+				return fmt.Errorf("ERR Prefix '%s' overlaps with an existing prefix '%s'. Prefixes for a single client must not overlap.", prefix, other)
+			}
+		}
+	}
+	return nil
+}
+
+// track registers the keys read by cmd, or by the queued commands when cmd is
+// EXEC, before they are executed so no modification can slip in between. A
+// CLIENT CACHING given before MULTI applies to the whole transaction.
+func (c *Connection) track(sess *session, name string, args []string) {
+	if name == resp.CmdMulti || (sess.inMulti() && name != resp.CmdExec) {
+		return
+	}
+
+	cache := sess.caching
+	sess.caching = cachingUnset
+
+	invocations := []db.Invocation{{Name: name, Args: args}}
+	if name == resp.CmdExec && sess.tx != nil {
+		invocations = sess.tx.queued
+	}
+
+	for _, inv := range invocations {
+		if db.IsReadOnly(inv.Name) {
+			c.tracker.track(sess.clientID, cache, db.CommandKeys(inv.Name, inv.Args))
+		}
+	}
+}
+
+// invalidate sends an invalidation message to a client. Without RESP3 push
+// messages the client only receives it through the invalidation channel, so
+// only while it is subscribed to it.
+func (c *Connection) invalidate(target int64, keys []string) {
+	sess, ok := c.clients.get(target)
+	if !ok || !c.broker.Subscribed(sess.id, invalidateChannel) {
+		return
+	}
+
+	data := make([]any, 0, len(keys))
+	for _, key := range keys {
+		data = append(data, key)
+	}
+
+	c.broker.Reply(sess.id, []any{"message", invalidateChannel, data})
+}
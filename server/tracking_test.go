@@ -0,0 +1,169 @@
+package server_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/aelnahas/sider/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invalidations is a connection subscribed to the invalidation channel, and
+// to a channel whose messages mark that no invalidation came before them.
+type invalidations struct {
+	*conn
+	id int64
+}
+
+func newInvalidations(t *testing.T, socket string) *invalidations {
+	t.Helper()
+
+	c := dial(t, socket)
+	id, ok := c.do("CLIENT", "ID").(int64)
+	require.True(t, ok)
+
+	assert.Equal(t, []any{"subscribe", "__redis__:invalidate", int64(1)}, c.do("SUBSCRIBE", "__redis__:invalidate"))
+	assert.Equal(t, []any{"subscribe", "mark", int64(2)}, c.do("SUBSCRIBE", "mark"))
+	return &invalidations{conn: c, id: id}
+}
+
+// expect checks the next message is an invalidation of keys.
+func (inv *invalidations) expect(keys ...string) {
+	inv.t.Helper()
+
+	invalidated := make([]any, 0, len(keys))
+	for _, key := range keys {
+		invalidated = append(invalidated, key)
+	}
+	assert.Equal(inv.t, []any{"message", "__redis__:invalidate", invalidated}, inv.read())
+}
+
+// expectNone checks no invalidation came, by publishing a mark through c
+// that has to be the next message.
+func (inv *invalidations) expectNone(c *conn) {
+	inv.t.Helper()
+
+	assert.Equal(inv.t, int64(1), c.do("PUBLISH", "mark", "none"))
+	assert.Equal(inv.t, []any{"message", "mark", "none"}, inv.read())
+}
+
+// trackingConn returns a connection tracking the keys it reads with the
+// options in args, the invalidations are redirected to inv.
+func trackingConn(t *testing.T, socket string, inv *invalidations, args ...string) *conn {
+	t.Helper()
+
+	c := dial(t, socket)
+	tracking := append([]string{"CLIENT", "TRACKING", "ON", "REDIRECT", strconv.FormatInt(inv.id, 10)}, args...)
+	require.Equal(t, "OK", c.do(tracking...))
+	return c
+}
+
+func TestTracking(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+	inv := newInvalidations(t, socket)
+	tracking := trackingConn(t, socket, inv)
+
+	assert.Nil(t, tracking.do("GET", "read"))
+	require.Equal(t, "OK", c.do("SET", "read", "1"))
+	inv.expect("read")
+
+	// the key is forgotten until it is read again
+	require.Equal(t, "OK", c.do("SET", "read", "2"))
+	require.Equal(t, "OK", c.do("SET", "unread", "1"))
+	inv.expectNone(c)
+
+	assert.Equal(t, inv.id, tracking.do("CLIENT", "GETREDIR"))
+
+	// turning tracking off forgets the keys read
+	assert.Equal(t, "2", tracking.do("GET", "read"))
+	require.Equal(t, "OK", tracking.do("CLIENT", "TRACKING", "OFF"))
+	require.Equal(t, "OK", c.do("SET", "read", "3"))
+	inv.expectNone(c)
+}
+
+func TestTrackingOptInOptOut(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+	inv := newInvalidations(t, socket)
+
+	optIn := trackingConn(t, socket, inv, "OPTIN")
+	assert.Nil(t, optIn.do("GET", "optin:uncached"))
+	require.Equal(t, "OK", optIn.do("CLIENT", "CACHING", "YES"))
+	assert.Nil(t, optIn.do("GET", "optin:cached"))
+
+	require.Equal(t, "OK", c.do("SET", "optin:uncached", "1"))
+	inv.expectNone(c)
+	require.Equal(t, "OK", c.do("SET", "optin:cached", "1"))
+	inv.expect("optin:cached")
+
+	optOut := trackingConn(t, socket, inv, "OPTOUT")
+	require.Equal(t, "OK", optOut.do("CLIENT", "CACHING", "NO"))
+	assert.Nil(t, optOut.do("GET", "optout:uncached"))
+	assert.Nil(t, optOut.do("GET", "optout:cached"))
+
+	require.Equal(t, "OK", c.do("SET", "optout:uncached", "1"))
+	inv.expectNone(c)
+	require.Equal(t, "OK", c.do("SET", "optout:cached", "1"))
+	inv.expect("optout:cached")
+
+	assert.Equal(t, resp.ReplyError("ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode."),
+		optIn.do("CLIENT", "CACHING", "NO"))
+}
+
+func TestTrackingNoLoop(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+	inv := newInvalidations(t, socket)
+	tracking := trackingConn(t, socket, inv, "NOLOOP")
+
+	assert.Nil(t, tracking.do("GET", "key"))
+	require.Equal(t, "OK", tracking.do("SET", "key", "mine"))
+	inv.expectNone(c)
+
+	assert.Equal(t, "mine", tracking.do("GET", "key"))
+	require.Equal(t, "OK", c.do("SET", "key", "theirs"))
+	inv.expect("key")
+}
+
+func TestTrackingBroadcast(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+	inv := newInvalidations(t, socket)
+	trackingConn(t, socket, inv, "BCAST", "PREFIX", "user:", "PREFIX", "session:")
+
+	// keys are reported without being read
+	require.Equal(t, "OK", c.do("SET", "user:1", "a"))
+	inv.expect("user:1")
+	require.Equal(t, "OK", c.do("SET", "session:1", "a"))
+	inv.expect("session:1")
+	require.Equal(t, "OK", c.do("SET", "order:1", "a"))
+	inv.expectNone(c)
+
+	assert.Equal(t, resp.ReplyError("ERR Prefix 'user:admin:' overlaps with an existing prefix 'user:'. Prefixes for a single client must not overlap."),
+		dial(t, socket).do("CLIENT", "TRACKING", "ON", "BCAST", "PREFIX", "user:", "PREFIX", "user:admin:"))
+}
+
+func TestTrackingRedirectNotSubscribed(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+
+	// the target is in subscriber mode but not on the invalidation channel
+	target := dial(t, socket)
+	id, ok := target.do("CLIENT", "ID").(int64)
+	require.True(t, ok)
+	assert.Equal(t, []any{"subscribe", "mark", int64(1)}, target.do("SUBSCRIBE", "mark"))
+
+	tracking := dial(t, socket)
+	require.Equal(t, "OK", tracking.do("CLIENT", "TRACKING", "ON", "REDIRECT", strconv.FormatInt(id, 10)))
+
+	assert.Nil(t, tracking.do("GET", "key"))
+	require.Equal(t, "OK", c.do("SET", "key", "1"))
+
+	assert.Equal(t, int64(1), c.do("PUBLISH", "mark", "none"))
+	assert.Equal(t, []any{"message", "mark", "none"}, target.read())
+
+	assert.Equal(t, resp.ReplyError("ERR The client ID you want redirect to does not exist"),
+		tracking.do("CLIENT", "TRACKING", "ON", "REDIRECT", strconv.FormatInt(id+100, 10)))
+}
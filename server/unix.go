@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+)
+
+// WithUnixSocket makes the server listen on a unix socket at path as well,
+// like unixsocket and unixsocketperm in redis. A perm of 0 keeps the
+// permissions the socket is created with.
+func WithUnixSocket(path string, perm os.FileMode) Option {
+	return func(c *Config) {
+		c.UnixSocket = path
+		c.UnixSocketPerm = perm
+	}
+}
+
+// listenUnix listens on the unix socket at path, removing the socket a
+// previous run did not get to clean up.
+func listenUnix(path string, perm os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on unix socket: %w", err)
+	}
+
+	if perm != 0 {
+		if err := os.Chmod(path, perm); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("could not set unix socket permissions: %w", err)
+		}
+	}
+
+	return l, nil
+}
+
+// removeStaleSocket removes the socket at path unless a server is still
+// listening on it. Anything other than a socket is left alone.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not stat unix socket: %w", err)
+	}
+
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("unix socket %s is already in use", path)
+	}
+
+	return os.Remove(path)
+}
+
+// unixSessionID names sessions of unix socket clients, which all share the
+// same empty remote address, after the socket and their client id.
+func unixSessionID(path string, clientID int64) string {
+	return fmt.Sprintf("%s:%d", path, clientID)
+}
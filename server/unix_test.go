@@ -0,0 +1,100 @@
+package server_test
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aelnahas/sider/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startUnix runs a server listening only on the unix socket at path and
+// returns the error it stopped with.
+func startUnix(path string) error {
+	cfg := server.DefaultConfig()
+	cfg.Port = 0
+	cfg.UnixSocket = path
+	return server.NewConnection(server.WithConfig(cfg)).Start()
+}
+
+func TestUnixSocket(t *testing.T) {
+	t.Run("stale socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sider.sock")
+
+		// a server that did not get to remove its socket
+		l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+		require.NoError(t, err)
+		l.SetUnlinkOnClose(false)
+		require.NoError(t, l.Close())
+		_, err = os.Lstat(path)
+		require.NoError(t, err)
+
+		socket := startServer(t, server.WithUnixSocket(path, 0))
+		assert.Equal(t, "PONG", dial(t, socket).do("PING"))
+	})
+
+	t.Run("socket in use", func(t *testing.T) {
+		socket := startServer(t)
+
+		assert.ErrorContains(t, startUnix(socket), "already in use")
+
+		// the running server keeps its socket
+		assert.Equal(t, "PONG", dial(t, socket).do("PING"))
+	})
+
+	t.Run("not a socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sider.sock")
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+
+		assert.ErrorContains(t, startUnix(path), "is not a socket")
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(data))
+	})
+
+	t.Run("permissions", func(t *testing.T) {
+		for _, perm := range []os.FileMode{0o700, 0o755, 0o777} {
+			path := filepath.Join(t.TempDir(), "sider.sock")
+			startServer(t, server.WithUnixSocket(path, perm))
+
+			info, err := os.Lstat(path)
+			require.NoError(t, err)
+			assert.Equal(t, fs.ModeSocket|perm, info.Mode())
+		}
+	})
+}
+
+func TestUnixClientNames(t *testing.T) {
+	socket := startServer(t)
+	c := dial(t, socket)
+
+	// clients connect while CLIENT LIST reads their sessions, run with -race
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			other := dial(t, socket)
+			other.do("PING")
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		c.do("CLIENT", "LIST")
+	}
+	wg.Wait()
+
+	id := c.do("CLIENT", "ID")
+	require.IsType(t, int64(0), id)
+
+	info := c.do("CLIENT", "INFO")
+	require.IsType(t, "", info)
+	assert.True(t, strings.HasPrefix(info.(string), fmt.Sprintf("id=%d addr=%s:%d ", id, socket, id)), info)
+}
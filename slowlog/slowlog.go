@@ -0,0 +1,163 @@
+// Package slowlog keeps the most recent commands that took longer than a
+// threshold to run, like the SLOWLOG of redis.
+package slowlog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Like redis only the first arguments of a command and the first bytes of an
+// argument are kept, the rest is summarized.
+const (
+	MaxArgs      = 32
+	MaxArgLength = 128
+)
+
+// Entry is a logged command.
+type Entry struct {
+	ID       int64
+	Time     time.Time
+	Duration time.Duration
+
+	// Args holds the command name followed by its arguments.
+	Args []string
+
+	ClientAddr string
+	ClientName string
+}
+
+// Log is a ring buffer of the slowest recent commands.
+type Log struct {
+	mu sync.Mutex
+
+	threshold time.Duration
+	entries   []Entry
+	next      int
+	count     int
+	lastID    int64
+}
+
+// New returns a log keeping up to maxLen commands that ran for threshold or
+// longer. A negative threshold disables the log.
+func New(threshold time.Duration, maxLen int) *Log {
+	return &Log{threshold: threshold, entries: make([]Entry, maxLen)}
+}
+
+// SetThreshold changes which commands are logged from now on.
+func (l *Log) SetThreshold(threshold time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.threshold = threshold
+}
+
+// SetMaxLen changes how many commands are kept, dropping the oldest ones if
+// there are more.
+func (l *Log) SetMaxLen(maxLen int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := l.latest(maxLen)
+	l.entries = make([]Entry, maxLen)
+	l.count = len(entries)
+	l.next = 0
+	if maxLen > 0 {
+		l.next = l.count % maxLen
+	}
+	for i, entry := range entries {
+		l.entries[l.count-1-i] = entry
+	}
+}
+
+// Add logs the command if it ran for long enough and reports whether it did.
+func (l *Log) Add(duration time.Duration, args []string, addr, name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.threshold < 0 || duration < l.threshold {
+		return false
+	}
+
+	id := l.lastID
+	l.lastID++
+	if len(l.entries) == 0 {
+		return false
+	}
+
+	l.entries[l.next] = Entry{
+		ID:         id,
+		Time:       time.Now(),
+		Duration:   duration,
+		Args:       truncate(args),
+		ClientAddr: addr,
+		ClientName: name,
+	}
+	l.next = (l.next + 1) % len(l.entries)
+	if l.count < len(l.entries) {
+		l.count++
+	}
+	return true
+}
+
+// Get returns up to n commands, the most recent first. A negative n returns
+// all of them.
+func (l *Log) Get(n int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.latest(n)
+}
+
+func (l *Log) latest(n int) []Entry {
+	if n < 0 || n > l.count {
+		n = l.count
+	}
+
+	entries := make([]Entry, 0, n)
+	for i := 1; i <= n; i++ {
+		entries = append(entries, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return entries
+}
+
+// Len returns how many commands are logged.
+func (l *Log) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.count
+}
+
+// Reset drops every logged command, ids keep increasing.
+func (l *Log) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = make([]Entry, len(l.entries))
+	l.next = 0
+	l.count = 0
+}
+
+func truncate(args []string) []string {
+	n := len(args)
+	if n > MaxArgs {
+		n = MaxArgs
+	}
+
+	truncated := make([]string, n)
+	for i := 0; i < n; i++ {
+		if i == MaxArgs-1 && len(args) > MaxArgs {
+			truncated[i] = fmt.Sprintf("... (%d more arguments)", len(args)-MaxArgs+1)
+			break
+		}
+
+		arg := args[i]
+		if len(arg) > MaxArgLength {
+			arg = fmt.Sprintf("%s... (%d more bytes)", arg[:MaxArgLength], len(arg)-MaxArgLength)
+		}
+		truncated[i] = arg
+	}
+	return truncated
+}
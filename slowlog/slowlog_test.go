@@ -0,0 +1,64 @@
+package slowlog_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/sider/slowlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(entries []slowlog.Entry) []int64 {
+	var ids []int64
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestLog(t *testing.T) {
+	l := slowlog.New(10*time.Millisecond, 3)
+
+	assert.False(t, l.Add(time.Millisecond, []string{"GET", "fast"}, "127.0.0.1:1", ""))
+	for i := 0; i < 4; i++ {
+		assert.True(t, l.Add(10*time.Millisecond, []string{"GET", "slow"}, "127.0.0.1:1", "worker"))
+	}
+
+	assert.Equal(t, 3, l.Len())
+	assert.Equal(t, []int64{3, 2, 1}, ids(l.Get(-1)))
+	assert.Equal(t, []int64{3, 2}, ids(l.Get(2)))
+
+	entry := l.Get(1)[0]
+	assert.Equal(t, []string{"GET", "slow"}, entry.Args)
+	assert.Equal(t, "127.0.0.1:1", entry.ClientAddr)
+	assert.Equal(t, "worker", entry.ClientName)
+
+	l.SetMaxLen(2)
+	assert.Equal(t, []int64{3, 2}, ids(l.Get(-1)))
+	l.SetMaxLen(4)
+	l.Add(time.Second, []string{"GET", "slow"}, "127.0.0.1:1", "")
+	assert.Equal(t, []int64{4, 3, 2}, ids(l.Get(-1)))
+
+	l.SetThreshold(-1)
+	assert.False(t, l.Add(time.Hour, []string{"GET", "slow"}, "127.0.0.1:1", ""))
+
+	l.Reset()
+	assert.Equal(t, 0, l.Len())
+}
+
+func TestTruncate(t *testing.T) {
+	args := make([]string, 40)
+	for i := range args {
+		args[i] = "a"
+	}
+	args[1] = strings.Repeat("b", 130)
+
+	l := slowlog.New(0, 1)
+	l.Add(0, args, "", "")
+
+	logged := l.Get(1)[0].Args
+	assert.Len(t, logged, slowlog.MaxArgs)
+	assert.Equal(t, strings.Repeat("b", 128)+"... (2 more bytes)", logged[1])
+	assert.Equal(t, "... (9 more arguments)", logged[slowlog.MaxArgs-1])
+}